@@ -16,16 +16,20 @@ func _() {
 	_ = x[ArchPowerPC_32-6]
 	_ = x[ArchPowerPC_64BE-7]
 	_ = x[ArchPowerPC_64LE-8]
+	_ = x[ArchMIPS_32BE-9]
+	_ = x[ArchMIPS_64LE-10]
+	_ = x[ArchMIPS_64BE-11]
+	_ = x[ArchX86_16-12]
 }
 
-const _Arch_name = "x86_32x86_64MIPS_32ARM_32ARM_64PowerPC_32PowerPC_64 big endianPowerPC_64 little endian"
+const _Arch_name = "x86_32x86_64MIPS_32ARM_32ARM_64PowerPC_32PowerPC_64 big endianPowerPC_64 little endianMIPS_32 big endianMIPS_64 little endianMIPS_64 big endianx86_16"
 
-var _Arch_index = [...]uint8{0, 6, 12, 19, 25, 31, 41, 62, 86}
+var _Arch_index = [...]uint8{0, 6, 12, 19, 25, 31, 41, 62, 86, 104, 125, 143, 149}
 
 func (i Arch) String() string {
-	i -= 1
-	if i >= Arch(len(_Arch_index)-1) {
-		return "Arch(" + strconv.FormatInt(int64(i+1), 10) + ")"
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_Arch_index)-1 {
+		return "Arch(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _Arch_name[_Arch_index[i]:_Arch_index[i+1]]
+	return _Arch_name[_Arch_index[idx]:_Arch_index[idx+1]]
 }
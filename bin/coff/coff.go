@@ -0,0 +1,495 @@
+// Package coff provides access to COFF (Common Object File Format) object
+// files, as produced by MSVC.
+package coff
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger with the "coff:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("coff:")+" ", 0)
+	// warn is a logger with the "coff:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("coff:")+" ", 0)
+)
+
+// Register COFF format.
+func init() {
+	// COFF object files have no magic header, and are thus identified by the
+	// machine field of the COFF file header.
+	//
+	// Common Object File Format (COFF) format (x86).
+	//
+	//    4C 01  |L.|
+	bin.RegisterFormat("coff", "\x4C\x01", Parse)
+	bin.RegisterIdentify("coff", "\x4C\x01", Identify)
+	// Common Object File Format (COFF) format (x86-64).
+	//
+	//    64 86  |d.|
+	bin.RegisterFormat("coff", "\x64\x86", Parse)
+	bin.RegisterIdentify("coff", "\x64\x86", Identify)
+}
+
+// ParseFile parses the given COFF object file, reading from path.
+func ParseFile(path string) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Identify identifies the machine architecture of the given COFF object file,
+// reading only the machine field of the COFF file header from r.
+func Identify(r io.ReaderAt) (*bin.Info, error) {
+	var buf [2]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	machine := binary.LittleEndian.Uint16(buf[:])
+	arch, ok := parseArch(machine)
+	if !ok {
+		info := &bin.Info{Format: "coff", ByteOrder: binary.LittleEndian}
+		return info, errors.WithStack(&bin.UnsupportedMachineError{Format: "coff", Machine: uint32(machine)})
+	}
+	return bin.NewInfo("coff", arch), nil
+}
+
+// parseArch returns the machine architecture corresponding to the given machine
+// field of the COFF file header. The boolean return value indicates success.
+func parseArch(machine uint16) (bin.Arch, bool) {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return bin.ArchX86_32, true
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return bin.ArchX86_64, true
+	}
+	return 0, false
+}
+
+// Parse parses the given COFF object file, reading from r.
+//
+// Object files are not linked, and therefore sections are assigned synthetic
+// addresses, laid out consecutively starting at address 0. External symbols
+// referenced by relocations are assigned synthetic addresses following the
+// last section, and recorded as function imports.
+//
+// Users are responsible for closing r.
+func Parse(r io.ReaderAt) (*bin.File, error) {
+	// Open COFF file.
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse machine architecture.
+	file := &bin.File{
+		Imports:     make(map[bin.Address]string),
+		Exports:     make(map[bin.Address]string),
+		Relocs:      make(map[bin.Address]bin.RelocKind),
+		Relocatable: true,
+	}
+	arch, ok := parseArch(f.FileHeader.Machine)
+	if !ok {
+		return nil, errors.WithStack(&bin.UnsupportedMachineError{Format: "coff", Machine: uint32(f.FileHeader.Machine)})
+	}
+	file.Arch = arch
+
+	// Parse sections.
+	//
+	// sectAddrs maps from section number (1-based) to synthetic section
+	// address; or absent if the section is not loaded.
+	sectAddrs := make(map[int]bin.Address)
+	var addr bin.Address
+	for i, s := range f.Sections {
+		if s.Characteristics&(scnLnkInfo|scnLnkRemove|scnMemDiscardable) != 0 {
+			// skip linker directives (e.g. .drectve) and debug information (e.g.
+			// .debug$S).
+			continue
+		}
+		align := parseAlign(s.Characteristics)
+		if rem := addr % align; rem != 0 {
+			addr += align - rem
+		}
+		var data []byte
+		if s.Characteristics&scnCntUninitializedData == 0 {
+			data, err = s.Data()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		sect := &bin.Section{
+			Name:     s.Name,
+			Addr:     addr,
+			Offset:   uint64(s.Offset),
+			Data:     data,
+			FileSize: len(data),
+			MemSize:  int(s.Size),
+			Perm:     parsePerm(s.Characteristics),
+		}
+		file.Sections = append(file.Sections, sect)
+		sectAddrs[i+1] = addr
+		addr += bin.Address(s.Size)
+	}
+
+	// Parse symbols.
+	syms, err := parseSymbols(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, sym := range syms {
+		if sym.SectionNumber <= 0 {
+			continue
+		}
+		sectAddr, ok := sectAddrs[int(sym.SectionNumber)]
+		if !ok {
+			continue
+		}
+		if f.Sections[sym.SectionNumber-1].Characteristics&scnMemExecute == 0 {
+			// skip symbols of non-executable sections.
+			continue
+		}
+		// Function symbols, and external symbols of executable sections (as
+		// some assemblers do not specify the symbol type of functions).
+		if sym.Type != symTypeFunc && sym.StorageClass != symClassExternal {
+			continue
+		}
+		symAddr := sectAddr + bin.Address(sym.Value)
+		if _, ok := file.Exports[symAddr]; ok {
+			continue
+		}
+		dbg.Printf("export %q at %v", sym.Name, symAddr)
+		file.Exports[symAddr] = sym.Name
+	}
+
+	// Assign synthetic addresses to external symbols.
+	ptrSize := bin.Address(file.Arch.BitSize() / 8)
+	if rem := addr % ptrSize; rem != 0 {
+		addr += ptrSize - rem
+	}
+	impStart := addr
+	// symAddrs maps from symbol table index to symbol address.
+	symAddrs := make(map[uint32]bin.Address)
+	for _, sym := range syms {
+		if sym.SectionNumber != 0 || sym.StorageClass != symClassExternal {
+			continue
+		}
+		if sym.Value != 0 {
+			// skip common symbols (uninitialized data of the given size).
+			warn.Printf("support for common symbol %q not yet implemented", sym.Name)
+			continue
+		}
+		name := strings.TrimPrefix(sym.Name, "__imp_")
+		dbg.Printf("import %q at %v", name, addr)
+		file.Imports[addr] = name
+		symAddrs[sym.Index] = addr
+		addr += ptrSize
+	}
+	if addr > impStart {
+		// Zero-initialized memory of external symbols.
+		size := int(addr - impStart)
+		sect := &bin.Section{
+			Name:    ".idata",
+			Addr:    impStart,
+			Data:    make([]byte, size),
+			MemSize: size,
+			Perm:    bin.PermR,
+		}
+		file.Sections = append(file.Sections, sect)
+	}
+	for _, sym := range syms {
+		if sym.SectionNumber <= 0 {
+			continue
+		}
+		if sectAddr, ok := sectAddrs[int(sym.SectionNumber)]; ok {
+			symAddrs[sym.Index] = sectAddr + bin.Address(sym.Value)
+		}
+	}
+
+	// Apply relocations.
+	for i, s := range f.Sections {
+		sectAddr, ok := sectAddrs[i+1]
+		if !ok {
+			continue
+		}
+		sect, err := findSection(file.Sections, sectAddr, s.Name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, reloc := range s.Relocs {
+			if err := applyReloc(file, sect, reloc, symAddrs); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	// Sort sections in ascending order.
+	less := func(i, j int) bool {
+		if file.Sections[i].Addr == file.Sections[j].Addr {
+			if len(file.Sections[i].Data) > len(file.Sections[j].Data) {
+				// prioritize longer sections with identical addresses.
+				return true
+			}
+			return file.Sections[i].Name < file.Sections[j].Name
+		}
+		return file.Sections[i].Addr < file.Sections[j].Addr
+	}
+	sort.Slice(file.Sections, less)
+
+	return file, nil
+}
+
+// Section characteristics.
+const (
+	// Section contains uninitialized data.
+	scnCntUninitializedData = 0x00000080
+	// Section contains comments or other information (e.g. .drectve).
+	scnLnkInfo = 0x00000200
+	// Section will not become part of the image.
+	scnLnkRemove = 0x00000800
+	// Section can be discarded as needed (e.g. debug information).
+	scnMemDiscardable = 0x02000000
+	// Section can be executed as code.
+	scnMemExecute = 0x20000000
+	// Section can be read.
+	scnMemRead = 0x40000000
+	// Section can be written to.
+	scnMemWrite = 0x80000000
+)
+
+// parseAlign returns the section alignment represented by the given section
+// characteristics.
+func parseAlign(char uint32) bin.Address {
+	// IMAGE_SCN_ALIGN_1BYTES (1) through IMAGE_SCN_ALIGN_8192BYTES (14).
+	n := (char >> 20) & 0xF
+	if n == 0 {
+		// Default alignment of 16 bytes.
+		return 16
+	}
+	return 1 << (n - 1)
+}
+
+// parsePerm returns the memory access permissions represented by the given
+// section characteristics.
+func parsePerm(char uint32) bin.Perm {
+	var perm bin.Perm
+	if char&scnMemRead != 0 {
+		perm |= bin.PermR
+	}
+	if char&scnMemWrite != 0 {
+		perm |= bin.PermW
+	}
+	if char&scnMemExecute != 0 {
+		perm |= bin.PermX
+	}
+	return perm
+}
+
+// Symbol types and storage classes.
+const (
+	// Function symbol type.
+	symTypeFunc = 0x20
+	// External symbol storage class.
+	symClassExternal = 2
+)
+
+// A symbol is a COFF symbol table entry.
+type symbol struct {
+	// Symbol name.
+	Name string
+	// Index into the symbol table.
+	Index uint32
+	// Symbol value; the offset within the section for symbols defined in
+	// sections.
+	Value uint32
+	// Section number (1-based); or 0 for external symbols, -1 for absolute
+	// symbols and -2 for debugging symbols.
+	SectionNumber int16
+	// Symbol type.
+	Type uint16
+	// Storage class.
+	StorageClass uint8
+}
+
+// parseSymbols parses the symbol table of the given COFF file, skipping
+// auxiliary symbol records.
+func parseSymbols(f *pe.File) ([]*symbol, error) {
+	var syms []*symbol
+	for i := 0; i < len(f.COFFSymbols); i++ {
+		s := &f.COFFSymbols[i]
+		name, err := s.FullName(f.StringTable)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sym := &symbol{
+			Name:          name,
+			Index:         uint32(i),
+			Value:         s.Value,
+			SectionNumber: s.SectionNumber,
+			Type:          s.Type,
+			StorageClass:  s.StorageClass,
+		}
+		syms = append(syms, sym)
+		i += int(s.NumberOfAuxSymbols)
+	}
+	return syms, nil
+}
+
+// Relocation types.
+//
+// ref: https://docs.microsoft.com/en-us/windows/win32/debug/pe-format#type-indicators
+const (
+	// x86 relocation types.
+	relI386Absolute = 0x0000
+	relI386Dir32    = 0x0006
+	relI386Dir32NB  = 0x0007
+	relI386Section  = 0x000A
+	relI386SecRel   = 0x000B
+	relI386Rel32    = 0x0014
+	// x86-64 relocation types.
+	relAMD64Absolute = 0x0000
+	relAMD64Addr64   = 0x0001
+	relAMD64Addr32   = 0x0002
+	relAMD64Addr32NB = 0x0003
+	relAMD64Rel32    = 0x0004
+	relAMD64Rel32_5  = 0x0009
+	relAMD64Section  = 0x000A
+	relAMD64SecRel   = 0x000B
+)
+
+// applyReloc applies the given relocation to the section data.
+func applyReloc(file *bin.File, sect *bin.Section, reloc pe.Reloc, symAddrs map[uint32]bin.Address) error {
+	symAddr, ok := symAddrs[reloc.SymbolTableIndex]
+	if !ok {
+		warn.Printf("unable to locate address of symbol %d referenced by relocation at offset 0x%X of section %q", reloc.SymbolTableIndex, reloc.VirtualAddress, sect.Name)
+		return nil
+	}
+	offset := reloc.VirtualAddress
+	addr := sect.Addr + bin.Address(offset)
+	// check validates that the relocated value of the given size is within the
+	// bounds of the section data.
+	check := func(size uint32) error {
+		if uint64(offset)+uint64(size) > uint64(len(sect.Data)) {
+			return errors.Errorf("invalid relocation offset 0x%X of section %q; expected <= 0x%X", offset, sect.Name, len(sect.Data)-int(size))
+		}
+		return nil
+	}
+	// Base address of the synthetic image, for image relative relocations.
+	const imageBase = 0
+	switch file.Arch {
+	case bin.ArchX86_32:
+		switch reloc.Type {
+		case relI386Absolute, relI386Section:
+			// nothing to do.
+		case relI386Dir32:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr)+addend)
+			file.Relocs[addr] = bin.RelocAbs32
+		case relI386Dir32NB:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr-imageBase)+addend)
+		case relI386SecRel:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr-symSectAddr(symAddr, file))+addend)
+		case relI386Rel32:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr)-uint32(addr+4)+addend)
+		default:
+			warn.Printf("support for x86 relocation type 0x%04X at %v not yet implemented", reloc.Type, addr)
+		}
+	case bin.ArchX86_64:
+		switch reloc.Type {
+		case relAMD64Absolute, relAMD64Section:
+			// nothing to do.
+		case relAMD64Addr64:
+			if err := check(8); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint64(sect.Data[offset:])
+			binary.LittleEndian.PutUint64(sect.Data[offset:], uint64(symAddr)+addend)
+			file.Relocs[addr] = bin.RelocAbs64
+		case relAMD64Addr32:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr)+addend)
+			file.Relocs[addr] = bin.RelocAbs32
+		case relAMD64Addr32NB:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr-imageBase)+addend)
+		case relAMD64SecRel:
+			if err := check(4); err != nil {
+				return errors.WithStack(err)
+			}
+			addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+			binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr-symSectAddr(symAddr, file))+addend)
+		default:
+			if relAMD64Rel32 <= reloc.Type && reloc.Type <= relAMD64Rel32_5 {
+				// IMAGE_REL_AMD64_REL32_n; relative to the address n bytes after
+				// the end of the 32-bit value.
+				if err := check(4); err != nil {
+					return errors.WithStack(err)
+				}
+				n := uint32(reloc.Type - relAMD64Rel32)
+				addend := binary.LittleEndian.Uint32(sect.Data[offset:])
+				binary.LittleEndian.PutUint32(sect.Data[offset:], uint32(symAddr)-uint32(addr+4)-n+addend)
+				break
+			}
+			warn.Printf("support for x86-64 relocation type 0x%04X at %v not yet implemented", reloc.Type, addr)
+		}
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// findSection returns the section with the given address and name.
+func findSection(sects []*bin.Section, addr bin.Address, name string) (*bin.Section, error) {
+	for _, sect := range sects {
+		if sect.Addr == addr && sect.Name == name {
+			return sect, nil
+		}
+	}
+	return nil, errors.Errorf("unable to locate section %q at address %v", name, addr)
+}
+
+// symSectAddr returns the start address of the section containing the given
+// symbol address.
+func symSectAddr(symAddr bin.Address, file *bin.File) bin.Address {
+	for _, sect := range file.Sections {
+		if sect.Addr <= symAddr && symAddr < sect.Addr+bin.Address(sect.MemSize) {
+			return sect.Addr
+		}
+	}
+	return 0
+}
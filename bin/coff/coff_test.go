@@ -0,0 +1,115 @@
+package coff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestParse(t *testing.T) {
+	// A value stored at an address.
+	type value struct {
+		addr bin.Address
+		size int
+		want uint64
+	}
+	golden := []struct {
+		// Path to input COFF object file.
+		in string
+		// Expected imports.
+		imports map[bin.Address]string
+		// Expected exports.
+		exports map[bin.Address]string
+		// Expected relocations.
+		relocs map[bin.Address]bin.RelocKind
+		// Expected relocated values.
+		values []value
+	}{
+		// .text at 0x00, .data at 0x0C and _foo at 0x14.
+		{
+			in:      "testdata/reloc_x86_32.obj",
+			imports: map[bin.Address]string{0x14: "_foo"},
+			exports: map[bin.Address]string{0x00: "_start"},
+			relocs: map[bin.Address]bin.RelocKind{
+				0x06: bin.RelocAbs32,
+				0x10: bin.RelocAbs32,
+			},
+			values: []value{
+				// call _foo (REL32)
+				{addr: 0x01, size: 4, want: 0x14 - 0x05},
+				// mov eax, [counter] (DIR32)
+				{addr: 0x06, size: 4, want: 0x0C},
+				// ptr: dd _start+4 (DIR32 with addend)
+				{addr: 0x10, size: 4, want: 0x04},
+			},
+		},
+		// .text at 0x00, .data at 0x14 and foo at 0x28.
+		{
+			in:      "testdata/reloc_x86_64.obj",
+			imports: map[bin.Address]string{0x28: "foo"},
+			exports: map[bin.Address]string{0x00: "start"},
+			relocs: map[bin.Address]bin.RelocKind{
+				0x0E: bin.RelocAbs32,
+				0x1C: bin.RelocAbs64,
+			},
+			values: []value{
+				// call foo (REL32)
+				{addr: 0x01, size: 4, want: 0x28 - 0x05},
+				// mov eax, [rel counter] (REL32)
+				{addr: 0x07, size: 4, want: 0x14 - 0x0B},
+				// mov ecx, [counter] (ADDR32)
+				{addr: 0x0E, size: 4, want: 0x14},
+				// ptr: dq start+4 (ADDR64 with addend)
+				{addr: 0x1C, size: 8, want: 0x04},
+			},
+		},
+	}
+	for _, g := range golden {
+		file, err := ParseFile(g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse COFF file; %+v", g.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(file.Imports, g.imports) {
+			t.Errorf("%q: imports mismatch; expected %v, got %v", g.in, g.imports, file.Imports)
+		}
+		if !reflect.DeepEqual(file.Exports, g.exports) {
+			t.Errorf("%q: exports mismatch; expected %v, got %v", g.in, g.exports, file.Exports)
+		}
+		if !reflect.DeepEqual(file.Relocs, g.relocs) {
+			t.Errorf("%q: relocations mismatch; expected %v, got %v", g.in, g.relocs, file.Relocs)
+		}
+		for _, v := range g.values {
+			var got uint64
+			switch v.size {
+			case 4:
+				x, err := file.Uint32(v.addr)
+				if err != nil {
+					t.Errorf("%q: unable to read relocated value at %v; %+v", g.in, v.addr, err)
+					continue
+				}
+				got = uint64(x)
+			case 8:
+				got, err = file.Uint64(v.addr)
+				if err != nil {
+					t.Errorf("%q: unable to read relocated value at %v; %+v", g.in, v.addr, err)
+					continue
+				}
+			}
+			if got != v.want {
+				t.Errorf("%q: relocated value mismatch at %v; expected 0x%X, got 0x%X", g.in, v.addr, v.want, got)
+			}
+		}
+	}
+}
+
+func TestFindSection(t *testing.T) {
+	sects := []*bin.Section{{Name: ".text", Addr: 0x00}, {Name: ".data", Addr: 0x10}}
+	if sect, err := findSection(sects, 0x10, ".data"); err != nil || sect != sects[1] {
+		t.Errorf("section mismatch; expected %v, got %v (%v)", sects[1], sect, err)
+	}
+	if _, err := findSection(sects, 0x10, ".bss"); err == nil {
+		t.Errorf("expected error when locating missing section")
+	}
+}
@@ -0,0 +1,15 @@
+# Object file with DIR32 and REL32 relocations, and a reference to the
+# external function _foo.
+
+	.text
+	.globl	_start
+_start:
+	calll	_foo
+	movl	counter, %eax
+	retl
+
+	.data
+counter:
+	.long	0x11223344
+ptr:
+	.long	_start+4
@@ -0,0 +1,17 @@
+# Object file with ADDR64, ADDR32 and REL32 relocations, and a reference to the
+# external function foo.
+
+	.text
+	.globl	start
+start:
+	callq	foo
+	movl	counter(%rip), %eax
+	movl	counter, %ecx
+	retq
+
+	.data
+counter:
+	.long	0x11223344
+	.long	0
+ptr:
+	.quad	start+4
@@ -0,0 +1,228 @@
+package elf
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io/ioutil"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// parseDynamic parses the dynamic section of the ELF file, as located by the
+// PT_DYNAMIC program header; thus not relying on section headers. The returned
+// map specifies the value of each dynamic tag, or is nil if the ELF file has no
+// dynamic section.
+func parseDynamic(f *elf.File) (map[elf.DynTag]uint64, error) {
+	var prog *elf.Prog
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_DYNAMIC {
+			prog = p
+			break
+		}
+	}
+	if prog == nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(prog.Open())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dyn := make(map[elf.DynTag]uint64)
+	order := f.ByteOrder
+	switch f.Class {
+	case elf.ELFCLASS32:
+		// Elf32_Dyn: d_tag int32, d_val uint32
+		for i := 0; i+8 <= len(data); i += 8 {
+			tag := elf.DynTag(int32(order.Uint32(data[i:])))
+			if tag == elf.DT_NULL {
+				break
+			}
+			if _, ok := dyn[tag]; !ok {
+				dyn[tag] = uint64(order.Uint32(data[i+4:]))
+			}
+		}
+	case elf.ELFCLASS64:
+		// Elf64_Dyn: d_tag int64, d_val uint64
+		for i := 0; i+16 <= len(data); i += 16 {
+			tag := elf.DynTag(int64(order.Uint64(data[i:])))
+			if tag == elf.DT_NULL {
+				break
+			}
+			if _, ok := dyn[tag]; !ok {
+				dyn[tag] = order.Uint64(data[i+8:])
+			}
+		}
+	default:
+		return nil, errors.Errorf("support for ELF class %v not yet implemented", f.Class)
+	}
+	return dyn, nil
+}
+
+// dynamicSymbols returns the first n symbols of the dynamic symbol table, as
+// located by the DT_SYMTAB and DT_STRTAB dynamic tags. The symbol at index 0 is
+// the undefined symbol.
+func (r *relocator) dynamicSymbols(dyn map[elf.DynTag]uint64, n int) ([]elf.Symbol, error) {
+	symtabAddr, ok := dyn[elf.DT_SYMTAB]
+	if !ok {
+		return nil, errors.New("unable to locate dynamic symbol table; missing DT_SYMTAB")
+	}
+	strtabAddr, ok := dyn[elf.DT_STRTAB]
+	if !ok {
+		return nil, errors.New("unable to locate dynamic string table; missing DT_STRTAB")
+	}
+	strtab, err := r.locate(bin.Address(strtabAddr), int(dyn[elf.DT_STRSZ]))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	strtab = strtab[:dyn[elf.DT_STRSZ]]
+	// Size of symbol table entries.
+	symSize := elf.Sym64Size
+	if r.f.Class == elf.ELFCLASS32 {
+		symSize = elf.Sym32Size
+	}
+	if v, ok := dyn[elf.DT_SYMENT]; ok && v != uint64(symSize) {
+		return nil, errors.Errorf("invalid size of dynamic symbol table entry (DT_SYMENT); expected %d, got %d", symSize, v)
+	}
+	symtab, err := r.locate(bin.Address(symtabAddr), n*symSize)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	order := r.f.ByteOrder
+	syms := make([]elf.Symbol, n)
+	for i := range syms {
+		buf := symtab[i*symSize:]
+		var name uint32
+		sym := &syms[i]
+		switch r.f.Class {
+		case elf.ELFCLASS32:
+			// Elf32_Sym: st_name uint32, st_value uint32, st_size uint32,
+			// st_info uint8, st_other uint8, st_shndx uint16
+			name = order.Uint32(buf[0:])
+			sym.Value = uint64(order.Uint32(buf[4:]))
+			sym.Size = uint64(order.Uint32(buf[8:]))
+			sym.Info = buf[12]
+			sym.Other = buf[13]
+			sym.Section = elf.SectionIndex(order.Uint16(buf[14:]))
+		default:
+			// Elf64_Sym: st_name uint32, st_info uint8, st_other uint8,
+			// st_shndx uint16, st_value uint64, st_size uint64
+			name = order.Uint32(buf[0:])
+			sym.Info = buf[4]
+			sym.Other = buf[5]
+			sym.Section = elf.SectionIndex(order.Uint16(buf[6:]))
+			sym.Value = order.Uint64(buf[8:])
+			sym.Size = order.Uint64(buf[16:])
+		}
+		if int(name) < len(strtab) {
+			sym.Name, err = parseString(strtab[name:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid name of dynamic symbol %d", i)
+			}
+		}
+	}
+	return syms, nil
+}
+
+// parsePLTs locates the PLT stubs of imported functions, and records them as
+// function imports. The GOT slots referenced by PLT stubs are recorded as known
+// data regions rather than imports, as each imported function is recorded at
+// a single address; GOT slots without PLT stubs (e.g. ELF files compiled with
+// -fno-plt) remain function imports. The PLT stubs are identified by indirect
+// jumps through the GOT slots of imported functions, and are thus located
+// independent of the initial values of GOT slots; which point to the lazy
+// binding code of the PLT (if present), or are unset for ELF files linked with
+// -z now.
+//
+// The following PLT stub variants are recognized.
+//
+//    jmp     [rel got.printf]        ; FF 25 disp32     (x86-64)
+//    bnd jmp [rel got.printf]        ; F2 FF 25 disp32  (x86-64, MPX)
+//    jmp     [got.printf]            ; FF 25 addr32     (x86-32)
+//    jmp     [ebx + got.printf]      ; FF A3 disp32     (x86-32, PIC)
+//
+// Stubs of IBT-enabled PLTs (e.g. .plt.sec) are additionally prefixed by an
+// ENDBR64 or ENDBR32 instruction.
+//
+// gotplt specifies the address of the GOT, as used by position independent
+// x86-32 PLT stubs.
+func parsePLTs(file *bin.File, slots map[bin.Address]string, gotplt bin.Address) {
+	if len(slots) == 0 {
+		return
+	}
+	switch file.Arch {
+	case bin.ArchX86_32, bin.ArchX86_64:
+		// PLT stubs of x86 and x86-64.
+	default:
+		// TODO: Add support for PLT stubs of other machine architectures.
+		return
+	}
+	// Locate PLT sections; or executable segments if section headers are
+	// missing.
+	var sects []*bin.Section
+	for _, sect := range file.Sections {
+		switch sect.Name {
+		case ".plt", ".plt.sec", ".plt.got", ".iplt":
+			sects = append(sects, sect)
+		}
+	}
+	if len(sects) == 0 {
+		for _, sect := range file.Sections {
+			if len(sect.Name) == 0 && sect.Perm&bin.PermX != 0 {
+				sects = append(sects, sect)
+			}
+		}
+	}
+	var (
+		endbr64 = []byte{0xF3, 0x0F, 0x1E, 0xFA}
+		endbr32 = []byte{0xF3, 0x0F, 0x1E, 0xFB}
+	)
+	stubbed := make(map[bin.Address]bool)
+	for _, sect := range sects {
+		data := sect.Data
+		for i := 0; i+6 <= len(data); i++ {
+			if data[i] != 0xFF {
+				continue
+			}
+			start := i
+			// Address of the end of the instruction.
+			end := sect.Addr + bin.Address(i+6)
+			disp := bin.Address(int64(int32(binary.LittleEndian.Uint32(data[i+2:]))))
+			var slot bin.Address
+			switch {
+			case data[i+1] == 0x25 && file.Arch.BitSize() == 64:
+				// jmp [rel slot]
+				slot = end + disp
+				if i > 0 && data[i-1] == 0xF2 {
+					// bnd prefix.
+					start--
+				}
+			case data[i+1] == 0x25:
+				// jmp [slot]
+				slot = bin.Address(uint32(disp))
+			case data[i+1] == 0xA3 && gotplt != 0:
+				// jmp [ebx + slot]
+				slot = gotplt + disp
+			default:
+				continue
+			}
+			name, ok := slots[slot]
+			if !ok {
+				continue
+			}
+			if start >= 4 {
+				if prefix := data[start-4 : start]; bytes.Equal(prefix, endbr64) || bytes.Equal(prefix, endbr32) {
+					start -= 4
+				}
+			}
+			addr := sect.Addr + bin.Address(start)
+			file.Imports[addr] = name
+			stubbed[slot] = true
+		}
+	}
+	for slot := range stubbed {
+		delete(file.Imports, slot)
+		file.DataAddrs = bin.InsertAddr(file.DataAddrs, slot)
+	}
+}
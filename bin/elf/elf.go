@@ -8,13 +8,21 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"sort"
 
 	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
 	"github.com/pkg/errors"
 )
 
+var (
+	// warn is a logger with the "elf:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("elf:")+" ", 0)
+)
+
 // Register ELF format.
 func init() {
 	// Executable and Linkable Format (ELF)
@@ -22,6 +30,7 @@ func init() {
 	//    7F 45 4C 46  |.ELF|
 	const magic = "\x7FELF"
 	bin.RegisterFormat("elf", magic, Parse)
+	bin.RegisterIdentify("elf", magic, Identify)
 }
 
 // ParseFile parses the given ELF binary executable, reading from path.
@@ -34,6 +43,69 @@ func ParseFile(path string) (*bin.File, error) {
 	return Parse(f)
 }
 
+// Identify identifies the machine architecture of the given ELF binary
+// executable, reading only the ELF header from r.
+func Identify(r io.ReaderAt) (*bin.Info, error) {
+	// ELF identification and the e_type and e_machine fields of the ELF header.
+	var buf [20]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	class := elf.Class(buf[elf.EI_CLASS])
+	data := elf.Data(buf[elf.EI_DATA])
+	info := &bin.Info{Format: "elf"}
+	var order binary.ByteOrder
+	switch data {
+	case elf.ELFDATA2LSB:
+		order = binary.LittleEndian
+	case elf.ELFDATA2MSB:
+		order = binary.BigEndian
+	default:
+		return nil, errors.Errorf("invalid ELF data encoding %v", data)
+	}
+	info.ByteOrder = order
+	switch class {
+	case elf.ELFCLASS32:
+		info.BitSize = 32
+	case elf.ELFCLASS64:
+		info.BitSize = 64
+	default:
+		return nil, errors.Errorf("invalid ELF class %v", class)
+	}
+	machine := elf.Machine(order.Uint16(buf[18:]))
+	arch, ok := parseArch(machine, class, data)
+	if !ok {
+		return info, errors.WithStack(&bin.UnsupportedMachineError{Format: "elf", Machine: uint32(machine), MachineName: machine.String()})
+	}
+	return bin.NewInfo("elf", arch), nil
+}
+
+// parseArch returns the machine architecture corresponding to the given ELF
+// machine, class and data encoding. The boolean return value indicates success.
+func parseArch(machine elf.Machine, class elf.Class, data elf.Data) (bin.Arch, bool) {
+	switch machine {
+	case elf.EM_386:
+		return bin.ArchX86_32, true
+	case elf.EM_X86_64:
+		return bin.ArchX86_64, true
+	case elf.EM_PPC:
+		return bin.ArchPowerPC_32, true
+	case elf.EM_MIPS:
+		bigEndian := data == elf.ELFDATA2MSB
+		switch {
+		case class == elf.ELFCLASS32 && bigEndian:
+			return bin.ArchMIPS_32BE, true
+		case class == elf.ELFCLASS32:
+			return bin.ArchMIPS_32, true
+		case bigEndian:
+			return bin.ArchMIPS_64BE, true
+		default:
+			return bin.ArchMIPS_64LE, true
+		}
+	}
+	return 0, false
+}
+
 // Parse parses the given ELF binary executable, reading from r.
 //
 // Users are responsible for closing r.
@@ -48,21 +120,45 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	file := &bin.File{
 		Imports: make(map[bin.Address]string),
 		Exports: make(map[bin.Address]string),
+		Relocs:  make(map[bin.Address]bin.RelocKind),
+		// The dynamic relocations of executables (ET_EXEC) linked at a fixed
+		// address are not retained.
+		Relocatable: f.Type == elf.ET_DYN || f.Type == elf.ET_REL,
 	}
-	switch f.Machine {
-	case elf.EM_386:
-		file.Arch = bin.ArchX86_32
-	case elf.EM_X86_64:
-		file.Arch = bin.ArchX86_64
-	case elf.EM_PPC:
-		file.Arch = bin.ArchPowerPC_32
+	arch, ok := parseArch(f.Machine, f.Class, f.Data)
+	if !ok {
+		return nil, errors.WithStack(&bin.UnsupportedMachineError{Format: "elf", Machine: uint32(f.Machine), MachineName: f.Machine.String()})
 	}
+	file.Arch = arch
 
 	// Parse entry address.
 	file.Entry = bin.Address(f.Entry)
 
 	// Parse sections.
-	for _, s := range f.Sections {
+	//
+	// Sections of relocatable object files are not linked, and therefore
+	// loadable sections are assigned synthetic addresses, laid out
+	// consecutively starting at address 0.
+	//
+	// sectAddrs maps from section header index to section address.
+	sectAddrs := make(map[int]bin.Address)
+	var next bin.Address
+	for i, s := range f.Sections {
+		addr := bin.Address(s.Addr)
+		if f.Type == elf.ET_REL {
+			if s.Flags&elf.SHF_ALLOC == 0 {
+				// skip non-loadable sections of relocatable object files.
+				continue
+			}
+			if align := bin.Address(s.Addralign); align > 1 {
+				if rem := next % align; rem != 0 {
+					next += align - rem
+				}
+			}
+			addr = next
+			next += bin.Address(s.Size)
+		}
+		sectAddrs[i] = addr
 		perm := parseSectFlags(s.Flags)
 		var data []byte
 		if s.Type != elf.SHT_NOBITS {
@@ -76,7 +172,7 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 		}
 		sect := &bin.Section{
 			Name:     s.Name,
-			Addr:     bin.Address(s.Addr),
+			Addr:     addr,
 			Offset:   s.Offset,
 			FileSize: int(s.FileSize),
 			MemSize:  int(s.Size),
@@ -87,17 +183,7 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	}
 
 	// Sort sections in ascending order.
-	less := func(i, j int) bool {
-		if file.Sections[i].Addr == file.Sections[j].Addr {
-			if len(file.Sections[i].Data) > len(file.Sections[j].Data) {
-				// prioritize longer sections with identical addresses.
-				return true
-			}
-			return file.Sections[i].Name < file.Sections[j].Name
-		}
-		return file.Sections[i].Addr < file.Sections[j].Addr
-	}
-	sort.Slice(file.Sections, less)
+	sortSections(file.Sections)
 
 	// Parse segments.
 	var segments []*bin.Section
@@ -123,7 +209,13 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	}
 
 	// Sort segments in ascending order.
-	sort.Slice(segments, less)
+	sortSections(segments)
+
+	// Parse base address; the address of the first loadable segment (e.g. 0 for
+	// position independent executables and shared objects).
+	if len(segments) > 0 {
+		file.Base = segments[0].Addr
+	}
 
 	// Fix section permissions.
 	if len(segments) > 0 {
@@ -142,197 +234,150 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	// Append segments as sections.
 	file.Sections = append(file.Sections, segments...)
 
-	// Sort sections (and segments) in ascending order.
-	sort.Slice(segments, less)
+	// Parse dynamic section.
+	dyn, err := parseDynamic(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 
-	// TODO: Parse imports.
+	// Parse relocations.
+	slots, err := parseRelocs(file, f, sectAddrs, dyn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Sort sections (and segments) in ascending order.
+	sortSections(file.Sections)
 
 	// Parse imports.
-	gotplt := f.Section(".got.plt")
-	// TODO: Add support for reading .got.plt from segments when section
-	// information is missing. Locate using DT_PLTGOT in .dynamic.
-	if gotplt != nil {
-		gotpltData, err := gotplt.Data()
+	//
+	// The GOT address (DT_PLTGOT) is used by position independent x86-32 PLT
+	// stubs.
+	gotplt := bin.Address(dyn[elf.DT_PLTGOT])
+	if s := f.Section(".got.plt"); s != nil {
+		gotplt = bin.Address(s.Addr)
+	}
+	parsePLTs(file, slots, gotplt)
+
+	// Parse symbols and exports.
+	if err := parseSymbols(file, f, sectAddrs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse DWARF debug information.
+	//
+	// The addresses of debug information in relocatable object files are
+	// section relative, and thus not applicable to the synthetic section
+	// addresses.
+	if f.Type != elf.ET_REL && f.Section(".debug_info") != nil {
+		d, err := f.DWARF()
 		if err != nil {
-			return nil, errors.WithStack(err)
+			warn.Printf("unable to parse DWARF debug information; %v", err)
+		} else {
+			file.DWARF = d
 		}
-		dynSyms, err := f.DynamicSymbols()
-		if err != nil {
-			return nil, errors.WithStack(err)
+	}
+
+	return file, nil
+}
+
+// parseSymbols parses the symbols of the symbol table (.symtab) and the dynamic
+// symbol table (.dynsym) of the ELF file. Defined function symbols are recorded
+// as function exports.
+func parseSymbols(file *bin.File, f *elf.File, sectAddrs map[int]bin.Address) error {
+	syms, err := f.Symbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return errors.WithStack(err)
+	}
+	dynSyms, err := f.DynamicSymbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return errors.WithStack(err)
+	}
+	// Symbols present in both .symtab and .dynsym are only recorded once.
+	type key struct {
+		name string
+		addr bin.Address
+	}
+	seen := make(map[key]bool)
+	for _, sym := range append(syms, dynSyms...) {
+		if sym.Section == elf.SHN_UNDEF || sym.Section == elf.SHN_COMMON {
+			// skip undefined symbols.
+			continue
 		}
-		// Program Linkage Table example.
-		//
-		//    plt:
-		//
-		//      ...
-		//
-		//      .printf:
-		//        jmp     [rel (BASE_DATA - BASE_CODE) + got_plt.printf]
-		//
-		//      .resolve_printf:
-		//        push    QWORD dynsym.printf_idx
-		//        jmp     NEAR .resolve
-		//        jmp     [rel (BASE_DATA - BASE_CODE) + got_plt.printf]
-		//
-		//      ...
-		//
-		// ref: https://github.com/mewrev/dissection/blob/master/elf.asm
-
-		// The length of the 32- and 64-bit JMP instruction.
-		//
-		//    jmp     [rel (BASE_DATA - BASE_CODE) + got_plt.printf]
-		const jmplen = 6
-		switch file.Arch.BitSize() {
-		case 32:
-			// skip .got.plt:dynamic            (4 bytes)
-			// skip .got.plt:link_map           (4 bytes)
-			// skip .got.plt:dl_runtime_resolve (4 bytes)
-			r := bytes.NewReader(gotpltData[4+4+4:])
-			for _, dynSym := range dynSyms {
-				var v uint32
-				if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
-					if errors.Cause(err) == io.EOF {
-						break
-					}
-					return nil, errors.WithStack(err)
-				}
-				// v points to .plt:resolve_printf, and .plt:printf is at the jmp
-				// instruction directly preceding; thus subtract the length of the
-				// jmp instruction from v to arrive at .plt:printf.
-				addr := bin.Address(v) - jmplen
-				file.Imports[addr] = dynSym.Name
-			}
-		case 64:
-			// skip .got.plt:dynamic            (8 bytes)
-			// skip .got.plt:link_map           (8 bytes)
-			// skip .got.plt:dl_runtime_resolve (8 bytes)
-			r := bytes.NewReader(gotpltData[8+8+8:])
-			for _, dynSym := range dynSyms {
-				var v uint64
-				if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
-					if errors.Cause(err) == io.EOF {
-						break
-					}
-					return nil, errors.WithStack(err)
-				}
-				// v points to .plt:resolve_printf, and .plt:printf is at the jmp
-				// instruction directly preceding; thus subtract the length of the
-				// jmp instruction from v to arrive at .plt:printf.
-				addr := bin.Address(v) - jmplen
-				file.Imports[addr] = dynSym.Name
-			}
+		var kind bin.SymbolKind
+		switch typ := SymType(elf.ST_TYPE(sym.Info)); typ {
+		case SymTypeNone:
+			kind = bin.SymbolNone
+		case SymTypeFunc, SymTypeOS0:
+			// STT_GNU_IFUNC (OS 0) denotes an indirect function.
+			kind = bin.SymbolFunc
+		case SymTypeObject, SymTypeCommon:
+			kind = bin.SymbolObject
+		case SymTypeTLS:
+			kind = bin.SymbolTLS
 		default:
-			panic(fmt.Errorf("support for CPU bit size %d not yet implemented", file.Arch.BitSize()))
+			// skip section, file and processor-specific symbols.
+			continue
 		}
-	}
-
-	// Parse exports.
-	symtab := f.Section(".symtab")
-	strtab := f.Section(".strtab")
-	if symtab != nil && strtab != nil {
-		symtabData, err := symtab.Data()
-		if err != nil {
-			return nil, errors.WithStack(err)
+		if len(sym.Name) == 0 {
+			continue
 		}
-		strtabData, err := strtab.Data()
-		if err != nil {
-			return nil, errors.WithStack(err)
+		addr := bin.Address(sym.Value)
+		if f.Type == elf.ET_REL && sym.Section < elf.SHN_LORESERVE && kind != bin.SymbolTLS {
+			// Symbol values of relocatable object files are offsets relative to
+			// the start of the section. Symbol values of thread-local symbols
+			// are offsets into the thread-local storage template.
+			addr += sectAddrs[int(sym.Section)]
 		}
-		r := bytes.NewReader(symtabData)
-		// undef specifies that a symbol is not associated with a specific
-		// section.
-		const undef = 0
-		switch file.Arch.BitSize() {
-		case 32:
-			// Sym32 represents a 32-bit symbol descriptor.
-			type Sym32 struct {
-				// Index into the symbol string table.
-				Name uint32
-				// Value of the associated symbol. Depending on the context, this can
-				// be an absolute value, an address, etc.
-				Value uint32
-				// Size in bytes; or 0 if the symbol has no size or an unknown size.
-				Size uint32
-				// Symbol type and binding information.
-				Info uint8
-				// Symbol visibility.
-				Visibility SymVisibility
-				// Section header table index relevant for the symbol.
-				SectHdrIndex uint16
-			}
-			for {
-				var sym Sym32
-				if err := binary.Read(r, binary.LittleEndian, &sym); err != nil {
-					if errors.Cause(err) == io.EOF {
-						break
-					}
-					return nil, errors.WithStack(err)
-				}
-				//pretty.Println("sym:", sym)
-				name := parseString(strtabData[sym.Name:])
-				addr := bin.Address(sym.Value)
-				typ := SymType(sym.Info & 0x0F)
-				//bind := SymBind(sym.Info >> 4)
-				// TODO: Remove debug output.
-				//fmt.Println("name:", name)
-				//fmt.Println("addr:", addr)
-				//fmt.Println("size:", sym.Size)
-				//fmt.Println("typ:", typ)
-				//fmt.Println("bind:", bind)
-				//fmt.Println("visibility:", sym.Visibility)
-				//fmt.Println()
-				if typ == SymTypeFunc && sym.SectHdrIndex != undef {
-					file.Exports[addr] = name
-				}
-			}
-		case 64:
-			// Sym64 represents a 64-bit symbol descriptor.
-			type Sym64 struct {
-				// Index into the symbol string table.
-				Name uint32
-				// Symbol type and binding information.
-				Info uint8
-				// Symbol visibility.
-				Visibility SymVisibility
-				// Section header table index relevant for the symbol.
-				SectHdrIndex uint16
-				// Value of the associated symbol. Depending on the context, this can
-				// be an absolute value, an address, etc.
-				Value uint64
-				// Size in bytes; or 0 if the symbol has no size or an unknown size.
-				Size uint64
-			}
-			for {
-				var sym Sym64
-				if err := binary.Read(r, binary.LittleEndian, &sym); err != nil {
-					if errors.Cause(err) == io.EOF {
-						break
-					}
-					return nil, errors.WithStack(err)
-				}
-				//pretty.Println("sym:", sym)
-				name := parseString(strtabData[sym.Name:])
-				addr := bin.Address(sym.Value)
-				typ := SymType(sym.Info & 0x0F)
-				//bind := SymBind(sym.Info >> 4)
-				// TODO: Remove debug output.
-				//fmt.Println("name:", name)
-				//fmt.Println("addr:", addr)
-				//fmt.Println("size:", sym.Size)
-				//fmt.Println("typ:", typ)
-				//fmt.Println("bind:", bind)
-				//fmt.Println("visibility:", sym.Visibility)
-				//fmt.Println()
-				if typ == SymTypeFunc && sym.SectHdrIndex != undef {
-					file.Exports[addr] = name
-				}
-			}
+		k := key{name: sym.Name, addr: addr}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		var binding bin.SymbolBinding
+		switch bind := SymBind(elf.ST_BIND(sym.Info)); bind {
+		case SymBindLocal:
+			binding = bin.BindingLocal
+		case SymBindWeak:
+			binding = bin.BindingWeak
+		default:
+			// Global and STB_GNU_UNIQUE (OS 0) symbols.
+			binding = bin.BindingGlobal
+		}
+		var visibility bin.SymbolVisibility
+		switch SymVisibility(elf.ST_VISIBILITY(sym.Other)) {
+		case SymVisibilityInternal:
+			visibility = bin.VisibilityInternal
+		case SymVisibilityHidden:
+			visibility = bin.VisibilityHidden
+		case SymVisibilityProtected:
+			visibility = bin.VisibilityProtected
 		default:
-			panic(fmt.Errorf("support for CPU bit size %d not yet implemented", file.Arch.BitSize()))
+			visibility = bin.VisibilityDefault
+		}
+		s := &bin.Symbol{
+			Name:       sym.Name,
+			Addr:       addr,
+			Size:       sym.Size,
+			Kind:       kind,
+			Binding:    binding,
+			Visibility: visibility,
+		}
+		file.Symbols = append(file.Symbols, s)
+		if kind == bin.SymbolFunc {
+			if _, ok := file.Exports[addr]; !ok {
+				file.Exports[addr] = sym.Name
+			}
 		}
 	}
-
-	return file, nil
+	less := func(i, j int) bool {
+		if file.Symbols[i].Addr == file.Symbols[j].Addr {
+			return file.Symbols[i].Name < file.Symbols[j].Name
+		}
+		return file.Symbols[i].Addr < file.Symbols[j].Addr
+	}
+	sort.Slice(file.Symbols, less)
+	return nil
 }
 
 // SymType specifies a symbol type.
@@ -347,6 +392,7 @@ func (typ SymType) String() string {
 		SymTypeSection: "section",
 		SymTypeFile:    "file",
 		SymTypeCommon:  "common",
+		SymTypeTLS:     "TLS",
 		SymTypeOS0:     "OS 0",
 		SymTypeOS1:     "OS 1",
 		SymTypeOS2:     "OS 2",
@@ -375,6 +421,8 @@ const (
 	SymTypeFile SymType = 4
 	// This symbol labels an uninitialized common block.
 	SymTypeCommon SymType = 5
+	// This symbol specifies a thread-local storage entity.
+	SymTypeTLS SymType = 6
 	// Reserved for operating system-specific semantics.
 	SymTypeOS0 SymType = 10
 	// Reserved for operating system-specific semantics.
@@ -493,11 +541,28 @@ func parseProgFlags(flags elf.ProgFlag) bin.Perm {
 
 // ### [ Helper functions ] ####################################################
 
+// sortSections sorts the given sections by address in ascending order,
+// prioritizing longer sections; and disambiguating sections at same address and
+// length by ascending section name.
+func sortSections(sects []*bin.Section) {
+	less := func(i, j int) bool {
+		if sects[i].Addr == sects[j].Addr {
+			if len(sects[i].Data) > len(sects[j].Data) {
+				// prioritize longer sections with identical addresses.
+				return true
+			}
+			return sects[i].Name < sects[j].Name
+		}
+		return sects[i].Addr < sects[j].Addr
+	}
+	sort.Slice(sects, less)
+}
+
 // parseString parses the NULL-terminated string in the given data.
-func parseString(data []byte) string {
+func parseString(data []byte) (string, error) {
 	pos := bytes.IndexByte(data, '\x00')
 	if pos == -1 {
-		panic(fmt.Errorf("unable to locate NULL-terminated string in % 02X", data))
+		return "", errors.Errorf("unable to locate NULL-terminated string in %d bytes of data", len(data))
 	}
-	return string(data[:pos])
+	return string(data[:pos]), nil
 }
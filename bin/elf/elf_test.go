@@ -0,0 +1,313 @@
+package elf
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestParseImports(t *testing.T) {
+	golden := []struct {
+		// Path to input ELF file.
+		in string
+		// Path to golden imports and data addresses.
+		out string
+	}{
+		// Lazy binding through .plt.
+		{in: "testdata/plt_lazy.out", out: "testdata/plt_lazy.golden"},
+		// Immediate binding; GOT slots unset prior to loading.
+		{in: "testdata/plt_now.out", out: "testdata/plt_now.golden"},
+		// IBT-enabled PLT stubs in .plt.sec.
+		{in: "testdata/plt_ibt.out", out: "testdata/plt_ibt.golden"},
+		// No PLT stubs; imported functions called through GOT slots.
+		{in: "testdata/plt_noplt.out", out: "testdata/plt_noplt.golden"},
+		// Lazy binding through .plt; without section headers.
+		{in: "testdata/plt_sstrip.out", out: "testdata/plt_lazy.golden"},
+	}
+	for _, g := range golden {
+		file, err := ParseFile(g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse ELF file; %+v", g.in, err)
+			continue
+		}
+		// Each imported function is recorded at a single address.
+		names := make(map[string]bin.Address)
+		for addr, name := range file.Imports {
+			if prev, ok := names[name]; ok {
+				t.Errorf("%q: duplicate import %q at %v and %v", g.in, name, prev, addr)
+			}
+			names[name] = addr
+		}
+		buf, err := ioutil.ReadFile(g.out)
+		if err != nil {
+			t.Errorf("%q: unable to read golden file; %v", g.in, err)
+			continue
+		}
+		want := string(buf)
+		got := dumpImports(file)
+		if got != want {
+			t.Errorf("%q: imports mismatch; expected:\n%s\ngot:\n%s", g.in, want, got)
+		}
+	}
+}
+
+// dumpImports returns a textual representation of the function imports and
+// known data regions of the given file, sorted by address.
+func dumpImports(file *bin.File) string {
+	var addrs []bin.Address
+	for addr := range file.Imports {
+		addrs = append(addrs, addr)
+	}
+	sort.Sort(bin.Addresses(addrs))
+	buf := &strings.Builder{}
+	for _, addr := range addrs {
+		fmt.Fprintf(buf, "import %v %s\n", addr, file.Imports[addr])
+	}
+	for _, addr := range file.DataAddrs {
+		fmt.Fprintf(buf, "data   %v\n", addr)
+	}
+	return buf.String()
+}
+
+func TestParseSymbols(t *testing.T) {
+	file, err := ParseFile("testdata/tls.out")
+	if err != nil {
+		t.Fatalf("unable to parse ELF file; %+v", err)
+	}
+	golden := map[string]bin.Symbol{
+		"_start": {Name: "_start", Addr: 0x400160, Size: 23, Kind: bin.SymbolFunc, Binding: bin.BindingGlobal},
+		"data":   {Name: "data", Addr: 0x402000, Size: 4, Kind: bin.SymbolObject, Binding: bin.BindingGlobal},
+		// Addresses of thread-local symbols are offsets into the thread-local
+		// storage template.
+		"tls_data": {Name: "tls_data", Addr: 0x0, Size: 4, Kind: bin.SymbolTLS, Binding: bin.BindingGlobal},
+		"tls_bss":  {Name: "tls_bss", Addr: 0x4, Size: 4, Kind: bin.SymbolTLS, Binding: bin.BindingGlobal},
+	}
+	found := 0
+	for _, sym := range file.Symbols {
+		want, ok := golden[sym.Name]
+		if !ok {
+			continue
+		}
+		found++
+		if *sym != want {
+			t.Errorf("symbol %q mismatch; expected %+v, got %+v", sym.Name, want, *sym)
+		}
+	}
+	if found != len(golden) {
+		t.Errorf("number of symbols mismatch; expected %d, got %d", len(golden), found)
+	}
+}
+
+func TestParseGOTRelocs(t *testing.T) {
+	// x86-64 object file; GOT slots accessed RIP-relative.
+	file, err := ParseFile("testdata/got_x86_64.o")
+	if err != nil {
+		t.Fatalf("unable to parse ELF file; %+v", err)
+	}
+	text := findSection(t, file, ".text")
+	data := findSection(t, file, ".data")
+	// slotAt returns the GOT slot referenced by the RIP-relative displacement
+	// at the given offset of .text.
+	slotAt := func(offset bin.Address) bin.Address {
+		disp, err := file.Uint32(text + offset)
+		if err != nil {
+			t.Fatalf("unable to read displacement at %v; %v", text+offset, err)
+		}
+		return text + offset + 4 + bin.Address(int32(disp))
+	}
+	//    call [rel ext_func wrt ..got]   ; R_X86_64_GOTPCRELX
+	//    mov  rax, [rel def_var wrt ..got]   ; R_X86_64_REX_GOTPCRELX
+	//    mov  rdx, [rel ext_var wrt ..got]   ; R_X86_64_REX_GOTPCRELX
+	checkImport(t, file, slotAt(0x06), "ext_func")
+	checkImport(t, file, slotAt(0x14), "ext_var")
+	slot := slotAt(0x0D)
+	if v, err := file.Uint64(slot); err != nil || bin.Address(v) != data {
+		t.Errorf("GOT slot of def_var mismatch; expected %v, got %v (%v)", data, bin.Address(v), err)
+	}
+	if kind := file.Relocs[slot]; kind != bin.RelocAbs64 {
+		t.Errorf("relocation of GOT slot %v mismatch; expected %v, got %v", slot, bin.RelocAbs64, kind)
+	}
+
+	// x86 object file; GOT slots accessed relative to the GOT address.
+	file, err = ParseFile("testdata/got_x86_32.o")
+	if err != nil {
+		t.Fatalf("unable to parse ELF file; %+v", err)
+	}
+	text = findSection(t, file, ".text")
+	data = findSection(t, file, ".data")
+	bss := findSection(t, file, ".bss")
+	got := findSection(t, file, ".extern")
+	uint32At := func(offset bin.Address) bin.Address {
+		v, err := file.Uint32(text + offset)
+		if err != nil {
+			t.Fatalf("unable to read value at %v; %v", text+offset, err)
+		}
+		return bin.Address(v)
+	}
+	// gotRel returns the address referenced by the GOT-relative offset at the
+	// given offset of .text.
+	gotRel := func(offset bin.Address) bin.Address {
+		return bin.Address(uint32(got + uint32At(offset)))
+	}
+	//    call __x86.get_pc_thunk.bx
+	//    add  ebx, _GLOBAL_OFFSET_TABLE_   ; R_386_GOTPC
+	if ebx := uint32At(0x08) + text + 0x06; ebx != got {
+		t.Errorf("GOT address mismatch; expected %v, got %v", got, ebx)
+	}
+	//    call [ebx + ext_func wrt ..got]   ; R_386_GOT32X
+	//    mov  edx, [ebx + ext_var wrt ..got]   ; R_386_GOT32X
+	//    mov  eax, [ebx + def_var wrt ..got]   ; R_386_GOT32X
+	checkImport(t, file, gotRel(0x11), "ext_func")
+	checkImport(t, file, gotRel(0x17), "ext_var")
+	slot = gotRel(0x1D)
+	if v, err := file.Uint32(slot); err != nil || bin.Address(v) != data {
+		t.Errorf("GOT slot of def_var mismatch; expected %v, got %v (%v)", data, bin.Address(v), err)
+	}
+	if kind := file.Relocs[slot]; kind != bin.RelocAbs32 {
+		t.Errorf("relocation of GOT slot %v mismatch; expected %v, got %v", slot, bin.RelocAbs32, kind)
+	}
+	//    call __x86.get_pc_thunk.ax
+	//    add  eax, _GLOBAL_OFFSET_TABLE_   ; R_386_GOTPC
+	//    lea  eax, [eax + local_arr wrt ..gotoff]   ; R_386_GOTOFF
+	if eax := uint32At(0x36) + text + 0x35; eax != got {
+		t.Errorf("GOT address mismatch; expected %v, got %v", got, eax)
+	}
+	if addr := gotRel(0x3C); addr != bss {
+		t.Errorf("GOT-relative address of local_arr mismatch; expected %v, got %v", bss, addr)
+	}
+}
+
+func TestUnsupportedReloc(t *testing.T) {
+	buf := &bytes.Buffer{}
+	warn.SetOutput(buf)
+	defer warn.SetOutput(os.Stderr)
+	r := &relocator{
+		f:      &elf.File{FileHeader: elf.FileHeader{Machine: elf.EM_386}},
+		warned: make(map[fmt.Stringer]bool),
+	}
+	syms := []elf.Symbol{{}}
+	// Relocations of unsupported types are reported once per type.
+	for i := 0; i < 100; i++ {
+		for _, typ := range []elf.R_386{elf.R_386_TLS_TPOFF, elf.R_386_IRELATIVE} {
+			rel := &reloc{Addr: bin.Address(0x1000 + 4*i), Type: uint32(typ)}
+			if err := r.apply(rel, nil, syms); err != nil {
+				t.Fatalf("unable to apply relocation; %+v", err)
+			}
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("number of warnings mismatch; expected 2, got %d:\n%s", n, buf)
+	}
+}
+
+func TestDynamicSymbols(t *testing.T) {
+	// newRelocator returns a relocator of a 64-bit ELF file, with a dynamic
+	// string table at 0x1000 and a dynamic symbol table of two symbols at
+	// 0x1010.
+	newRelocator := func() *relocator {
+		data := make([]byte, 0x10+2*elf.Sym64Size)
+		copy(data, "\x00foo\x00")
+		binary.LittleEndian.PutUint32(data[0x10+elf.Sym64Size:], 1)
+		return &relocator{
+			file: &bin.File{
+				Sections: []*bin.Section{{Addr: 0x1000, Data: data}},
+			},
+			f: &elf.File{FileHeader: elf.FileHeader{Class: elf.ELFCLASS64, ByteOrder: binary.LittleEndian}},
+		}
+	}
+	newDyn := func() map[elf.DynTag]uint64 {
+		return map[elf.DynTag]uint64{
+			elf.DT_STRTAB: 0x1000,
+			elf.DT_STRSZ:  5,
+			elf.DT_SYMTAB: 0x1010,
+			elf.DT_SYMENT: elf.Sym64Size,
+		}
+	}
+	syms, err := newRelocator().dynamicSymbols(newDyn(), 2)
+	if err != nil {
+		t.Fatalf("unable to parse dynamic symbols; %+v", err)
+	}
+	if len(syms) != 2 || syms[1].Name != "foo" {
+		t.Errorf("dynamic symbols mismatch; expected [\"\" \"foo\"], got %v", syms)
+	}
+
+	// Malformed dynamic symbol tables.
+	golden := []struct {
+		name   string
+		modify func(dyn map[elf.DynTag]uint64)
+		n      int
+		err    string
+	}{
+		{
+			name:   "unterminated symbol name",
+			modify: func(dyn map[elf.DynTag]uint64) { dyn[elf.DT_STRSZ] = 4 },
+			n:      2,
+			err:    "unable to locate NULL-terminated string",
+		},
+		{
+			name:   "symbol table entry size too small",
+			modify: func(dyn map[elf.DynTag]uint64) { dyn[elf.DT_SYMENT] = 8 },
+			n:      2,
+			err:    "invalid size of dynamic symbol table entry",
+		},
+		{
+			name:   "string table size overflow",
+			modify: func(dyn map[elf.DynTag]uint64) { dyn[elf.DT_STRSZ] = 1 << 63 },
+			n:      2,
+			err:    "invalid size",
+		},
+		{
+			name:   "symbol table out of bounds",
+			modify: func(dyn map[elf.DynTag]uint64) {},
+			n:      3,
+			err:    "unable to locate 72 bytes of data",
+		},
+		{
+			name:   "missing symbol table",
+			modify: func(dyn map[elf.DynTag]uint64) { delete(dyn, elf.DT_SYMTAB) },
+			n:      2,
+			err:    "missing DT_SYMTAB",
+		},
+	}
+	for _, g := range golden {
+		dyn := newDyn()
+		g.modify(dyn)
+		_, err := newRelocator().dynamicSymbols(dyn, g.n)
+		if err == nil {
+			t.Errorf("%s: expected error %q, got nil", g.name, g.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), g.err) {
+			t.Errorf("%s: error mismatch; expected %q, got %q", g.name, g.err, err)
+		}
+	}
+}
+
+// findSection returns the address of the section with the given name.
+func findSection(t *testing.T, file *bin.File, name string) bin.Address {
+	t.Helper()
+	for _, sect := range file.Sections {
+		if sect.Name == name {
+			return sect.Addr
+		}
+	}
+	t.Fatalf("unable to locate section %q", name)
+	return 0
+}
+
+// checkImport checks that the given address is recorded as an import of the
+// given name.
+func checkImport(t *testing.T, file *bin.File, addr bin.Address, want string) {
+	t.Helper()
+	if got, ok := file.Imports[addr]; !ok || got != want {
+		t.Errorf("import at %v mismatch; expected %q, got %q", addr, want, got)
+	}
+}
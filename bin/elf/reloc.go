@@ -0,0 +1,879 @@
+package elf
+
+import (
+	"debug/elf"
+	"fmt"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// A reloc is an ELF relocation.
+type reloc struct {
+	// Relocated address.
+	Addr bin.Address
+	// Symbol table index.
+	SymIndex uint32
+	// Relocation type.
+	Type uint32
+	// Second relocation type of composed MIPS64 relocations; or 0 if not
+	// present.
+	Type2 uint32
+	// Explicit addend of RELA relocations.
+	Addend int64
+	// Specifies whether the relocation has an explicit addend.
+	HasAddend bool
+}
+
+// relocator tracks information required to resolve relocations.
+type relocator struct {
+	// Binary executable.
+	file *bin.File
+	// ELF file.
+	f *elf.File
+	// Map from section header index to section address.
+	sectAddrs map[int]bin.Address
+	// Map from external symbol name to synthetic address.
+	externs map[string]bin.Address
+	// Start address of synthetic external symbols.
+	externStart bin.Address
+	// Address of the next synthetic external symbol.
+	externEnd bin.Address
+	// Map from GOT slot address to imported function name.
+	slots map[bin.Address]string
+	// Map from symbol address to synthetic GOT slot address, for GOT-relative
+	// relocations of defined symbols in relocatable object files.
+	gotSlots map[bin.Address]bin.Address
+	// Unsupported relocation types and machine architectures already reported.
+	warned map[fmt.Stringer]bool
+}
+
+// parseRelocs parses the relocation sections of the ELF file, and resolves the
+// relocations against symbols. References to undefined symbols are assigned
+// synthetic addresses, which are recorded as function imports; and references
+// to defined symbols are applied to the section data. GOT-relative relocations
+// of relocatable object files are resolved against a synthetic GOT, located at
+// the start of the synthetic addresses.
+//
+// Relocations of relocatable object files are static, and refer to sections by
+// the section header index of the relocation section. Relocations of shared
+// objects and executables are dynamic, and refer to virtual addresses. The
+// dynamic relocations of ELF files without relocation sections (e.g. stripped
+// of section headers) are located through the dynamic section.
+//
+// dyn specifies the value of each dynamic tag of the dynamic section, or is nil
+// if not present.
+//
+// The returned map specifies the names of imported functions, indexed by the
+// addresses of their GOT slots.
+func parseRelocs(file *bin.File, f *elf.File, sectAddrs map[int]bin.Address, dyn map[elf.DynTag]uint64) (map[bin.Address]string, error) {
+	if file.Relocs == nil {
+		file.Relocs = make(map[bin.Address]bin.RelocKind)
+	}
+	// Synthetic addresses of external symbols are located directly after the
+	// last section (or segment).
+	var end bin.Address
+	for _, sect := range file.Sections {
+		sectEnd := sect.Addr + bin.Address(sect.MemSize)
+		if end < sectEnd {
+			end = sectEnd
+		}
+	}
+	const externAlign = 16
+	if rem := end % externAlign; rem != 0 {
+		end += externAlign - rem
+	}
+	r := &relocator{
+		file:        file,
+		f:           f,
+		sectAddrs:   sectAddrs,
+		externs:     make(map[string]bin.Address),
+		externStart: end,
+		externEnd:   end,
+		slots:       make(map[bin.Address]string),
+		gotSlots:    make(map[bin.Address]bin.Address),
+		warned:      make(map[fmt.Stringer]bool),
+	}
+	hasRelocSects := false
+	for i, s := range f.Sections {
+		if s.Type != elf.SHT_REL && s.Type != elf.SHT_RELA {
+			continue
+		}
+		hasRelocSects = true
+		// Base address of relocated offsets.
+		var base bin.Address
+		if f.Type == elf.ET_REL {
+			// The sh_info field holds the section header index of the section to
+			// which the relocations apply.
+			target, ok := sectAddrs[int(s.Info)]
+			if !ok {
+				// skip relocations of non-loadable sections (e.g. debug
+				// information).
+				continue
+			}
+			base = target
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		relocs, err := parseRelocData(f, data, s.Type == elf.SHT_RELA)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, rel := range relocs {
+			rel.Addr += base
+		}
+		syms, err := r.symbols(s)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := r.applyAll(relocs, syms); err != nil {
+			return nil, errors.Wrapf(err, "unable to apply relocations of section %d (%q)", i, s.Name)
+		}
+	}
+	if !hasRelocSects {
+		if err := r.parseDynamicRelocs(dyn); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	// Add section of synthetic external symbols.
+	if r.externEnd > r.externStart {
+		size := int(r.externEnd - r.externStart)
+		sect := &bin.Section{
+			Name:    ".extern",
+			Addr:    r.externStart,
+			Data:    make([]byte, size),
+			MemSize: size,
+			Perm:    bin.PermR,
+		}
+		file.Sections = append(file.Sections, sect)
+	}
+	// Store symbol addresses in synthetic GOT slots.
+	for S, slot := range r.gotSlots {
+		if r.f.Class == elf.ELFCLASS64 {
+			r.file.Relocs[slot] = bin.RelocAbs64
+			if err := r.putUint64(slot, uint64(S)); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			continue
+		}
+		r.file.Relocs[slot] = bin.RelocAbs32
+		if err := r.putUint32(slot, uint32(S)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return r.slots, nil
+}
+
+// parseDynamicRelocs parses the dynamic relocations (DT_REL, DT_RELA and
+// DT_JMPREL) located through the dynamic section of the ELF file.
+func (r *relocator) parseDynamicRelocs(dyn map[elf.DynTag]uint64) error {
+	if dyn == nil {
+		// ELF file without dynamic section.
+		return nil
+	}
+	// Relocation tables.
+	tables := []struct {
+		// Dynamic tag of the relocation table address.
+		addrTag elf.DynTag
+		// Dynamic tag of the relocation table size.
+		sizeTag elf.DynTag
+		// Specifies whether the relocation entries have explicit addends.
+		rela bool
+	}{
+		{addrTag: elf.DT_REL, sizeTag: elf.DT_RELSZ, rela: false},
+		{addrTag: elf.DT_RELA, sizeTag: elf.DT_RELASZ, rela: true},
+		// The DT_PLTREL entry specifies the type of relocation entries
+		// referred to by DT_JMPREL; either DT_REL or DT_RELA.
+		{addrTag: elf.DT_JMPREL, sizeTag: elf.DT_PLTRELSZ, rela: elf.DynTag(dyn[elf.DT_PLTREL]) == elf.DT_RELA},
+	}
+	var relocs []*reloc
+	for _, table := range tables {
+		addr, ok := dyn[table.addrTag]
+		if !ok {
+			continue
+		}
+		size := dyn[table.sizeTag]
+		data, err := r.locate(bin.Address(addr), int(size))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		rs, err := parseRelocData(r.f, data[:size], table.rela)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		relocs = append(relocs, rs...)
+	}
+	if len(relocs) == 0 {
+		return nil
+	}
+	// Parse the dynamic symbols referenced by relocations.
+	n := 0
+	for _, rel := range relocs {
+		if n <= int(rel.SymIndex) {
+			n = int(rel.SymIndex) + 1
+		}
+	}
+	syms, err := r.dynamicSymbols(dyn, n)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := r.applyAll(relocs, syms); err != nil {
+		return errors.Wrap(err, "unable to apply dynamic relocations")
+	}
+	return nil
+}
+
+// applyAll applies the given relocations, resolving symbol references using
+// the given symbols.
+func (r *relocator) applyAll(relocs []*reloc, syms []elf.Symbol) error {
+	for i, rel := range relocs {
+		// MIPS HI16 relocations use the addend of the succeeding LO16
+		// relocation.
+		var next *reloc
+		if i+1 < len(relocs) {
+			next = relocs[i+1]
+		}
+		if err := r.apply(rel, next, syms); err != nil {
+			return errors.Wrapf(err, "unable to apply relocation %d", i)
+		}
+	}
+	return nil
+}
+
+// symbols returns the symbols of the symbol table associated with the given
+// relocation section. The symbol at index 0 is the undefined symbol.
+func (r *relocator) symbols(s *elf.Section) ([]elf.Symbol, error) {
+	if int(s.Link) >= len(r.f.Sections) {
+		return nil, errors.Errorf("invalid symbol table section index of relocation section %q; expected < %d, got %d", s.Name, len(r.f.Sections), s.Link)
+	}
+	var (
+		syms []elf.Symbol
+		err  error
+	)
+	switch typ := r.f.Sections[s.Link].Type; typ {
+	case elf.SHT_SYMTAB:
+		syms, err = r.f.Symbols()
+	case elf.SHT_DYNSYM:
+		syms, err = r.f.DynamicSymbols()
+	case elf.SHT_NULL:
+		// Relocations without associated symbol table (e.g. R_386_RELATIVE).
+		return []elf.Symbol{{}}, nil
+	default:
+		return nil, errors.Errorf("support for symbol table section type %v not yet implemented", typ)
+	}
+	if err != nil && err != elf.ErrNoSymbols {
+		return nil, errors.WithStack(err)
+	}
+	// The debug/elf package omits the undefined symbol at index 0.
+	return append([]elf.Symbol{{}}, syms...), nil
+}
+
+// parseRelocData parses the relocation entries of the given data; with
+// explicit addends if rela is set.
+func parseRelocData(f *elf.File, data []byte, rela bool) ([]*reloc, error) {
+	order := f.ByteOrder
+	// parseInfo64 parses the symbol index and relocation types of the given
+	// 64-bit r_info field.
+	parseInfo64 := func(buf []byte) (sym, typ, typ2 uint32) {
+		if f.Machine == elf.EM_MIPS {
+			// MIPS64 uses a different r_info encoding, with up to three
+			// relocation types per entry.
+			//
+			//    r_sym   uint32
+			//    r_ssym  uint8
+			//    r_type3 uint8
+			//    r_type2 uint8
+			//    r_type  uint8
+			return order.Uint32(buf), uint32(buf[7]), uint32(buf[6])
+		}
+		info := order.Uint64(buf)
+		return elf.R_SYM64(info), elf.R_TYPE64(info), 0
+	}
+	var relocs []*reloc
+	switch {
+	case f.Class == elf.ELFCLASS32 && !rela:
+		// Elf32_Rel: r_offset uint32, r_info uint32
+		for i := 0; i+8 <= len(data); i += 8 {
+			info := order.Uint32(data[i+4:])
+			rel := &reloc{
+				Addr:     bin.Address(order.Uint32(data[i:])),
+				SymIndex: elf.R_SYM32(info),
+				Type:     elf.R_TYPE32(info),
+			}
+			relocs = append(relocs, rel)
+		}
+	case f.Class == elf.ELFCLASS32 && rela:
+		// Elf32_Rela: r_offset uint32, r_info uint32, r_addend int32
+		for i := 0; i+12 <= len(data); i += 12 {
+			info := order.Uint32(data[i+4:])
+			rel := &reloc{
+				Addr:      bin.Address(order.Uint32(data[i:])),
+				SymIndex:  elf.R_SYM32(info),
+				Type:      elf.R_TYPE32(info),
+				Addend:    int64(int32(order.Uint32(data[i+8:]))),
+				HasAddend: true,
+			}
+			relocs = append(relocs, rel)
+		}
+	case f.Class == elf.ELFCLASS64 && !rela:
+		// Elf64_Rel: r_offset uint64, r_info uint64
+		for i := 0; i+16 <= len(data); i += 16 {
+			sym, typ, typ2 := parseInfo64(data[i+8:])
+			rel := &reloc{
+				Addr:     bin.Address(order.Uint64(data[i:])),
+				SymIndex: sym,
+				Type:     typ,
+				Type2:    typ2,
+			}
+			relocs = append(relocs, rel)
+		}
+	case f.Class == elf.ELFCLASS64 && rela:
+		// Elf64_Rela: r_offset uint64, r_info uint64, r_addend int64
+		for i := 0; i+24 <= len(data); i += 24 {
+			sym, typ, typ2 := parseInfo64(data[i+8:])
+			rel := &reloc{
+				Addr:      bin.Address(order.Uint64(data[i:])),
+				SymIndex:  sym,
+				Type:      typ,
+				Type2:     typ2,
+				Addend:    int64(order.Uint64(data[i+16:])),
+				HasAddend: true,
+			}
+			relocs = append(relocs, rel)
+		}
+	default:
+		return nil, errors.Errorf("support for relocations of ELF class %v not yet implemented", f.Class)
+	}
+	return relocs, nil
+}
+
+// resolve returns the address of the given symbol. Undefined symbols are
+// assigned synthetic addresses, and recorded as function imports.
+func (r *relocator) resolve(sym elf.Symbol) bin.Address {
+	switch sym.Section {
+	case elf.SHN_UNDEF:
+		if len(sym.Name) == 0 {
+			// The undefined symbol at index 0.
+			return 0
+		}
+		if addr, ok := r.externs[sym.Name]; ok {
+			return addr
+		}
+		addr := r.alloc()
+		r.externs[sym.Name] = addr
+		r.file.Imports[addr] = sym.Name
+		return addr
+	case elf.SHN_ABS:
+		return bin.Address(sym.Value)
+	}
+	if r.f.Type == elf.ET_REL {
+		// Symbol values of relocatable object files are offsets relative to the
+		// start of the section.
+		return r.sectAddrs[int(sym.Section)] + bin.Address(sym.Value)
+	}
+	return bin.Address(sym.Value)
+}
+
+// alloc allocates a pointer-sized synthetic address after the last section.
+func (r *relocator) alloc() bin.Address {
+	addr := r.externEnd
+	ptrSize := bin.Address(4)
+	if r.f.Class == elf.ELFCLASS64 {
+		ptrSize = 8
+	}
+	r.externEnd += ptrSize
+	return addr
+}
+
+// got returns the address of the synthetic global offset table (GOT) of
+// relocatable object files, which is located at the start of the synthetic
+// external symbols.
+func (r *relocator) got() bin.Address {
+	return r.externStart
+}
+
+// gotSlot returns the address of the GOT slot of the given symbol. The
+// synthetic addresses of undefined symbols, which are recorded as function
+// imports, are used as their GOT slots; analogous to the import address table
+// of PE files. GOT slots of defined symbols are allocated within the synthetic
+// GOT, and hold the address of the symbol.
+func (r *relocator) gotSlot(sym elf.Symbol) bin.Address {
+	S := r.resolve(sym)
+	if sym.Section == elf.SHN_UNDEF {
+		return S
+	}
+	if slot, ok := r.gotSlots[S]; ok {
+		return slot
+	}
+	slot := r.alloc()
+	r.gotSlots[S] = slot
+	return slot
+}
+
+// apply applies the given relocation. Next specifies the succeeding relocation
+// in the same relocation section; or nil if not present.
+func (r *relocator) apply(rel, next *reloc, syms []elf.Symbol) error {
+	if int(rel.SymIndex) >= len(syms) {
+		return errors.Errorf("invalid symbol index; expected < %d, got %d", len(syms), rel.SymIndex)
+	}
+	sym := syms[rel.SymIndex]
+	// Relocation calculation.
+	//
+	//    A     addend
+	//    B     base address (0)
+	//    G     offset of the GOT slot of the symbol within the GOT
+	//    GOT   address of the GOT
+	//    P     place; relocated address
+	//    S     symbol address
+	P := rel.Addr
+	switch r.f.Machine {
+	case elf.EM_386:
+		return r.apply386(rel, sym, P)
+	case elf.EM_X86_64:
+		return r.applyX86_64(rel, sym, P)
+	case elf.EM_MIPS:
+		return r.applyMIPS(rel, next, syms, P)
+	case elf.EM_PPC:
+		return r.applyPPC(rel, sym, P)
+	}
+	if !r.warned[r.f.Machine] {
+		r.warned[r.f.Machine] = true
+		warn.Printf("support for relocations of machine architecture %v not yet implemented", r.f.Machine)
+	}
+	return nil
+}
+
+// apply386 applies the given x86 relocation.
+func (r *relocator) apply386(rel *reloc, sym elf.Symbol, P bin.Address) error {
+	switch typ := elf.R_386(rel.Type); typ {
+	case elf.R_386_NONE, elf.R_386_COPY:
+		// nothing to do.
+	case elf.R_386_32:
+		// S + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, uint32(S)+A)
+	case elf.R_386_PC32, elf.R_386_PLT32:
+		// S + A - P
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		return r.putUint32(P, uint32(S)+A-uint32(P))
+	case elf.R_386_GOT32, elf.R_386_GOT32X:
+		// G + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		G := r.gotSlot(sym) - r.got()
+		return r.putUint32(P, uint32(G)+A)
+	case elf.R_386_GOTOFF:
+		// S + A - GOT
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		return r.putUint32(P, uint32(S)+A-uint32(r.got()))
+	case elf.R_386_GOTPC:
+		// GOT + A - P
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return r.putUint32(P, uint32(r.got())+A-uint32(P))
+	case elf.R_386_GLOB_DAT, elf.R_386_JMP_SLOT:
+		r.importSlot(sym, P)
+	case elf.R_386_RELATIVE:
+		// B + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, A)
+	default:
+		r.unsupported(typ, P)
+	}
+	return nil
+}
+
+// applyX86_64 applies the given x86-64 relocation.
+func (r *relocator) applyX86_64(rel *reloc, sym elf.Symbol, P bin.Address) error {
+	switch typ := elf.R_X86_64(rel.Type); typ {
+	case elf.R_X86_64_NONE, elf.R_X86_64_COPY:
+		// nothing to do.
+	case elf.R_X86_64_64:
+		// S + A
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		r.file.Relocs[P] = bin.RelocAbs64
+		return r.putUint64(P, uint64(S)+A)
+	case elf.R_X86_64_32, elf.R_X86_64_32S:
+		// S + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, uint32(S)+A)
+	case elf.R_X86_64_PC32, elf.R_X86_64_PLT32:
+		// S + A - P
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		return r.putUint32(P, uint32(S)+A-uint32(P))
+	case elf.R_X86_64_PC64:
+		// S + A - P
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		return r.putUint64(P, uint64(S)+A-uint64(P))
+	case elf.R_X86_64_GOT32:
+		// G + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		G := r.gotSlot(sym) - r.got()
+		return r.putUint32(P, uint32(G)+A)
+	case elf.R_X86_64_GOT64:
+		// G + A
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		G := r.gotSlot(sym) - r.got()
+		return r.putUint64(P, uint64(G)+A)
+	case elf.R_X86_64_GOTPCREL, elf.R_X86_64_GOTPCRELX, elf.R_X86_64_REX_GOTPCRELX:
+		// G + GOT + A - P
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		slot := r.gotSlot(sym)
+		return r.putUint32(P, uint32(slot)+A-uint32(P))
+	case elf.R_X86_64_GOTPCREL64:
+		// G + GOT + A - P
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		slot := r.gotSlot(sym)
+		return r.putUint64(P, uint64(slot)+A-uint64(P))
+	case elf.R_X86_64_GOTOFF64:
+		// S + A - GOT
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		return r.putUint64(P, uint64(S)+A-uint64(r.got()))
+	case elf.R_X86_64_GOTPC32:
+		// GOT + A - P
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return r.putUint32(P, uint32(r.got())+A-uint32(P))
+	case elf.R_X86_64_GOTPC64:
+		// GOT + A - P
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return r.putUint64(P, uint64(r.got())+A-uint64(P))
+	case elf.R_X86_64_GLOB_DAT, elf.R_X86_64_JMP_SLOT:
+		r.importSlot(sym, P)
+	case elf.R_X86_64_RELATIVE:
+		// B + A
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		r.file.Relocs[P] = bin.RelocAbs64
+		return r.putUint64(P, A)
+	default:
+		r.unsupported(typ, P)
+	}
+	return nil
+}
+
+// rMIPSJumpSlot is the MIPS relocation type of PLT entries (R_MIPS_JUMP_SLOT),
+// which is not defined by debug/elf.
+const rMIPSJumpSlot elf.R_MIPS = 127
+
+// applyMIPS applies the given MIPS relocation. Next specifies the succeeding
+// relocation in the same relocation section; or nil if not present.
+func (r *relocator) applyMIPS(rel, next *reloc, syms []elf.Symbol, P bin.Address) error {
+	sym := syms[rel.SymIndex]
+	switch typ := elf.R_MIPS(rel.Type); typ {
+	case elf.R_MIPS_NONE:
+		// nothing to do.
+	case elf.R_MIPS_32:
+		// S + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, uint32(S)+A)
+	case elf.R_MIPS_64:
+		// S + A
+		A, err := r.addend64(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		r.file.Relocs[P] = bin.RelocAbs64
+		return r.putUint64(P, uint64(S)+A)
+	case elf.R_MIPS_REL32:
+		// B + A; or S + A for symbol references.
+		var S bin.Address
+		if rel.SymIndex != 0 {
+			S = r.resolve(sym)
+		}
+		if elf.R_MIPS(rel.Type2) == elf.R_MIPS_64 {
+			// Composed 64-bit relocation of MIPS64 (R_MIPS_REL32/R_MIPS_64).
+			A, err := r.addend64(rel)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			r.file.Relocs[P] = bin.RelocAbs64
+			return r.putUint64(P, uint64(S)+A)
+		}
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, uint32(S)+A)
+	case elf.R_MIPS_26:
+		// ((A << 2) | (P & 0xF0000000) + S) >> 2
+		inst, err := r.getUint32(P)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		A := (inst & 0x03FFFFFF) << 2
+		if rel.HasAddend {
+			A = uint32(rel.Addend)
+		}
+		S := r.resolve(sym)
+		v := ((A | uint32(P)&0xF0000000) + uint32(S)) >> 2
+		return r.putUint32(P, inst&^0x03FFFFFF|v&0x03FFFFFF)
+	case elf.R_MIPS_HI16:
+		// ((AHL + S) - (short)(AHL + S)) >> 16, where AHL is computed from the
+		// addends of the HI16 relocation and the succeeding LO16 relocation.
+		inst, err := r.getUint32(P)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		AHL := (inst & 0xFFFF) << 16
+		if next != nil && elf.R_MIPS(next.Type) == elf.R_MIPS_LO16 {
+			lo, err := r.getUint32(next.Addr)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			AHL += uint32(int32(int16(lo)))
+		}
+		S := r.resolve(sym)
+		v := AHL + uint32(S)
+		hi := (v - uint32(int32(int16(v)))) >> 16
+		return r.putUint32(P, inst&^0xFFFF|hi&0xFFFF)
+	case elf.R_MIPS_LO16:
+		// AHL + S
+		inst, err := r.getUint32(P)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		A := uint32(int32(int16(inst)))
+		S := r.resolve(sym)
+		v := A + uint32(S)
+		return r.putUint32(P, inst&^0xFFFF|v&0xFFFF)
+	case rMIPSJumpSlot:
+		r.importSlot(sym, P)
+	default:
+		r.unsupported(typ, P)
+	}
+	return nil
+}
+
+// applyPPC applies the given PowerPC relocation.
+func (r *relocator) applyPPC(rel *reloc, sym elf.Symbol, P bin.Address) error {
+	switch typ := elf.R_PPC(rel.Type); typ {
+	case elf.R_PPC_NONE, elf.R_PPC_COPY:
+		// nothing to do.
+	case elf.R_PPC_ADDR32:
+		// S + A
+		A, err := r.addend32(rel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, uint32(S)+A)
+	case elf.R_PPC_ADDR16_LO:
+		// #lo(S + A)
+		S := r.resolve(sym)
+		v := uint32(S) + uint32(rel.Addend)
+		return r.putUint16(P, uint16(v))
+	case elf.R_PPC_ADDR16_HI:
+		// #hi(S + A)
+		S := r.resolve(sym)
+		v := uint32(S) + uint32(rel.Addend)
+		return r.putUint16(P, uint16(v>>16))
+	case elf.R_PPC_ADDR16_HA:
+		// #ha(S + A)
+		S := r.resolve(sym)
+		v := uint32(S) + uint32(rel.Addend)
+		return r.putUint16(P, uint16((v+0x8000)>>16))
+	case elf.R_PPC_REL24, elf.R_PPC_PLTREL24:
+		// (S + A - P) >> 2
+		inst, err := r.getUint32(P)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		S := r.resolve(sym)
+		v := uint32(S) + uint32(rel.Addend) - uint32(P)
+		return r.putUint32(P, inst&^0x03FFFFFC|v&0x03FFFFFC)
+	case elf.R_PPC_REL32:
+		// S + A - P
+		S := r.resolve(sym)
+		return r.putUint32(P, uint32(S)+uint32(rel.Addend)-uint32(P))
+	case elf.R_PPC_GLOB_DAT, elf.R_PPC_JMP_SLOT:
+		r.importSlot(sym, P)
+	case elf.R_PPC_RELATIVE:
+		// B + A
+		r.file.Relocs[P] = bin.RelocAbs32
+		return r.putUint32(P, uint32(rel.Addend))
+	default:
+		r.unsupported(typ, P)
+	}
+	return nil
+}
+
+// unsupported reports that support for the given relocation type, first
+// encountered at P, is not yet implemented. Each relocation type is reported
+// once, as shared objects may contain thousands of relocations of the same
+// type.
+func (r *relocator) unsupported(typ fmt.Stringer, P bin.Address) {
+	if r.warned[typ] {
+		return
+	}
+	r.warned[typ] = true
+	warn.Printf("support for relocation type %v at %v not yet implemented", typ, P)
+}
+
+// importSlot records the given GOT slot of an undefined symbol as a function
+// import.
+func (r *relocator) importSlot(sym elf.Symbol, P bin.Address) {
+	if sym.Section != elf.SHN_UNDEF || len(sym.Name) == 0 {
+		return
+	}
+	if _, ok := r.file.Imports[P]; !ok {
+		r.file.Imports[P] = sym.Name
+	}
+	r.slots[P] = sym.Name
+}
+
+// addend32 returns the 32-bit addend of the given relocation; either explicit
+// (RELA) or implicit (REL) as stored at the relocated address.
+func (r *relocator) addend32(rel *reloc) (uint32, error) {
+	if rel.HasAddend {
+		return uint32(rel.Addend), nil
+	}
+	return r.getUint32(rel.Addr)
+}
+
+// addend64 returns the 64-bit addend of the given relocation; either explicit
+// (RELA) or implicit (REL) as stored at the relocated address.
+func (r *relocator) addend64(rel *reloc) (uint64, error) {
+	if rel.HasAddend {
+		return uint64(rel.Addend), nil
+	}
+	return r.getUint64(rel.Addr)
+}
+
+// getUint32 returns the 32-bit value at the given address.
+func (r *relocator) getUint32(addr bin.Address) (uint32, error) {
+	buf, err := r.locate(addr, 4)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return r.f.ByteOrder.Uint32(buf), nil
+}
+
+// getUint64 returns the 64-bit value at the given address.
+func (r *relocator) getUint64(addr bin.Address) (uint64, error) {
+	buf, err := r.locate(addr, 8)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return r.f.ByteOrder.Uint64(buf), nil
+}
+
+// putUint16 stores the 16-bit value at the given address of every section (and
+// segment) containing the address.
+func (r *relocator) putUint16(addr bin.Address, v uint16) error {
+	return r.put(addr, 2, func(buf []byte) { r.f.ByteOrder.PutUint16(buf, v) })
+}
+
+// putUint32 stores the 32-bit value at the given address of every section (and
+// segment) containing the address.
+func (r *relocator) putUint32(addr bin.Address, v uint32) error {
+	return r.put(addr, 4, func(buf []byte) { r.f.ByteOrder.PutUint32(buf, v) })
+}
+
+// putUint64 stores the 64-bit value at the given address of every section (and
+// segment) containing the address.
+func (r *relocator) putUint64(addr bin.Address, v uint64) error {
+	return r.put(addr, 8, func(buf []byte) { r.f.ByteOrder.PutUint64(buf, v) })
+}
+
+// put invokes store on the size bytes at the given address of every section
+// (and segment) containing the address.
+func (r *relocator) put(addr bin.Address, size int, store func(buf []byte)) error {
+	found := false
+	for _, sect := range r.file.Sections {
+		if addr < sect.Addr || sect.Addr+bin.Address(len(sect.Data)) < addr+bin.Address(size) {
+			continue
+		}
+		found = true
+		store(sect.Data[addr-sect.Addr:])
+	}
+	if !found {
+		return errors.Errorf("unable to locate %d bytes of data at relocated address %v", size, addr)
+	}
+	return nil
+}
+
+// locate returns the size bytes at the given address.
+func (r *relocator) locate(addr bin.Address, size int) ([]byte, error) {
+	if size < 0 || addr+bin.Address(size) < addr {
+		return nil, errors.Errorf("invalid size %d of data at relocated address %v", size, addr)
+	}
+	for _, sect := range r.file.Sections {
+		if addr < sect.Addr || sect.Addr+bin.Address(len(sect.Data)) < addr+bin.Address(size) {
+			continue
+		}
+		return sect.Data[addr-sect.Addr:], nil
+	}
+	return nil, errors.Errorf("unable to locate %d bytes of data at relocated address %v", size, addr)
+}
@@ -0,0 +1,15 @@
+extern int ext_var;
+extern void ext_func(void);
+
+int def_var = 42;
+
+int get(void) {
+	ext_func();
+	return ext_var + def_var;
+}
+
+static int local_arr[4];
+
+int *addr(void) {
+	return local_arr;
+}
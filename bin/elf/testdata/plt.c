@@ -0,0 +1,7 @@
+#include <stdio.h>
+#include <stdlib.h>
+
+void _start(void) {
+	puts("hello");
+	exit(0);
+}
@@ -0,0 +1,7 @@
+__thread int tls_data = 42;
+__thread int tls_bss;
+int data = 1;
+
+void _start(void) {
+	tls_bss = tls_data + data;
+}
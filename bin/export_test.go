@@ -0,0 +1,7 @@
+package bin
+
+// Export unexported functions for benchmarks.
+var (
+	LocateCode = locateCode
+	LocateData = locateData
+)
@@ -1,9 +1,12 @@
 package bin
 
 import (
+	"debug/dwarf"
+	"encoding/binary"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -12,31 +15,89 @@ import (
 type File struct {
 	// Machine architecture specifying the assembly instruction set.
 	Arch Arch
+	// Base address of the executable; i.e. the address at which the executable
+	// is loaded (e.g. PE ImageBase).
+	Base Address
 	// Entry point of the executable.
 	Entry Address
 	// Sections (and segments) of the executable. Sections are sorted by address
 	// in ascending order, prioritizing longer sections; and disambiguating
-	// sections at same address and length by ascending section name.
+	// sections at same address and length by ascending section name. Use
+	// BuildIndex after modifying the sections.
 	Sections []*Section
 	// Function imports.
 	Imports map[Address]string
 	// Function exports.
 	Exports map[Address]string
+	// Forwarded function exports; map from export name to forwarder (e.g.
+	// "NTDLL.RtlAllocateHeap"). Forwarded exports are resolved by the loader to
+	// functions of other libraries, and thus have no associated address.
+	Forwards map[string]string
+	// Relocations; map from relocated address to relocation kind. The value
+	// stored at a relocated address is an absolute address (or part thereof).
+	Relocs map[Address]RelocKind
+	// Relocatable specifies whether the executable may be loaded at a different
+	// base address; i.e. whether Relocs records every address to adjust. The
+	// relocation information of executables linked at a fixed address (e.g. PE
+	// IMAGE_FILE_RELOCS_STRIPPED or ELF ET_EXEC) is stripped or not present.
+	Relocatable bool
+	// Symbols of the symbol table, sorted by address in ascending order.
+	Symbols []*Symbol
+	// Thread local storage (TLS) callbacks; functions invoked by the loader
+	// before the entry point.
+	TLSCallbacks []Address
+	// Function table used for exception handling and stack unwinding, sorted by
+	// start address in ascending order.
+	RuntimeFuncs []*RuntimeFunc
+	// Addresses of known data regions (e.g. PE resource data), sorted in
+	// ascending order.
+	DataAddrs []Address
+	// DWARF debug information; or nil if not present.
+	DWARF *dwarf.Data
+	// Offset from the link-time addresses of the DWARF debug information to the
+	// addresses of the executable; non-zero if the executable has been rebased.
+	DWARFDelta Address
+
+	// Index used for fast address to section lookup; guarded by idxMu.
+	idx   *fileIndex
+	idxMu sync.Mutex
+}
+
+// ByteOrder returns the byte order of the binary executable, as specified by
+// its machine architecture.
+func (file *File) ByteOrder() binary.ByteOrder {
+	return file.Arch.ByteOrder()
 }
 
 // Code returns the code starting at the specified address of the binary
 // executable.
+//
+// Code panics if the address is not located within an executable section. Use
+// CodeAt to handle unmapped addresses gracefully.
 func (file *File) Code(addr Address) []byte {
-	if code, ok := locateCode(addr, file.Sections); ok {
-		return code
+	code, err := file.CodeAt(addr)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
+
+// CodeAt returns the code starting at the specified address of the binary
+// executable, extending to the end of the section containing the address.
+func (file *File) CodeAt(addr Address) ([]byte, error) {
+	if sect, ok := file.index().code.lookup(addr); ok {
+		return sect.Data[addr-sect.Addr:], nil
 	}
-	panic(fmt.Errorf("unable to locate code at address %v", addr))
+	return nil, errors.Errorf("unable to locate code at address %v", addr)
 }
 
 // locateCode tries to locate the code starting at the specified address by
 // searching through the given sections. The boolean return value indicates
 // success.
 //
+// Note, locateCode performs a linear scan of the sections; File.CodeAt uses the
+// section index instead.
+//
 // pre-condition: sects must be sorted by address in ascending order,
 // prioritizing longer sections with identical addresses.
 func locateCode(addr Address, sects []*Section) ([]byte, bool) {
@@ -55,18 +116,39 @@ func locateCode(addr Address, sects []*Section) ([]byte, bool) {
 
 // Data returns the data starting at the specified address of the binary
 // executable.
+//
+// Data panics if the address is not located within a section. Use DataAt to
+// handle unmapped addresses gracefully.
 func (file *File) Data(addr Address) []byte {
-	data, ok := locateData(addr, file.Sections)
-	if ok {
-		return data
+	data, err := file.DataAt(addr)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// DataAt returns the data starting at the specified address of the binary
+// executable, extending to the end of the section containing the address.
+// Uninitialized data (e.g. .bss) reads as zero; the returned zero data is
+// shared between calls and must not be modified.
+func (file *File) DataAt(addr Address) ([]byte, error) {
+	idx := file.index()
+	if sect, ok := idx.data.lookup(addr); ok {
+		return sect.Data[addr-sect.Addr:], nil
+	}
+	if sect, ok := idx.mem.lookup(addr); ok {
+		return idx.zeroData(sect, addr), nil
 	}
-	panic(fmt.Errorf("unable to locate data at address %v", addr))
+	return nil, errors.Errorf("unable to locate data at address %v", addr)
 }
 
 // locateData tries to locate the data starting at the specified address by
 // searching through the given sections. The boolean return value indicates
 // success.
 //
+// Note, locateData performs a linear scan of the sections; File.DataAt uses the
+// section index instead.
+//
 // pre-condition: sects must be sorted by address in ascending order,
 // prioritizing longer sections with identical addresses.
 func locateData(addr Address, sects []*Section) ([]byte, bool) {
@@ -79,6 +161,251 @@ func locateData(addr Address, sects []*Section) ([]byte, bool) {
 	return nil, false
 }
 
+// FindSection returns the section containing the specified address of the
+// binary executable, including uninitialized data (e.g. .bss). The boolean
+// return value indicates success.
+func (file *File) FindSection(addr Address) (*Section, bool) {
+	return file.index().mem.lookup(addr)
+}
+
+// ReadAt reads len(buf) bytes starting at the specified address of the binary
+// executable. The read may span adjacent sections, and uninitialized data (e.g.
+// .bss) reads as zero. An error is returned if any part of the address range is
+// not mapped.
+func (file *File) ReadAt(buf []byte, addr Address) error {
+	for n := 0; n < len(buf); {
+		cur := addr + Address(n)
+		sect, ok := file.index().mem.lookup(cur)
+		if !ok {
+			if n == 0 {
+				return errors.Errorf("unable to locate data at address %v", cur)
+			}
+			return errors.Errorf("unable to read %d bytes at address %v; address %v not mapped", len(buf), addr, cur)
+		}
+		dst := buf[n:]
+		if max := sect.End() - cur; Address(len(dst)) > max {
+			dst = dst[:max]
+		}
+		offset := cur - sect.Addr
+		m := 0
+		if offset < Address(len(sect.Data)) {
+			m = copy(dst, sect.Data[offset:])
+		}
+		// Zero-fill uninitialized data.
+		for i := m; i < len(dst); i++ {
+			dst[i] = 0
+		}
+		n += len(dst)
+	}
+	return nil
+}
+
+// Uint8 reads an 8-bit unsigned integer at the specified address of the binary
+// executable.
+func (file *File) Uint8(addr Address) (uint8, error) {
+	var buf [1]byte
+	if err := file.ReadAt(buf[:], addr); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return buf[0], nil
+}
+
+// Uint16 reads a 16-bit unsigned integer at the specified address of the
+// binary executable, using the byte order of the binary executable.
+func (file *File) Uint16(addr Address) (uint16, error) {
+	var buf [2]byte
+	if err := file.ReadAt(buf[:], addr); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return file.ByteOrder().Uint16(buf[:]), nil
+}
+
+// Uint32 reads a 32-bit unsigned integer at the specified address of the
+// binary executable, using the byte order of the binary executable.
+func (file *File) Uint32(addr Address) (uint32, error) {
+	var buf [4]byte
+	if err := file.ReadAt(buf[:], addr); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return file.ByteOrder().Uint32(buf[:]), nil
+}
+
+// Uint64 reads a 64-bit unsigned integer at the specified address of the
+// binary executable, using the byte order of the binary executable.
+func (file *File) Uint64(addr Address) (uint64, error) {
+	var buf [8]byte
+	if err := file.ReadAt(buf[:], addr); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return file.ByteOrder().Uint64(buf[:]), nil
+}
+
+// Uintptr reads a pointer-sized unsigned integer at the specified address of
+// the binary executable, using the bit size and byte order of the machine
+// architecture. The size in bytes of the pointer is returned as well.
+func (file *File) Uintptr(addr Address) (uint64, int, error) {
+	bits, ok := bitSize[file.Arch]
+	if !ok {
+		return 0, 0, errors.Errorf("support for machine architecture %v not yet implemented", uint16(file.Arch))
+	}
+	switch bits {
+	case 16:
+		v, err := file.Uint16(addr)
+		return uint64(v), 2, errors.WithStack(err)
+	case 32:
+		v, err := file.Uint32(addr)
+		return uint64(v), 4, errors.WithStack(err)
+	case 64:
+		v, err := file.Uint64(addr)
+		return v, 8, errors.WithStack(err)
+	default:
+		return 0, 0, errors.Errorf("support for machine architecture with bit size %d not yet implemented", bits)
+	}
+}
+
+// Rebase relocates the binary executable to the given base address. The
+// relocations of the binary executable are applied to the section data, and the
+// entry point, imports, exports, relocations and section addresses are shifted
+// accordingly.
+//
+// An error is returned if the binary executable is not relocatable, in which
+// case the binary executable is left unchanged.
+func (file *File) Rebase(base Address) error {
+	delta := base - file.Base
+	if delta == 0 {
+		return nil
+	}
+	if !file.Relocatable {
+		return errors.Errorf("unable to rebase binary executable from %v to %v; relocation information stripped or not present", file.Base, base)
+	}
+	// Validate relocations before modifying section data.
+	for addr, kind := range file.Relocs {
+		size, err := kind.Size()
+		if err != nil {
+			return errors.Wrapf(err, "invalid relocation at address %v", addr)
+		}
+		if kind == RelocSeg16 && delta&0xF != 0 {
+			return errors.Errorf("invalid rebase delta %v of segment relocation at address %v; expected multiple of 16", delta, addr)
+		}
+		if !file.relocMapped(addr, size) {
+			return errors.Errorf("unable to locate %d bytes of data at relocated address %v", size, addr)
+		}
+	}
+	// Apply relocations to section data.
+	for addr, kind := range file.Relocs {
+		if err := file.applyReloc(addr, kind, delta); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	// Shift addresses.
+	if file.Entry != 0 {
+		file.Entry += delta
+	}
+	file.Imports = rebaseAddrs(file.Imports, delta)
+	file.Exports = rebaseAddrs(file.Exports, delta)
+	if file.Relocs != nil {
+		relocs := make(map[Address]RelocKind)
+		for addr, kind := range file.Relocs {
+			relocs[addr+delta] = kind
+		}
+		file.Relocs = relocs
+	}
+	for _, sym := range file.Symbols {
+		if sym.Kind == SymbolTLS {
+			// Offset into the thread-local storage template.
+			continue
+		}
+		sym.Addr += delta
+	}
+	for i := range file.TLSCallbacks {
+		file.TLSCallbacks[i] += delta
+	}
+	for i := range file.DataAddrs {
+		file.DataAddrs[i] += delta
+	}
+	for _, f := range file.RuntimeFuncs {
+		f.Start += delta
+		f.End += delta
+		f.UnwindInfo += delta
+		if f.Parent != 0 {
+			f.Parent += delta
+		}
+	}
+	for _, sect := range file.Sections {
+		sect.Addr += delta
+	}
+	if file.DWARF != nil {
+		file.DWARFDelta += delta
+	}
+	file.Base = base
+	file.BuildIndex()
+	return nil
+}
+
+// applyReloc applies the relocation of the given kind at the specified address,
+// adjusting the stored value by delta. The relocation is applied to every
+// section (and segment) containing the relocated address.
+//
+// The relocation is validated by Rebase prior to invocation.
+func (file *File) applyReloc(addr Address, kind RelocKind, delta Address) error {
+	order := file.ByteOrder()
+	size, err := kind.Size()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, sect := range file.Sections {
+		if !sect.containsReloc(addr, size) {
+			continue
+		}
+		buf := sect.Data[addr-sect.Addr:]
+		switch kind {
+		case RelocAbs32:
+			order.PutUint32(buf, order.Uint32(buf)+uint32(delta))
+		case RelocAbs64:
+			order.PutUint64(buf, order.Uint64(buf)+uint64(delta))
+		case RelocHigh16:
+			order.PutUint16(buf, order.Uint16(buf)+uint16(delta>>16))
+		case RelocLow16:
+			order.PutUint16(buf, order.Uint16(buf)+uint16(delta))
+		case RelocSeg16:
+			order.PutUint16(buf, order.Uint16(buf)+uint16(delta>>4))
+		default:
+			return errors.Errorf("support for relocation kind %v not yet implemented", kind)
+		}
+	}
+	return nil
+}
+
+// relocMapped reports whether the size bytes at the relocated address are
+// contained within the data of any section.
+func (file *File) relocMapped(addr Address, size int) bool {
+	for _, sect := range file.Sections {
+		if sect.containsReloc(addr, size) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsReloc reports whether the size bytes at the relocated address are
+// contained within the data of the section.
+func (sect *Section) containsReloc(addr Address, size int) bool {
+	return sect.Addr <= addr && addr+Address(size) <= sect.Addr+Address(len(sect.Data))
+}
+
+// rebaseAddrs returns a copy of the given address map with addresses shifted by
+// delta.
+func rebaseAddrs(m map[Address]string, delta Address) map[Address]string {
+	if m == nil {
+		return nil
+	}
+	n := make(map[Address]string, len(m))
+	for addr, name := range m {
+		n[addr+delta] = name
+	}
+	return n
+}
+
 //go:generate stringer -linecomment -type Arch
 //go:generate string2enum -samepkg -linecomment -type Arch
 
@@ -93,7 +420,8 @@ const (
 	// ArchX86_64 represents the 64-bit x86-64 machine architecture, as used by
 	// Intel and AMD.
 	ArchX86_64 // x86_64
-	// ArchMIPS_32 represents the 32-bit MIPS machine architecture.
+	// ArchMIPS_32 represents the 32-bit MIPS machine architecture encoded as
+	// little endian.
 	ArchMIPS_32 // MIPS_32
 	// ArchARM_32 represents the 32-bit ARM machine architecture.
 	ArchARM_32 // ARM_32
@@ -107,22 +435,40 @@ const (
 	// ArchPowerPC_64LE represents the 64-bit PowerPC machine architecture
 	// encoded as little endian.
 	ArchPowerPC_64LE // PowerPC_64 little endian
+	// ArchMIPS_32BE represents the 32-bit MIPS machine architecture encoded as
+	// big endian.
+	ArchMIPS_32BE // MIPS_32 big endian
+	// ArchMIPS_64LE represents the 64-bit MIPS machine architecture encoded as
+	// little endian.
+	ArchMIPS_64LE // MIPS_64 little endian
+	// ArchMIPS_64BE represents the 64-bit MIPS machine architecture encoded as
+	// big endian.
+	ArchMIPS_64BE // MIPS_64 big endian
+	// ArchX86_16 represents the 16-bit x86 machine architecture, as used by
+	// DOS and 16-bit Windows executables in real mode or 16-bit protected
+	// mode.
+	ArchX86_16 // x86_16
 
 	// First and last machine architectures.
 	archFirst = ArchX86_32
-	archLast  = ArchPowerPC_64LE
+	archLast  = ArchX86_16
 )
 
 // bitSize maps from machine architecture to bit size.
 var bitSize = map[Arch]int{
+	// 16-bit architectures.
+	ArchX86_16: 16,
 	// 32-bit architectures.
 	ArchX86_32:     32,
 	ArchMIPS_32:    32,
+	ArchMIPS_32BE:  32,
 	ArchPowerPC_32: 32,
 	ArchARM_32:     32,
 	// 64-bit architectures.
 	ArchARM_64:       64,
 	ArchX86_64:       64,
+	ArchMIPS_64LE:    64,
+	ArchMIPS_64BE:    64,
 	ArchPowerPC_64BE: 64,
 	ArchPowerPC_64LE: 64,
 }
@@ -135,6 +481,22 @@ func (arch Arch) BitSize() int {
 	panic(fmt.Errorf("support for machine architecture %v not yet implemented", uint16(arch)))
 }
 
+// bigEndian specifies the set of machine architectures encoded as big endian.
+var bigEndian = map[Arch]bool{
+	ArchMIPS_32BE:    true,
+	ArchMIPS_64BE:    true,
+	ArchPowerPC_32:   true,
+	ArchPowerPC_64BE: true,
+}
+
+// ByteOrder returns the byte order of the machine architecture.
+func (arch Arch) ByteOrder() binary.ByteOrder {
+	if bigEndian[arch] {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
 // Set sets arch to the machine architecture represented by s.
 func (arch *Arch) Set(s string) (err error) {
 	defer func() {
@@ -185,6 +547,16 @@ type Section struct {
 	Perm Perm
 }
 
+// End returns the end address of the section in memory, including
+// uninitialized data.
+func (sect *Section) End() Address {
+	size := len(sect.Data)
+	if sect.MemSize > size {
+		size = sect.MemSize
+	}
+	return sect.Addr + Address(size)
+}
+
 // Perm specifies the access permissions of a segment or section in memory.
 type Perm uint8
 
@@ -214,3 +586,35 @@ func (perm Perm) String() string {
 	}
 	return r + w + x
 }
+
+// Set sets perm to the access permissions represented by s; e.g. "r-x" or
+// "rw".
+func (perm *Perm) Set(s string) error {
+	var p Perm
+	for _, r := range s {
+		switch r {
+		case 'r', 'R':
+			p |= PermR
+		case 'w', 'W':
+			p |= PermW
+		case 'x', 'X':
+			p |= PermX
+		case '-':
+			// no permission.
+		default:
+			return errors.Errorf("invalid access permission %q in %q; expected 'r', 'w', 'x' or '-'", r, s)
+		}
+	}
+	*perm = p
+	return nil
+}
+
+// UnmarshalText unmarshals the text into perm.
+func (perm *Perm) UnmarshalText(text []byte) error {
+	return perm.Set(string(text))
+}
+
+// MarshalText returns the textual representation of perm.
+func (perm Perm) MarshalText() ([]byte, error) {
+	return []byte(perm.String()), nil
+}
@@ -0,0 +1,236 @@
+package bin_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	_ "github.com/decomp/exp/bin/coff" // register COFF decoder
+	_ "github.com/decomp/exp/bin/elf"  // register ELF decoder
+)
+
+// benchPaths specifies the binary executables used for benchmarks, as built by
+// the Makefile of the lift testdata directory.
+var benchPaths = []string{
+	"../lift/x86/testdata/x86_32/format/format_elf.o",
+	"../lift/x86/testdata/x86_32/format/format_elf.so",
+	"../lift/x86/testdata/x86_32/format/format_elf.out",
+	"../lift/x86/testdata/x86_32/format/format.coff",
+	"../lift/x86/testdata/x86_64/format/format_elf.o",
+	"../lift/x86/testdata/x86_64/format/format_elf.so",
+	"../lift/x86/testdata/x86_64/format/format_elf.out",
+	"../lift/x86/testdata/x86_64/format/format.coff",
+	"../lift/x86/testdata/x86_32/import/import.out",
+	"../lift/x86/testdata/x86_64/import/import.out",
+}
+
+func TestRebase(t *testing.T) {
+	// newFile returns a relocatable 32-bit x86 executable at base address
+	// 0x1000, with relocations of each kind.
+	newFile := func(relocs map[bin.Address]bin.RelocKind) *bin.File {
+		data := make([]byte, 0x20)
+		binary.LittleEndian.PutUint32(data[0x00:], 0x1010)
+		binary.LittleEndian.PutUint64(data[0x08:], 0x1018)
+		binary.LittleEndian.PutUint16(data[0x10:], 0x0000)
+		binary.LittleEndian.PutUint16(data[0x12:], 0x1000)
+		binary.LittleEndian.PutUint16(data[0x14:], 0x0100)
+		return &bin.File{
+			Arch:  bin.ArchX86_32,
+			Base:  0x1000,
+			Entry: 0x1004,
+			Sections: []*bin.Section{
+				{Name: ".data", Addr: 0x1000, Data: data, FileSize: len(data), MemSize: len(data), Perm: bin.PermR | bin.PermW | bin.PermX},
+			},
+			Imports: map[bin.Address]string{0x1018: "foo"},
+			Exports: map[bin.Address]string{0x1004: "bar"},
+			Symbols: []*bin.Symbol{
+				{Name: "bar", Addr: 0x1004, Kind: bin.SymbolFunc},
+				{Name: "baz", Addr: 0x0004, Kind: bin.SymbolTLS},
+			},
+			Relocs:      relocs,
+			Relocatable: true,
+		}
+	}
+	relocs := map[bin.Address]bin.RelocKind{
+		0x1000: bin.RelocAbs32,
+		0x1008: bin.RelocAbs64,
+		0x1010: bin.RelocHigh16,
+		0x1012: bin.RelocLow16,
+		0x1014: bin.RelocSeg16,
+	}
+
+	// Relocatable executable.
+	file := newFile(relocs)
+	if err := file.Rebase(0x21000); err != nil {
+		t.Fatalf("unable to rebase executable; %+v", err)
+	}
+	if file.Base != 0x21000 || file.Entry != 0x21004 || file.Sections[0].Addr != 0x21000 {
+		t.Errorf("base, entry or section address mismatch; expected 0x21000, 0x21004 and 0x21000, got %v, %v and %v", file.Base, file.Entry, file.Sections[0].Addr)
+	}
+	wantImports := map[bin.Address]string{0x21018: "foo"}
+	if !reflect.DeepEqual(file.Imports, wantImports) {
+		t.Errorf("imports mismatch; expected %v, got %v", wantImports, file.Imports)
+	}
+	wantExports := map[bin.Address]string{0x21004: "bar"}
+	if !reflect.DeepEqual(file.Exports, wantExports) {
+		t.Errorf("exports mismatch; expected %v, got %v", wantExports, file.Exports)
+	}
+	// Offsets of thread-local symbols are not rebased.
+	if file.Symbols[0].Addr != 0x21004 || file.Symbols[1].Addr != 0x0004 {
+		t.Errorf("symbol address mismatch; expected 0x21004 and 0x0004, got %v and %v", file.Symbols[0].Addr, file.Symbols[1].Addr)
+	}
+	if _, ok := file.Relocs[0x21000]; !ok || len(file.Relocs) != len(relocs) {
+		t.Errorf("relocations not rebased; got %v", file.Relocs)
+	}
+	checks := []struct {
+		addr bin.Address
+		size int
+		want uint64
+	}{
+		{addr: 0x21000, size: 4, want: 0x21010},
+		{addr: 0x21008, size: 8, want: 0x21018},
+		{addr: 0x21010, size: 2, want: 0x0002},
+		{addr: 0x21012, size: 2, want: 0x1000},
+		{addr: 0x21014, size: 2, want: 0x2100},
+	}
+	for _, c := range checks {
+		buf := make([]byte, 8)
+		if err := file.ReadAt(buf[:c.size], c.addr); err != nil {
+			t.Errorf("unable to read relocated value at %v; %+v", c.addr, err)
+			continue
+		}
+		if got := binary.LittleEndian.Uint64(buf); got != c.want {
+			t.Errorf("relocated value mismatch at %v; expected 0x%X, got 0x%X", c.addr, c.want, got)
+		}
+	}
+
+	// Invalid rebase requests leave the executable unchanged.
+	golden := []struct {
+		name string
+		file *bin.File
+		base bin.Address
+	}{
+		{
+			name: "relocation information stripped",
+			file: func() *bin.File {
+				file := newFile(relocs)
+				file.Relocatable = false
+				return file
+			}(),
+			base: 0x21000,
+		},
+		{
+			name: "unknown relocation kind",
+			file: newFile(map[bin.Address]bin.RelocKind{0x1000: bin.RelocAbs32, 0x1008: bin.RelocKind(0xFF)}),
+			base: 0x21000,
+		},
+		{
+			name: "segment relocation with unaligned delta",
+			file: newFile(relocs),
+			base: 0x21008,
+		},
+		{
+			name: "unmapped relocation",
+			file: newFile(map[bin.Address]bin.RelocKind{0x1000: bin.RelocAbs32, 0x1008: bin.RelocAbs32, 0x2000: bin.RelocAbs32}),
+			base: 0x21000,
+		},
+		{
+			name: "relocation extends past section",
+			file: newFile(map[bin.Address]bin.RelocKind{0x1000: bin.RelocAbs32, 0x101C: bin.RelocAbs64}),
+			base: 0x21000,
+		},
+	}
+	for _, g := range golden {
+		data := append([]byte(nil), g.file.Sections[0].Data...)
+		if err := g.file.Rebase(g.base); err == nil {
+			t.Errorf("%s: expected error, got nil", g.name)
+			continue
+		}
+		if g.file.Base != 0x1000 || g.file.Entry != 0x1004 || g.file.Sections[0].Addr != 0x1000 || !reflect.DeepEqual(g.file.Sections[0].Data, data) {
+			t.Errorf("%s: executable modified by failed rebase", g.name)
+		}
+	}
+}
+
+func BenchmarkCodeLinear(b *testing.B) {
+	benchCode(b, func(file *bin.File, addr bin.Address) bool {
+		_, ok := bin.LocateCode(addr, file.Sections)
+		return ok
+	})
+}
+
+func BenchmarkCodeIndex(b *testing.B) {
+	benchCode(b, func(file *bin.File, addr bin.Address) bool {
+		_, err := file.CodeAt(addr)
+		return err == nil
+	})
+}
+
+func BenchmarkDataLinear(b *testing.B) {
+	benchData(b, func(file *bin.File, addr bin.Address) bool {
+		_, ok := bin.LocateData(addr, file.Sections)
+		return ok
+	})
+}
+
+func BenchmarkDataIndex(b *testing.B) {
+	benchData(b, func(file *bin.File, addr bin.Address) bool {
+		_, err := file.DataAt(addr)
+		return err == nil
+	})
+}
+
+// benchCode benchmarks the given code lookup function, for each address of the
+// executable sections of the benchmark binaries.
+func benchCode(b *testing.B, lookup func(file *bin.File, addr bin.Address) bool) {
+	bench(b, lookup, func(sect *bin.Section) bool {
+		return sect.Perm&bin.PermX != 0
+	})
+}
+
+// benchData benchmarks the given data lookup function, for each address of the
+// sections of the benchmark binaries.
+func benchData(b *testing.B, lookup func(file *bin.File, addr bin.Address) bool) {
+	bench(b, lookup, func(sect *bin.Section) bool {
+		return true
+	})
+}
+
+// bench benchmarks the given lookup function, for each address of the sections
+// of the benchmark binaries accepted by include.
+func bench(b *testing.B, lookup func(file *bin.File, addr bin.Address) bool, include func(sect *bin.Section) bool) {
+	for _, path := range benchPaths {
+		b.Run(filepath.Base(filepath.Dir(filepath.Dir(path)))+"/"+filepath.Base(path), func(b *testing.B) {
+			if _, err := os.Stat(path); err != nil {
+				b.Skipf("unable to locate %q; run make in lift/x86/testdata", path)
+			}
+			file, err := bin.ParseFile(path)
+			if err != nil {
+				b.Fatalf("unable to parse %q; %+v", path, err)
+			}
+			var addrs []bin.Address
+			for _, sect := range file.Sections {
+				if !include(sect) {
+					continue
+				}
+				for i := range sect.Data {
+					addrs = append(addrs, sect.Addr+bin.Address(i))
+				}
+			}
+			if len(addrs) == 0 {
+				b.Skipf("no addresses to look up in %q", path)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, addr := range addrs {
+					if !lookup(file, addr) {
+						b.Fatalf("unable to locate address %v in %q", addr, path)
+					}
+				}
+			}
+		})
+	}
+}
@@ -58,7 +58,12 @@ func Parse(r io.ReaderAt) (*File, error) {
 			return nil, errors.WithStack(err)
 		}
 		if match(format.magic, buf) {
-			return format.parse(r)
+			file, err := format.parse(r)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			file.BuildIndex()
+			return file, nil
 		}
 	}
 	return nil, errors.New("unknown binary executable format;\ntip: remember to register a file format (e.g. import _ \".../bin/pe\")\n\ttip: try loading as raw binary executable")
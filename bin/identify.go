@@ -0,0 +1,118 @@
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Info is a summary of a binary executable, as identified from its file
+// headers.
+type Info struct {
+	// Name of the binary executable format (e.g. "pe" or "elf").
+	Format string
+	// Machine architecture; or 0 if not supported.
+	Arch Arch
+	// Bit size of the machine architecture (e.g. 32 or 64); or 0 if unknown.
+	BitSize int
+	// Byte order of the machine architecture; or nil if unknown.
+	ByteOrder binary.ByteOrder
+}
+
+// NewInfo returns a new summary of a binary executable of the given format and
+// machine architecture.
+func NewInfo(format string, arch Arch) *Info {
+	return &Info{
+		Format:    format,
+		Arch:      arch,
+		BitSize:   arch.BitSize(),
+		ByteOrder: arch.ByteOrder(),
+	}
+}
+
+// RegisterIdentify registers a binary executable format for use by Identify.
+// Name is the name of the format, like "pe" or "elf". Magic is the magic prefix
+// that identifies the format's encoding. The magic string can contain "?"
+// wildcards that each match any one byte.
+//
+// Identify returns a summary of the binary executable, reading only the file
+// headers required to identify its machine architecture. For unsupported
+// machine architectures, identify returns a partial summary (e.g. with bit size
+// and byte order as specified by the file headers) and an
+// *UnsupportedMachineError.
+func RegisterIdentify(name, magic string, identify func(io.ReaderAt) (*Info, error)) {
+	identifiers = append(identifiers, identifier{name: name, magic: magic, identify: identify})
+}
+
+// identifiers is the list of registered format identifiers.
+var identifiers []identifier
+
+// An identifier holds a binary executable format's name, magic header and how
+// to identify it.
+type identifier struct {
+	// Name of the binary executable format.
+	name string
+	// Magic prefix that identifies the format's encoding. The magic string can
+	// contain "?" wildcards that each match any one byte.
+	magic string
+	// identify returns a summary of the given binary executable, reading from r.
+	identify func(r io.ReaderAt) (*Info, error)
+}
+
+// IdentifyFile identifies the format and machine architecture of the given
+// binary executable, reading from path.
+func IdentifyFile(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Identify(f)
+}
+
+// Identify identifies the format and machine architecture of the given binary
+// executable, reading from r. Only the file headers are parsed, which makes
+// Identify considerably faster than Parse.
+//
+// For unsupported machine architectures, a partial summary is returned along
+// with an error whose cause is an *UnsupportedMachineError.
+//
+// Users are responsible for closing r.
+func Identify(r io.ReaderAt) (*Info, error) {
+	for _, id := range identifiers {
+		buf := make([]byte, len(id.magic))
+		if n, err := r.ReadAt(buf, 0); err != nil {
+			if errors.Cause(err) == io.EOF && n < len(id.magic) {
+				continue
+			}
+			return nil, errors.WithStack(err)
+		}
+		if match(id.magic, buf) {
+			info, err := id.identify(r)
+			return info, errors.WithStack(err)
+		}
+	}
+	return nil, errors.New("unknown binary executable format")
+}
+
+// UnsupportedMachineError is the error returned by format parsers for binary
+// executables of unsupported machine architectures.
+type UnsupportedMachineError struct {
+	// Name of the binary executable format (e.g. "pe" or "elf").
+	Format string
+	// Raw machine value of the file header (e.g. e_machine of ELF files).
+	Machine uint32
+	// Name of the raw machine value (e.g. "EM_ARM"); or empty if unknown.
+	MachineName string
+}
+
+// Error returns the string representation of the error.
+func (e *UnsupportedMachineError) Error() string {
+	if len(e.MachineName) > 0 {
+		return fmt.Sprintf("support for %s machine architecture %s (0x%X) not yet implemented", e.Format, e.MachineName, e.Machine)
+	}
+	return fmt.Sprintf("support for %s machine architecture 0x%X not yet implemented", e.Format, e.Machine)
+}
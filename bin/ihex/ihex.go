@@ -0,0 +1,146 @@
+// Package ihex provides access to Intel HEX files.
+//
+// Intel HEX files contain no information about the machine architecture nor the
+// access permissions of memory, so the architecture is specified by the user
+// and all memory is mapped as readable, writable and executable.
+package ihex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// ParseFile parses the given Intel HEX file, reading from path.
+//
+// The entry point is specified by the start address record if present; or 0
+// otherwise.
+func ParseFile(path string, arch bin.Arch) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f, arch)
+}
+
+// Record types.
+const (
+	// Data record.
+	recData = 0x00
+	// End of file record.
+	recEOF = 0x01
+	// Extended segment address record; bits 4-19 of the address.
+	recExtSegAddr = 0x02
+	// Start segment address record; CS:IP of the entry point.
+	recStartSegAddr = 0x03
+	// Extended linear address record; bits 16-31 of the address.
+	recExtLinearAddr = 0x04
+	// Start linear address record; EIP of the entry point.
+	recStartLinearAddr = 0x05
+)
+
+// Parse parses the given Intel HEX file, reading from r.
+//
+// The entry point is specified by the start address record if present; or 0
+// otherwise.
+//
+// Each record has the following layout, where all fields are encoded as pairs
+// of hexadecimal digits.
+//
+//	:        start code
+//	length   uint8
+//	offset   uint16 (big-endian)
+//	type     uint8
+//	data     [length]byte
+//	checksum uint8 (two's complement of the sum of the preceding fields)
+func Parse(r io.Reader, arch bin.Arch) (*bin.File, error) {
+	file := &bin.File{
+		Arch: arch,
+	}
+	var (
+		// Base address of data records, as specified by extended address records.
+		base bin.Address
+		// Current section; extended while data records are contiguous.
+		sect *bin.Section
+	)
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != ':' {
+			return nil, errors.Errorf("invalid start code of record on line %d; expected ':', got %q", lineNum, line[0])
+		}
+		rec, err := hex.DecodeString(line[1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode record on line %d", lineNum)
+		}
+		if len(rec) < 5 || len(rec) != 5+int(rec[0]) {
+			return nil, errors.Errorf("invalid length of record on line %d; expected %d bytes, got %d", lineNum, 5+int(rec[0]), len(rec))
+		}
+		var sum uint8
+		for _, b := range rec {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, errors.Errorf("invalid checksum of record on line %d", lineNum)
+		}
+		offset := binary.BigEndian.Uint16(rec[1:])
+		data := rec[4 : len(rec)-1]
+		switch typ := rec[3]; typ {
+		case recData:
+			addr := base + bin.Address(offset)
+			if sect != nil && sect.Addr+bin.Address(len(sect.Data)) == addr {
+				sect.Data = append(sect.Data, data...)
+				continue
+			}
+			sect = &bin.Section{
+				Addr: addr,
+				Data: append([]byte(nil), data...),
+				Perm: bin.PermR | bin.PermW | bin.PermX,
+			}
+			file.Sections = append(file.Sections, sect)
+		case recEOF:
+			if err := bin.MergeRecords(file); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return file, nil
+		case recExtSegAddr:
+			if len(data) != 2 {
+				return nil, errors.Errorf("invalid extended segment address record on line %d; expected 2 bytes of data, got %d", lineNum, len(data))
+			}
+			base = bin.Address(binary.BigEndian.Uint16(data)) << 4
+		case recStartSegAddr:
+			if len(data) != 4 {
+				return nil, errors.Errorf("invalid start segment address record on line %d; expected 4 bytes of data, got %d", lineNum, len(data))
+			}
+			cs := binary.BigEndian.Uint16(data)
+			ip := binary.BigEndian.Uint16(data[2:])
+			file.Entry = bin.Address(cs)<<4 + bin.Address(ip)
+		case recExtLinearAddr:
+			if len(data) != 2 {
+				return nil, errors.Errorf("invalid extended linear address record on line %d; expected 2 bytes of data, got %d", lineNum, len(data))
+			}
+			base = bin.Address(binary.BigEndian.Uint16(data)) << 16
+		case recStartLinearAddr:
+			if len(data) != 4 {
+				return nil, errors.Errorf("invalid start linear address record on line %d; expected 4 bytes of data, got %d", lineNum, len(data))
+			}
+			file.Entry = bin.Address(binary.BigEndian.Uint32(data))
+		default:
+			return nil, errors.Errorf("support for record type 0x%02X on line %d not yet implemented", typ, lineNum)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return nil, errors.New("missing end of file record")
+}
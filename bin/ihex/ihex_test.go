@@ -0,0 +1,154 @@
+package ihex
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestParse(t *testing.T) {
+	golden := []struct {
+		name string
+		// Records of the Intel HEX file.
+		recs []string
+		// Expected entry point.
+		entry bin.Address
+		// Expected sections.
+		sects []*bin.Section
+		// Expected error; or empty if valid.
+		err string
+	}{
+		{
+			name: "contiguous data records",
+			recs: []string{
+				record(recData, 0x0100, "0123"),
+				record(recData, 0x0102, "4567"),
+				record(recEOF, 0, ""),
+			},
+			sects: []*bin.Section{
+				newSection(0x0100, "01234567"),
+			},
+		},
+		{
+			name: "out-of-order data records",
+			recs: []string{
+				record(recData, 0x0102, "4567"),
+				record(recData, 0x0200, "AA"),
+				record(recData, 0x0100, "0123"),
+				record(recEOF, 0, ""),
+			},
+			sects: []*bin.Section{
+				newSection(0x0100, "01234567"),
+				newSection(0x0200, "AA"),
+			},
+		},
+		{
+			name: "extended linear address and start linear address",
+			recs: []string{
+				record(recExtLinearAddr, 0, "0800"),
+				record(recData, 0x0010, "CAFE"),
+				record(recStartLinearAddr, 0, "08000010"),
+				record(recEOF, 0, ""),
+			},
+			entry: 0x08000010,
+			sects: []*bin.Section{
+				newSection(0x08000010, "CAFE"),
+			},
+		},
+		{
+			name: "extended segment address and start segment address",
+			recs: []string{
+				record(recExtSegAddr, 0, "1000"),
+				record(recData, 0x0004, "90"),
+				record(recStartSegAddr, 0, "10000004"),
+				record(recEOF, 0, ""),
+			},
+			entry: 0x10004,
+			sects: []*bin.Section{
+				newSection(0x10004, "90"),
+			},
+		},
+		{
+			name: "invalid checksum",
+			recs: []string{
+				record(recData, 0x0100, "0123")[:13] + "00",
+				record(recEOF, 0, ""),
+			},
+			err: "invalid checksum of record on line 1",
+		},
+		{
+			name: "overlapping data records",
+			recs: []string{
+				record(recData, 0x0102, "4567"),
+				record(recData, 0x0100, "012345"),
+				record(recEOF, 0, ""),
+			},
+			err: "overlapping data records at address 0x102",
+		},
+		{
+			name: "missing end of file record",
+			recs: []string{
+				record(recData, 0x0100, "0123"),
+			},
+			err: "missing end of file record",
+		},
+	}
+	for _, g := range golden {
+		r := strings.NewReader(strings.Join(g.recs, "\n"))
+		file, err := Parse(r, bin.ArchX86_32)
+		if len(g.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), g.err) {
+				t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to parse Intel HEX file; %+v", g.name, err)
+			continue
+		}
+		if file.Entry != g.entry {
+			t.Errorf("%s: entry point mismatch; expected %v, got %v", g.name, g.entry, file.Entry)
+		}
+		if want := g.sects[0].Addr; file.Base != want {
+			t.Errorf("%s: base address mismatch; expected %v, got %v", g.name, want, file.Base)
+		}
+		if !reflect.DeepEqual(file.Sections, g.sects) {
+			t.Errorf("%s: sections mismatch; expected %v, got %v", g.name, g.sects, file.Sections)
+		}
+	}
+}
+
+// record returns the Intel HEX record of the given type, offset and
+// hexadecimal data, with a valid checksum.
+func record(typ uint8, offset uint16, data string) string {
+	buf, err := hex.DecodeString(data)
+	if err != nil {
+		panic(err)
+	}
+	rec := append([]byte{uint8(len(buf)), uint8(offset >> 8), uint8(offset), typ}, buf...)
+	var sum uint8
+	for _, b := range rec {
+		sum += b
+	}
+	rec = append(rec, -sum)
+	return ":" + strings.ToUpper(hex.EncodeToString(rec))
+}
+
+// newSection returns a readable, writable and executable section of the given
+// address and hexadecimal data.
+func newSection(addr bin.Address, data string) *bin.Section {
+	buf, err := hex.DecodeString(data)
+	if err != nil {
+		panic(err)
+	}
+	return &bin.Section{
+		Addr:     addr,
+		Data:     buf,
+		FileSize: len(buf),
+		MemSize:  len(buf),
+		Perm:     bin.PermR | bin.PermW | bin.PermX,
+	}
+}
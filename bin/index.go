@@ -0,0 +1,182 @@
+package bin
+
+import (
+	"sort"
+	"sync"
+)
+
+// fileIndex is an index of the sections of a binary executable, used for fast
+// address to section lookup.
+type fileIndex struct {
+	// Index of executable sections, spanning initialized data.
+	code *sectionIndex
+	// Index of sections, spanning initialized data.
+	data *sectionIndex
+	// Index of sections, spanning initialized and uninitialized data.
+	mem *sectionIndex
+
+	// Guards zeros.
+	mu sync.Mutex
+	// Zero-initialized data of the uninitialized part of sections (e.g. .bss);
+	// allocated on first use.
+	zeros map[*Section][]byte
+}
+
+// index returns the section index of the binary executable, building the index
+// on first use if not built by the format parser.
+func (file *File) index() *fileIndex {
+	file.idxMu.Lock()
+	defer file.idxMu.Unlock()
+	if file.idx == nil {
+		file.idx = newFileIndex(file.Sections)
+	}
+	return file.idx
+}
+
+// BuildIndex builds the index used for fast address to section lookup. The
+// index is built by the format parsers and by Rebase, and must be rebuilt by
+// users that modify the sections of the binary executable after parsing (e.g.
+// adding, removing or moving sections, or changing the length of section data).
+//
+// pre-condition: file.Sections must be sorted by address in ascending order,
+// prioritizing longer sections with identical addresses.
+func (file *File) BuildIndex() {
+	idx := newFileIndex(file.Sections)
+	file.idxMu.Lock()
+	file.idx = idx
+	file.idxMu.Unlock()
+}
+
+// newFileIndex returns a new index of the given sections.
+//
+// pre-condition: sects must be sorted by address in ascending order,
+// prioritizing longer sections with identical addresses.
+func newFileIndex(sects []*Section) *fileIndex {
+	dataEnd := func(sect *Section) Address {
+		return sect.Addr + Address(len(sect.Data))
+	}
+	return &fileIndex{
+		code: newSectionIndex(sects, func(sect *Section) (Address, bool) {
+			return dataEnd(sect), sect.Perm&PermX != 0
+		}),
+		data: newSectionIndex(sects, func(sect *Section) (Address, bool) {
+			return dataEnd(sect), true
+		}),
+		mem: newSectionIndex(sects, func(sect *Section) (Address, bool) {
+			return sect.End(), true
+		}),
+	}
+}
+
+// zeroData returns the zero-initialized data of the uninitialized part of the
+// given section, starting at the specified address. The data is shared between
+// calls and must not be modified.
+func (idx *fileIndex) zeroData(sect *Section, addr Address) []byte {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	start := sect.Addr + Address(len(sect.Data))
+	zeros, ok := idx.zeros[sect]
+	if !ok {
+		if idx.zeros == nil {
+			idx.zeros = make(map[*Section][]byte)
+		}
+		zeros = make([]byte, sect.End()-start)
+		idx.zeros[sect] = zeros
+	}
+	return zeros[addr-start:]
+}
+
+// sectionIndex is an interval index mapping addresses to sections. The
+// intervals of the index are disjoint and sorted by address in ascending order.
+type sectionIndex struct {
+	// Start address of each interval.
+	starts []Address
+	// End address of each interval.
+	ends []Address
+	// Section of each interval.
+	sects []*Section
+}
+
+// newSectionIndex returns a new interval index of the given sections. The end
+// function returns the end address of a section, and whether to include the
+// section in the index.
+//
+// Overlapping sections are resolved in favour of the section first in order,
+// which mirrors a linear scan of the sections.
+//
+// pre-condition: sects must be sorted by address in ascending order,
+// prioritizing longer sections with identical addresses.
+func newSectionIndex(sects []*Section, end func(sect *Section) (Address, bool)) *sectionIndex {
+	idx := &sectionIndex{}
+	for _, sect := range sects {
+		sectEnd, ok := end(sect)
+		if !ok || sectEnd <= sect.Addr {
+			continue
+		}
+		idx.insert(sect.Addr, sectEnd, sect)
+	}
+	return idx
+}
+
+// insert adds the given section to the parts of [start, end) not yet covered by
+// the index.
+func (idx *sectionIndex) insert(start, end Address, sect *Section) {
+	// Locate the first interval ending after start.
+	i := sort.Search(len(idx.ends), func(i int) bool {
+		return start < idx.ends[i]
+	})
+	var (
+		starts []Address
+		ends   []Address
+		sects  []*Section
+	)
+	for addr := start; addr < end; {
+		if i < len(idx.starts) && idx.starts[i] <= addr {
+			// Address covered by existing interval.
+			addr = idx.ends[i]
+			i++
+			continue
+		}
+		// Fill gap up to the next interval.
+		gapEnd := end
+		if i < len(idx.starts) && idx.starts[i] < gapEnd {
+			gapEnd = idx.starts[i]
+		}
+		starts = append(starts, addr)
+		ends = append(ends, gapEnd)
+		sects = append(sects, sect)
+		addr = gapEnd
+	}
+	if len(starts) == 0 {
+		return
+	}
+	idx.starts = append(idx.starts, starts...)
+	idx.ends = append(idx.ends, ends...)
+	idx.sects = append(idx.sects, sects...)
+	idx.sort()
+}
+
+// sort sorts the intervals of the index by start address in ascending order.
+func (idx *sectionIndex) sort() {
+	sort.Sort(idx)
+}
+
+func (idx *sectionIndex) Len() int           { return len(idx.starts) }
+func (idx *sectionIndex) Less(i, j int) bool { return idx.starts[i] < idx.starts[j] }
+func (idx *sectionIndex) Swap(i, j int) {
+	idx.starts[i], idx.starts[j] = idx.starts[j], idx.starts[i]
+	idx.ends[i], idx.ends[j] = idx.ends[j], idx.ends[i]
+	idx.sects[i], idx.sects[j] = idx.sects[j], idx.sects[i]
+}
+
+// lookup returns the section containing the given address. The boolean return
+// value indicates success.
+func (idx *sectionIndex) lookup(addr Address) (*Section, bool) {
+	i := sort.Search(len(idx.ends), func(i int) bool {
+		return addr < idx.ends[i]
+	})
+	if i < len(idx.starts) && idx.starts[i] <= addr {
+		return idx.sects[i], true
+	}
+	return nil, false
+}
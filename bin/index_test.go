@@ -0,0 +1,130 @@
+package bin_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+// newIndexFile returns a 32-bit x86 executable with adjacent and overlapping
+// sections, and sections of uninitialized data.
+//
+//	0x1000-0x1100  .text  code
+//	0x1100-0x1180  .data  data
+//	0x1180-0x1300  .data  uninitialized data
+//	0x1200-0x1380  .ovl   code; overlapping the uninitialized data of .data
+//	0x1380-0x1400  .bss   uninitialized data
+//	0x2000-0x2010  .rdata data
+func newIndexFile() *bin.File {
+	newSection := func(name string, addr bin.Address, dataSize, memSize int, perm bin.Perm) *bin.Section {
+		data := make([]byte, dataSize)
+		for i := range data {
+			data[i] = byte(addr) + byte(i)
+		}
+		return &bin.Section{Name: name, Addr: addr, Data: data, FileSize: dataSize, MemSize: memSize, Perm: perm}
+	}
+	return &bin.File{
+		Arch: bin.ArchX86_32,
+		Base: 0x1000,
+		Sections: []*bin.Section{
+			newSection(".text", 0x1000, 0x100, 0x100, bin.PermR|bin.PermX),
+			newSection(".data", 0x1100, 0x80, 0x200, bin.PermR|bin.PermW),
+			newSection(".ovl", 0x1200, 0x180, 0x180, bin.PermR|bin.PermX),
+			newSection(".bss", 0x1380, 0, 0x80, bin.PermR|bin.PermW),
+			newSection(".rdata", 0x2000, 0x10, 0x10, bin.PermR),
+		},
+	}
+}
+
+func TestIndex(t *testing.T) {
+	file := newIndexFile()
+	// findSection returns the first section containing the given address,
+	// including uninitialized data.
+	findSection := func(addr bin.Address) (*bin.Section, bool) {
+		for _, sect := range file.Sections {
+			if sect.Addr <= addr && addr < sect.End() {
+				return sect, true
+			}
+		}
+		return nil, false
+	}
+	for addr := bin.Address(0x0FF0); addr < 0x2020; addr++ {
+		// Code lookup.
+		wantCode, ok := bin.LocateCode(addr, file.Sections)
+		code, err := file.CodeAt(addr)
+		if ok != (err == nil) || !bytes.Equal(code, wantCode) {
+			t.Errorf("code mismatch at address %v; expected % X (%v), got % X (%v)", addr, wantCode, ok, code, err)
+		}
+		// Data lookup; uninitialized data reads as zero.
+		wantSect, inMem := findSection(addr)
+		wantData, ok := bin.LocateData(addr, file.Sections)
+		if !ok && inMem {
+			wantData, ok = make([]byte, wantSect.End()-addr), true
+		}
+		data, err := file.DataAt(addr)
+		if ok != (err == nil) || !bytes.Equal(data, wantData) {
+			t.Errorf("data mismatch at address %v; expected % X (%v), got % X (%v)", addr, wantData, ok, data, err)
+		}
+		// Section lookup.
+		sect, ok := file.FindSection(addr)
+		if ok != inMem || sect != wantSect {
+			t.Errorf("section mismatch at address %v; expected %v (%v), got %v (%v)", addr, wantSect, inMem, sect, ok)
+		}
+	}
+}
+
+func TestIndexRebuild(t *testing.T) {
+	file := newIndexFile()
+	if _, err := file.DataAt(0x3000); err == nil {
+		t.Fatalf("expected error for unmapped address 0x3000, got nil")
+	}
+	// Move section in place, and rebuild the index.
+	rdata := file.Sections[len(file.Sections)-1]
+	rdata.Addr = 0x3000
+	file.BuildIndex()
+	data, err := file.DataAt(0x3000)
+	if err != nil {
+		t.Fatalf("unable to locate data of moved section; %v", err)
+	}
+	if !bytes.Equal(data, rdata.Data) {
+		t.Errorf("data mismatch at address 0x3000; expected % X, got % X", rdata.Data, data)
+	}
+	if _, err := file.DataAt(0x2000); err == nil {
+		t.Errorf("expected error for address 0x2000 of moved section, got nil")
+	}
+}
+
+func TestIndexConcurrent(t *testing.T) {
+	// Build the index on first use from concurrent lookups.
+	file := newIndexFile()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := bin.Address(0x1000); addr < 0x1400; addr++ {
+				if _, err := file.DataAt(addr); err != nil {
+					t.Errorf("unable to locate data at address %v; %v", addr, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDataAtUninitialized(t *testing.T) {
+	file := newIndexFile()
+	file.BuildIndex()
+	// Uninitialized data is allocated once per section.
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := file.DataAt(0x1390); err != nil {
+			t.Fatalf("unable to locate uninitialized data; %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("allocations mismatch; expected 0 allocations per lookup of uninitialized data, got %v", allocs)
+	}
+}
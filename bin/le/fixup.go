@@ -0,0 +1,313 @@
+package le
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// Fixup source types; i.e. the kind of the value stored at the fixed up
+// address.
+const (
+	// 8-bit offset.
+	srcByte = 0x00
+	// 16-bit selector.
+	srcSelector16 = 0x02
+	// 16:16 far pointer.
+	srcPointer32 = 0x03
+	// 16-bit offset.
+	srcOffset16 = 0x05
+	// 16:32 far pointer.
+	srcPointer48 = 0x06
+	// 32-bit offset.
+	srcOffset32 = 0x07
+	// 32-bit self-relative offset.
+	srcRelative32 = 0x08
+	// Mask of source type.
+	srcTypeMask = 0x0F
+	// Source offset list; the fixup applies to a list of source offsets.
+	srcList = 0x20
+)
+
+// Fixup target flags.
+const (
+	// Internal reference to an object of the module.
+	targetInternal = 0x00
+	// Imported function by ordinal.
+	targetImportOrdinal = 0x01
+	// Imported function by name.
+	targetImportName = 0x02
+	// Internal reference through the entry table.
+	targetEntry = 0x03
+	// Mask of target type.
+	targetTypeMask = 0x03
+	// Additive fixup.
+	targetAdditive = 0x04
+	// 32-bit target offset.
+	target32 = 0x10
+	// 32-bit additive value.
+	targetAdditive32 = 0x20
+	// 16-bit object number or module ordinal; 8-bit otherwise.
+	target16 = 0x40
+	// 8-bit import ordinal.
+	targetOrdinal8 = 0x80
+)
+
+// parseFixups parses and applies the fixup records of each page.
+//
+// The fixup page table specifies the offset of the fixup records of each page
+// into the fixup record table. Each fixup record has the following layout.
+//
+//	source type uint8
+//	target      uint8 (flags)
+//	source      int16 (offset within page); or uint8 (count) if source list
+//	target data variable length
+//	additive    uint16 or uint32 (if additive)
+//	source list [count]int16 (if source list)
+func (p *parser) parseFixups() error {
+	if p.hdr.FixupPageTableOffset == 0 || p.hdr.FixupRecordTableOffset == 0 {
+		return nil
+	}
+	// The fixup page table has one entry per page, followed by the end offset
+	// of the fixup record table.
+	fpt := make([]byte, (int(p.hdr.NPages)+1)*4)
+	if _, err := p.r.ReadAt(fpt, p.hdrOffset+int64(p.hdr.FixupPageTableOffset)); err != nil {
+		return errors.Wrap(err, "unable to read fixup page table")
+	}
+	end := binary.LittleEndian.Uint32(fpt[len(fpt)-4:])
+	records := make([]byte, end)
+	if _, err := p.r.ReadAt(records, p.hdrOffset+int64(p.hdr.FixupRecordTableOffset)); err != nil {
+		return errors.Wrap(err, "unable to read fixup record table")
+	}
+	for _, obj := range p.objs {
+		for j := 0; j < int(obj.NPages); j++ {
+			pageIndex := int(obj.PageTableIndex) + j
+			if pageIndex < 1 || pageIndex > int(p.hdr.NPages) {
+				continue
+			}
+			start := binary.LittleEndian.Uint32(fpt[(pageIndex-1)*4:])
+			end := binary.LittleEndian.Uint32(fpt[pageIndex*4:])
+			if start > end || end > uint32(len(records)) {
+				return errors.Errorf("invalid fixup record range [0x%X:0x%X] of page %d", start, end, pageIndex)
+			}
+			r := &fixupReader{data: records[start:end]}
+			pageOffset := int64(j) * int64(p.hdr.PageSize)
+			for len(r.data) > r.pos {
+				if err := p.applyFixup(obj, pageOffset, r); err != nil {
+					// Report invalid fixups as warnings, to allow for the analysis
+					// of partially relocated objects.
+					warn.Printf("unable to apply fixup of page %d; %v", pageIndex, err)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyFixup parses the next fixup record of r, and applies it to the page at
+// the given offset within the object.
+func (p *parser) applyFixup(obj *object, pageOffset int64, r *fixupReader) error {
+	src := r.uint8()
+	flags := r.uint8()
+	var srcOffs []int16
+	count := 0
+	if src&srcList != 0 {
+		count = int(r.uint8())
+	} else {
+		srcOffs = append(srcOffs, int16(r.uint16()))
+	}
+	// Parse target.
+	var target bin.Address
+	switch flags & targetTypeMask {
+	case targetInternal:
+		objNum := r.index(flags)
+		targetObj, err := p.object(objNum)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		target = targetObj.Addr
+		if src&srcTypeMask != srcSelector16 {
+			target += bin.Address(r.offset(flags))
+		}
+	case targetImportOrdinal:
+		module, err := p.module(r.index(flags))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		var ordinal uint32
+		switch {
+		case flags&targetOrdinal8 != 0:
+			ordinal = uint32(r.uint8())
+		default:
+			ordinal = r.offset(flags)
+		}
+		target = p.extern(fmt.Sprintf("%s_ordinal_%d", module, ordinal))
+	case targetImportName:
+		module, err := p.module(r.index(flags))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		off := p.hdrOffset + int64(p.hdr.ImportProcTableOffset) + int64(r.offset(flags))
+		name, _, err := p.readName(off)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read name of function imported from %q", module)
+		}
+		target = p.extern(name)
+	case targetEntry:
+		ordinal := r.index(flags)
+		if ordinal < 1 || ordinal > len(p.entries) || p.entries[ordinal-1] == nil {
+			return errors.Errorf("invalid entry ordinal %d of fixup target", ordinal)
+		}
+		e := p.entries[ordinal-1]
+		targetObj, err := p.object(e.obj)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		target = targetObj.Addr + bin.Address(e.offset)
+	}
+	if flags&targetAdditive != 0 {
+		if flags&targetAdditive32 != 0 {
+			target += bin.Address(r.uint32())
+		} else {
+			target += bin.Address(r.uint16())
+		}
+	}
+	for i := 0; i < count; i++ {
+		srcOffs = append(srcOffs, int16(r.uint16()))
+	}
+	if r.err != nil {
+		return errors.WithStack(r.err)
+	}
+	// Apply fixup to source offsets.
+	for _, srcOff := range srcOffs {
+		// Source offsets are relative to the page, and may be negative for
+		// fixups crossing page boundaries.
+		offset := pageOffset + int64(srcOff)
+		if err := p.put(obj, offset, src&srcTypeMask, target); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// put stores the target address at the given offset of the object, as
+// specified by the fixup source type.
+func (p *parser) put(obj *object, offset int64, srcType uint8, target bin.Address) error {
+	var size int64
+	switch srcType {
+	case srcByte:
+		size = 1
+	case srcSelector16, srcOffset16:
+		size = 2
+	case srcPointer32, srcOffset32, srcRelative32:
+		size = 4
+	case srcPointer48:
+		size = 6
+	default:
+		return errors.Errorf("support for fixup source type 0x%02X not yet implemented", srcType)
+	}
+	if offset < 0 || offset+size > int64(len(obj.mem)) {
+		return errors.Errorf("invalid fixup offset 0x%X; exceeds size %d of loaded object pages", offset, len(obj.mem))
+	}
+	buf := obj.mem[offset:]
+	addr := obj.Addr + bin.Address(offset)
+	switch srcType {
+	case srcByte:
+		buf[0] = uint8(target)
+	case srcSelector16:
+		// TODO: Add support for selectors of 16-bit objects.
+	case srcOffset16, srcPointer32:
+		// The selector of 16:16 far pointers is left as is.
+		binary.LittleEndian.PutUint16(buf, uint16(target))
+	case srcOffset32, srcPointer48:
+		// The selector of 16:32 far pointers is left as is.
+		binary.LittleEndian.PutUint32(buf, uint32(target))
+		p.file.Relocs[addr] = bin.RelocAbs32
+	case srcRelative32:
+		binary.LittleEndian.PutUint32(buf, uint32(target-(addr+4)))
+	}
+	return nil
+}
+
+// module returns the name of the imported module of the given module ordinal
+// (1-based).
+func (p *parser) module(ordinal int) (string, error) {
+	if ordinal < 1 || ordinal > len(p.modules) {
+		return "", errors.Errorf("invalid import module ordinal; expected >= 1 and <= %d, got %d", len(p.modules), ordinal)
+	}
+	return p.modules[ordinal-1], nil
+}
+
+// fixupReader reads the fields of fixup records. Reads past the end of the data
+// are recorded as an error and return zero values.
+type fixupReader struct {
+	// Fixup records.
+	data []byte
+	// Current offset into the data.
+	pos int
+	// First error encountered.
+	err error
+}
+
+// bytes reads n bytes.
+func (r *fixupReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data)-r.pos {
+		r.err = errors.Errorf("unexpected end of fixup record; expected %d bytes at offset %d, got %d", n, r.pos, len(r.data)-r.pos)
+		r.pos = len(r.data)
+		return nil
+	}
+	buf := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return buf
+}
+
+// uint8 reads an 8-bit value.
+func (r *fixupReader) uint8() uint8 {
+	buf := r.bytes(1)
+	if buf == nil {
+		return 0
+	}
+	return buf[0]
+}
+
+// uint16 reads a 16-bit little-endian value.
+func (r *fixupReader) uint16() uint16 {
+	buf := r.bytes(2)
+	if buf == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(buf)
+}
+
+// uint32 reads a 32-bit little-endian value.
+func (r *fixupReader) uint32() uint32 {
+	buf := r.bytes(4)
+	if buf == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(buf)
+}
+
+// index reads an object number, module ordinal or entry ordinal; 16-bit or
+// 8-bit as specified by the target flags.
+func (r *fixupReader) index(flags uint8) int {
+	if flags&target16 != 0 {
+		return int(r.uint16())
+	}
+	return int(r.uint8())
+}
+
+// offset reads a target offset, import ordinal or procedure name offset;
+// 32-bit or 16-bit as specified by the target flags.
+func (r *fixupReader) offset(flags uint8) uint32 {
+	if flags&target32 != 0 {
+		return r.uint32()
+	}
+	return uint32(r.uint16())
+}
@@ -0,0 +1,679 @@
+// Package le provides access to LE (Linear Executable) and LX files, as used by
+// 32-bit DOS extender (e.g. DOS/4GW), VxD and OS/2 executables.
+//
+// ref: http://fileformats.archiveteam.org/wiki/Linear_Executable
+// ref: https://github.com/open-watcom/open-watcom-v2/blob/master/bld/watcom/h/exeflat.h
+package le
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger with the "le:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("le:")+" ", 0)
+	// warn is a logger with the "le:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("le:")+" ", 0)
+)
+
+// ParseFile parses the given LE or LX binary executable, reading from path.
+func ParseFile(path string) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses the given LE or LX binary executable, reading from r. The LE
+// header is located by the e_lfanew field of the DOS header.
+//
+// Users are responsible for closing r.
+func Parse(r io.ReaderAt) (*bin.File, error) {
+	// Locate LE header.
+	var buf [4]byte
+	if _, err := r.ReadAt(buf[:], 0x3C); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	hdrOffset := int64(binary.LittleEndian.Uint32(buf[:]))
+	hdr := &Header{}
+	if err := binary.Read(io.NewSectionReader(r, hdrOffset, headerSize), binary.LittleEndian, hdr); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch magic := string(hdr.Magic[:]); magic {
+	case "LE", "LX":
+	default:
+		return nil, errors.Errorf("invalid LE signature; expected \"LE\" or \"LX\", got %q", magic)
+	}
+	if hdr.ByteOrder != 0 || hdr.WordOrder != 0 {
+		return nil, errors.New("support for big-endian LE files not yet implemented")
+	}
+	dbg.Printf("LE header: %+v", hdr)
+	p := &parser{
+		r:         r,
+		hdrOffset: hdrOffset,
+		hdr:       hdr,
+		lx:        hdr.Magic[1] == 'X',
+		file: &bin.File{
+			Arch:        bin.ArchX86_32,
+			Imports:     make(map[bin.Address]string),
+			Exports:     make(map[bin.Address]string),
+			Forwards:    make(map[string]string),
+			Relocs:      make(map[bin.Address]bin.RelocKind),
+			Relocatable: true,
+		},
+		externs: make(map[string]bin.Address),
+	}
+	if err := p.parseObjects(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseImportModules(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseEntries(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseNames(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseFixups(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Parse entry point.
+	if hdr.EIPObject != 0 {
+		obj, err := p.object(int(hdr.EIPObject))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid entry point")
+		}
+		p.file.Entry = obj.Addr + bin.Address(hdr.EIP)
+	}
+	// Add synthetic section of imported functions.
+	if p.externEnd > p.externStart {
+		size := int(p.externEnd - p.externStart)
+		p.file.Sections = append(p.file.Sections, &bin.Section{
+			Name:    ".extern",
+			Addr:    p.externStart,
+			Data:    make([]byte, size),
+			MemSize: size,
+			Perm:    bin.PermR,
+		})
+	}
+	return p.file, nil
+}
+
+// parser tracks information required to parse LE and LX files.
+type parser struct {
+	// Reader of the LE file.
+	r io.ReaderAt
+	// File offset of the LE header; the offsets of most LE tables are relative
+	// to the LE header.
+	hdrOffset int64
+	// LE header.
+	hdr *Header
+	// LX file; LE file otherwise.
+	lx bool
+	// Binary executable.
+	file *bin.File
+	// Objects, indexed by object number - 1.
+	objs []*object
+	// Imported module names, indexed by module ordinal - 1.
+	modules []string
+	// Entry points, indexed by ordinal - 1; or nil if unused ordinal.
+	entries []*entry
+	// Map from imported function name to address of synthetic import slot.
+	externs map[string]bin.Address
+	// Start and end address of synthetic import slots.
+	externStart, externEnd bin.Address
+}
+
+// An object is an object (i.e. segment) of an LE file.
+type object struct {
+	// Object table entry.
+	ObjectEntry
+	// Loaded object.
+	*bin.Section
+	// Contents of the loaded pages of the object, including zero-filled pages;
+	// uninitialized data past the last page is not allocated.
+	mem []byte
+}
+
+// An entry is an entry point of an LE file.
+type entry struct {
+	// Object number (1-based).
+	obj int
+	// Offset within the object.
+	offset uint32
+}
+
+// Object flags.
+const (
+	objReadable   = 0x0001
+	objWritable   = 0x0002
+	objExecutable = 0x0004
+)
+
+// Object page flags.
+const (
+	// Legal physical page.
+	pageLegal = 0x00
+	// Iterated data page.
+	pageIterated = 0x01
+	// Invalid page.
+	pageInvalid = 0x02
+	// Zero-filled page.
+	pageZeroed = 0x03
+	// Range of pages.
+	pageRange = 0x04
+	// Compressed page (EXEPACK2).
+	pageCompressed = 0x05
+)
+
+// maxPageSize is the maximum page size of LE and LX files.
+const maxPageSize = 0x10000
+
+// parseObjects parses the object table and the object page table, and loads
+// the objects at their relocation base addresses.
+func (p *parser) parseObjects() error {
+	const entrySize = 24
+	sr := io.NewSectionReader(p.r, p.hdrOffset+int64(p.hdr.ObjectTableOffset), int64(p.hdr.NObjects)*entrySize)
+	var end bin.Address
+	for i := 0; i < int(p.hdr.NObjects); i++ {
+		var e ObjectEntry
+		if err := binary.Read(sr, binary.LittleEndian, &e); err != nil {
+			return errors.Wrapf(err, "unable to read object table entry %d", i+1)
+		}
+		// The virtual size of objects is not bounded by the file size. Thus, only
+		// the pages of the object (as stored in the file) are allocated.
+		if e.NPages > 0 {
+			if p.hdr.PageSize == 0 || p.hdr.PageSize > maxPageSize {
+				return errors.Errorf("invalid page size; expected > 0 and <= %d, got %d", maxPageSize, p.hdr.PageSize)
+			}
+			last := uint64(e.PageTableIndex) + uint64(e.NPages) - 1
+			if e.PageTableIndex < 1 || last > uint64(p.hdr.NPages) {
+				return errors.Errorf("invalid page table range [%d, %d] of object %d; expected >= 1 and <= %d", e.PageTableIndex, last, i+1, p.hdr.NPages)
+			}
+		}
+		var mem []byte
+		fileSize := 0
+		for j := 0; j < int(e.NPages); j++ {
+			start := uint64(j) * uint64(p.hdr.PageSize)
+			if start >= uint64(e.VirtualSize) {
+				// Ignore pages beyond the virtual size of the object.
+				break
+			}
+			page := make([]byte, p.hdr.PageSize)
+			if rem := uint64(e.VirtualSize) - start; rem < uint64(len(page)) {
+				page = page[:rem]
+			}
+			pageIndex := int(e.PageTableIndex) + j
+			n, err := p.loadPage(pageIndex, page)
+			if err != nil {
+				return errors.Wrapf(err, "unable to load page %d of object %d", pageIndex, i+1)
+			}
+			mem = append(mem, page...)
+			if n > 0 {
+				fileSize = int(start) + n
+			}
+		}
+		var perm bin.Perm
+		if e.Flags&objReadable != 0 {
+			perm |= bin.PermR
+		}
+		if e.Flags&objWritable != 0 {
+			perm |= bin.PermW
+		}
+		if e.Flags&objExecutable != 0 {
+			perm |= bin.PermX
+		}
+		addr := bin.Address(e.RelocBase)
+		sect := &bin.Section{
+			Name:     fmt.Sprintf("obj%03d", i+1),
+			Addr:     addr,
+			Data:     mem[:fileSize],
+			FileSize: fileSize,
+			MemSize:  int(e.VirtualSize),
+			Perm:     perm,
+		}
+		if i == 0 || addr < p.file.Base {
+			p.file.Base = addr
+		}
+		if objEnd := addr + bin.Address(e.VirtualSize); objEnd > end {
+			end = objEnd
+		}
+		p.objs = append(p.objs, &object{ObjectEntry: e, Section: sect, mem: mem})
+		p.file.Sections = append(p.file.Sections, sect)
+	}
+	less := func(i, j int) bool {
+		return p.file.Sections[i].Addr < p.file.Sections[j].Addr
+	}
+	sort.SliceStable(p.file.Sections, less)
+	// Place synthetic import slots after the last object, aligned to a page
+	// boundary.
+	const pageAlign = 0x1000
+	p.externStart = (end + pageAlign - 1) &^ (pageAlign - 1)
+	p.externEnd = p.externStart
+	return nil
+}
+
+// loadPage loads the contents of the given object page (1-based) into buf, and
+// returns the number of bytes stored in the file.
+func (p *parser) loadPage(pageIndex int, buf []byte) (int, error) {
+	if pageIndex < 1 || pageIndex > int(p.hdr.NPages) {
+		return 0, errors.Errorf("invalid page index; expected >= 1 and <= %d, got %d", p.hdr.NPages, pageIndex)
+	}
+	pageSize := int(p.hdr.PageSize)
+	if len(buf) > pageSize {
+		buf = buf[:pageSize]
+	}
+	var (
+		// File offset of the page contents.
+		offset int64
+		// Size of the page contents in the file.
+		size  int
+		flags uint16
+	)
+	if p.lx {
+		// LX object page table entry.
+		//
+		//	offset   uint32 (shifted by page offset shift, relative to data pages)
+		//	size     uint16
+		//	flags    uint16
+		var e [8]byte
+		if _, err := p.r.ReadAt(e[:], p.hdrOffset+int64(p.hdr.PageTableOffset)+int64(pageIndex-1)*8); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		size = int(binary.LittleEndian.Uint16(e[4:]))
+		flags = binary.LittleEndian.Uint16(e[6:])
+		pageOffset := int64(binary.LittleEndian.Uint32(e[0:])) << p.hdr.PageShift
+		if flags == pageIterated {
+			offset = int64(p.hdr.IteratedPagesOffset) + pageOffset
+		} else {
+			offset = int64(p.hdr.DataPagesOffset) + pageOffset
+		}
+	} else {
+		// LE object page table entry.
+		//
+		//	page number uint24 (big endian)
+		//	flags       uint8
+		var e [4]byte
+		if _, err := p.r.ReadAt(e[:], p.hdrOffset+int64(p.hdr.PageTableOffset)+int64(pageIndex-1)*4); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		pageNum := int64(e[0])<<16 | int64(e[1])<<8 | int64(e[2])
+		flags = uint16(e[3])
+		if flags == pageIterated {
+			return 0, errors.New("support for iterated pages of LE files not yet implemented")
+		}
+		offset = int64(p.hdr.DataPagesOffset) + (pageNum-1)*int64(pageSize)
+		size = pageSize
+		if pageNum == int64(p.hdr.NPages) && p.hdr.PageShift != 0 {
+			// The page shift field of LE files specifies the size of the last
+			// page.
+			size = int(p.hdr.PageShift)
+		}
+	}
+	switch flags {
+	case pageLegal, pageRange:
+		if size > len(buf) {
+			size = len(buf)
+		}
+		n, err := p.r.ReadAt(buf[:size], offset)
+		if err != nil && errors.Cause(err) != io.EOF {
+			return 0, errors.WithStack(err)
+		}
+		return n, nil
+	case pageIterated:
+		data := make([]byte, size)
+		if _, err := p.r.ReadAt(data, offset); err != nil && errors.Cause(err) != io.EOF {
+			return 0, errors.WithStack(err)
+		}
+		return unpackIterated(buf, data)
+	case pageInvalid, pageZeroed:
+		return 0, nil
+	default:
+		return 0, errors.Errorf("support for object page flags 0x%04X not yet implemented", flags)
+	}
+}
+
+// unpackIterated unpacks the given iterated data page (EXEPACK1) into buf, and
+// returns the number of unpacked bytes.
+//
+//	iterations uint16
+//	length     uint16
+//	data       [length]byte
+func unpackIterated(buf, data []byte) (int, error) {
+	n := 0
+	for len(data) >= 4 {
+		iterations := int(binary.LittleEndian.Uint16(data[0:]))
+		length := int(binary.LittleEndian.Uint16(data[2:]))
+		if iterations == 0 {
+			break
+		}
+		data = data[4:]
+		if length > len(data) {
+			return 0, errors.Errorf("invalid iterated data record; expected %d bytes, got %d", length, len(data))
+		}
+		for i := 0; i < iterations; i++ {
+			if n+length > len(buf) {
+				return 0, errors.Errorf("invalid iterated data page; unpacked size exceeds %d bytes", len(buf))
+			}
+			n += copy(buf[n:], data[:length])
+		}
+		data = data[length:]
+	}
+	return n, nil
+}
+
+// parseImportModules parses the import module name table.
+func (p *parser) parseImportModules() error {
+	off := p.hdrOffset + int64(p.hdr.ImportModuleTableOffset)
+	for i := 0; i < int(p.hdr.NImportModules); i++ {
+		name, n, err := p.readName(off)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read name of imported module %d", i+1)
+		}
+		p.modules = append(p.modules, name)
+		off += int64(n)
+	}
+	return nil
+}
+
+// Entry bundle types.
+const (
+	// Unused entries.
+	bundleUnused = 0x00
+	// 16-bit entries.
+	bundle16 = 0x01
+	// 286 call gate entries.
+	bundleCallGate = 0x02
+	// 32-bit entries.
+	bundle32 = 0x03
+	// Forwarder entries.
+	bundleForwarder = 0x04
+)
+
+// parseEntries parses the entry table.
+//
+// The entry table consists of bundles of entry points with consecutive
+// ordinals, each prefixed by the number of entries, the bundle type and the
+// object number (unless unused).
+func (p *parser) parseEntries() error {
+	off := p.hdrOffset + int64(p.hdr.EntryTableOffset)
+	for {
+		var hdr [2]byte
+		if _, err := p.r.ReadAt(hdr[:], off); err != nil {
+			return errors.Wrap(err, "unable to read entry table")
+		}
+		n := int(hdr[0])
+		typ := hdr[1] &^ 0x80
+		off += 2
+		if n == 0 {
+			return nil
+		}
+		if typ == bundleUnused {
+			for i := 0; i < n; i++ {
+				p.entries = append(p.entries, nil)
+			}
+			continue
+		}
+		var objBuf [2]byte
+		if _, err := p.r.ReadAt(objBuf[:], off); err != nil {
+			return errors.Wrap(err, "unable to read entry table")
+		}
+		obj := int(binary.LittleEndian.Uint16(objBuf[:]))
+		off += 2
+		var size int
+		switch typ {
+		case bundle16:
+			size = 3
+		case bundleCallGate:
+			size = 5
+		case bundle32:
+			size = 5
+		case bundleForwarder:
+			size = 7
+		default:
+			return errors.Errorf("support for entry bundle type 0x%02X not yet implemented", typ)
+		}
+		buf := make([]byte, n*size)
+		if _, err := p.r.ReadAt(buf, off); err != nil {
+			return errors.Wrap(err, "unable to read entry table")
+		}
+		off += int64(len(buf))
+		for i := 0; i < n; i++ {
+			e := buf[i*size:]
+			switch typ {
+			case bundle16, bundleCallGate:
+				p.entries = append(p.entries, &entry{obj: obj, offset: uint32(binary.LittleEndian.Uint16(e[1:]))})
+			case bundle32:
+				p.entries = append(p.entries, &entry{obj: obj, offset: binary.LittleEndian.Uint32(e[1:])})
+			case bundleForwarder:
+				// TODO: Record forwarded entry points.
+				p.entries = append(p.entries, nil)
+			}
+		}
+	}
+}
+
+// parseNames parses the resident and non-resident names tables, and records
+// the named entry points as exports. Unnamed entry points are exported by
+// ordinal.
+func (p *parser) parseNames() error {
+	names := make(map[uint16]string)
+	if err := p.parseNameTable(p.hdrOffset+int64(p.hdr.ResidentNamesOffset), names); err != nil {
+		return errors.Wrap(err, "unable to parse resident names table")
+	}
+	if p.hdr.NonResidentNamesSize > 0 {
+		if err := p.parseNameTable(int64(p.hdr.NonResidentNamesOffset), names); err != nil {
+			return errors.Wrap(err, "unable to parse non-resident names table")
+		}
+	}
+	// The name of ordinal 0 is the module name (resident) or description
+	// (non-resident).
+	moduleName := names[0]
+	for i, e := range p.entries {
+		if e == nil {
+			continue
+		}
+		ordinal := uint16(i + 1)
+		obj, err := p.object(e.obj)
+		if err != nil {
+			warn.Printf("invalid entry point of ordinal %d; %v", ordinal, err)
+			continue
+		}
+		addr := obj.Addr + bin.Address(e.offset)
+		name, ok := names[ordinal]
+		if !ok {
+			name = fmt.Sprintf("%s_ordinal_%d", moduleName, ordinal)
+		}
+		if _, ok := p.file.Exports[addr]; !ok {
+			p.file.Exports[addr] = name
+		}
+	}
+	return nil
+}
+
+// parseNameTable parses the resident or non-resident names table at the given
+// file offset, and records the name of each ordinal; the first name of the
+// table takes precedence.
+//
+//	length  uint8
+//	name    [length]byte
+//	ordinal uint16
+func (p *parser) parseNameTable(off int64, names map[uint16]string) error {
+	for first := true; ; first = false {
+		name, n, err := p.readName(off)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(name) == 0 {
+			return nil
+		}
+		off += int64(n)
+		var buf [2]byte
+		if _, err := p.r.ReadAt(buf[:], off); err != nil {
+			return errors.WithStack(err)
+		}
+		off += 2
+		ordinal := binary.LittleEndian.Uint16(buf[:])
+		if first {
+			// Module name or description.
+			ordinal = 0
+		}
+		if _, ok := names[ordinal]; !ok {
+			names[ordinal] = name
+		}
+	}
+}
+
+// readName reads the length-prefixed name at the given file offset, and
+// returns the name and its size in bytes including the length prefix.
+func (p *parser) readName(off int64) (string, int, error) {
+	var n [1]byte
+	if _, err := p.r.ReadAt(n[:], off); err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+	buf := make([]byte, n[0])
+	if _, err := p.r.ReadAt(buf, off+1); err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+	return string(buf), 1 + len(buf), nil
+}
+
+// object returns the object of the given object number (1-based).
+func (p *parser) object(objNum int) (*object, error) {
+	if objNum < 1 || objNum > len(p.objs) {
+		return nil, errors.Errorf("invalid object number; expected >= 1 and <= %d, got %d", len(p.objs), objNum)
+	}
+	return p.objs[objNum-1], nil
+}
+
+// extern returns the address of the synthetic import slot of the given
+// imported function, allocating a new slot if not yet present.
+func (p *parser) extern(name string) bin.Address {
+	if addr, ok := p.externs[name]; ok {
+		return addr
+	}
+	addr := p.externEnd
+	p.externEnd += 4
+	p.externs[name] = addr
+	p.file.Imports[addr] = name
+	return addr
+}
+
+// headerSize is the size in bytes of the LE header, up to and including the
+// non-resident names table checksum.
+const headerSize = 0x94
+
+// Header is an LE or LX header.
+type Header struct {
+	// Signature; "LE" or "LX".
+	Magic [2]byte
+	// Byte order; 0 for little endian.
+	ByteOrder uint8
+	// Word order; 0 for little endian.
+	WordOrder uint8
+	// Format level.
+	FormatLevel uint32
+	// CPU type (e.g. 2 for 80386).
+	CPUType uint16
+	// Target operating system (e.g. 1 for OS/2, 4 for Windows 386).
+	TargetOS uint16
+	// Module version.
+	ModuleVersion uint32
+	// Module flags.
+	ModuleFlags uint32
+	// Number of pages.
+	NPages uint32
+	// Object number of initial EIP.
+	EIPObject uint32
+	// Initial EIP value, relative to object.
+	EIP uint32
+	// Object number of initial ESP.
+	ESPObject uint32
+	// Initial ESP value, relative to object.
+	ESP uint32
+	// Page size in bytes.
+	PageSize uint32
+	// Size of the last page in bytes (LE); or page offset shift (LX).
+	PageShift uint32
+	// Size of the fixup section in bytes.
+	FixupSectionSize uint32
+	// Checksum of the fixup section.
+	FixupSectionChecksum uint32
+	// Size of the loader section in bytes.
+	LoaderSectionSize uint32
+	// Checksum of the loader section.
+	LoaderSectionChecksum uint32
+	// Offset of the object table, relative to the LE header.
+	ObjectTableOffset uint32
+	// Number of object table entries.
+	NObjects uint32
+	// Offset of the object page table, relative to the LE header.
+	PageTableOffset uint32
+	// File offset of the object iterated pages.
+	IteratedPagesOffset uint32
+	// Offset of the resource table, relative to the LE header.
+	ResourceTableOffset uint32
+	// Number of resource table entries.
+	NResources uint32
+	// Offset of the resident names table, relative to the LE header.
+	ResidentNamesOffset uint32
+	// Offset of the entry table, relative to the LE header.
+	EntryTableOffset uint32
+	// Offset of the module directives table, relative to the LE header.
+	ModuleDirectivesOffset uint32
+	// Number of module directives.
+	NModuleDirectives uint32
+	// Offset of the fixup page table, relative to the LE header.
+	FixupPageTableOffset uint32
+	// Offset of the fixup record table, relative to the LE header.
+	FixupRecordTableOffset uint32
+	// Offset of the import module name table, relative to the LE header.
+	ImportModuleTableOffset uint32
+	// Number of import module name table entries.
+	NImportModules uint32
+	// Offset of the import procedure name table, relative to the LE header.
+	ImportProcTableOffset uint32
+	// Offset of the per-page checksum table, relative to the LE header.
+	PageChecksumOffset uint32
+	// File offset of the data pages.
+	DataPagesOffset uint32
+	// Number of preload pages.
+	NPreloadPages uint32
+	// File offset of the non-resident names table.
+	NonResidentNamesOffset uint32
+	// Size of the non-resident names table in bytes.
+	NonResidentNamesSize uint32
+	// Checksum of the non-resident names table.
+	NonResidentNamesChecksum uint32
+}
+
+// ObjectEntry is an object table entry.
+type ObjectEntry struct {
+	// Virtual size in bytes.
+	VirtualSize uint32
+	// Relocation base address; the preferred load address.
+	RelocBase uint32
+	// Object flags.
+	Flags uint32
+	// Index of the first page of the object in the object page table (1-based).
+	PageTableIndex uint32
+	// Number of pages.
+	NPages uint32
+	// Reserved.
+	_ uint32
+}
@@ -0,0 +1,100 @@
+package le
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestParseObjects(t *testing.T) {
+	// LE file with a single object page table entry at offset 0x100, and the
+	// contents of its last (and only) page of 4 bytes at offset 0x200.
+	data := make([]byte, 0x204)
+	copy(data[0x100:], []byte{0x00, 0x00, 0x01, pageLegal})
+	copy(data[0x200:], []byte{0x90, 0x90, 0xC3, 0xCC})
+	newParser := func(e ObjectEntry) *parser {
+		hdr := &Header{
+			NPages:            1,
+			PageSize:          0x1000,
+			PageShift:         4,
+			NObjects:          1,
+			ObjectTableOffset: 0x300,
+			PageTableOffset:   0x100,
+			DataPagesOffset:   0x200,
+		}
+		buf := &bytes.Buffer{}
+		buf.Write(data)
+		buf.Write(make([]byte, 0x300-len(data)))
+		if err := binary.Write(buf, binary.LittleEndian, e); err != nil {
+			t.Fatalf("unable to write object table entry; %v", err)
+		}
+		return &parser{
+			r:    bytes.NewReader(buf.Bytes()),
+			hdr:  hdr,
+			file: &bin.File{},
+		}
+	}
+
+	// Object with a virtual size exceeding the file size; only the pages of the
+	// object are allocated.
+	p := newParser(ObjectEntry{
+		VirtualSize:    0xFFFFFFF0,
+		RelocBase:      0x10000,
+		Flags:          objReadable | objExecutable,
+		PageTableIndex: 1,
+		NPages:         1,
+	})
+	if err := p.parseObjects(); err != nil {
+		t.Fatalf("unable to parse objects; %+v", err)
+	}
+	obj := p.objs[0]
+	if want := []byte{0x90, 0x90, 0xC3, 0xCC}; !bytes.Equal(obj.Data, want) {
+		t.Errorf("object contents mismatch; expected % X, got % X", want, obj.Data)
+	}
+	if obj.MemSize != 0xFFFFFFF0 {
+		t.Errorf("object memory size mismatch; expected 0x%X, got 0x%X", 0xFFFFFFF0, obj.MemSize)
+	}
+	if len(obj.mem) != 0x1000 {
+		t.Errorf("size of allocated object pages mismatch; expected 0x%X, got 0x%X", 0x1000, len(obj.mem))
+	}
+
+	// Malformed objects.
+	golden := []struct {
+		name   string
+		e      ObjectEntry
+		modify func(hdr *Header)
+		err    string
+	}{
+		{
+			name: "page table range exceeds number of pages",
+			e:    ObjectEntry{VirtualSize: 0x2000, PageTableIndex: 1, NPages: 2},
+			err:  "invalid page table range [1, 2] of object 1",
+		},
+		{
+			name: "page table index out of range",
+			e:    ObjectEntry{VirtualSize: 0x1000, PageTableIndex: 0, NPages: 1},
+			err:  "invalid page table range",
+		},
+		{
+			name: "invalid page size",
+			e:    ObjectEntry{VirtualSize: 0x1000, PageTableIndex: 1, NPages: 1},
+			modify: func(hdr *Header) {
+				hdr.PageSize = 0x80000000
+			},
+			err: "invalid page size",
+		},
+	}
+	for _, g := range golden {
+		p := newParser(g.e)
+		if g.modify != nil {
+			g.modify(p.hdr)
+		}
+		err := p.parseObjects()
+		if err == nil || !strings.Contains(err.Error(), g.err) {
+			t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+		}
+	}
+}
@@ -0,0 +1,837 @@
+// Package macho provides access to Mach-O files.
+package macho
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger with the "macho:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("macho:")+" ", 0)
+	// warn is a logger with the "macho:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("macho:")+" ", 0)
+)
+
+// Register Mach-O format.
+func init() {
+	// Mach-O format (32-bit, big-endian).
+	//
+	//    FE ED FA CE  |....|
+	bin.RegisterFormat("macho", "\xFE\xED\xFA\xCE", Parse)
+	bin.RegisterIdentify("macho", "\xFE\xED\xFA\xCE", Identify)
+	// Mach-O format (32-bit, little-endian).
+	//
+	//    CE FA ED FE  |....|
+	bin.RegisterFormat("macho", "\xCE\xFA\xED\xFE", Parse)
+	bin.RegisterIdentify("macho", "\xCE\xFA\xED\xFE", Identify)
+	// Mach-O format (64-bit, big-endian).
+	//
+	//    FE ED FA CF  |....|
+	bin.RegisterFormat("macho", "\xFE\xED\xFA\xCF", Parse)
+	bin.RegisterIdentify("macho", "\xFE\xED\xFA\xCF", Identify)
+	// Mach-O format (64-bit, little-endian).
+	//
+	//    CF FA ED FE  |....|
+	bin.RegisterFormat("macho", "\xCF\xFA\xED\xFE", Parse)
+	bin.RegisterIdentify("macho", "\xCF\xFA\xED\xFE", Identify)
+	// Mach-O universal binary format (fat file).
+	//
+	// Note, the magic is shared with Java class files.
+	//
+	//    CA FE BA BE  |....|
+	bin.RegisterFormat("macho", "\xCA\xFE\xBA\xBE", Parse)
+	bin.RegisterIdentify("macho", "\xCA\xFE\xBA\xBE", Identify)
+}
+
+// ParseFile parses the given Mach-O binary executable, reading from path.
+func ParseFile(path string) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses the given Mach-O binary executable, reading from r. For
+// universal binaries, the first machine architecture supported by the bin
+// package is parsed.
+//
+// The rebase information of dyld is not parsed. Thus Mach-O files are never
+// marked as Relocatable, and bin.File.Rebase fails for Mach-O files.
+//
+// Users are responsible for closing r.
+func Parse(r io.ReaderAt) (*bin.File, error) {
+	// Open Mach-O file.
+	var buf [4]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if binary.BigEndian.Uint32(buf[:]) == macho.MagicFat {
+		ff, err := macho.NewFatFile(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, arch := range ff.Arches {
+			if _, ok := parseArch(arch.Cpu); !ok {
+				warn.Printf("skip universal binary machine architecture %v", arch.Cpu)
+				continue
+			}
+			return parseFile(arch.File)
+		}
+		if len(ff.Arches) > 0 {
+			cpu := ff.Arches[0].Cpu
+			return nil, errors.WithStack(&bin.UnsupportedMachineError{Format: "macho", Machine: uint32(cpu), MachineName: cpu.String()})
+		}
+		return nil, errors.New("unable to locate supported machine architecture in universal binary")
+	}
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseFile(f)
+}
+
+// Identify identifies the machine architecture of the given Mach-O binary
+// executable, reading only the Mach-O header from r. For universal binaries, the
+// first machine architecture supported by the bin package is identified.
+func Identify(r io.ReaderAt) (*bin.Info, error) {
+	// magic and cputype fields of the Mach-O header.
+	var buf [8]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info := &bin.Info{Format: "macho"}
+	var cpu macho.Cpu
+	switch magic := binary.BigEndian.Uint32(buf[:]); magic {
+	case macho.MagicFat:
+		// The fat header is followed by fat_arch entries of 20 bytes each,
+		// starting with the cputype field.
+		n := binary.BigEndian.Uint32(buf[4:])
+		for i := uint32(0); i < n; i++ {
+			var cpuBuf [4]byte
+			if _, err := r.ReadAt(cpuBuf[:], 8+int64(i)*20); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			cpu = macho.Cpu(binary.BigEndian.Uint32(cpuBuf[:]))
+			if _, ok := parseArch(cpu); ok {
+				break
+			}
+		}
+	case macho.Magic32, macho.Magic64:
+		info.BitSize = 32
+		if magic == macho.Magic64 {
+			info.BitSize = 64
+		}
+		info.ByteOrder = binary.BigEndian
+		cpu = macho.Cpu(binary.BigEndian.Uint32(buf[4:]))
+	default:
+		// Little-endian Mach-O header.
+		info.BitSize = 32
+		if binary.LittleEndian.Uint32(buf[:]) == macho.Magic64 {
+			info.BitSize = 64
+		}
+		info.ByteOrder = binary.LittleEndian
+		cpu = macho.Cpu(binary.LittleEndian.Uint32(buf[4:]))
+	}
+	arch, ok := parseArch(cpu)
+	if !ok {
+		return info, errors.WithStack(&bin.UnsupportedMachineError{Format: "macho", Machine: uint32(cpu), MachineName: cpu.String()})
+	}
+	return bin.NewInfo("macho", arch), nil
+}
+
+// parseFile parses the given Mach-O file.
+func parseFile(f *macho.File) (*bin.File, error) {
+	// Parse machine architecture.
+	file := &bin.File{
+		Imports:  make(map[bin.Address]string),
+		Exports:  make(map[bin.Address]string),
+		Forwards: make(map[string]string),
+	}
+	arch, ok := parseArch(f.Cpu)
+	if !ok {
+		return nil, errors.WithStack(&bin.UnsupportedMachineError{Format: "macho", Machine: uint32(f.Cpu), MachineName: f.Cpu.String()})
+	}
+	file.Arch = arch
+
+	// Parse segments.
+	var segments []*macho.Segment
+	for _, load := range f.Loads {
+		seg, ok := load.(*macho.Segment)
+		if !ok {
+			continue
+		}
+		segments = append(segments, seg)
+		if seg.Name == "__TEXT" {
+			// The base address is the address of the segment mapping the
+			// Mach-O header.
+			file.Base = bin.Address(seg.Addr - seg.Offset)
+		}
+	}
+	var segs []*bin.Section
+	for _, s := range segments {
+		if s.Prot == 0 {
+			// skip inaccessible segments (e.g. __PAGEZERO).
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		seg := &bin.Section{
+			Addr:     bin.Address(s.Addr),
+			Offset:   s.Offset,
+			Data:     data,
+			FileSize: int(s.Filesz),
+			MemSize:  int(s.Memsz),
+			Perm:     parsePerm(s.Prot),
+		}
+		segs = append(segs, seg)
+	}
+
+	// Parse sections.
+	for _, s := range f.Sections {
+		var data []byte
+		if !isZeroFill(s.Flags) {
+			var err error
+			data, err = s.Data()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		var perm bin.Perm
+		if seg := f.Segment(s.Seg); seg != nil {
+			perm = parsePerm(seg.Prot)
+		}
+		sect := &bin.Section{
+			Name:     s.Seg + "," + s.Name,
+			Addr:     bin.Address(s.Addr),
+			Offset:   uint64(s.Offset),
+			Data:     data,
+			FileSize: len(data),
+			MemSize:  int(s.Size),
+			Perm:     perm,
+		}
+		file.Sections = append(file.Sections, sect)
+	}
+	// Append segments as sections.
+	file.Sections = append(file.Sections, segs...)
+	// Sort sections (and segments) in ascending order.
+	less := func(i, j int) bool {
+		if file.Sections[i].Addr == file.Sections[j].Addr {
+			if len(file.Sections[i].Data) > len(file.Sections[j].Data) {
+				// prioritize longer sections with identical addresses.
+				return true
+			}
+			return file.Sections[i].Name < file.Sections[j].Name
+		}
+		return file.Sections[i].Addr < file.Sections[j].Addr
+	}
+	sort.Slice(file.Sections, less)
+
+	// Parse entry address.
+	if err := parseEntry(file, f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse imports.
+	if err := parseImports(file, f, segments); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse exports.
+	if err := parseExports(file, f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return file, nil
+}
+
+// parseArch returns the machine architecture corresponding to the given Mach-O
+// CPU type. The boolean return value indicates success.
+func parseArch(cpu macho.Cpu) (bin.Arch, bool) {
+	switch cpu {
+	case macho.Cpu386:
+		return bin.ArchX86_32, true
+	case macho.CpuAmd64:
+		return bin.ArchX86_64, true
+	case macho.CpuArm:
+		return bin.ArchARM_32, true
+	case macho.CpuArm64:
+		return bin.ArchARM_64, true
+	case macho.CpuPpc:
+		return bin.ArchPowerPC_32, true
+	case macho.CpuPpc64:
+		return bin.ArchPowerPC_64BE, true
+	}
+	return 0, false
+}
+
+// Load commands not defined by the debug/macho package.
+const (
+	// Unix thread; initial thread state of the main thread.
+	loadCmdUnixThread macho.LoadCmd = 0x5
+	// Compressed dyld information.
+	loadCmdDyldInfo macho.LoadCmd = 0x22
+	// Compressed dyld information only.
+	loadCmdDyldInfoOnly macho.LoadCmd = 0x80000022
+	// Replacement for LC_UNIXTHREAD; entry point file offset.
+	loadCmdMain macho.LoadCmd = 0x80000028
+	// Export trie of dyld chained fixups.
+	loadCmdDyldExportsTrie macho.LoadCmd = 0x80000033
+)
+
+// parseEntry parses the entry point of the Mach-O file, as specified by either
+// LC_MAIN or LC_UNIXTHREAD.
+func parseEntry(file *bin.File, f *macho.File) error {
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) < 8 {
+			continue
+		}
+		switch cmd := macho.LoadCmd(f.ByteOrder.Uint32(raw)); cmd {
+		case loadCmdMain:
+			// entry_point_command
+			//
+			//    cmd       uint32
+			//    cmdsize   uint32
+			//    entryoff  uint64 // file offset of main()
+			//    stacksize uint64
+			if len(raw) < 16 {
+				return errors.Errorf("invalid LC_MAIN load command size; expected >= 16, got %d", len(raw))
+			}
+			entryOff := f.ByteOrder.Uint64(raw[8:])
+			file.Entry = file.Base + bin.Address(entryOff)
+			return nil
+		case loadCmdUnixThread:
+			// thread_command
+			//
+			//    cmd     uint32
+			//    cmdsize uint32
+			//    flavor  uint32
+			//    count   uint32
+			//    state   [count]uint32
+			if len(raw) < 16 {
+				return errors.Errorf("invalid LC_UNIXTHREAD load command size; expected >= 16, got %d", len(raw))
+			}
+			flavor := f.ByteOrder.Uint32(raw[8:])
+			state := raw[16:]
+			pc, err := parseThreadPC(f, flavor, state)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			file.Entry = bin.Address(pc)
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseThreadPC returns the program counter of the given thread state.
+func parseThreadPC(f *macho.File, flavor uint32, state []byte) (uint64, error) {
+	// Index of the program counter in the thread state (in number of
+	// registers), and register size in bytes.
+	var index, size int
+	switch {
+	case f.Cpu == macho.Cpu386 && flavor == 1:
+		// x86_THREAD_STATE32: eax, ebx, ecx, edx, edi, esi, ebp, esp, ss, eflags,
+		// eip, ...
+		index, size = 10, 4
+	case f.Cpu == macho.CpuAmd64 && flavor == 4:
+		// x86_THREAD_STATE64: rax, rbx, rcx, rdx, rdi, rsi, rbp, rsp, r8-r15,
+		// rip, ...
+		index, size = 16, 8
+	case f.Cpu == macho.CpuArm && flavor == 1:
+		// ARM_THREAD_STATE: r0-r12, sp, lr, pc, cpsr
+		index, size = 15, 4
+	case f.Cpu == macho.CpuArm64 && flavor == 6:
+		// ARM_THREAD_STATE64: x0-x28, fp, lr, sp, pc, cpsr
+		index, size = 32, 8
+	case f.Cpu == macho.CpuPpc && flavor == 1:
+		// PPC_THREAD_STATE: srr0, srr1, r0-r31, ...
+		index, size = 0, 4
+	case f.Cpu == macho.CpuPpc64 && flavor == 5:
+		// PPC_THREAD_STATE64: srr0, srr1, r0-r31, ...
+		index, size = 0, 8
+	default:
+		return 0, errors.Errorf("support for thread state flavor %d of machine architecture %v not yet implemented", flavor, f.Cpu)
+	}
+	offset := index * size
+	if offset+size > len(state) {
+		return 0, errors.Errorf("invalid thread state size; expected >= %d, got %d", offset+size, len(state))
+	}
+	if size == 4 {
+		return uint64(f.ByteOrder.Uint32(state[offset:])), nil
+	}
+	return f.ByteOrder.Uint64(state[offset:]), nil
+}
+
+// Section types.
+const (
+	// Zero-fill on demand section.
+	sectTypeZeroFill = 0x01
+	// Section with only non-lazy symbol pointers.
+	sectTypeNonLazySymbolPointers = 0x06
+	// Section with only lazy symbol pointers.
+	sectTypeLazySymbolPointers = 0x07
+	// Section with only symbol stubs; the stub size is stored in reserved2.
+	sectTypeSymbolStubs = 0x08
+	// Zero-fill on demand section; which may be larger than 4 GB.
+	sectTypeGBZeroFill = 0x0C
+	// Section with only lazy symbol pointers to lazy loaded dylibs.
+	sectTypeLazyDylibSymbolPointers = 0x10
+	// Thread local zero-fill section.
+	sectTypeThreadLocalZeroFill = 0x12
+)
+
+// isZeroFill reports whether the section of the given section flags contains
+// zero-initialized data not part of the executable file.
+func isZeroFill(flags uint32) bool {
+	switch flags & 0xFF {
+	case sectTypeZeroFill, sectTypeGBZeroFill, sectTypeThreadLocalZeroFill:
+		return true
+	}
+	return false
+}
+
+// parseImports parses the function imports of the Mach-O file, as specified by
+// the symbol stubs and the lazy and non-lazy symbol pointers.
+//
+// Functions called through symbol stubs are imported at the address of their
+// stub, and the symbol pointers of the stubs are recorded as data. Symbol
+// pointers of functions without symbol stubs (e.g. called indirectly through
+// __got) are imported at the address of the symbol pointer.
+func parseImports(file *bin.File, f *macho.File, segments []*macho.Segment) error {
+	if f.Symtab == nil || f.Dysymtab == nil {
+		return nil
+	}
+	// Special indirect symbol table indices.
+	const (
+		indirectSymLocal = 0x80000000
+		indirectSymAbs   = 0x40000000
+	)
+	ptrSize := uint64(file.Arch.BitSize() / 8)
+	sects, err := parseSectHeaders(f, segments)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Map from symbol name to addresses of symbol pointers.
+	ptrs := make(map[string][]bin.Address)
+	// Symbol names of symbol stubs.
+	stubbed := make(map[string]bool)
+	for _, sect := range sects {
+		// Size of each entry in the section.
+		var entrySize uint64
+		isStub := false
+		switch sect.flags & 0xFF {
+		case sectTypeNonLazySymbolPointers, sectTypeLazySymbolPointers, sectTypeLazyDylibSymbolPointers:
+			entrySize = ptrSize
+		case sectTypeSymbolStubs:
+			entrySize = uint64(sect.reserved2)
+			isStub = true
+		default:
+			continue
+		}
+		if entrySize == 0 {
+			warn.Printf("invalid entry size of section %q; expected > 0, got 0", sect.name)
+			continue
+		}
+		// The reserved1 field holds the index into the indirect symbol table of
+		// the first entry.
+		n := sect.size / entrySize
+		for i := uint64(0); i < n; i++ {
+			j := uint64(sect.reserved1) + i
+			if j >= uint64(len(f.Dysymtab.IndirectSyms)) {
+				return errors.Errorf("invalid indirect symbol table index of section %q; expected < %d, got %d", sect.name, len(f.Dysymtab.IndirectSyms), j)
+			}
+			symIndex := f.Dysymtab.IndirectSyms[j]
+			if symIndex&(indirectSymLocal|indirectSymAbs) != 0 {
+				// skip local and absolute symbols.
+				continue
+			}
+			if symIndex >= uint32(len(f.Symtab.Syms)) {
+				return errors.Errorf("invalid symbol table index of section %q; expected < %d, got %d", sect.name, len(f.Symtab.Syms), symIndex)
+			}
+			addr := bin.Address(sect.addr + i*entrySize)
+			name := symName(f.Symtab.Syms[symIndex].Name)
+			if !isStub {
+				ptrs[name] = append(ptrs[name], addr)
+				continue
+			}
+			dbg.Printf("import %q at %v", name, addr)
+			file.Imports[addr] = name
+			stubbed[name] = true
+		}
+	}
+	for name, addrs := range ptrs {
+		for _, addr := range addrs {
+			if stubbed[name] {
+				file.DataAddrs = bin.InsertAddr(file.DataAddrs, addr)
+				continue
+			}
+			dbg.Printf("import %q at %v", name, addr)
+			file.Imports[addr] = name
+		}
+	}
+	return nil
+}
+
+// sectHeader is a Mach-O section header, including the reserved fields omitted
+// by the debug/macho package.
+type sectHeader struct {
+	// Section name.
+	name string
+	// Section address.
+	addr uint64
+	// Section size in bytes.
+	size uint64
+	// Section type and attributes.
+	flags uint32
+	// Reserved field; index into the indirect symbol table for symbol pointer
+	// and symbol stub sections.
+	reserved1 uint32
+	// Reserved field; stub size for symbol stub sections.
+	reserved2 uint32
+}
+
+// parseSectHeaders parses the section headers of the given segment load
+// commands.
+func parseSectHeaders(f *macho.File, segments []*macho.Segment) ([]sectHeader, error) {
+	// Section header layouts.
+	//
+	//    section (32-bit)                  section_64 (64-bit)
+	//
+	//    sectname  [16]byte                sectname  [16]byte
+	//    segname   [16]byte                segname   [16]byte
+	//    addr      uint32                  addr      uint64
+	//    size      uint32                  size      uint64
+	//    offset    uint32                  offset    uint32
+	//    align     uint32                  align     uint32
+	//    reloff    uint32                  reloff    uint32
+	//    nreloc    uint32                  nreloc    uint32
+	//    flags     uint32                  flags     uint32
+	//    reserved1 uint32                  reserved1 uint32
+	//    reserved2 uint32                  reserved2 uint32
+	//                                      reserved3 uint32
+	var sects []sectHeader
+	for _, seg := range segments {
+		raw := seg.Raw()
+		// Size of segment load command and section header.
+		segSize, sectSize := 56, 68
+		if seg.Cmd == macho.LoadCmdSegment64 {
+			segSize, sectSize = 72, 80
+		}
+		for i := 0; i < int(seg.Nsect); i++ {
+			start := segSize + i*sectSize
+			if start+sectSize > len(raw) {
+				return nil, errors.Errorf("invalid section header offset of segment %q; expected <= %d, got %d", seg.Name, len(raw), start+sectSize)
+			}
+			buf := raw[start : start+sectSize]
+			sect := sectHeader{
+				name: seg.Name + "," + parseString(buf[:16]),
+			}
+			if seg.Cmd == macho.LoadCmdSegment64 {
+				sect.addr = f.ByteOrder.Uint64(buf[32:])
+				sect.size = f.ByteOrder.Uint64(buf[40:])
+				sect.flags = f.ByteOrder.Uint32(buf[64:])
+				sect.reserved1 = f.ByteOrder.Uint32(buf[68:])
+				sect.reserved2 = f.ByteOrder.Uint32(buf[72:])
+			} else {
+				sect.addr = uint64(f.ByteOrder.Uint32(buf[32:]))
+				sect.size = uint64(f.ByteOrder.Uint32(buf[36:]))
+				sect.flags = f.ByteOrder.Uint32(buf[56:])
+				sect.reserved1 = f.ByteOrder.Uint32(buf[60:])
+				sect.reserved2 = f.ByteOrder.Uint32(buf[64:])
+			}
+			sects = append(sects, sect)
+		}
+	}
+	return sects, nil
+}
+
+// parseExports parses the function exports of the Mach-O file, as specified by
+// the export trie; or the symbol table if no export trie is present.
+func parseExports(file *bin.File, f *macho.File) error {
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) < 8 {
+			continue
+		}
+		var off, size uint32
+		switch cmd := macho.LoadCmd(f.ByteOrder.Uint32(raw)); cmd {
+		case loadCmdDyldInfo, loadCmdDyldInfoOnly:
+			// dyld_info_command
+			//
+			//    cmd            uint32
+			//    cmdsize        uint32
+			//    rebase_off     uint32
+			//    rebase_size    uint32
+			//    bind_off       uint32
+			//    bind_size      uint32
+			//    weak_bind_off  uint32
+			//    weak_bind_size uint32
+			//    lazy_bind_off  uint32
+			//    lazy_bind_size uint32
+			//    export_off     uint32
+			//    export_size    uint32
+			if len(raw) < 48 {
+				return errors.Errorf("invalid LC_DYLD_INFO load command size; expected >= 48, got %d", len(raw))
+			}
+			off = f.ByteOrder.Uint32(raw[40:])
+			size = f.ByteOrder.Uint32(raw[44:])
+		case loadCmdDyldExportsTrie:
+			// linkedit_data_command
+			//
+			//    cmd      uint32
+			//    cmdsize  uint32
+			//    dataoff  uint32
+			//    datasize uint32
+			if len(raw) < 16 {
+				return errors.Errorf("invalid LC_DYLD_EXPORTS_TRIE load command size; expected >= 16, got %d", len(raw))
+			}
+			off = f.ByteOrder.Uint32(raw[8:])
+			size = f.ByteOrder.Uint32(raw[12:])
+		default:
+			continue
+		}
+		if size == 0 {
+			continue
+		}
+		trie, err := readLinkEdit(f, uint64(off), uint64(size))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		libs, err := f.ImportedLibraries()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return parseExportTrie(file, trie, libs)
+	}
+	// Fall back to exports of the symbol table.
+	if f.Symtab == nil {
+		return nil
+	}
+	// Symbol types.
+	const (
+		// Mask of debugging symbol types.
+		nStab = 0xE0
+		// Mask of symbol type.
+		nType = 0x0E
+		// External symbol.
+		nExt = 0x01
+		// Symbol defined in section.
+		nSect = 0x0E
+	)
+	for _, sym := range f.Symtab.Syms {
+		if sym.Type&nStab != 0 || sym.Type&nType != nSect || sym.Type&nExt == 0 {
+			continue
+		}
+		if sym.Sect == 0 || int(sym.Sect) > len(f.Sections) {
+			continue
+		}
+		// Only export symbols defined in executable segments.
+		sect := f.Sections[sym.Sect-1]
+		if seg := f.Segment(sect.Seg); seg == nil || parsePerm(seg.Prot)&bin.PermX == 0 {
+			continue
+		}
+		addr := bin.Address(sym.Value)
+		if _, ok := file.Exports[addr]; ok {
+			continue
+		}
+		file.Exports[addr] = symName(sym.Name)
+	}
+	return nil
+}
+
+// readLinkEdit reads size bytes at the given file offset of the __LINKEDIT
+// segment.
+func readLinkEdit(f *macho.File, off, size uint64) ([]byte, error) {
+	seg := f.Segment("__LINKEDIT")
+	if seg == nil {
+		return nil, errors.New("unable to locate __LINKEDIT segment")
+	}
+	if off < seg.Offset || off+size > seg.Offset+seg.Filesz {
+		return nil, errors.Errorf("invalid __LINKEDIT file offset range [0x%X, 0x%X); expected within [0x%X, 0x%X)", off, off+size, seg.Offset, seg.Offset+seg.Filesz)
+	}
+	buf := make([]byte, size)
+	if _, err := seg.ReadAt(buf, int64(off-seg.Offset)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf, nil
+}
+
+// parseExportTrie parses the given export trie, and records the function
+// exports and re-exports in file. The export trie is a prefix tree of symbol
+// names, where terminal nodes hold the export information of the symbol.
+//
+// ref: https://opensource.apple.com/source/dyld/dyld-852.2/src/MachOLoaded.cpp
+func parseExportTrie(file *bin.File, trie []byte, libs []string) error {
+	// Export symbol flags.
+	const (
+		// Mask of export symbol kind.
+		exportSymbolKindMask = 0x03
+		// Regular export symbol.
+		exportSymbolKindRegular = 0x00
+		// Re-exported symbol of another library.
+		exportSymbolReexport = 0x08
+	)
+	visited := make(map[uint64]bool)
+	var walk func(offset uint64, prefix string) error
+	walk = func(offset uint64, prefix string) error {
+		if visited[offset] {
+			return errors.Errorf("invalid export trie; cycle at node offset 0x%X", offset)
+		}
+		visited[offset] = true
+		if offset >= uint64(len(trie)) {
+			return errors.Errorf("invalid export trie node offset; expected < 0x%X, got 0x%X", len(trie), offset)
+		}
+		r := bytes.NewReader(trie[offset:])
+		terminalSize, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if terminalSize > 0 {
+			start := offset + uint64(len(trie[offset:])-r.Len())
+			end := start + terminalSize
+			if end > uint64(len(trie)) {
+				return errors.Errorf("invalid export trie terminal size at node offset 0x%X; expected <= 0x%X, got 0x%X", offset, uint64(len(trie))-start, terminalSize)
+			}
+			tr := bytes.NewReader(trie[start:end])
+			flags, err := binary.ReadUvarint(tr)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			name := symName(prefix)
+			switch {
+			case flags&exportSymbolReexport != 0:
+				ordinal, err := binary.ReadUvarint(tr)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				impName, err := readString(tr)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				if len(impName) == 0 {
+					impName = prefix
+				}
+				lib := fmt.Sprintf("library_%d", ordinal)
+				if 1 <= ordinal && ordinal <= uint64(len(libs)) {
+					lib = libs[ordinal-1]
+				}
+				file.Forwards[name] = lib + "." + symName(impName)
+			case flags&exportSymbolKindMask == exportSymbolKindRegular:
+				// Note, for stub and resolver symbols the address is that of the
+				// stub.
+				off, err := binary.ReadUvarint(tr)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				addr := file.Base + bin.Address(off)
+				if _, ok := file.Exports[addr]; !ok {
+					dbg.Printf("export %q at %v", name, addr)
+					file.Exports[addr] = name
+				}
+			default:
+				// skip thread local and absolute symbols.
+			}
+			if _, err := r.Seek(int64(terminalSize), io.SeekCurrent); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		nchildren, err := r.ReadByte()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for i := 0; i < int(nchildren); i++ {
+			edge, err := readString(r)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			child, err := binary.ReadUvarint(r)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := walk(child, prefix+edge); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	}
+	return walk(0, "")
+}
+
+// parsePerm returns the memory access permissions represented by the given
+// Mach-O virtual memory protection.
+func parsePerm(prot uint32) bin.Perm {
+	// Virtual memory protection.
+	const (
+		// vmProtRead specifies that the memory is readable.
+		vmProtRead = 0x1
+		// vmProtWrite specifies that the memory is writeable.
+		vmProtWrite = 0x2
+		// vmProtExecute specifies that the memory is executable.
+		vmProtExecute = 0x4
+	)
+	var perm bin.Perm
+	if prot&vmProtRead != 0 {
+		perm |= bin.PermR
+	}
+	if prot&vmProtWrite != 0 {
+		perm |= bin.PermW
+	}
+	if prot&vmProtExecute != 0 {
+		perm |= bin.PermX
+	}
+	return perm
+}
+
+// ### [ Helper functions ] ####################################################
+
+// symName returns the C name of the given Mach-O symbol name; i.e. without the
+// leading underscore.
+func symName(name string) string {
+	return strings.TrimPrefix(name, "_")
+}
+
+// parseString parses the NULL-terminated string in the given data; or the
+// entire data if not NULL-terminated.
+func parseString(data []byte) string {
+	pos := bytes.IndexByte(data, '\x00')
+	if pos == -1 {
+		return string(data)
+	}
+	return string(data[:pos])
+}
+
+// readString reads and returns the NULL-terminated string from r.
+func readString(r io.ByteReader) (string, error) {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if b == 0 {
+			break
+		}
+		buf = append(buf, b)
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,365 @@
+package macho
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+func TestParse(t *testing.T) {
+	golden := []struct {
+		name string
+		// Mach-O file contents.
+		data []byte
+		// Expected machine architecture.
+		arch bin.Arch
+		// Expected base address.
+		base bin.Address
+		// Expected entry point address.
+		entry bin.Address
+		// Expected imports.
+		imports map[bin.Address]string
+		// Expected data addresses.
+		dataAddrs []bin.Address
+		// Expected exports.
+		exports map[bin.Address]string
+		// Expected forwarded exports.
+		forwards map[string]string
+	}{
+		// 32-bit x86 executable with LC_UNIXTHREAD entry point and symbol table
+		// exports.
+		{
+			name:  "32-bit",
+			data:  buildImage(macho.Cpu386, true, false),
+			arch:  bin.ArchX86_32,
+			base:  0x1000,
+			entry: 0x1800,
+			imports: map[bin.Address]string{
+				0x1810: "puts",
+				0x1816: "exit",
+				0x2008: "environ",
+			},
+			dataAddrs: []bin.Address{0x2000, 0x2004},
+			exports:   map[bin.Address]string{0x1800: "main"},
+			forwards:  map[string]string{},
+		},
+		// 64-bit x86-64 executable with LC_MAIN entry point and export trie.
+		{
+			name:  "64-bit",
+			data:  buildImage(macho.CpuAmd64, false, true),
+			arch:  bin.ArchX86_64,
+			base:  0x100000000,
+			entry: 0x100000800,
+			imports: map[bin.Address]string{
+				0x100000810: "puts",
+				0x100000816: "exit",
+				0x100001010: "environ",
+			},
+			dataAddrs: []bin.Address{0x100001000, 0x100001008},
+			exports:   map[bin.Address]string{0x100000800: "main"},
+			forwards:  map[string]string{"foo": "/usr/lib/libSystem.B.dylib.bar"},
+		},
+		// 64-bit x86-64 executable with LC_UNIXTHREAD entry point.
+		{
+			name:  "64-bit LC_UNIXTHREAD",
+			data:  buildImage(macho.CpuAmd64, true, true),
+			arch:  bin.ArchX86_64,
+			base:  0x100000000,
+			entry: 0x100000800,
+			imports: map[bin.Address]string{
+				0x100000810: "puts",
+				0x100000816: "exit",
+				0x100001010: "environ",
+			},
+			dataAddrs: []bin.Address{0x100001000, 0x100001008},
+			exports:   map[bin.Address]string{0x100000800: "main"},
+			forwards:  map[string]string{"foo": "/usr/lib/libSystem.B.dylib.bar"},
+		},
+		// Universal binary with an unsupported machine architecture followed by
+		// x86-64.
+		{
+			name: "universal binary",
+			data: buildFat(
+				buildImage(macho.Cpu(14), false, true),
+				buildImage(macho.CpuAmd64, false, true),
+			),
+			arch:  bin.ArchX86_64,
+			base:  0x100000000,
+			entry: 0x100000800,
+			imports: map[bin.Address]string{
+				0x100000810: "puts",
+				0x100000816: "exit",
+				0x100001010: "environ",
+			},
+			dataAddrs: []bin.Address{0x100001000, 0x100001008},
+			exports:   map[bin.Address]string{0x100000800: "main"},
+			forwards:  map[string]string{"foo": "/usr/lib/libSystem.B.dylib.bar"},
+		},
+	}
+	for _, g := range golden {
+		info, err := Identify(bytes.NewReader(g.data))
+		if err != nil {
+			t.Errorf("%s: unable to identify Mach-O file; %+v", g.name, err)
+			continue
+		}
+		if info.Format != "macho" || info.Arch != g.arch {
+			t.Errorf("%s: format or machine architecture mismatch; expected macho and %v, got %s and %v", g.name, g.arch, info.Format, info.Arch)
+		}
+		file, err := Parse(bytes.NewReader(g.data))
+		if err != nil {
+			t.Errorf("%s: unable to parse Mach-O file; %+v", g.name, err)
+			continue
+		}
+		if file.Arch != g.arch {
+			t.Errorf("%s: machine architecture mismatch; expected %v, got %v", g.name, g.arch, file.Arch)
+		}
+		if file.Base != g.base {
+			t.Errorf("%s: base address mismatch; expected %v, got %v", g.name, g.base, file.Base)
+		}
+		if file.Entry != g.entry {
+			t.Errorf("%s: entry point mismatch; expected %v, got %v", g.name, g.entry, file.Entry)
+		}
+		if !reflect.DeepEqual(file.Imports, g.imports) {
+			t.Errorf("%s: imports mismatch; expected %v, got %v", g.name, g.imports, file.Imports)
+		}
+		if !reflect.DeepEqual(file.DataAddrs, g.dataAddrs) {
+			t.Errorf("%s: data addresses mismatch; expected %v, got %v", g.name, g.dataAddrs, file.DataAddrs)
+		}
+		if !reflect.DeepEqual(file.Exports, g.exports) {
+			t.Errorf("%s: exports mismatch; expected %v, got %v", g.name, g.exports, file.Exports)
+		}
+		if !reflect.DeepEqual(file.Forwards, g.forwards) {
+			t.Errorf("%s: forwarded exports mismatch; expected %v, got %v", g.name, g.forwards, file.Forwards)
+		}
+		if file.Relocatable {
+			t.Errorf("%s: unexpected relocatable Mach-O file", g.name)
+		}
+	}
+}
+
+func TestParseUnsupportedMachine(t *testing.T) {
+	data := buildFat(buildImage(macho.Cpu(14), false, false))
+	_, err := Parse(bytes.NewReader(data))
+	if _, ok := errors.Cause(err).(*bin.UnsupportedMachineError); !ok {
+		t.Errorf("error mismatch; expected *bin.UnsupportedMachineError, got %v", err)
+	}
+}
+
+// buildImage returns a minimal little-endian Mach-O executable of the given CPU
+// type, with an entry point specified by either LC_UNIXTHREAD or LC_MAIN, and
+// exports specified by either the export trie or the symbol table.
+//
+// The executable imports puts and exit through symbol stubs, and environ
+// through a non-lazy symbol pointer.
+//
+//    Segment      File offset   Sections
+//
+//    __PAGEZERO   -             -
+//    __TEXT       0x0000        __text (0x800), __stubs (0x810)
+//    __DATA       0x1000        __la_symbol_ptr (0x1000), __got
+//    __LINKEDIT   0x2000        symbol table (0x2000), string table (0x2100),
+//                               indirect symbol table (0x2200), export trie
+//                               (0x2300)
+func buildImage(cpu macho.Cpu, unixThread, trie bool) []byte {
+	const (
+		// Load command types.
+		lcSegment   = 0x01
+		lcSymtab    = 0x02
+		lcDysymtab  = 0x0B
+		lcLoadDylib = 0x0C
+		lcSegment64 = 0x19
+		pageSize    = 0x1000
+		stubSize    = 6
+		libSystem   = "/usr/lib/libSystem.B.dylib"
+		symtabOff   = 0x2000
+		strtabOff   = 0x2100
+		indirectOff = 0x2200
+		trieOff     = 0x2300
+		textOff     = 0x800
+		stubsOff    = 0x810
+		dataOff     = 0x1000
+		linkeditOff = 0x2000
+		vmProtRead  = 0x1
+		vmProtWrite = 0x2
+		vmProtExec  = 0x4
+		// S_ATTR_PURE_INSTRUCTIONS | S_ATTR_SOME_INSTRUCTIONS
+		sectAttrCode = 0x80000400
+	)
+	is64 := cpu&0x01000000 != 0
+	order := binary.LittleEndian
+	base := uint64(0x1000)
+	ptrSize := uint64(4)
+	if is64 {
+		base = 0x100000000
+		ptrSize = 8
+	}
+	file := make([]byte, 3*pageSize)
+	put := func(offset int, v interface{}) {
+		buf := &bytes.Buffer{}
+		if err := binary.Write(buf, order, v); err != nil {
+			panic(err)
+		}
+		copy(file[offset:], buf.Bytes())
+	}
+
+	// Symbol table, string table and indirect symbol table.
+	strtab := "\x00_main\x00_puts\x00_exit\x00_environ\x00"
+	copy(file[strtabOff:], strtab)
+	syms := []struct {
+		name  uint32
+		typ   uint8
+		sect  uint8
+		value uint64
+	}{
+		{name: 1, typ: 0x0F, sect: 1, value: base + textOff}, // _main (N_SECT | N_EXT)
+		{name: 7, typ: 0x01},  // _puts (N_UNDF | N_EXT)
+		{name: 13, typ: 0x01}, // _exit (N_UNDF | N_EXT)
+		{name: 19, typ: 0x01}, // _environ (N_UNDF | N_EXT)
+	}
+	for i, sym := range syms {
+		if is64 {
+			put(symtabOff+i*16, macho.Nlist64{Name: sym.name, Type: sym.typ, Sect: sym.sect, Desc: 1 << 8, Value: sym.value})
+		} else {
+			put(symtabOff+i*12, macho.Nlist32{Name: sym.name, Type: sym.typ, Sect: sym.sect, Desc: 1 << 8, Value: uint32(sym.value)})
+		}
+	}
+	// __stubs: puts, exit; __la_symbol_ptr: puts, exit; __got: environ, local.
+	indirectSyms := []uint32{1, 2, 1, 2, 3, 0x80000000}
+	put(indirectOff, indirectSyms)
+	// Export trie of _main (regular) and _foo (re-exported _bar of libSystem).
+	//
+	//    0x00  root node
+	//    0x05  "_" node
+	//    0x12  "_main" node
+	//    0x17  "_foo" node
+	copy(file[trieOff:], []byte{
+		0x00, 0x01, '_', 0x00, 0x05,
+		0x00, 0x02, 'm', 'a', 'i', 'n', 0x00, 0x12, 'f', 'o', 'o', 0x00, 0x17,
+		0x03, 0x00, 0x80, 0x10, 0x00,
+		0x07, 0x08, 0x01, '_', 'b', 'a', 'r', 0x00, 0x00,
+	})
+
+	// Load commands.
+	cmds := &bytes.Buffer{}
+	ncmd := 0
+	write := func(vs ...interface{}) {
+		for _, v := range vs {
+			if err := binary.Write(cmds, order, v); err != nil {
+				panic(err)
+			}
+		}
+	}
+	name16 := func(s string) (name [16]byte) {
+		copy(name[:], s)
+		return name
+	}
+	type sect struct {
+		name, seg           string
+		addr, size          uint64
+		offset, flags, res1 uint32
+		res2                uint32
+	}
+	segment := func(name string, addr, memsz, offset, filesz uint64, prot uint32, sects ...sect) {
+		ncmd++
+		if is64 {
+			write(macho.Segment64{Cmd: lcSegment64, Len: uint32(72 + 80*len(sects)), Name: name16(name), Addr: addr, Memsz: memsz, Offset: offset, Filesz: filesz, Maxprot: prot, Prot: prot, Nsect: uint32(len(sects))})
+			for _, s := range sects {
+				write(macho.Section64{Name: name16(s.name), Seg: name16(s.seg), Addr: s.addr, Size: s.size, Offset: s.offset, Flags: s.flags, Reserve1: s.res1, Reserve2: s.res2})
+			}
+			return
+		}
+		write(macho.Segment32{Cmd: lcSegment, Len: uint32(56 + 68*len(sects)), Name: name16(name), Addr: uint32(addr), Memsz: uint32(memsz), Offset: uint32(offset), Filesz: uint32(filesz), Maxprot: prot, Prot: prot, Nsect: uint32(len(sects))})
+		for _, s := range sects {
+			write(macho.Section32{Name: name16(s.name), Seg: name16(s.seg), Addr: uint32(s.addr), Size: uint32(s.size), Offset: s.offset, Flags: s.flags, Reserve1: s.res1, Reserve2: s.res2})
+		}
+	}
+	segment("__PAGEZERO", 0, base, 0, 0, 0)
+	segment("__TEXT", base, pageSize, 0, pageSize, vmProtRead|vmProtExec,
+		sect{name: "__text", seg: "__TEXT", addr: base + textOff, size: stubsOff - textOff, offset: textOff, flags: sectAttrCode},
+		sect{name: "__stubs", seg: "__TEXT", addr: base + stubsOff, size: 2 * stubSize, offset: stubsOff, flags: sectAttrCode | sectTypeSymbolStubs, res1: 0, res2: stubSize},
+	)
+	segment("__DATA", base+dataOff, pageSize, dataOff, pageSize, vmProtRead|vmProtWrite,
+		sect{name: "__la_symbol_ptr", seg: "__DATA", addr: base + dataOff, size: 2 * ptrSize, offset: dataOff, flags: sectTypeLazySymbolPointers, res1: 2},
+		sect{name: "__got", seg: "__DATA", addr: base + dataOff + 2*ptrSize, size: 2 * ptrSize, offset: uint32(dataOff + 2*ptrSize), flags: sectTypeNonLazySymbolPointers, res1: 4},
+	)
+	segment("__LINKEDIT", base+linkeditOff, pageSize, linkeditOff, pageSize, vmProtRead)
+	ncmd++
+	write(macho.SymtabCmd{Cmd: lcSymtab, Len: 24, Symoff: symtabOff, Nsyms: uint32(len(syms)), Stroff: strtabOff, Strsize: uint32(len(strtab))})
+	ncmd++
+	write(macho.DysymtabCmd{Cmd: lcDysymtab, Len: 80, Indirectsymoff: indirectOff, Nindirectsyms: uint32(len(indirectSyms))})
+	ncmd++
+	dylibName := make([]byte, 32)
+	copy(dylibName, libSystem)
+	write(macho.DylibCmd{Cmd: lcLoadDylib, Len: uint32(24 + len(dylibName)), Name: 24}, dylibName)
+	if trie {
+		ncmd++
+		// dyld_info_command with export trie only.
+		write(loadCmdDyldInfoOnly, uint32(48), [8]uint32{}, uint32(trieOff), uint32(32))
+	}
+	entry := base + textOff
+	switch {
+	case unixThread && is64:
+		// x86_THREAD_STATE64 with rip at index 16.
+		ncmd++
+		state := make([]uint64, 21)
+		state[16] = entry
+		write(loadCmdUnixThread, uint32(16+8*len(state)), uint32(4), uint32(2*len(state)), state)
+	case unixThread:
+		// x86_THREAD_STATE32 with eip at index 10.
+		ncmd++
+		state := make([]uint32, 16)
+		state[10] = uint32(entry)
+		write(loadCmdUnixThread, uint32(16+4*len(state)), uint32(1), uint32(len(state)), state)
+	default:
+		// entry_point_command.
+		ncmd++
+		write(loadCmdMain, uint32(24), uint64(textOff), uint64(0))
+	}
+
+	// Mach-O header.
+	hdr := macho.FileHeader{
+		Magic: macho.Magic32,
+		Cpu:   cpu,
+		Type:  macho.TypeExec,
+		Ncmd:  uint32(ncmd),
+		Cmdsz: uint32(cmds.Len()),
+	}
+	hdrSize := 28
+	if is64 {
+		hdr.Magic = macho.Magic64
+		hdrSize = 32
+	}
+	put(0, hdr)
+	copy(file[hdrSize:], cmds.Bytes())
+	return file
+}
+
+// buildFat returns a universal binary containing the given Mach-O files.
+func buildFat(files ...[]byte) []byte {
+	const align = 0x1000
+	buf := &bytes.Buffer{}
+	w := func(v interface{}) {
+		if err := binary.Write(buf, binary.BigEndian, v); err != nil {
+			panic(err)
+		}
+	}
+	w(macho.MagicFat)
+	w(uint32(len(files)))
+	offset := uint32(align)
+	for _, data := range files {
+		// fat_arch: cputype, cpusubtype, offset, size, align
+		cpu := binary.LittleEndian.Uint32(data[4:])
+		w([5]uint32{cpu, 0, offset, uint32(len(data)), 12})
+		offset += uint32(len(data))
+	}
+	buf.Write(make([]byte, align-buf.Len()))
+	for _, data := range files {
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,187 @@
+// Package mz provides access to DOS MZ executables.
+//
+// Addresses of DOS MZ executables are linear real mode addresses (segment*16 +
+// offset), where the load module is loaded at segment 0x1000 (i.e. address
+// 0x10000).
+package mz
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger with the "mz:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("mz:")+" ", 0)
+	// warn is a logger with the "mz:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("mz:")+" ", 0)
+)
+
+// LoadSeg is the segment at which the load module is loaded.
+const LoadSeg = 0x1000
+
+// Linear returns the linear address of the given segment:offset address.
+func Linear(seg, off uint16) bin.Address {
+	return bin.Address(seg)<<4 + bin.Address(off)
+}
+
+// ParseFile parses the given DOS MZ executable, reading from path.
+func ParseFile(path string) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses the given DOS MZ executable, reading from r.
+//
+// Users are responsible for closing r.
+func Parse(r io.ReaderAt) (*bin.File, error) {
+	// Parse DOS header.
+	hdr := &Header{}
+	if err := binary.Read(io.NewSectionReader(r, 0, headerSize), binary.LittleEndian, hdr); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if hdr.Magic != 0x5A4D && hdr.Magic != 0x4D5A {
+		return nil, errors.Errorf("invalid DOS MZ signature; expected \"MZ\" or \"ZM\", got 0x%04X", hdr.Magic)
+	}
+	dbg.Printf("DOS header: %+v", hdr)
+
+	// Read load module; the contents of the executable following the header, as
+	// specified by the number of pages and bytes on the last page.
+	hdrSize := int64(hdr.HeaderParagraphs) * 16
+	imageSize := int64(hdr.Pages) * 512
+	if hdr.LastPageBytes != 0 {
+		imageSize -= 512 - int64(hdr.LastPageBytes)
+	}
+	imageSize -= hdrSize
+	if imageSize < 0 {
+		return nil, errors.Errorf("invalid DOS MZ load module size; expected >= 0, got %d", imageSize)
+	}
+	data := make([]byte, imageSize)
+	n, err := r.ReadAt(data, hdrSize)
+	if err != nil && errors.Cause(err) != io.EOF {
+		return nil, errors.WithStack(err)
+	}
+	if n < len(data) {
+		// Linkers are known to produce files which are shorter than specified
+		// by the header.
+		warn.Printf("truncated load module; expected %d bytes, got %d", len(data), n)
+		data = data[:n]
+	}
+
+	file := &bin.File{
+		Arch:        bin.ArchX86_16,
+		Base:        Linear(LoadSeg, 0),
+		Entry:       Linear(LoadSeg+hdr.CS, hdr.IP),
+		Imports:     make(map[bin.Address]string),
+		Exports:     make(map[bin.Address]string),
+		Forwards:    make(map[string]string),
+		Relocs:      make(map[bin.Address]bin.RelocKind),
+		Relocatable: true,
+	}
+
+	// Parse relocation table; segment:offset addresses of segment words,
+	// relative to the start of the load module.
+	segs := map[uint16]bool{0: true, hdr.CS: true, hdr.SS: true}
+	for i := 0; i < int(hdr.NRelocs); i++ {
+		var reloc struct {
+			Off uint16
+			Seg uint16
+		}
+		sr := io.NewSectionReader(r, int64(hdr.RelocOffset)+int64(i)*4, 4)
+		if err := binary.Read(sr, binary.LittleEndian, &reloc); err != nil {
+			return nil, errors.Wrapf(err, "unable to read relocation %d", i)
+		}
+		offset := Linear(reloc.Seg, reloc.Off)
+		if offset+2 > bin.Address(len(data)) {
+			warn.Printf("relocation %d at %04X:%04X outside of load module", i, reloc.Seg, reloc.Off)
+			continue
+		}
+		seg := binary.LittleEndian.Uint16(data[offset:])
+		segs[seg] = true
+		binary.LittleEndian.PutUint16(data[offset:], seg+LoadSeg)
+		file.Relocs[file.Base+offset] = bin.RelocSeg16
+	}
+
+	// Partition the load module into segments, as identified by the code and
+	// stack segments and the targets of segment relocations.
+	var starts []int
+	for seg := range segs {
+		if start := int(seg) * 16; start < len(data) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Ints(starts)
+	// Memory following the load module (e.g. uninitialized data and stack).
+	extraSize := int(hdr.MinAlloc) * 16
+	for i, start := range starts {
+		end := len(data)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		memSize := end - start
+		if i == len(starts)-1 {
+			memSize += extraSize
+		}
+		sect := &bin.Section{
+			Name:     fmt.Sprintf("seg%03d", i),
+			Addr:     file.Base + bin.Address(start),
+			Offset:   uint64(hdrSize) + uint64(start),
+			Data:     data[start:end],
+			FileSize: end - start,
+			MemSize:  memSize,
+			Perm:     bin.PermR | bin.PermW | bin.PermX,
+		}
+		file.Sections = append(file.Sections, sect)
+	}
+	return file, nil
+}
+
+// headerSize is the size in bytes of the DOS header, excluding reserved fields.
+const headerSize = 28
+
+// Header is a DOS header.
+type Header struct {
+	// Magic number; "MZ".
+	Magic uint16
+	// Bytes on the last page of the file; or 0 if full page.
+	LastPageBytes uint16
+	// Pages of 512 bytes in the file.
+	Pages uint16
+	// Number of relocations.
+	NRelocs uint16
+	// Size of the header in paragraphs.
+	HeaderParagraphs uint16
+	// Minimum number of extra paragraphs.
+	MinAlloc uint16
+	// Maximum number of extra paragraphs.
+	MaxAlloc uint16
+	// Initial SS value, relative to the load segment.
+	SS uint16
+	// Initial SP value.
+	SP uint16
+	// Checksum.
+	Checksum uint16
+	// Initial IP value.
+	IP uint16
+	// Initial CS value, relative to the load segment.
+	CS uint16
+	// File offset of the relocation table.
+	RelocOffset uint16
+	// Overlay number.
+	Overlay uint16
+}
@@ -0,0 +1,508 @@
+// Package ne provides access to NE (New Executable) files, as used by 16-bit
+// Windows and OS/2 executables.
+//
+// The segments of NE files are loaded at consecutive paragraph-aligned linear
+// real mode addresses, starting at address 0x10000. The selector of each
+// segment is thus its linear address divided by 16, and the linear address of
+// a selector:offset address is computed as selector*16 + offset.
+//
+// ref: https://wiki.osdev.org/NE
+package ne
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/bin/mz"
+	"github.com/mewkiz/pkg/pathutil"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger with the "ne:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("ne:")+" ", 0)
+	// warn is a logger with the "ne:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("ne:")+" ", 0)
+)
+
+// ParseFile parses the given NE binary executable, reading from path.
+func ParseFile(path string) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses the given NE binary executable, reading from r. The NE header
+// is located by the e_lfanew field of the DOS header.
+//
+// Users are responsible for closing r.
+func Parse(r io.ReaderAt) (*bin.File, error) {
+	// Locate NE header.
+	var buf [4]byte
+	if _, err := r.ReadAt(buf[:], 0x3C); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	hdrOffset := int64(binary.LittleEndian.Uint32(buf[:]))
+	hdr := &Header{}
+	if err := binary.Read(io.NewSectionReader(r, hdrOffset, headerSize), binary.LittleEndian, hdr); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if string(hdr.Magic[:]) != "NE" {
+		return nil, errors.Errorf("invalid NE signature; expected \"NE\", got %q", hdr.Magic[:])
+	}
+	dbg.Printf("NE header: %+v", hdr)
+	p := &parser{
+		r:         r,
+		hdrOffset: hdrOffset,
+		hdr:       hdr,
+		file: &bin.File{
+			Arch:        bin.ArchX86_16,
+			Base:        mz.Linear(mz.LoadSeg, 0),
+			Imports:     make(map[bin.Address]string),
+			Exports:     make(map[bin.Address]string),
+			Forwards:    make(map[string]string),
+			Relocs:      make(map[bin.Address]bin.RelocKind),
+			Relocatable: true,
+		},
+		externs: make(map[string]bin.Address),
+	}
+	if err := p.parseSegments(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseModuleRefs(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseEntries(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseNames(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseRelocs(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Parse entry point.
+	if hdr.CS != 0 {
+		seg, err := p.segment(int(hdr.CS))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid entry point")
+		}
+		p.file.Entry = seg.Addr + bin.Address(hdr.IP)
+	}
+	// Add synthetic segment of imported functions.
+	if len(p.externs) > 0 {
+		p.file.Sections = append(p.file.Sections, &bin.Section{
+			Name:    ".extern",
+			Addr:    p.externStart,
+			Data:    make([]byte, p.externEnd-p.externStart),
+			MemSize: int(p.externEnd - p.externStart),
+			Perm:    bin.PermR,
+		})
+	}
+	return p.file, nil
+}
+
+// parser tracks information required to parse NE files.
+type parser struct {
+	// Reader of the NE file.
+	r io.ReaderAt
+	// File offset of the NE header; the offsets of most NE tables are relative
+	// to the NE header.
+	hdrOffset int64
+	// NE header.
+	hdr *Header
+	// Binary executable.
+	file *bin.File
+	// Segments, indexed by segment number - 1.
+	segs []*segment
+	// Module names, indexed by module reference index - 1.
+	modules []string
+	// Entry points, indexed by ordinal - 1; or nil if unused ordinal.
+	entries []*entry
+	// Map from imported function name to address of synthetic import slot.
+	externs map[string]bin.Address
+	// Start and end address of synthetic import slots.
+	externStart, externEnd bin.Address
+}
+
+// A segment is a segment of an NE file.
+type segment struct {
+	// Segment table entry.
+	SegmentEntry
+	// Loaded segment.
+	*bin.Section
+}
+
+// An entry is an entry point of an NE file.
+type entry struct {
+	// Segment number (1-based).
+	seg int
+	// Offset within the segment.
+	offset uint16
+}
+
+// Segment flags.
+const (
+	// Data segment; code segment otherwise.
+	segData = 0x0001
+	// Execute-only code segment or read-only data segment.
+	segReadOnly = 0x0080
+	// Segment contains relocation information.
+	segRelocInfo = 0x0100
+)
+
+// parseSegments parses the segment table and loads the segments at consecutive
+// paragraph-aligned addresses.
+func (p *parser) parseSegments() error {
+	const entrySize = 8
+	off := p.hdrOffset + int64(p.hdr.SegmentTableOffset)
+	sr := io.NewSectionReader(p.r, off, int64(p.hdr.NSegments)*entrySize)
+	// A logical sector alignment shift count of 0 specifies 9 (512-byte
+	// sectors).
+	alignShift := uint(p.hdr.AlignShift)
+	if alignShift == 0 {
+		alignShift = 9
+	}
+	const maxAlignShift = 16
+	if alignShift > maxAlignShift {
+		return errors.Errorf("invalid logical sector alignment shift count; expected <= %d, got %d", maxAlignShift, alignShift)
+	}
+	addr := p.file.Base
+	for i := 0; i < int(p.hdr.NSegments); i++ {
+		var e SegmentEntry
+		if err := binary.Read(sr, binary.LittleEndian, &e); err != nil {
+			return errors.Wrapf(err, "unable to read segment table entry %d", i+1)
+		}
+		// A length or minimum allocation size of 0 specifies 64K.
+		fileSize := int(e.Length)
+		if fileSize == 0 && e.SectorOffset != 0 {
+			fileSize = 0x10000
+		}
+		if e.SectorOffset == 0 {
+			fileSize = 0
+		}
+		memSize := int(e.MinAlloc)
+		if memSize == 0 {
+			memSize = 0x10000
+		}
+		if memSize < fileSize {
+			memSize = fileSize
+		}
+		data := make([]byte, memSize)
+		offset := int64(e.SectorOffset) << alignShift
+		if fileSize > 0 {
+			if _, err := p.r.ReadAt(data[:fileSize], offset); err != nil {
+				return errors.Wrapf(err, "unable to read contents of segment %d", i+1)
+			}
+		}
+		perm := bin.PermR | bin.PermX
+		if e.Flags&segData != 0 {
+			perm = bin.PermR | bin.PermW
+			if e.Flags&segReadOnly != 0 {
+				perm = bin.PermR
+			}
+		}
+		sect := &bin.Section{
+			Name:     fmt.Sprintf("seg%03d", i+1),
+			Addr:     addr,
+			Offset:   uint64(offset),
+			Data:     data[:fileSize],
+			FileSize: fileSize,
+			MemSize:  memSize,
+			Perm:     perm,
+		}
+		p.segs = append(p.segs, &segment{SegmentEntry: e, Section: sect})
+		p.file.Sections = append(p.file.Sections, sect)
+		addr = align16(addr + bin.Address(memSize))
+	}
+	p.externStart = addr
+	p.externEnd = addr
+	return nil
+}
+
+// parseModuleRefs parses the module reference table; offsets into the
+// imported names table of the names of imported modules.
+func (p *parser) parseModuleRefs() error {
+	off := p.hdrOffset + int64(p.hdr.ModuleRefTableOffset)
+	for i := 0; i < int(p.hdr.NModuleRefs); i++ {
+		var buf [2]byte
+		if _, err := p.r.ReadAt(buf[:], off+int64(i)*2); err != nil {
+			return errors.Wrapf(err, "unable to read module reference %d", i+1)
+		}
+		name, err := p.importedName(binary.LittleEndian.Uint16(buf[:]))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		p.modules = append(p.modules, name)
+	}
+	return nil
+}
+
+// parseEntries parses the entry table.
+//
+// The entry table consists of bundles of entry points with consecutive
+// ordinals, each prefixed by the number of entries and a segment indicator.
+//
+//	0x00      unused ordinals
+//	0x01-0xFE fixed segment number; 3-byte entries (flags, offset)
+//	0xFF      movable segments; 6-byte entries (flags, INT 3Fh, segment, offset)
+func (p *parser) parseEntries() error {
+	off := p.hdrOffset + int64(p.hdr.EntryTableOffset)
+	data := make([]byte, p.hdr.EntryTableSize)
+	if _, err := p.r.ReadAt(data, off); err != nil {
+		return errors.Wrap(err, "unable to read entry table")
+	}
+	for len(data) >= 2 {
+		n := int(data[0])
+		indicator := data[1]
+		data = data[2:]
+		if n == 0 {
+			break
+		}
+		switch indicator {
+		case 0x00:
+			for i := 0; i < n; i++ {
+				p.entries = append(p.entries, nil)
+			}
+		case 0xFF:
+			if len(data) < n*6 {
+				return errors.New("invalid entry table; truncated movable entry bundle")
+			}
+			for i := 0; i < n; i++ {
+				e := data[i*6:]
+				p.entries = append(p.entries, &entry{seg: int(e[3]), offset: binary.LittleEndian.Uint16(e[4:])})
+			}
+			data = data[n*6:]
+		default:
+			if len(data) < n*3 {
+				return errors.New("invalid entry table; truncated fixed entry bundle")
+			}
+			for i := 0; i < n; i++ {
+				e := data[i*3:]
+				p.entries = append(p.entries, &entry{seg: int(indicator), offset: binary.LittleEndian.Uint16(e[1:])})
+			}
+			data = data[n*3:]
+		}
+	}
+	return nil
+}
+
+// parseNames parses the resident and non-resident names tables, and records
+// the named entry points as exports. Unnamed entry points are exported by
+// ordinal.
+func (p *parser) parseNames() error {
+	names := make(map[uint16]string)
+	// The resident names table is terminated by a zero-length name.
+	resident := io.NewSectionReader(p.r, p.hdrOffset+int64(p.hdr.ResidentNamesOffset), int64(p.hdr.ModuleRefTableOffset)-int64(p.hdr.ResidentNamesOffset))
+	if err := parseNameTable(resident, names); err != nil {
+		return errors.Wrap(err, "unable to parse resident names table")
+	}
+	if p.hdr.NonResidentNamesSize > 0 {
+		nonresident := io.NewSectionReader(p.r, int64(p.hdr.NonResidentNamesOffset), int64(p.hdr.NonResidentNamesSize))
+		if err := parseNameTable(nonresident, names); err != nil {
+			return errors.Wrap(err, "unable to parse non-resident names table")
+		}
+	}
+	// The name of ordinal 0 is the module name (resident) or description
+	// (non-resident).
+	moduleName := names[0]
+	for i, e := range p.entries {
+		if e == nil {
+			continue
+		}
+		ordinal := uint16(i + 1)
+		seg, err := p.segment(e.seg)
+		if err != nil {
+			warn.Printf("invalid entry point of ordinal %d; %v", ordinal, err)
+			continue
+		}
+		addr := seg.Addr + bin.Address(e.offset)
+		name, ok := names[ordinal]
+		if !ok {
+			name = fmt.Sprintf("%s_ordinal_%d", moduleName, ordinal)
+		}
+		if _, ok := p.file.Exports[addr]; !ok {
+			p.file.Exports[addr] = name
+		}
+	}
+	return nil
+}
+
+// parseNameTable parses the given resident or non-resident names table, and
+// records the name of each ordinal; the first name of the table takes
+// precedence.
+//
+//	length  uint8
+//	name    [length]byte
+//	ordinal uint16
+func parseNameTable(r io.Reader, names map[uint16]string) error {
+	first := true
+	for {
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+		if n[0] == 0 {
+			return nil
+		}
+		buf := make([]byte, int(n[0])+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return errors.WithStack(err)
+		}
+		name := string(buf[:n[0]])
+		ordinal := binary.LittleEndian.Uint16(buf[n[0]:])
+		if first {
+			// Module name or description.
+			ordinal = 0
+			first = false
+		}
+		if _, ok := names[ordinal]; !ok {
+			names[ordinal] = name
+		}
+	}
+}
+
+// importedName returns the length-prefixed name at the given offset of the
+// imported names table.
+func (p *parser) importedName(offset uint16) (string, error) {
+	off := p.hdrOffset + int64(p.hdr.ImportedNamesOffset) + int64(offset)
+	var n [1]byte
+	if _, err := p.r.ReadAt(n[:], off); err != nil {
+		return "", errors.Wrapf(err, "unable to read imported name at offset 0x%X", offset)
+	}
+	buf := make([]byte, n[0])
+	if _, err := p.r.ReadAt(buf, off+1); err != nil {
+		return "", errors.Wrapf(err, "unable to read imported name at offset 0x%X", offset)
+	}
+	return string(buf), nil
+}
+
+// segment returns the segment of the given segment number (1-based).
+func (p *parser) segment(segNum int) (*segment, error) {
+	if segNum < 1 || segNum > len(p.segs) {
+		return nil, errors.Errorf("invalid segment number; expected >= 1 and <= %d, got %d", len(p.segs), segNum)
+	}
+	return p.segs[segNum-1], nil
+}
+
+// extern returns the address of the synthetic import slot of the given
+// imported function, allocating a new slot if not yet present.
+func (p *parser) extern(name string) bin.Address {
+	if addr, ok := p.externs[name]; ok {
+		return addr
+	}
+	// Each import slot is the size of a far pointer.
+	addr := p.externEnd
+	p.externEnd += 4
+	p.externs[name] = addr
+	p.file.Imports[addr] = name
+	return addr
+}
+
+// ### [ Helper functions ] ####################################################
+
+// align16 aligns the given address to a paragraph boundary.
+func align16(addr bin.Address) bin.Address {
+	return (addr + 15) &^ 15
+}
+
+// moduleName returns the name of the given imported module, as used for
+// imports by ordinal.
+func moduleName(module string) string {
+	return pathutil.TrimExt(module)
+}
+
+// headerSize is the size in bytes of the NE header.
+const headerSize = 64
+
+// Header is an NE header.
+type Header struct {
+	// Signature; "NE".
+	Magic [2]byte
+	// Linker version and revision.
+	LinkerVersion, LinkerRevision uint8
+	// Offset of the entry table, relative to the NE header.
+	EntryTableOffset uint16
+	// Size in bytes of the entry table.
+	EntryTableSize uint16
+	// File checksum.
+	Checksum uint32
+	// Module flags.
+	Flags uint16
+	// Segment number of the automatic data segment.
+	AutoDataSegment uint16
+	// Initial size of the local heap.
+	HeapSize uint16
+	// Initial size of the stack.
+	StackSize uint16
+	// Initial IP value.
+	IP uint16
+	// Initial CS value; segment number.
+	CS uint16
+	// Initial SP value.
+	SP uint16
+	// Initial SS value; segment number.
+	SS uint16
+	// Number of segment table entries.
+	NSegments uint16
+	// Number of module reference table entries.
+	NModuleRefs uint16
+	// Size in bytes of the non-resident names table.
+	NonResidentNamesSize uint16
+	// Offset of the segment table, relative to the NE header.
+	SegmentTableOffset uint16
+	// Offset of the resource table, relative to the NE header.
+	ResourceTableOffset uint16
+	// Offset of the resident names table, relative to the NE header.
+	ResidentNamesOffset uint16
+	// Offset of the module reference table, relative to the NE header.
+	ModuleRefTableOffset uint16
+	// Offset of the imported names table, relative to the NE header.
+	ImportedNamesOffset uint16
+	// File offset of the non-resident names table.
+	NonResidentNamesOffset uint32
+	// Number of movable entry points.
+	NMovableEntries uint16
+	// Logical sector alignment shift count.
+	AlignShift uint16
+	// Number of resource segments.
+	NResourceSegments uint16
+	// Target operating system.
+	TargetOS uint8
+	// Additional flags.
+	ExtraFlags uint8
+	// Offset of the fast-load area, in sectors.
+	FastLoadOffset uint16
+	// Size of the fast-load area, in sectors.
+	FastLoadSize uint16
+	// Reserved.
+	_ uint16
+	// Expected Windows version.
+	WindowsVersion uint16
+}
+
+// SegmentEntry is a segment table entry.
+type SegmentEntry struct {
+	// Offset of the segment contents, in sectors; or 0 if no contents.
+	SectorOffset uint16
+	// Length of the segment contents in bytes; or 0 for 64K.
+	Length uint16
+	// Segment flags.
+	Flags uint16
+	// Minimum allocation size in bytes; or 0 for 64K.
+	MinAlloc uint16
+}
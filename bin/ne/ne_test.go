@@ -0,0 +1,85 @@
+package ne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestParseSegments(t *testing.T) {
+	// NE file with a single code segment of 4 bytes at file offset 0x200.
+	//
+	//    0x000  DOS header
+	//    0x040  NE header
+	//    0x080  segment table
+	//    0x200  segment contents
+	contents := []byte{0x90, 0x90, 0xC3, 0xCC}
+	newFile := func(alignShift, sectorOffset uint16) []byte {
+		const hdrOffset = 0x40
+		hdr := Header{
+			SegmentTableOffset:   0x40,
+			NSegments:            1,
+			ResidentNamesOffset:  0x48,
+			ModuleRefTableOffset: 0x48,
+			ImportedNamesOffset:  0x48,
+			EntryTableOffset:     0x48,
+			AlignShift:           alignShift,
+			CS:                   1,
+		}
+		copy(hdr.Magic[:], "NE")
+		seg := SegmentEntry{
+			SectorOffset: sectorOffset,
+			Length:       uint16(len(contents)),
+			MinAlloc:     uint16(len(contents)),
+		}
+		buf := &bytes.Buffer{}
+		dosHdr := make([]byte, hdrOffset)
+		copy(dosHdr, "MZ")
+		binary.LittleEndian.PutUint32(dosHdr[0x3C:], hdrOffset)
+		buf.Write(dosHdr)
+		for _, v := range []interface{}{hdr, seg} {
+			if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+				t.Fatalf("unable to write NE file; %v", err)
+			}
+		}
+		buf.Write(make([]byte, 0x200-buf.Len()))
+		buf.Write(contents)
+		return buf.Bytes()
+	}
+
+	golden := []struct {
+		name string
+		// Logical sector alignment shift count.
+		alignShift uint16
+		// Offset of segment contents, in sectors.
+		sectorOffset uint16
+		// Expected error; or empty if valid.
+		err string
+	}{
+		{name: "default alignment shift", alignShift: 0, sectorOffset: 1},
+		{name: "512-byte sectors", alignShift: 9, sectorOffset: 1},
+		{name: "16-byte sectors", alignShift: 4, sectorOffset: 0x20},
+		{name: "invalid alignment shift", alignShift: 17, sectorOffset: 1, err: "invalid logical sector alignment shift count"},
+	}
+	for _, g := range golden {
+		file, err := Parse(bytes.NewReader(newFile(g.alignShift, g.sectorOffset)))
+		if len(g.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), g.err) {
+				t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to parse NE file; %+v", g.name, err)
+			continue
+		}
+		sect := file.Sections[0]
+		if !bytes.Equal(sect.Data, contents) {
+			t.Errorf("%s: segment contents mismatch; expected % X, got % X", g.name, contents, sect.Data)
+		}
+		if sect.Offset != 0x200 {
+			t.Errorf("%s: segment file offset mismatch; expected 0x200, got 0x%X", g.name, sect.Offset)
+		}
+	}
+}
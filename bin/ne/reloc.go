@@ -0,0 +1,209 @@
+package ne
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// Relocation source types; i.e. the kind of the value stored at the relocated
+// address.
+const (
+	// Low byte of offset.
+	srcLoByte = 0x00
+	// 16-bit selector.
+	srcSegment = 0x02
+	// 32-bit far pointer (16-bit offset followed by 16-bit selector).
+	srcFarAddr = 0x03
+	// 16-bit offset.
+	srcOffset = 0x05
+)
+
+// Relocation target types.
+const (
+	// Internal reference to a segment of the module.
+	targetInternal = 0x00
+	// Imported function by ordinal.
+	targetImportOrdinal = 0x01
+	// Imported function by name.
+	targetImportName = 0x02
+	// Operating system fixup (e.g. floating-point emulation).
+	targetOSFixup = 0x03
+	// The target is added to the value stored at the relocated address, rather
+	// than replacing a chain of relocated addresses.
+	targetAdditive = 0x04
+)
+
+// parseRelocs parses and applies the relocation records of each segment.
+//
+// Each relocation record has the following layout.
+//
+//	source type uint8
+//	flags       uint8 (target type)
+//	offset      uint16
+//	target      [4]byte
+func (p *parser) parseRelocs() error {
+	for i, seg := range p.segs {
+		if seg.SegmentEntry.Flags&segRelocInfo == 0 || seg.FileSize == 0 {
+			continue
+		}
+		// The relocation records follow the segment contents.
+		off := int64(seg.Offset) + int64(seg.FileSize)
+		var buf [2]byte
+		if _, err := p.r.ReadAt(buf[:], off); err != nil {
+			return errors.Wrapf(err, "unable to read relocation count of segment %d", i+1)
+		}
+		n := int(binary.LittleEndian.Uint16(buf[:]))
+		const recordSize = 8
+		records := make([]byte, n*recordSize)
+		if _, err := p.r.ReadAt(records, off+2); err != nil && errors.Cause(err) != io.EOF {
+			return errors.Wrapf(err, "unable to read relocation records of segment %d", i+1)
+		}
+		for j := 0; j < n; j++ {
+			rec := records[j*recordSize : (j+1)*recordSize]
+			if err := p.applyReloc(seg, rec); err != nil {
+				// Report invalid relocations as warnings, to allow for the
+				// analysis of partially relocated segments.
+				warn.Printf("unable to apply relocation %d of segment %d; %v", j, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyReloc applies the given relocation record to the segment.
+func (p *parser) applyReloc(seg *segment, rec []byte) error {
+	src := rec[0]
+	flags := rec[1]
+	offset := binary.LittleEndian.Uint16(rec[2:])
+	// Resolve target selector:offset address.
+	var target bin.Address
+	switch flags & 0x03 {
+	case targetInternal:
+		segNum := int(rec[4])
+		targetOff := binary.LittleEndian.Uint16(rec[6:])
+		if segNum == 0xFF {
+			// Movable segment; target specified by entry ordinal.
+			ordinal := int(targetOff)
+			if ordinal < 1 || ordinal > len(p.entries) || p.entries[ordinal-1] == nil {
+				return errors.Errorf("invalid entry ordinal %d of movable segment reference", ordinal)
+			}
+			e := p.entries[ordinal-1]
+			segNum, targetOff = e.seg, e.offset
+		}
+		targetSeg, err := p.segment(segNum)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		target = targetSeg.Addr + bin.Address(targetOff)
+	case targetImportOrdinal, targetImportName:
+		moduleIndex := int(binary.LittleEndian.Uint16(rec[4:]))
+		if moduleIndex < 1 || moduleIndex > len(p.modules) {
+			return errors.Errorf("invalid module reference index; expected >= 1 and <= %d, got %d", len(p.modules), moduleIndex)
+		}
+		module := p.modules[moduleIndex-1]
+		v := binary.LittleEndian.Uint16(rec[6:])
+		var name string
+		if flags&0x03 == targetImportOrdinal {
+			name = fmt.Sprintf("%s_ordinal_%d", moduleName(module), v)
+		} else {
+			s, err := p.importedName(v)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			name = s
+		}
+		target = p.extern(name)
+	case targetOSFixup:
+		// TODO: Add support for operating system fixups.
+		return nil
+	}
+	// Target addresses are expressed relative to the start of their segment,
+	// using the selector of the paragraph-aligned segment.
+	sel, targetOff := p.selOff(target)
+	// put stores the target at the given offset of the segment.
+	put := func(offset uint16) error {
+		data := seg.Data
+		addr := seg.Addr + bin.Address(offset)
+		switch src & 0x0F {
+		case srcLoByte:
+			if int(offset)+1 > len(data) {
+				return errors.Errorf("invalid relocation offset 0x%04X; exceeds segment size %d", offset, len(data))
+			}
+			if flags&targetAdditive != 0 {
+				data[offset] += uint8(targetOff)
+			} else {
+				data[offset] = uint8(targetOff)
+			}
+		case srcSegment:
+			if int(offset)+2 > len(data) {
+				return errors.Errorf("invalid relocation offset 0x%04X; exceeds segment size %d", offset, len(data))
+			}
+			p.put16(data[offset:], sel, flags)
+			p.file.Relocs[addr] = bin.RelocSeg16
+		case srcFarAddr:
+			if int(offset)+4 > len(data) {
+				return errors.Errorf("invalid relocation offset 0x%04X; exceeds segment size %d", offset, len(data))
+			}
+			p.put16(data[offset:], targetOff, flags)
+			p.put16(data[offset+2:], sel, flags)
+			p.file.Relocs[addr+2] = bin.RelocSeg16
+		case srcOffset:
+			if int(offset)+2 > len(data) {
+				return errors.Errorf("invalid relocation offset 0x%04X; exceeds segment size %d", offset, len(data))
+			}
+			p.put16(data[offset:], targetOff, flags)
+		default:
+			return errors.Errorf("support for relocation source type 0x%02X not yet implemented", src)
+		}
+		return nil
+	}
+	if flags&targetAdditive != 0 {
+		return put(offset)
+	}
+	// Non-additive relocations form a chain through the relocated addresses,
+	// terminated by 0xFFFF.
+	for i := 0; ; i++ {
+		if i > len(seg.Data) {
+			return errors.Errorf("invalid relocation chain at offset 0x%04X; cycle detected", offset)
+		}
+		if int(offset)+2 > len(seg.Data) {
+			return errors.Errorf("invalid relocation chain offset 0x%04X; exceeds segment size %d", offset, len(seg.Data))
+		}
+		next := binary.LittleEndian.Uint16(seg.Data[offset:])
+		if err := put(offset); err != nil {
+			return errors.WithStack(err)
+		}
+		if next == 0xFFFF || src&0x0F == srcLoByte {
+			break
+		}
+		offset = next
+	}
+	return nil
+}
+
+// selOff returns the selector:offset address of the given linear address,
+// relative to the start of its segment.
+func (p *parser) selOff(addr bin.Address) (sel, off uint16) {
+	start := p.externStart
+	if addr < p.externStart {
+		for _, seg := range p.segs {
+			if seg.Addr <= addr {
+				start = seg.Addr
+			}
+		}
+	}
+	return uint16(start >> 4), uint16(addr - start)
+}
+
+// put16 stores the 16-bit value v into buf; added to the stored value if
+// additive.
+func (p *parser) put16(buf []byte, v uint16, flags uint8) {
+	if flags&targetAdditive != 0 {
+		v += binary.LittleEndian.Uint16(buf)
+	}
+	binary.LittleEndian.PutUint16(buf, v)
+}
@@ -14,6 +14,9 @@ import (
 	"sort"
 
 	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/bin/le"
+	"github.com/decomp/exp/bin/mz"
+	"github.com/decomp/exp/bin/ne"
 	//"github.com/kr/pretty"
 	"github.com/mewkiz/pkg/pathutil"
 	"github.com/mewkiz/pkg/term"
@@ -34,8 +37,91 @@ func init() {
 	// Portable Executable (PE) format.
 	//
 	//    4D 5A  |MZ|
+	//
+	// The DOS MZ, NE and LE/LX formats share the same magic number, and are
+	// dispatched by Parse based on the signature of the extended header.
 	const magic = "MZ"
 	bin.RegisterFormat("pe", magic, Parse)
+	bin.RegisterIdentify("pe", magic, Identify)
+}
+
+// Identify identifies the format and machine architecture of the given PE, NE,
+// LE/LX or DOS MZ binary executable, reading only the file headers from r.
+func Identify(r io.ReaderAt) (*bin.Info, error) {
+	switch signature(r) {
+	case "PE\x00\x00":
+		// Portable Executable.
+	case "NE":
+		return bin.NewInfo("ne", bin.ArchX86_16), nil
+	case "LE", "LX":
+		return bin.NewInfo("le", bin.ArchX86_32), nil
+	default:
+		return bin.NewInfo("mz", bin.ArchX86_16), nil
+	}
+	// The COFF file header follows the PE signature, and the optional header
+	// follows the COFF file header.
+	var buf [4]byte
+	if _, err := r.ReadAt(buf[:], 0x3C); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fhOffset := int64(binary.LittleEndian.Uint32(buf[:])) + 4
+	const fileHeaderSize = 20
+	var hdr [fileHeaderSize + 2]byte
+	if _, err := r.ReadAt(hdr[:], fhOffset); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	machine := binary.LittleEndian.Uint16(hdr[:])
+	if arch, ok := parseArch(machine); ok {
+		return bin.NewInfo("pe", arch), nil
+	}
+	// Partial summary of unsupported machine architecture, with bit size as
+	// specified by the magic number of the optional header.
+	info := &bin.Info{
+		Format:    "pe",
+		ByteOrder: binary.LittleEndian,
+	}
+	switch magic := binary.LittleEndian.Uint16(hdr[fileHeaderSize:]); magic {
+	case 0x10B:
+		info.BitSize = 32
+	case 0x20B:
+		info.BitSize = 64
+	}
+	return info, errors.WithStack(&bin.UnsupportedMachineError{Format: "pe", Machine: uint32(machine)})
+}
+
+// parseArch returns the machine architecture corresponding to the given machine
+// field of the COFF file header. The boolean return value indicates success.
+func parseArch(machine uint16) (bin.Arch, bool) {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return bin.ArchX86_32, true
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return bin.ArchX86_64, true
+	case pe.IMAGE_FILE_MACHINE_POWERPC:
+		return bin.ArchPowerPC_32, true
+	}
+	return 0, false
+}
+
+// signature returns the signature of the extended header located by the
+// e_lfanew field of the DOS header; or the empty string if not present.
+func signature(r io.ReaderAt) string {
+	var buf [4]byte
+	if _, err := r.ReadAt(buf[:], 0x3C); err != nil {
+		return ""
+	}
+	off := int64(binary.LittleEndian.Uint32(buf[:]))
+	// The extended header follows the DOS header.
+	if off < 0x40 {
+		return ""
+	}
+	if _, err := r.ReadAt(buf[:], off); err != nil {
+		return ""
+	}
+	if string(buf[:]) == "PE\x00\x00" {
+		return string(buf[:])
+	}
+	return string(buf[:2])
 }
 
 // ParseFile parses the given PE binary executable, reading from path.
@@ -48,73 +134,160 @@ func ParseFile(path string) (*bin.File, error) {
 	return Parse(f)
 }
 
-// Parse parses the given PE binary executable, reading from r.
+// Parse parses the given PE binary executable, reading from r. DOS MZ, NE and
+// LE/LX executables are parsed by their respective loaders, as identified by
+// the signature at the offset specified by the e_lfanew field of the DOS
+// header.
 //
 // Users are responsible for closing r.
 func Parse(r io.ReaderAt) (*bin.File, error) {
+	switch signature(r) {
+	case "PE\x00\x00":
+		// Portable Executable.
+	case "NE":
+		return ne.Parse(r)
+	case "LE", "LX":
+		return le.Parse(r)
+	default:
+		// Plain DOS MZ executable.
+		return mz.Parse(r)
+	}
+	// Parse machine architecture before opening the PE file, as debug/pe
+	// rejects unknown machine architectures with an untyped error.
+	info, err := Identify(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	// Open PE file.
 	f, err := pe.NewFile(r)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	// Parse machine architecture.
 	file := &bin.File{
-		Imports: make(map[bin.Address]string),
-		// TODO: implement exports
-	}
-	switch f.FileHeader.Machine {
-	case pe.IMAGE_FILE_MACHINE_I386:
-		file.Arch = bin.ArchX86_32
-	case pe.IMAGE_FILE_MACHINE_AMD64:
-		file.Arch = bin.ArchX86_64
-	case pe.IMAGE_FILE_MACHINE_POWERPC:
-		file.Arch = bin.ArchPowerPC_32
-	default:
-		panic(fmt.Errorf("support for machine architecture %v not yet implemented", f.FileHeader.Machine))
+		Arch:     info.Arch,
+		Imports:  make(map[bin.Address]string),
+		Exports:  make(map[bin.Address]string),
+		Forwards: make(map[string]string),
+		Relocs:   make(map[bin.Address]bin.RelocKind),
 	}
+	// Images with stripped relocation information must be loaded at their
+	// preferred base address.
+	file.Relocatable = f.FileHeader.Characteristics&pe.IMAGE_FILE_RELOCS_STRIPPED == 0
 
 	// Parse entry address.
 	var (
 		// Image base address.
 		imageBase uint64
-		// Import table RVA and size.
-		itRVA  uint64
-		itSize uint64
-		// Import address table (IAT) RVA and size.
-		iatRVA  uint64
-		iatSize uint64
-	)
-	// Data directory indices.
-	const (
-		ImportTableIndex        = 1
-		ImportAddressTableIndex = 12
+		// Data directories.
+		dataDirs [16]pe.DataDirectory
 	)
 	switch opt := f.OptionalHeader.(type) {
 	case *pe.OptionalHeader32:
 		file.Entry = bin.Address(opt.ImageBase + opt.AddressOfEntryPoint)
 		imageBase = uint64(opt.ImageBase)
-		itRVA = uint64(opt.DataDirectory[ImportTableIndex].VirtualAddress)
-		itSize = uint64(opt.DataDirectory[ImportTableIndex].Size)
-		iatRVA = uint64(opt.DataDirectory[ImportAddressTableIndex].VirtualAddress)
-		iatSize = uint64(opt.DataDirectory[ImportAddressTableIndex].Size)
+		dataDirs = opt.DataDirectory
 	case *pe.OptionalHeader64:
 		file.Entry = bin.Address(opt.ImageBase) + bin.Address(opt.AddressOfEntryPoint)
 		imageBase = uint64(opt.ImageBase)
-		itRVA = uint64(opt.DataDirectory[ImportTableIndex].VirtualAddress)
-		itSize = uint64(opt.DataDirectory[ImportTableIndex].Size)
-		iatRVA = uint64(opt.DataDirectory[ImportAddressTableIndex].VirtualAddress)
-		iatSize = uint64(opt.DataDirectory[ImportAddressTableIndex].Size)
+		dataDirs = opt.DataDirectory
 	default:
-		panic(fmt.Errorf("support for optional header type %T not yet implemented", opt))
+		return nil, errors.Errorf("support for optional header type %T not yet implemented", opt)
 	}
+	file.Base = bin.Address(imageBase)
 
 	// Parse sections.
+	if err := parseSections(file, f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse import address table (IAT).
+	iatDir := dataDirs[importAddressTableIndex]
+	dbg.Println("iat")
+	if iatDir.Size != 0 {
+		iatAddr := bin.Address(imageBase) + bin.Address(iatDir.VirtualAddress)
+		dbg.Println("iat addr:", iatAddr)
+		data, err := file.DataAt(iatAddr)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to locate import address table")
+		}
+		if uint64(len(data)) < uint64(iatDir.Size) {
+			return nil, errors.Errorf("invalid import address table size at address %v; expected <= %d bytes, got %d", iatAddr, len(data), iatDir.Size)
+		}
+		data = data[:iatDir.Size]
+		dbg.Println(hex.Dump(data))
+	}
+
+	// Parse import table.
+	if err := parseImports(file, imageBase, dataDirs[importTableIndex]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse delay-load import table.
+	if err := parseDelayImports(file, imageBase, dataDirs[delayImportTableIndex]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse TLS directory.
+	if err := parseTLS(file, dataDirs[tlsTableIndex]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse exception table.
+	if err := parseRuntimeFuncs(file, dataDirs[exceptionTableIndex]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse resource directory, and record the resource data as known data
+	// regions.
+	rsrc, err := parseResourceDir(file, dataDirs[resourceTableIndex])
+	if err != nil {
+		// Malformed resources do not prevent the analysis of code, so report
+		// them as warnings.
+		warn.Printf("unable to parse resource directory; %v", err)
+	} else if rsrc != nil {
+		rsrcAddr := file.Base + bin.Address(dataDirs[resourceTableIndex].VirtualAddress)
+		for _, addr := range rsrc.dataAddrs(rsrcAddr) {
+			file.DataAddrs = bin.InsertAddr(file.DataAddrs, addr)
+		}
+	}
+
+	// Parse export table.
+	if err := parseExports(file, imageBase, dataDirs[exportTableIndex]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse base relocation table.
+	if err := parseRelocs(file, imageBase, dataDirs[baseRelocTableIndex]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return file, nil
+}
+
+// Data directory indices.
+const (
+	exportTableIndex        = 0
+	importTableIndex        = 1
+	resourceTableIndex      = 2
+	exceptionTableIndex     = 3
+	baseRelocTableIndex     = 5
+	tlsTableIndex           = 9
+	importAddressTableIndex = 12
+	delayImportTableIndex   = 13
+)
+
+// parseSections parses the sections of the given PE file, and records them
+// sorted by address in ascending order. The section index is built, as the data
+// directories are located through it. The image base address of file must be
+// set.
+func parseSections(file *bin.File, f *pe.File) error {
 	for _, s := range f.Sections {
-		addr := bin.Address(imageBase) + bin.Address(s.VirtualAddress)
+		addr := file.Base + bin.Address(s.VirtualAddress)
 		raw, err := s.Data()
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return errors.WithStack(err)
 		}
 		data := raw
 		fileSize := len(raw)
@@ -146,28 +319,34 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 		return file.Sections[i].Addr < file.Sections[j].Addr
 	}
 	sort.Slice(file.Sections, less)
+	file.BuildIndex()
+	return nil
+}
 
-	// Parse import address table (IAT).
-	dbg.Println("iat")
-	if iatSize != 0 {
-		iatAddr := bin.Address(imageBase + iatRVA)
-		dbg.Println("iat addr:", iatAddr)
-		data := file.Data(iatAddr)
-		data = data[:iatSize]
-		dbg.Println(hex.Dump(data))
-	}
-
+// parseImports parses the import table of the given data directory, and
+// records the function imports in file.
+//
+// The bound import directory (data directory 11) is not parsed, as it only
+// records the time stamps of bound DLLs. Imports of bound DLLs are located
+// through the import name table, since the import address tables of bound
+// images contain the resolved addresses of imported functions rather than
+// RVAs of hint/name entries.
+func parseImports(file *bin.File, imageBase uint64, itDir pe.DataDirectory) error {
 	// Early return if import table not present.
-	if itSize == 0 {
-		return file, nil
+	if itDir.Size == 0 {
+		return nil
 	}
-
-	// Parse import table.
 	dbg.Println("it")
-	itAddr := bin.Address(imageBase + itRVA)
+	itAddr := bin.Address(imageBase) + bin.Address(itDir.VirtualAddress)
 	dbg.Println("it addr:", itAddr)
-	data := file.Data(itAddr)
-	data = data[:itSize]
+	data, err := file.DataAt(itAddr)
+	if err != nil {
+		return errors.Wrap(err, "unable to locate import table")
+	}
+	if uint64(len(data)) < uint64(itDir.Size) {
+		return errors.Errorf("invalid import table size at address %v; expected <= %d bytes, got %d", itAddr, len(data), itDir.Size)
+	}
+	data = data[:itDir.Size]
 	dbg.Println(hex.Dump(data))
 	br := bytes.NewReader(data)
 	zero := importDesc{}
@@ -175,7 +354,7 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	for {
 		var impDesc importDesc
 		if err := binary.Read(br, binary.LittleEndian, &impDesc); err != nil {
-			return nil, errors.WithStack(err)
+			return errors.Wrap(err, "unable to read import descriptor")
 		}
 		if impDesc == zero {
 			break
@@ -185,44 +364,447 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	for _, impDesc := range impDescs {
 		//dbg.Printf("impDesc: %#v\n", pretty.Formatter(impDesc))
 		dllNameAddr := bin.Address(imageBase) + bin.Address(impDesc.DLLNameRVA)
-		data := file.Data(dllNameAddr)
-		dllName := parseString(data)
+		dllName, err := readString(file, dllNameAddr)
+		if err != nil {
+			return errors.Wrap(err, "unable to read DLL name of import descriptor")
+		}
 		dbg.Println("dll name:", dllName)
 		// Parse import name table and import address table.
 		impNameTableAddr := bin.Address(imageBase) + bin.Address(impDesc.ImportNameTableRVA)
 		impAddrTableAddr := bin.Address(imageBase) + bin.Address(impDesc.ImportAddressTableRVA)
-		inAddr := impNameTableAddr
-		iaAddr := impAddrTableAddr
-		for {
-			impNameRVA, n := readUintptr(file, inAddr)
-			if impNameRVA == 0 {
-				break
-			}
-			impAddr := iaAddr
-			inAddr += bin.Address(n)
-			iaAddr += bin.Address(n)
-			dbg.Println("impAddr:", impAddr)
-			if impNameRVA&0x80000000 != 0 {
-				// ordinal
-				ordinal := impNameRVA &^ 0x80000000
-				dbg.Println("===> ordinal", ordinal)
-				impName := fmt.Sprintf("%s_ordinal_%d", pathutil.TrimExt(dllName), ordinal)
-				file.Imports[impAddr] = impName
+		if impDesc.ImportNameTableRVA == 0 {
+			// The import name table is omitted by some linkers (e.g. Borland), in
+			// which case the import address table holds the RVAs of hint/name
+			// entries; unless the DLL is bound, as indicated by a non-zero time
+			// stamp.
+			if impDesc.Date != 0 {
+				warn.Printf("unable to locate imports of bound DLL %q; missing import name table", dllName)
 				continue
 			}
-			impNameAddr := bin.Address(imageBase + impNameRVA)
-			data := file.Data(impNameAddr)
-			ordinal := binary.LittleEndian.Uint16(data)
-			data = data[2:]
-			impName := parseString(data)
-			dbg.Println("ordinal:", ordinal)
-			dbg.Println("impName:", impName)
-			file.Imports[impAddr] = impName
+			impNameTableAddr = impAddrTableAddr
+		}
+		if err := parseThunks(file, bin.Address(imageBase), dllName, impNameTableAddr, impAddrTableAddr); err != nil {
+			return errors.WithStack(err)
 		}
 		dbg.Println()
 	}
 
-	return file, nil
+	return nil
+}
+
+// parseThunks parses the import name table at inAddr, and records the function
+// imports of the corresponding import address table at iaAddr in file. Hint/name
+// entries of the import name table are located relative to nameBase.
+func parseThunks(file *bin.File, nameBase bin.Address, dllName string, inAddr, iaAddr bin.Address) error {
+	// The high-order bit of import name table entries specifies import by
+	// ordinal.
+	ordinalFlag := uint64(1) << uint(file.Arch.BitSize()-1)
+	for {
+		impNameRVA, n, err := file.Uintptr(inAddr)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read import name table entry of %q", dllName)
+		}
+		if impNameRVA == 0 {
+			break
+		}
+		impAddr := iaAddr
+		inAddr += bin.Address(n)
+		iaAddr += bin.Address(n)
+		dbg.Println("impAddr:", impAddr)
+		if impNameRVA&ordinalFlag != 0 {
+			// ordinal
+			ordinal := impNameRVA & 0xFFFF
+			dbg.Println("===> ordinal", ordinal)
+			impName := fmt.Sprintf("%s_ordinal_%d", pathutil.TrimExt(dllName), ordinal)
+			file.Imports[impAddr] = impName
+			continue
+		}
+		impNameAddr := nameBase + bin.Address(impNameRVA)
+		ordinal, err := file.Uint16(impNameAddr)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read hint of function imported from %q", dllName)
+		}
+		impName, err := readString(file, impNameAddr+2)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read name of function imported from %q", dllName)
+		}
+		dbg.Println("ordinal:", ordinal)
+		dbg.Println("impName:", impName)
+		file.Imports[impAddr] = impName
+	}
+	return nil
+}
+
+// parseDelayImports parses the delay-load import table of the given data
+// directory, and records the function imports of the delay-load import address
+// tables in file.
+func parseDelayImports(file *bin.File, imageBase uint64, ditDir pe.DataDirectory) error {
+	// Early return if delay-load import table not present.
+	if ditDir.Size == 0 {
+		return nil
+	}
+	ditAddr := bin.Address(imageBase) + bin.Address(ditDir.VirtualAddress)
+	dbg.Println("delay-load it addr:", ditAddr)
+	data, err := file.DataAt(ditAddr)
+	if err != nil {
+		return errors.Wrap(err, "unable to locate delay-load import table")
+	}
+	br := bytes.NewReader(data)
+	zero := delayImportDesc{}
+	for {
+		var impDesc delayImportDesc
+		if err := binary.Read(br, binary.LittleEndian, &impDesc); err != nil {
+			return errors.Wrap(err, "unable to read delay-load import descriptor")
+		}
+		if impDesc == zero {
+			break
+		}
+		// Delay-load import descriptors of old linkers (e.g. Visual C++ 6.0)
+		// contain virtual addresses rather than RVAs, as indicated by the
+		// absence of the RVA-based attribute.
+		const rvaBased = 0x1
+		base := bin.Address(imageBase)
+		if impDesc.Attributes&rvaBased == 0 {
+			base = 0
+		}
+		dllName, err := readString(file, base+bin.Address(impDesc.DLLNameRVA))
+		if err != nil {
+			return errors.Wrap(err, "unable to read DLL name of delay-load import descriptor")
+		}
+		dbg.Println("delay-load dll name:", dllName)
+		impNameTableAddr := base + bin.Address(impDesc.ImportNameTableRVA)
+		impAddrTableAddr := base + bin.Address(impDesc.ImportAddressTableRVA)
+		if err := parseThunks(file, base, dllName, impNameTableAddr, impAddrTableAddr); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// parseTLS parses the thread local storage (TLS) directory of the given data
+// directory, and records the addresses of TLS callbacks in file. TLS callbacks
+// are invoked by the loader before the entry point.
+func parseTLS(file *bin.File, tlsDir pe.DataDirectory) error {
+	// Early return if TLS directory not present.
+	if tlsDir.Size == 0 {
+		return nil
+	}
+	tlsAddr := file.Base + bin.Address(tlsDir.VirtualAddress)
+	dbg.Println("tls addr:", tlsAddr)
+	// The TLS directory contains pointer-sized virtual addresses (not RVAs).
+	//
+	//    StartAddressOfRawData
+	//    EndAddressOfRawData
+	//    AddressOfIndex
+	//    AddressOfCallBacks
+	//    SizeOfZeroFill        (4 bytes)
+	//    Characteristics       (4 bytes)
+	ptrSize := bin.Address(file.Arch.BitSize() / 8)
+	callbacksAddr, _, err := file.Uintptr(tlsAddr + 3*ptrSize)
+	if err != nil {
+		return errors.Wrap(err, "unable to read address of TLS callbacks")
+	}
+	if callbacksAddr == 0 {
+		return nil
+	}
+	// The TLS callback array is NULL-terminated.
+	for addr := bin.Address(callbacksAddr); ; addr += ptrSize {
+		callback, _, err := file.Uintptr(addr)
+		if err != nil {
+			return errors.Wrap(err, "unable to read TLS callback")
+		}
+		if callback == 0 {
+			break
+		}
+		dbg.Println("tls callback:", bin.Address(callback))
+		file.TLSCallbacks = append(file.TLSCallbacks, bin.Address(callback))
+	}
+	return nil
+}
+
+// parseRuntimeFuncs parses the function table of the exception table of the
+// given data directory, and records the extents of functions in file.
+//
+// Each entry of the function table of x86-64 PE files (RUNTIME_FUNCTION)
+// specifies the extent of a non-leaf function or function chunk, and the
+// address of its unwind information. The unwind information of function chunks
+// is chained to the function table entry of the primary function.
+//
+// ref: https://docs.microsoft.com/en-us/cpp/build/exception-handling-x64
+func parseRuntimeFuncs(file *bin.File, etDir pe.DataDirectory) error {
+	// Early return if exception table not present.
+	if etDir.Size == 0 {
+		return nil
+	}
+	if file.Arch != bin.ArchX86_64 {
+		// TODO: Add support for the exception table of other machine
+		// architectures (e.g. ARM and ARM64).
+		dbg.Printf("support for exception table of machine architecture %v not yet implemented", file.Arch)
+		return nil
+	}
+	etAddr := file.Base + bin.Address(etDir.VirtualAddress)
+	dbg.Println("exception table addr:", etAddr)
+	data, err := file.DataAt(etAddr)
+	if err != nil {
+		return errors.Wrap(err, "unable to locate exception table")
+	}
+	if uint64(len(data)) < uint64(etDir.Size) {
+		return errors.Errorf("invalid exception table size; expected >= %d bytes, got %d", etDir.Size, len(data))
+	}
+	data = data[:etDir.Size]
+	const runtimeFuncSize = 12
+	for ; len(data) >= runtimeFuncSize; data = data[runtimeFuncSize:] {
+		f := parseRuntimeFunc(file.Base, data)
+		if f.Start == file.Base {
+			// Skip zero padding.
+			continue
+		}
+		parent, err := chainedParent(file, f.UnwindInfo)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		f.Parent = parent
+		file.RuntimeFuncs = append(file.RuntimeFuncs, f)
+	}
+	less := func(i, j int) bool {
+		return file.RuntimeFuncs[i].Start < file.RuntimeFuncs[j].Start
+	}
+	sort.SliceStable(file.RuntimeFuncs, less)
+	return nil
+}
+
+// parseRuntimeFunc parses the given function table entry (RUNTIME_FUNCTION).
+//
+//	BeginAddress      (4 bytes; RVA)
+//	EndAddress        (4 bytes; RVA)
+//	UnwindInfoAddress (4 bytes; RVA)
+func parseRuntimeFunc(imageBase bin.Address, data []byte) *bin.RuntimeFunc {
+	return &bin.RuntimeFunc{
+		Start:      imageBase + bin.Address(binary.LittleEndian.Uint32(data[0:])),
+		End:        imageBase + bin.Address(binary.LittleEndian.Uint32(data[4:])),
+		UnwindInfo: imageBase + bin.Address(binary.LittleEndian.Uint32(data[8:])),
+	}
+}
+
+// chainedParent returns the start address of the primary function of a
+// function chunk, by following the chained unwind information starting at the
+// given address. The returned address is 0 if the unwind information is not
+// chained.
+//
+// The unwind information (UNWIND_INFO) has the following layout.
+//
+//	Version:Flags     (1 byte; 3 and 5 bits)
+//	SizeOfProlog      (1 byte)
+//	CountOfCodes      (1 byte)
+//	FrameRegister:FrameOffset (1 byte)
+//	UnwindCode        (CountOfCodes * 2 bytes, aligned to 4 bytes)
+//	RUNTIME_FUNCTION  (12 bytes; if UNW_FLAG_CHAININFO)
+func chainedParent(file *bin.File, unwindAddr bin.Address) (bin.Address, error) {
+	const chainInfoFlag = 0x4
+	// Maximum length of unwind information chains, to prevent infinite loops
+	// on malformed input.
+	const maxChain = 32
+	var parent bin.Address
+	for i := 0; i < maxChain; i++ {
+		data, err := file.DataAt(unwindAddr)
+		if err != nil {
+			return 0, errors.Wrap(err, "unable to locate unwind information")
+		}
+		if len(data) < 4 {
+			return 0, errors.Errorf("invalid unwind information at address %v; expected >= 4 bytes, got %d", unwindAddr, len(data))
+		}
+		flags := data[0] >> 3
+		if flags&chainInfoFlag == 0 {
+			return parent, nil
+		}
+		countOfCodes := int(data[2])
+		// The array of unwind codes is aligned to an even number of entries.
+		offset := 4 + 2*(countOfCodes+countOfCodes&1)
+		if len(data) < offset+12 {
+			return 0, errors.Errorf("invalid chained unwind information at address %v; expected >= %d bytes, got %d", unwindAddr, offset+12, len(data))
+		}
+		f := parseRuntimeFunc(file.Base, data[offset:])
+		parent = f.Start
+		unwindAddr = f.UnwindInfo
+	}
+	return 0, errors.Errorf("invalid unwind information at address %v; chain exceeds %d entries", unwindAddr, maxChain)
+}
+
+// parseExports parses the export table of the given data directory, and records
+// the function exports and forwarded exports in file.
+func parseExports(file *bin.File, imageBase uint64, etDir pe.DataDirectory) error {
+	// Early return if export table not present.
+	if etDir.Size == 0 {
+		return nil
+	}
+	dbg.Println("et")
+	etAddr := bin.Address(imageBase) + bin.Address(etDir.VirtualAddress)
+	dbg.Println("et addr:", etAddr)
+	buf := make([]byte, binary.Size(exportDir{}))
+	if err := file.ReadAt(buf, etAddr); err != nil {
+		return errors.Wrap(err, "unable to read export directory")
+	}
+	var expDir exportDir
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &expDir); err != nil {
+		return errors.WithStack(err)
+	}
+	dllNameAddr := bin.Address(imageBase) + bin.Address(expDir.DLLNameRVA)
+	dllName, err := readString(file, dllNameAddr)
+	if err != nil {
+		return errors.Wrap(err, "unable to read DLL name of export directory")
+	}
+	dbg.Println("dll name:", dllName)
+	// Validate the number of entries of the export tables against the size of
+	// the sections containing them, as the counts are not otherwise bounded.
+	nameTableAddr := bin.Address(imageBase) + bin.Address(expDir.NameTableRVA)
+	ordTableAddr := bin.Address(imageBase) + bin.Address(expDir.OrdinalTableRVA)
+	addrTableAddr := bin.Address(imageBase) + bin.Address(expDir.AddrTableRVA)
+	if err := checkTable(file, "export name table", nameTableAddr, expDir.NNames, 4); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := checkTable(file, "export ordinal table", ordTableAddr, expDir.NNames, 2); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := checkTable(file, "export address table", addrTableAddr, expDir.NFuncs, 4); err != nil {
+		return errors.WithStack(err)
+	}
+	// Parse export name table and export ordinal table; map from index into
+	// the export address table to export name.
+	names := make(map[uint32]string)
+	for i := uint32(0); i < expDir.NNames; i++ {
+		nameRVA, err := file.Uint32(nameTableAddr + bin.Address(4*i))
+		if err != nil {
+			return errors.Wrap(err, "unable to read export name table entry")
+		}
+		ordIndex, err := file.Uint16(ordTableAddr + bin.Address(2*i))
+		if err != nil {
+			return errors.Wrap(err, "unable to read export ordinal table entry")
+		}
+		index := uint32(ordIndex)
+		if index >= expDir.NFuncs {
+			warn.Printf("invalid index %d of export ordinal table entry %d; expected < %d", index, i, expDir.NFuncs)
+			continue
+		}
+		if _, ok := names[index]; ok {
+			// Keep the first name of exports with multiple names.
+			continue
+		}
+		nameAddr := bin.Address(imageBase) + bin.Address(nameRVA)
+		name, err := readString(file, nameAddr)
+		if err != nil {
+			return errors.Wrap(err, "unable to read export name")
+		}
+		names[index] = name
+	}
+	// Parse export address table.
+	for i := uint32(0); i < expDir.NFuncs; i++ {
+		expRVA, err := file.Uint32(addrTableAddr + bin.Address(4*i))
+		if err != nil {
+			return errors.Wrap(err, "unable to read export address table entry")
+		}
+		if expRVA == 0 {
+			// skip unused export address table entry.
+			continue
+		}
+		expName, ok := names[i]
+		if !ok {
+			// Export by ordinal only.
+			ordinal := expDir.OrdinalBase + i
+			expName = fmt.Sprintf("%s_ordinal_%d", pathutil.TrimExt(dllName), ordinal)
+		}
+		if etDir.VirtualAddress <= expRVA && expRVA < etDir.VirtualAddress+etDir.Size {
+			// The RVA of forwarded exports points to a forwarder string within
+			// the export table (e.g. "NTDLL.RtlAllocateHeap"), rather than to
+			// code.
+			forwarderAddr := bin.Address(imageBase) + bin.Address(expRVA)
+			forwarder, err := readString(file, forwarderAddr)
+			if err != nil {
+				return errors.Wrapf(err, "unable to read forwarder of export %q", expName)
+			}
+			dbg.Printf("forwarded export %q: %q", expName, forwarder)
+			file.Forwards[expName] = forwarder
+			continue
+		}
+		expAddr := bin.Address(imageBase) + bin.Address(expRVA)
+		dbg.Printf("export %q at %v", expName, expAddr)
+		if _, ok := file.Exports[expAddr]; ok {
+			// Keep the first name of functions exported multiple times.
+			continue
+		}
+		file.Exports[expAddr] = expName
+	}
+	return nil
+}
+
+// parseRelocs parses the base relocation table of the given data directory, and
+// records the relocated addresses in file.
+func parseRelocs(file *bin.File, imageBase uint64, rtDir pe.DataDirectory) error {
+	// Early return if base relocation table not present.
+	if rtDir.Size == 0 {
+		return nil
+	}
+	dbg.Println("reloc")
+	rtAddr := bin.Address(imageBase) + bin.Address(rtDir.VirtualAddress)
+	dbg.Println("reloc addr:", rtAddr)
+	data, err := file.DataAt(rtAddr)
+	if err != nil {
+		return errors.Wrap(err, "unable to locate base relocation table")
+	}
+	if uint64(len(data)) < uint64(rtDir.Size) {
+		return errors.Errorf("invalid base relocation table size at address %v; expected <= %d bytes, got %d", rtAddr, len(data), rtDir.Size)
+	}
+	data = data[:rtDir.Size]
+	// Base relocation types.
+	//
+	// ref: https://docs.microsoft.com/en-us/windows/win32/debug/pe-format#base-relocation-types
+	const (
+		relocAbsolute = 0
+		relocHigh     = 1
+		relocLow      = 2
+		relocHighLow  = 3
+		relocHighAdj  = 4
+		relocDir64    = 10
+	)
+	// The base relocation table is divided into blocks, one for each 4K page.
+	// Each block starts with a block header, followed by 16-bit entries; the
+	// high 4 bits of which specifies the relocation type and the low 12 bits
+	// the offset from the page RVA.
+	const blockHdrSize = 8
+	for len(data) >= blockHdrSize {
+		pageRVA := binary.LittleEndian.Uint32(data)
+		blockSize := binary.LittleEndian.Uint32(data[4:])
+		if blockSize < blockHdrSize || uint64(blockSize) > uint64(len(data)) {
+			return errors.Errorf("invalid size of base relocation block at RVA 0x%08X; expected >= %d and <= %d, got %d", pageRVA, blockHdrSize, len(data), blockSize)
+		}
+		block := data[blockHdrSize:blockSize]
+		data = data[blockSize:]
+		pageAddr := bin.Address(imageBase) + bin.Address(pageRVA)
+		for i := 0; i+2 <= len(block); i += 2 {
+			entry := binary.LittleEndian.Uint16(block[i:])
+			typ := entry >> 12
+			addr := pageAddr + bin.Address(entry&0x0FFF)
+			switch typ {
+			case relocAbsolute:
+				// skip padding entry.
+			case relocHigh:
+				file.Relocs[addr] = bin.RelocHigh16
+			case relocLow:
+				file.Relocs[addr] = bin.RelocLow16
+			case relocHighLow:
+				file.Relocs[addr] = bin.RelocAbs32
+			case relocDir64:
+				file.Relocs[addr] = bin.RelocAbs64
+			case relocHighAdj:
+				// The relocation occupies two entries; the second of which holds
+				// the low 16 bits of the 32-bit address.
+				warn.Printf("support for base relocation type IMAGE_REL_BASED_HIGHADJ at %v not yet implemented", addr)
+				i += 2
+			default:
+				warn.Printf("support for base relocation type %d at %v not yet implemented", typ, addr)
+			}
+		}
+	}
+	return nil
 }
 
 // ref: https://msdn.microsoft.com/en-us/library/ms809762.aspx
@@ -250,6 +832,53 @@ type importName struct {
 	Name string
 }
 
+// A delayImportDesc is a delay-load import descriptor
+// (IMAGE_DELAYLOAD_DESCRIPTOR).
+type delayImportDesc struct {
+	// Attributes; 0x1 if the descriptor contains RVAs.
+	Attributes uint32
+	// DLL name RVA.
+	DLLNameRVA uint32
+	// Module handle RVA.
+	ModuleHandleRVA uint32
+	// Delay-load import address table RVA.
+	ImportAddressTableRVA uint32
+	// Delay-load import name table RVA.
+	ImportNameTableRVA uint32
+	// Bound delay-load import address table RVA.
+	BoundImportAddressTableRVA uint32
+	// Unload delay-load import address table RVA.
+	UnloadInformationTableRVA uint32
+	// Time stamp of the bound DLL.
+	Date uint32
+}
+
+// An exportDir is an export directory.
+type exportDir struct {
+	// Reserved; must be zero.
+	Characteristics uint32
+	// Time stamp.
+	Date uint32
+	// Major version number.
+	MajorVersion uint16
+	// Minor version number.
+	MinorVersion uint16
+	// DLL name RVA.
+	DLLNameRVA uint32
+	// Starting ordinal number of exports.
+	OrdinalBase uint32
+	// Number of entries in the export address table.
+	NFuncs uint32
+	// Number of entries in the export name table and export ordinal table.
+	NNames uint32
+	// Export address table RVA.
+	AddrTableRVA uint32
+	// Export name table RVA.
+	NameTableRVA uint32
+	// Export ordinal table RVA.
+	OrdinalTableRVA uint32
+}
+
 // parsePerm returns the memory access permissions represented by the given PE
 // image characteristics.
 func parsePerm(char uint32) bin.Perm {
@@ -277,32 +906,32 @@ func parsePerm(char uint32) bin.Perm {
 
 // ### [ Helper functions ] ####################################################
 
-// parseString parses the NULL-terminated string in the given data.
-func parseString(data []byte) string {
+// readString reads the NULL-terminated string at the specified address of the
+// binary executable.
+func readString(file *bin.File, addr bin.Address) (string, error) {
+	data, err := file.DataAt(addr)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
 	pos := bytes.IndexByte(data, '\x00')
 	if pos == -1 {
-		panic(fmt.Errorf("unable to locate NULL-terminated string in % 02X", data))
+		return "", errors.Errorf("unable to locate NULL-terminated string at address %v", addr)
 	}
-	return string(data[:pos])
+	return string(data[:pos]), nil
 }
 
-// readUintptr reads a little-endian encoded value of pointer size based on the
-// CPU architecture, and returns the number of bytes read.
-func readUintptr(file *bin.File, addr bin.Address) (uint64, int) {
-	bits := file.Arch.BitSize()
-	data := file.Data(addr)
-	switch bits {
-	case 32:
-		if len(data) < 4 {
-			panic(fmt.Errorf("data length too short; expected >= 4 bytes, got %d", len(data)))
-		}
-		return uint64(binary.LittleEndian.Uint32(data)), 4
-	case 64:
-		if len(data) < 8 {
-			panic(fmt.Errorf("data length too short; expected >= 8 bytes, got %d", len(data)))
-		}
-		return binary.LittleEndian.Uint64(data), 8
-	default:
-		panic(fmt.Errorf("support for machine architecture with bit size %d not yet implemented", bits))
+// checkTable checks that the table of n entries of the given size at addr is
+// contained within the section holding its first entry.
+func checkTable(file *bin.File, name string, addr bin.Address, n uint32, size int) error {
+	if n == 0 {
+		return nil
+	}
+	sect, ok := file.FindSection(addr)
+	if !ok {
+		return errors.Errorf("unable to locate %s at address %v", name, addr)
+	}
+	if max := uint64(sect.End()-addr) / uint64(size); uint64(n) > max {
+		return errors.Errorf("invalid number of %s entries at address %v; expected <= %d, got %d", name, addr, max, n)
 	}
+	return nil
 }
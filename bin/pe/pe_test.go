@@ -0,0 +1,711 @@
+package pe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestIdentify(t *testing.T) {
+	// newImage returns a minimal PE image of the given machine architecture,
+	// with an optional header as specified by the machine architecture of the
+	// test image (32-bit x86 or x86-64).
+	newImage := func(machine, testMachine uint16) []byte {
+		image := buildImageMachine(testMachine, make([]byte, 0x10), exportTableIndex, pe.DataDirectory{})
+		putUint16(image, 0x44, machine)
+		return image
+	}
+	golden := []struct {
+		name  string
+		image []byte
+		want  *bin.Info
+		err   string
+	}{
+		{
+			name:  "32-bit x86",
+			image: newImage(pe.IMAGE_FILE_MACHINE_I386, pe.IMAGE_FILE_MACHINE_I386),
+			want:  bin.NewInfo("pe", bin.ArchX86_32),
+		},
+		{
+			name:  "x86-64",
+			image: newImage(pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_MACHINE_AMD64),
+			want:  bin.NewInfo("pe", bin.ArchX86_64),
+		},
+		{
+			// Partial summary with bit size of optional header.
+			name:  "unsupported machine",
+			image: newImage(pe.IMAGE_FILE_MACHINE_ARM64, pe.IMAGE_FILE_MACHINE_AMD64),
+			want:  &bin.Info{Format: "pe", BitSize: 64, ByteOrder: binary.LittleEndian},
+			err:   "machine architecture 0xAA64 not yet implemented",
+		},
+	}
+	for _, g := range golden {
+		info, err := Identify(bytes.NewReader(g.image))
+		if g.err != "" {
+			checkErr(t, g.name, err, g.err)
+		} else if err != nil {
+			t.Errorf("%s: unable to identify PE image; %+v", g.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(info, g.want) {
+			t.Errorf("%s: summary mismatch; expected %+v, got %+v", g.name, g.want, info)
+		}
+	}
+}
+
+func TestParseExports(t *testing.T) {
+	// Export directory of test.dll, exporting foo, a forwarded bar and an
+	// ordinal-only export.
+	//
+	//    0x000  export directory
+	//    0x040  export address table
+	//    0x060  export name table
+	//    0x070  export ordinal table
+	//    0x080  forwarder string
+	//    0x100  DLL name and export names
+	newData := func() []byte {
+		data := make([]byte, 0x300)
+		putUint32(data, 0x0C, sectRVA+0x100) // DLL name RVA
+		putUint32(data, 0x10, 1)             // ordinal base
+		putUint32(data, 0x14, 3)             // number of functions
+		putUint32(data, 0x18, 2)             // number of names
+		putUint32(data, 0x1C, sectRVA+0x40)  // export address table RVA
+		putUint32(data, 0x20, sectRVA+0x60)  // export name table RVA
+		putUint32(data, 0x24, sectRVA+0x70)  // export ordinal table RVA
+		putUint32(data, 0x40, sectRVA+0x200)
+		putUint32(data, 0x44, sectRVA+0x80)
+		putUint32(data, 0x48, sectRVA+0x210)
+		putUint32(data, 0x60, sectRVA+0x110)
+		putUint32(data, 0x64, sectRVA+0x120)
+		putUint16(data, 0x70, 0)
+		putUint16(data, 0x72, 1)
+		copy(data[0x80:], "NTDLL.RtlAllocateHeap\x00")
+		copy(data[0x100:], "test.dll\x00")
+		copy(data[0x110:], "foo\x00")
+		copy(data[0x120:], "bar\x00")
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 0xA0}
+
+	// Valid export directory.
+	file, err := parseImage(newData(), exportTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	wantExports := map[bin.Address]string{
+		imageBase + sectRVA + 0x200: "foo",
+		imageBase + sectRVA + 0x210: "test_ordinal_3",
+	}
+	if !reflect.DeepEqual(file.Exports, wantExports) {
+		t.Errorf("exports mismatch; expected %v, got %v", wantExports, file.Exports)
+	}
+	wantForwards := map[string]string{
+		"bar": "NTDLL.RtlAllocateHeap",
+	}
+	if !reflect.DeepEqual(file.Forwards, wantForwards) {
+		t.Errorf("forwarded exports mismatch; expected %v, got %v", wantForwards, file.Forwards)
+	}
+
+	// Malformed export directories.
+	golden := []malformed{
+		{
+			name: "number of functions exceeds section",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x14, 0xFFFFFFFF)
+			},
+			err: "invalid number of export address table entries",
+		},
+		{
+			name: "number of names exceeds section",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x18, 0x40000000)
+			},
+			err: "invalid number of export name table entries",
+		},
+		{
+			name: "unmapped export name table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x20, 0x80000000)
+			},
+			err: "unable to locate export name table",
+		},
+		{
+			name: "unmapped export name",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x64, 0x80000000)
+			},
+			err: "unable to read export name",
+		},
+		{
+			name: "unterminated DLL name",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x0C, sectRVA+uint32(len(data))-3)
+				copy(data[len(data)-3:], "dll")
+			},
+			err: "unable to locate NULL-terminated string",
+		},
+		{
+			name: "truncated export directory",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = sectRVA + uint32(len(data)) - 8
+			},
+			err: "unable to read export directory",
+		},
+	}
+	checkMalformed(t, newData, dir, parseDir(exportTableIndex), golden)
+}
+
+func TestParseRelocs(t *testing.T) {
+	// Base relocation table with a single block, relocating a 32-bit address
+	// and padded by an absolute relocation entry.
+	newData := func() []byte {
+		data := make([]byte, 0x100)
+		putUint32(data, 0x00, sectRVA) // page RVA
+		putUint32(data, 0x04, 12)      // block size
+		putUint16(data, 0x08, 0x3<<12|0x040)
+		putUint16(data, 0x0A, 0x0<<12|0x000)
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 12}
+
+	// Valid base relocation table.
+	file, err := parseImage(newData(), baseRelocTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	want := map[bin.Address]bin.RelocKind{
+		imageBase + sectRVA + 0x040: bin.RelocAbs32,
+	}
+	if !reflect.DeepEqual(file.Relocs, want) {
+		t.Errorf("relocations mismatch; expected %v, got %v", want, file.Relocs)
+	}
+	if !file.Relocatable {
+		t.Errorf("expected relocatable image")
+	}
+
+	// Malformed base relocation tables.
+	golden := []malformed{
+		{
+			name: "table extends past section",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.Size = 0x2000
+			},
+			err: "invalid base relocation table size",
+		},
+		{
+			name: "unmapped table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = 0x80000000
+			},
+			err: "unable to locate base relocation table",
+		},
+		{
+			name: "block size exceeds table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x04, 0x1000)
+			},
+			err: "invalid size of base relocation block",
+		},
+	}
+	checkMalformed(t, newData, dir, parseDir(baseRelocTableIndex), golden)
+}
+
+func TestParseImports(t *testing.T) {
+	// Import table of test.dll, importing foo by name and ordinal 5.
+	//
+	//    0x000  import descriptors
+	//    0x040  import name table
+	//    0x060  import address table
+	//    0x080  hint/name entry
+	//    0x0A0  DLL name
+	newData := func() []byte {
+		data := make([]byte, 0x100)
+		putUint32(data, 0x00, sectRVA+0x40) // import name table RVA
+		putUint32(data, 0x0C, sectRVA+0xA0) // DLL name RVA
+		putUint32(data, 0x10, sectRVA+0x60) // import address table RVA
+		putUint32(data, 0x40, sectRVA+0x80)
+		putUint32(data, 0x44, 0x80000000|5)
+		putUint32(data, 0x60, sectRVA+0x80)
+		putUint32(data, 0x64, 0x80000000|5)
+		copy(data[0x82:], "foo\x00")
+		copy(data[0xA0:], "test.dll\x00")
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 0x28}
+
+	// Valid import table.
+	file, err := parseImage(newData(), importTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	want := map[bin.Address]string{
+		imageBase + sectRVA + 0x60: "foo",
+		imageBase + sectRVA + 0x64: "test_ordinal_5",
+	}
+	if !reflect.DeepEqual(file.Imports, want) {
+		t.Errorf("imports mismatch; expected %v, got %v", want, file.Imports)
+	}
+
+	// Import table without import name table (e.g. Borland); imports are
+	// located through the import address table.
+	data := newData()
+	putUint32(data, 0x00, 0)
+	file, err = parseImage(data, importTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image without import name table; %+v", err)
+	}
+	if !reflect.DeepEqual(file.Imports, want) {
+		t.Errorf("imports mismatch of image without import name table; expected %v, got %v", want, file.Imports)
+	}
+
+	// Bound DLL without import name table; the import address table contains
+	// resolved addresses rather than RVAs of hint/name entries.
+	data = newData()
+	putUint32(data, 0x00, 0)
+	putUint32(data, 0x04, 0xFFFFFFFF) // time stamp of bound DLL
+	putUint32(data, 0x60, 0x7C801D7B)
+	putUint32(data, 0x64, 0x7C801D8B)
+	file, err = parseImage(data, importTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image with bound DLL; %+v", err)
+	}
+	if len(file.Imports) != 0 {
+		t.Errorf("imports mismatch of bound DLL; expected none, got %v", file.Imports)
+	}
+
+	// Malformed import tables.
+	end := sectRVA + uint32(len(newData()))
+	golden := []malformed{
+		{
+			name: "table extends past section",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.Size = 0x2000
+			},
+			err: "invalid import table size",
+		},
+		{
+			name: "unmapped table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = 0x80000000
+			},
+			err: "unable to locate import table",
+		},
+		{
+			name: "missing terminating import descriptor",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.Size = 0x14
+			},
+			err: "unable to read import descriptor",
+		},
+		{
+			name: "unterminated DLL name",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x0C, end-3)
+				copy(data[len(data)-3:], "dll")
+			},
+			err: "unable to read DLL name of import descriptor",
+		},
+		{
+			name: "truncated import name table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x00, end-2)
+			},
+			err: "unable to read import name table entry",
+		},
+		{
+			name: "truncated hint/name entry",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x40, end-1)
+			},
+			err: "unable to read hint of function imported",
+		},
+		{
+			name: "unterminated import name",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x40, end-5)
+				copy(data[len(data)-3:], "foo")
+			},
+			err: "unable to read name of function imported",
+		},
+	}
+	checkMalformed(t, newData, dir, parseDir(importTableIndex), golden)
+}
+
+func TestParseDelayImports(t *testing.T) {
+	// Delay-load import table of test.dll, importing foo by name and ordinal 5.
+	//
+	//    0x000  delay-load import descriptors
+	//    0x040  import name table
+	//    0x060  import address table
+	//    0x080  hint/name entry
+	//    0x0A0  DLL name
+	newData := func() []byte {
+		data := make([]byte, 0x100)
+		putUint32(data, 0x00, 1)            // RVA-based attribute
+		putUint32(data, 0x04, sectRVA+0xA0) // DLL name RVA
+		putUint32(data, 0x0C, sectRVA+0x60) // import address table RVA
+		putUint32(data, 0x10, sectRVA+0x40) // import name table RVA
+		putUint32(data, 0x40, sectRVA+0x80)
+		putUint32(data, 0x44, 0x80000000|5)
+		copy(data[0x82:], "foo\x00")
+		copy(data[0xA0:], "test.dll\x00")
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 0x40}
+
+	// Valid delay-load import table.
+	file, err := parseImage(newData(), delayImportTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	want := map[bin.Address]string{
+		imageBase + sectRVA + 0x60: "foo",
+		imageBase + sectRVA + 0x64: "test_ordinal_5",
+	}
+	if !reflect.DeepEqual(file.Imports, want) {
+		t.Errorf("imports mismatch; expected %v, got %v", want, file.Imports)
+	}
+
+	// Malformed delay-load import tables.
+	end := sectRVA + uint32(len(newData()))
+	golden := []malformed{
+		{
+			name: "unmapped table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = 0x80000000
+			},
+			err: "unable to locate delay-load import table",
+		},
+		{
+			name: "missing terminating descriptor",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = end - 0x10
+				copy(data[len(data)-0x10:], "non-zero padding")
+			},
+			err: "unable to read delay-load import descriptor",
+		},
+		{
+			name: "unterminated DLL name",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x04, end-3)
+				copy(data[len(data)-3:], "dll")
+			},
+			err: "unable to read DLL name of delay-load import descriptor",
+		},
+		{
+			name: "virtual address based descriptor with RVAs",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x00, 0)
+			},
+			err: "unable to read DLL name of delay-load import descriptor",
+		},
+	}
+	checkMalformed(t, newData, dir, parseDir(delayImportTableIndex), golden)
+}
+
+func TestParseTLS(t *testing.T) {
+	// TLS directory with two callbacks.
+	//
+	//    0x000  TLS directory
+	//    0x040  TLS callbacks
+	newData := func() []byte {
+		data := make([]byte, 0x100)
+		putUint32(data, 0x0C, imageBase+sectRVA+0x40) // address of callbacks
+		putUint32(data, 0x40, imageBase+0x1100)
+		putUint32(data, 0x44, imageBase+0x1200)
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 0x18}
+
+	// Valid TLS directory.
+	file, err := parseImage(newData(), tlsTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	want := []bin.Address{imageBase + 0x1100, imageBase + 0x1200}
+	if !reflect.DeepEqual(file.TLSCallbacks, want) {
+		t.Errorf("TLS callbacks mismatch; expected %v, got %v", want, file.TLSCallbacks)
+	}
+
+	// Malformed TLS directories.
+	end := sectRVA + uint32(len(newData()))
+	golden := []malformed{
+		{
+			name: "truncated TLS directory",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = end - 8
+			},
+			err: "unable to read address of TLS callbacks",
+		},
+		{
+			name: "unmapped callbacks",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x0C, 0x80000000)
+			},
+			err: "unable to read TLS callback",
+		},
+		{
+			name: "unterminated callbacks",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x0C, imageBase+end-4)
+				putUint32(data, len(data)-4, imageBase+0x1100)
+			},
+			err: "unable to read TLS callback",
+		},
+	}
+	checkMalformed(t, newData, dir, parseDir(tlsTableIndex), golden)
+}
+
+func TestParseRuntimeFuncs(t *testing.T) {
+	// Function table of a function and a function chunk, the unwind information
+	// of which is chained to the function.
+	//
+	//    0x000  function table
+	//    0x040  unwind information of function
+	//    0x050  chained unwind information of function chunk
+	newData := func() []byte {
+		data := make([]byte, 0x100)
+		putRuntimeFunc(data, 0x00, 0x1100, 0x1110, sectRVA+0x40)
+		putRuntimeFunc(data, 0x0C, 0x1200, 0x1210, sectRVA+0x50)
+		data[0x40] = 1                // version 1
+		data[0x50] = 1 | chainInfo<<3 // version 1, chained
+		putRuntimeFunc(data, 0x54, 0x1100, 0x1110, sectRVA+0x40)
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 0x18}
+	parse := func(data []byte, dir pe.DataDirectory) (*bin.File, error) {
+		image := buildImageMachine(pe.IMAGE_FILE_MACHINE_AMD64, data, exceptionTableIndex, dir)
+		return Parse(bytes.NewReader(image))
+	}
+
+	// Valid exception table.
+	file, err := parse(newData(), dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	want := []*bin.RuntimeFunc{
+		{
+			Start:      imageBase + 0x1100,
+			End:        imageBase + 0x1110,
+			UnwindInfo: imageBase + sectRVA + 0x40,
+		},
+		{
+			Start:      imageBase + 0x1200,
+			End:        imageBase + 0x1210,
+			UnwindInfo: imageBase + sectRVA + 0x50,
+			Parent:     imageBase + 0x1100,
+		},
+	}
+	if !reflect.DeepEqual(file.RuntimeFuncs, want) {
+		t.Errorf("runtime functions mismatch; expected %v, got %v", want, file.RuntimeFuncs)
+	}
+
+	// Malformed exception tables.
+	end := sectRVA + uint32(len(newData()))
+	golden := []malformed{
+		{
+			name: "table extends past section",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.Size = 0x2000
+			},
+			err: "invalid exception table size",
+		},
+		{
+			name: "unmapped table",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = 0x80000000
+			},
+			err: "unable to locate exception table",
+		},
+		{
+			name: "unmapped unwind information",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x08, 0x80000000)
+			},
+			err: "unable to locate unwind information",
+		},
+		{
+			name: "truncated chained unwind information",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x14, end-8)
+				data[len(data)-8] = 1 | chainInfo<<3
+			},
+			err: "invalid chained unwind information",
+		},
+		{
+			name: "cyclic chained unwind information",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x5C, sectRVA+0x50)
+			},
+			err: "chain exceeds",
+		},
+	}
+	checkMalformed(t, newData, dir, func(data []byte, dir pe.DataDirectory) error {
+		_, err := parse(data, dir)
+		return err
+	}, golden)
+}
+
+// chainInfo is the UNW_FLAG_CHAININFO flag of unwind information.
+const chainInfo = 0x4
+
+// putRuntimeFunc stores the function table entry (RUNTIME_FUNCTION) of the
+// given RVAs at the given offset of data.
+func putRuntimeFunc(data []byte, offset int, start, end, unwindInfo uint32) {
+	putUint32(data, offset, start)
+	putUint32(data, offset+4, end)
+	putUint32(data, offset+8, unwindInfo)
+}
+
+// Image base address of test images, and RVA of their only section.
+const (
+	imageBase = 0x400000
+	sectRVA   = 0x1000
+)
+
+// parseImage parses a minimal 32-bit x86 PE image with a single readable and
+// writeable section holding data at RVA sectRVA, and with the data directory of
+// the given index set to dir.
+func parseImage(data []byte, index int, dir pe.DataDirectory) (*bin.File, error) {
+	return Parse(bytes.NewReader(buildImage(data, index, dir)))
+}
+
+// buildImage returns a minimal 32-bit x86 PE image with a single readable and
+// writeable section holding data at RVA sectRVA, and with the data directory of
+// the given index set to dir.
+func buildImage(data []byte, index int, dir pe.DataDirectory) []byte {
+	return buildImageMachine(pe.IMAGE_FILE_MACHINE_I386, data, index, dir)
+}
+
+// buildImageMachine returns a minimal PE image of the given machine
+// architecture (32-bit x86 or x86-64) with a single readable and writeable
+// section holding data at RVA sectRVA, and with the data directory of the given
+// index set to dir.
+func buildImageMachine(machine uint16, data []byte, index int, dir pe.DataDirectory) []byte {
+	const (
+		dosHeaderSize = 0x40
+		fileAlign     = 0x200
+		sectAlign     = 0x1000
+	)
+	align := func(n, a int) int {
+		return (n + a - 1) &^ (a - 1)
+	}
+	fileHdr := pe.FileHeader{
+		Machine:          machine,
+		NumberOfSections: 1,
+		Characteristics:  pe.IMAGE_FILE_EXECUTABLE_IMAGE,
+	}
+	var optHdr interface{}
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		opt := &pe.OptionalHeader64{
+			Magic:               0x20B,
+			ImageBase:           imageBase,
+			SectionAlignment:    sectAlign,
+			FileAlignment:       fileAlign,
+			SizeOfImage:         uint32(sectRVA + align(len(data), sectAlign)),
+			SizeOfHeaders:       fileAlign,
+			NumberOfRvaAndSizes: 16,
+		}
+		opt.DataDirectory[index] = dir
+		fileHdr.Characteristics |= pe.IMAGE_FILE_LARGE_ADDRESS_AWARE
+		optHdr = opt
+	default:
+		opt := &pe.OptionalHeader32{
+			Magic:               0x10B,
+			ImageBase:           imageBase,
+			SectionAlignment:    sectAlign,
+			FileAlignment:       fileAlign,
+			SizeOfImage:         uint32(sectRVA + align(len(data), sectAlign)),
+			SizeOfHeaders:       fileAlign,
+			NumberOfRvaAndSizes: 16,
+		}
+		opt.DataDirectory[index] = dir
+		fileHdr.Characteristics |= pe.IMAGE_FILE_32BIT_MACHINE
+		optHdr = opt
+	}
+	fileHdr.SizeOfOptionalHeader = uint16(binary.Size(optHdr))
+	sectHdr := pe.SectionHeader32{
+		VirtualSize:      uint32(len(data)),
+		VirtualAddress:   sectRVA,
+		SizeOfRawData:    uint32(align(len(data), fileAlign)),
+		PointerToRawData: fileAlign,
+		Characteristics:  pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ | pe.IMAGE_SCN_MEM_WRITE,
+	}
+	copy(sectHdr.Name[:], ".data")
+	buf := &bytes.Buffer{}
+	dosHdr := make([]byte, dosHeaderSize)
+	copy(dosHdr, "MZ")
+	putUint32(dosHdr, 0x3C, dosHeaderSize)
+	buf.Write(dosHdr)
+	buf.WriteString("PE\x00\x00")
+	for _, v := range []interface{}{fileHdr, optHdr, sectHdr} {
+		if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+			panic(err)
+		}
+	}
+	buf.Write(make([]byte, fileAlign-buf.Len()))
+	buf.Write(data)
+	buf.Write(make([]byte, align(len(data), fileAlign)-len(data)))
+	return buf.Bytes()
+}
+
+// A malformed test case specifies how to modify the data and data directory of
+// a valid test image, and the expected error.
+type malformed struct {
+	// Test case name.
+	name string
+	// Modifies the data and data directory of the test image.
+	modify func(data []byte, dir *pe.DataDirectory)
+	// Expected error message.
+	err string
+}
+
+// checkMalformed checks that parse fails with the expected error, for each
+// malformed test case applied to the data returned by newData and to dir.
+func checkMalformed(t *testing.T, newData func() []byte, dir pe.DataDirectory, parse func(data []byte, dir pe.DataDirectory) error, golden []malformed) {
+	t.Helper()
+	for _, g := range golden {
+		data, dir := newData(), dir
+		g.modify(data, &dir)
+		checkErr(t, g.name, parse(data, dir), g.err)
+	}
+}
+
+// parseDir returns a function which parses a minimal 32-bit x86 PE image (see
+// parseImage), with the data directory of the given index.
+func parseDir(index int) func(data []byte, dir pe.DataDirectory) error {
+	return func(data []byte, dir pe.DataDirectory) error {
+		_, err := parseImage(data, index, dir)
+		return err
+	}
+}
+
+// checkErr checks that err is non-nil and contains the expected error message.
+func checkErr(t *testing.T, name string, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Errorf("%s: expected error containing %q, got nil", name, want)
+		return
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("%s: error mismatch; expected error containing %q, got %q", name, want, err)
+	}
+}
+
+// putUint16 stores the little-endian encoded value v at the given offset of
+// data.
+func putUint16(data []byte, offset int, v uint16) {
+	binary.LittleEndian.PutUint16(data[offset:], v)
+}
+
+// putUint32 stores the little-endian encoded value v at the given offset of
+// data.
+func putUint32(data []byte, offset int, v uint32) {
+	binary.LittleEndian.PutUint32(data[offset:], v)
+}
@@ -0,0 +1,414 @@
+package pe
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf16"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// A ResourceDir is a resource directory (IMAGE_RESOURCE_DIRECTORY).
+//
+// The resource directory tree has three levels; resource type, resource name
+// and resource language. The leaves of the tree are resource data entries.
+//
+// ref: https://docs.microsoft.com/en-us/windows/win32/debug/pe-format#the-rsrc-section
+type ResourceDir struct {
+	// Resource flags; reserved.
+	Characteristics uint32
+	// Time stamp of the resource data.
+	Date uint32
+	// Major version number.
+	MajorVersion uint16
+	// Minor version number.
+	MinorVersion uint16
+	// Resource directory entries; named entries followed by ID entries.
+	Entries []*ResourceEntry
+}
+
+// A ResourceEntry is a resource directory entry
+// (IMAGE_RESOURCE_DIRECTORY_ENTRY).
+type ResourceEntry struct {
+	// Name of named entry; or empty if ID entry.
+	Name string
+	// ID of ID entry.
+	ID uint32
+	// Resource subdirectory; or nil if leaf.
+	Dir *ResourceDir
+	// Resource data of leaf; or nil if subdirectory.
+	Data *ResourceData
+}
+
+// String returns the string representation of the resource directory entry;
+// its name if named entry and its ID otherwise.
+func (entry *ResourceEntry) String() string {
+	if len(entry.Name) > 0 {
+		return entry.Name
+	}
+	return fmt.Sprintf("%d", entry.ID)
+}
+
+// A ResourceData is a resource data entry (IMAGE_RESOURCE_DATA_ENTRY).
+type ResourceData struct {
+	// Address of the resource data.
+	Addr bin.Address
+	// Resource data.
+	Data []byte
+	// Code page used to decode code point values within the resource data.
+	CodePage uint32
+}
+
+// A Resource is a leaf of the resource directory tree, as identified by its
+// type, name and language.
+type Resource struct {
+	// Resource type; or 0 if named resource type.
+	Type ResourceType
+	// Resource type name of named resource type; or empty if resource type ID.
+	TypeName string
+	// Resource name; or empty if resource ID.
+	Name string
+	// Resource ID.
+	ID uint32
+	// Language ID.
+	Lang uint32
+	// Resource data.
+	*ResourceData
+}
+
+// ResourceType specifies the type of a resource.
+type ResourceType uint32
+
+// Resource types.
+const (
+	ResourceCursor       ResourceType = 1  // RT_CURSOR
+	ResourceBitmap       ResourceType = 2  // RT_BITMAP
+	ResourceIcon         ResourceType = 3  // RT_ICON
+	ResourceMenu         ResourceType = 4  // RT_MENU
+	ResourceDialog       ResourceType = 5  // RT_DIALOG
+	ResourceString       ResourceType = 6  // RT_STRING
+	ResourceFontDir      ResourceType = 7  // RT_FONTDIR
+	ResourceFont         ResourceType = 8  // RT_FONT
+	ResourceAccelerator  ResourceType = 9  // RT_ACCELERATOR
+	ResourceRCData       ResourceType = 10 // RT_RCDATA
+	ResourceMessageTable ResourceType = 11 // RT_MESSAGETABLE
+	ResourceGroupCursor  ResourceType = 12 // RT_GROUP_CURSOR
+	ResourceGroupIcon    ResourceType = 14 // RT_GROUP_ICON
+	ResourceVersion      ResourceType = 16 // RT_VERSION
+	ResourceDlgInclude   ResourceType = 17 // RT_DLGINCLUDE
+	ResourcePlugPlay     ResourceType = 19 // RT_PLUGPLAY
+	ResourceVXD          ResourceType = 20 // RT_VXD
+	ResourceAniCursor    ResourceType = 21 // RT_ANICURSOR
+	ResourceAniIcon      ResourceType = 22 // RT_ANIICON
+	ResourceHTML         ResourceType = 23 // RT_HTML
+	ResourceManifest     ResourceType = 24 // RT_MANIFEST
+)
+
+// String returns the string representation of the resource type.
+func (typ ResourceType) String() string {
+	m := map[ResourceType]string{
+		ResourceCursor:       "cursor",
+		ResourceBitmap:       "bitmap",
+		ResourceIcon:         "icon",
+		ResourceMenu:         "menu",
+		ResourceDialog:       "dialog",
+		ResourceString:       "string",
+		ResourceFontDir:      "fontdir",
+		ResourceFont:         "font",
+		ResourceAccelerator:  "accelerator",
+		ResourceRCData:       "rcdata",
+		ResourceMessageTable: "messagetable",
+		ResourceGroupCursor:  "group_cursor",
+		ResourceGroupIcon:    "group_icon",
+		ResourceVersion:      "version",
+		ResourceDlgInclude:   "dlginclude",
+		ResourcePlugPlay:     "plugplay",
+		ResourceVXD:          "vxd",
+		ResourceAniCursor:    "anicursor",
+		ResourceAniIcon:      "aniicon",
+		ResourceHTML:         "html",
+		ResourceManifest:     "manifest",
+	}
+	if s, ok := m[typ]; ok {
+		return s
+	}
+	return fmt.Sprintf("ResourceType(%d)", uint32(typ))
+}
+
+// ParseResourcesFile parses the resource directory tree of the given PE binary
+// executable, reading from path. The returned resource directory is nil if the
+// PE file has no resources.
+func ParseResourcesFile(path string) (*ResourceDir, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return ParseResources(f)
+}
+
+// ParseResources parses the resource directory tree of the given PE binary
+// executable, reading from r. The returned resource directory is nil if the PE
+// file has no resources.
+//
+// Only the section table and the resource directory are parsed; thus resources
+// may be extracted from PE files of any machine architecture, and regardless of
+// malformed import tables.
+//
+// Users are responsible for closing r.
+func ParseResources(r io.ReaderAt) (*ResourceDir, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file := &bin.File{}
+	var rsrcDir pe.DataDirectory
+	switch opt := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		file.Base = bin.Address(opt.ImageBase)
+		rsrcDir = opt.DataDirectory[resourceTableIndex]
+	case *pe.OptionalHeader64:
+		file.Base = bin.Address(opt.ImageBase)
+		rsrcDir = opt.DataDirectory[resourceTableIndex]
+	default:
+		return nil, errors.Errorf("support for optional header type %T not yet implemented", opt)
+	}
+	if err := parseSections(file, f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseResourceDir(file, rsrcDir)
+}
+
+// parseResourceDir parses the resource directory tree of the given data
+// directory. The returned resource directory is nil if the PE file has no
+// resources.
+func parseResourceDir(file *bin.File, rsrcDir pe.DataDirectory) (*ResourceDir, error) {
+	// Early return if resource directory not present.
+	if rsrcDir.Size == 0 {
+		return nil, nil
+	}
+	rsrcAddr := file.Base + bin.Address(rsrcDir.VirtualAddress)
+	dbg.Println("resource directory addr:", rsrcAddr)
+	data, err := file.DataAt(rsrcAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to locate resource directory")
+	}
+	p := &resourceParser{
+		file: file,
+		data: data,
+		seen: make(map[uint32]bool),
+	}
+	return p.parseDir(0, 0)
+}
+
+// resourceParser parses the resource directory tree of a PE file.
+type resourceParser struct {
+	// PE file.
+	file *bin.File
+	// Contents of the resource directory; offsets of resource directory
+	// entries are relative to the start of the resource directory.
+	data []byte
+	// Offsets of parsed resource directories, to prevent infinite loops on
+	// malformed input.
+	seen map[uint32]bool
+}
+
+// parseDir parses the resource directory at the given offset and depth.
+func (p *resourceParser) parseDir(offset uint32, depth int) (*ResourceDir, error) {
+	// Maximum depth of the resource directory tree; the resource type, name and
+	// language levels.
+	const maxDepth = 3
+	if depth >= maxDepth {
+		return nil, errors.Errorf("invalid resource directory at offset 0x%X; exceeds maximum depth %d", offset, maxDepth)
+	}
+	if p.seen[offset] {
+		return nil, errors.Errorf("invalid resource directory at offset 0x%X; cycle detected", offset)
+	}
+	p.seen[offset] = true
+	// IMAGE_RESOURCE_DIRECTORY
+	//
+	//    Characteristics      uint32
+	//    TimeDateStamp        uint32
+	//    MajorVersion         uint16
+	//    MinorVersion         uint16
+	//    NumberOfNamedEntries uint16
+	//    NumberOfIdEntries    uint16
+	const dirSize = 16
+	buf, err := p.read(offset, dirSize)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dir := &ResourceDir{
+		Characteristics: binary.LittleEndian.Uint32(buf[0:]),
+		Date:            binary.LittleEndian.Uint32(buf[4:]),
+		MajorVersion:    binary.LittleEndian.Uint16(buf[8:]),
+		MinorVersion:    binary.LittleEndian.Uint16(buf[10:]),
+	}
+	nentries := uint32(binary.LittleEndian.Uint16(buf[12:])) + uint32(binary.LittleEndian.Uint16(buf[14:]))
+	// IMAGE_RESOURCE_DIRECTORY_ENTRY
+	//
+	//    Name         uint32 (high bit set if named entry)
+	//    OffsetToData uint32 (high bit set if subdirectory)
+	const entrySize = 8
+	const highBit = 0x80000000
+	for i := uint32(0); i < nentries; i++ {
+		buf, err := p.read(offset+dirSize+i*entrySize, entrySize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		name := binary.LittleEndian.Uint32(buf[0:])
+		dataOffset := binary.LittleEndian.Uint32(buf[4:])
+		entry := &ResourceEntry{}
+		if name&highBit != 0 {
+			s, err := p.readName(name &^ highBit)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			entry.Name = s
+		} else {
+			entry.ID = name
+		}
+		if dataOffset&highBit != 0 {
+			subdir, err := p.parseDir(dataOffset&^highBit, depth+1)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			entry.Dir = subdir
+		} else {
+			data, err := p.parseData(dataOffset)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			entry.Data = data
+		}
+		dir.Entries = append(dir.Entries, entry)
+	}
+	return dir, nil
+}
+
+// parseData parses the resource data entry at the given offset.
+func (p *resourceParser) parseData(offset uint32) (*ResourceData, error) {
+	// IMAGE_RESOURCE_DATA_ENTRY
+	//
+	//    OffsetToData uint32 (RVA)
+	//    Size         uint32
+	//    CodePage     uint32
+	//    Reserved     uint32
+	const dataEntrySize = 16
+	buf, err := p.read(offset, dataEntrySize)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addr := p.file.Base + bin.Address(binary.LittleEndian.Uint32(buf[0:]))
+	size := binary.LittleEndian.Uint32(buf[4:])
+	data, err := p.file.DataAt(addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to locate resource data")
+	}
+	if uint64(size) > uint64(len(data)) {
+		return nil, errors.Errorf("invalid size of resource data at address %v; expected <= %d bytes, got %d", addr, len(data), size)
+	}
+	rdata := &ResourceData{
+		Addr:     addr,
+		Data:     data[:size],
+		CodePage: binary.LittleEndian.Uint32(buf[8:]),
+	}
+	return rdata, nil
+}
+
+// readName reads the length-prefixed UTF-16 encoded resource name at the given
+// offset (IMAGE_RESOURCE_DIR_STRING_U).
+func (p *resourceParser) readName(offset uint32) (string, error) {
+	buf, err := p.read(offset, 2)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	n := uint32(binary.LittleEndian.Uint16(buf))
+	buf, err = p.read(offset+2, 2*n)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return decodeUTF16(buf), nil
+}
+
+// read returns n bytes at the given offset of the resource directory.
+func (p *resourceParser) read(offset, n uint32) ([]byte, error) {
+	end := uint64(offset) + uint64(n)
+	if end > uint64(len(p.data)) {
+		return nil, errors.Errorf("invalid resource directory offset 0x%X; exceeds %d bytes", end, len(p.data))
+	}
+	return p.data[offset:end], nil
+}
+
+// Resources returns the resources of the resource directory tree, in the order
+// of the resource type, name and language levels of the tree.
+func (dir *ResourceDir) Resources() []*Resource {
+	var rs []*Resource
+	for _, typ := range dir.Entries {
+		if typ.Dir == nil {
+			continue
+		}
+		for _, name := range typ.Dir.Entries {
+			if name.Dir == nil {
+				// Resource without language level.
+				if name.Data != nil {
+					rs = append(rs, newResource(typ, name, nil))
+				}
+				continue
+			}
+			for _, lang := range name.Dir.Entries {
+				if lang.Data == nil {
+					continue
+				}
+				rs = append(rs, newResource(typ, name, lang))
+			}
+		}
+	}
+	return rs
+}
+
+// newResource returns a new resource based on the given resource type, name and
+// language entries of the resource directory tree. The language entry is
+// optional; the resource data is stored in the name entry if lang is nil.
+func newResource(typ, name, lang *ResourceEntry) *Resource {
+	r := &Resource{
+		TypeName: typ.Name,
+		Name:     name.Name,
+		ID:       name.ID,
+	}
+	if len(typ.Name) == 0 {
+		r.Type = ResourceType(typ.ID)
+	}
+	if lang != nil {
+		r.Lang = lang.ID
+		r.ResourceData = lang.Data
+	} else {
+		r.ResourceData = name.Data
+	}
+	return r
+}
+
+// dataAddrs returns the addresses of the resource directory and resource data
+// of the given resource directory tree.
+func (dir *ResourceDir) dataAddrs(rsrcAddr bin.Address) []bin.Address {
+	addrs := []bin.Address{rsrcAddr}
+	for _, r := range dir.Resources() {
+		addrs = append(addrs, r.Addr)
+	}
+	return addrs
+}
+
+// ### [ Helper functions ] ####################################################
+
+// decodeUTF16 decodes the given little-endian UTF-16 encoded data.
+func decodeUTF16(buf []byte) string {
+	u := make([]uint16, len(buf)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(buf[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,256 @@
+package pe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestParseResources(t *testing.T) {
+	// Resource directory tree with a single version resource, named "ABC" and
+	// of language 0x409.
+	//
+	//    0x000  type directory
+	//    0x018  name directory
+	//    0x030  language directory
+	//    0x048  resource data entry
+	//    0x060  resource name
+	//    0x100  resource data
+	rsrcData := []byte("resource data")
+	newData := func() []byte {
+		data := make([]byte, 0x200)
+		putUint16(data, 0x0E, 1) // number of ID entries
+		putUint32(data, 0x10, uint32(ResourceVersion))
+		putUint32(data, 0x14, 0x80000000|0x18) // subdirectory
+		putUint16(data, 0x18+0x0C, 1)          // number of named entries
+		putUint32(data, 0x28, 0x80000000|0x60) // named entry
+		putUint32(data, 0x2C, 0x80000000|0x30) // subdirectory
+		putUint16(data, 0x30+0x0E, 1)          // number of ID entries
+		putUint32(data, 0x40, 0x409)           // language ID
+		putUint32(data, 0x44, 0x48)            // resource data entry
+		putUint32(data, 0x48, sectRVA+0x100)   // resource data RVA
+		putUint32(data, 0x4C, uint32(len(rsrcData)))
+		putUint32(data, 0x50, 1252) // code page
+		putUint16(data, 0x60, 3)    // name length
+		copy(data[0x62:], encodeUTF16("ABC"))
+		copy(data[0x100:], rsrcData)
+		return data
+	}
+	dir := pe.DataDirectory{VirtualAddress: sectRVA, Size: 0x100}
+	want := []*Resource{
+		{
+			Type: ResourceVersion,
+			Name: "ABC",
+			Lang: 0x409,
+			ResourceData: &ResourceData{
+				Addr:     imageBase + sectRVA + 0x100,
+				Data:     rsrcData,
+				CodePage: 1252,
+			},
+		},
+	}
+
+	// Valid resource directory, parsed as part of the PE image.
+	file, err := parseImage(newData(), resourceTableIndex, dir)
+	if err != nil {
+		t.Fatalf("unable to parse PE image; %+v", err)
+	}
+	wantAddrs := []bin.Address{imageBase + sectRVA, imageBase + sectRVA + 0x100}
+	if !reflect.DeepEqual(file.DataAddrs, wantAddrs) {
+		t.Errorf("data addresses mismatch; expected %v, got %v", wantAddrs, file.DataAddrs)
+	}
+
+	// Valid resource directory, parsed independent of the machine architecture
+	// of the PE image.
+	image := buildImage(newData(), resourceTableIndex, dir)
+	putUint16(image, 0x44, pe.IMAGE_FILE_MACHINE_ARM64)
+	rsrc, err := ParseResources(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("unable to parse resources; %+v", err)
+	}
+	if got := rsrc.Resources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("resources mismatch; expected %v, got %v", want, got)
+	}
+
+	// Malformed resource directories.
+	golden := []malformed{
+		{
+			name: "unmapped resource directory",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = 0x80000000
+			},
+			err: "unable to locate resource directory",
+		},
+		{
+			name: "truncated resource directory",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				dir.VirtualAddress = sectRVA + uint32(len(data)) - 8
+			},
+			err: "invalid resource directory offset",
+		},
+		{
+			name: "cyclic subdirectory",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x2C, 0x80000000|0x18)
+			},
+			err: "cycle detected",
+		},
+		{
+			name: "name length exceeds resource directory",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint16(data, 0x60, 0xFFFF)
+			},
+			err: "invalid resource directory offset",
+		},
+		{
+			name: "unmapped resource data",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x48, 0x80000000)
+			},
+			err: "unable to locate resource data",
+		},
+		{
+			name: "resource data size exceeds section",
+			modify: func(data []byte, dir *pe.DataDirectory) {
+				putUint32(data, 0x4C, 0x1000)
+			},
+			err: "invalid size of resource data",
+		},
+	}
+	parse := func(data []byte, dir pe.DataDirectory) error {
+		_, err := ParseResources(bytes.NewReader(buildImage(data, resourceTableIndex, dir)))
+		return err
+	}
+	checkMalformed(t, newData, dir, parse, golden)
+}
+
+func TestParseVersionInfo(t *testing.T) {
+	// VS_FIXEDFILEINFO of file version 1.2.3.4 and product version 5.6.7.8.
+	fixed := make([]byte, 52)
+	putUint32(fixed, 0, 0xFEEF04BD)
+	putUint32(fixed, 8, 1<<16|2)
+	putUint32(fixed, 12, 3<<16|4)
+	putUint32(fixed, 16, 5<<16|6)
+	putUint32(fixed, 20, 7<<16|8)
+	putUint32(fixed, 24, 0x3F) // file flags mask
+	putUint32(fixed, 28, 0x41) // file flags
+	putUint32(fixed, 32, 0x40004)
+	putUint32(fixed, 36, 1)
+	data := encodeVersionBlock("VS_VERSION_INFO", fixed, false,
+		encodeVersionBlock("StringFileInfo", nil, true,
+			encodeVersionBlock("040904B0", nil, true,
+				encodeVersionBlock("ProductName", encodeUTF16("Foo\x00"), true),
+				encodeVersionBlock("FileVersion", encodeUTF16("1.2.3.4\x00"), true),
+			),
+		),
+		encodeVersionBlock("VarFileInfo", nil, true,
+			encodeVersionBlock("Translation", []byte{0x09, 0x04, 0xB0, 0x04}, false),
+		),
+	)
+	info, err := ParseVersionInfo(data)
+	if err != nil {
+		t.Fatalf("unable to parse version information; %+v", err)
+	}
+	want := &VersionInfo{
+		FileVersion:    [4]uint16{1, 2, 3, 4},
+		ProductVersion: [4]uint16{5, 6, 7, 8},
+		FileFlags:      0x01,
+		FileOS:         0x40004,
+		FileType:       1,
+		Strings: map[string]map[string]string{
+			"040904B0": {
+				"ProductName": "Foo",
+				"FileVersion": "1.2.3.4",
+			},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("version information mismatch; expected %+v, got %+v", want, info)
+	}
+
+	// Block without value, whose length excludes the padding of the key.
+	//
+	//    wLength=10, wValueLength=0, wType=0, szKey="A"
+	block := []byte{10, 0, 0, 0, 0, 0, 'A', 0, 0, 0}
+	if _, err := parseVersionBlock(block, 0); err != nil {
+		t.Errorf("unable to parse version information block without value; %+v", err)
+	}
+
+	// Malformed version information.
+	golden := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{
+			// wLength=10, wValueLength=4, wType=0, szKey="A"
+			name: "value offset exceeds block length",
+			data: []byte{10, 0, 4, 0, 0, 0, 'A', 0, 0, 0},
+			err:  "value offset 12 exceeds block length 10",
+		},
+		{
+			// wLength=10, wValueLength=0, wType=0, szKey="AB" (unterminated)
+			name: "unterminated key",
+			data: []byte{10, 0, 0, 0, 0, 0, 'A', 0, 'B', 0},
+			err:  "missing NULL-terminator",
+		},
+		{
+			name: "block length exceeds data",
+			data: []byte{12, 0, 0, 0, 0, 0, 'A', 0, 0, 0},
+			err:  "invalid version information block length",
+		},
+		{
+			name: "invalid key",
+			data: encodeVersionBlock("VS_VERSION", nil, false),
+			err:  "invalid version information key",
+		},
+	}
+	for _, g := range golden {
+		_, err := ParseVersionInfo(g.data)
+		checkErr(t, g.name, err, g.err)
+	}
+}
+
+// encodeVersionBlock returns the encoding of a version information block with
+// the given key, value and child blocks.
+func encodeVersionBlock(key string, value []byte, text bool, children ...[]byte) []byte {
+	pad := func(buf []byte) []byte {
+		for len(buf)%4 != 0 {
+			buf = append(buf, 0)
+		}
+		return buf
+	}
+	buf := make([]byte, 6)
+	valueLength := len(value)
+	if text {
+		valueLength /= 2
+	}
+	binary.LittleEndian.PutUint16(buf[2:], uint16(valueLength))
+	if text {
+		binary.LittleEndian.PutUint16(buf[4:], 1)
+	}
+	buf = append(buf, encodeUTF16(key+"\x00")...)
+	if len(value) > 0 || len(children) > 0 {
+		buf = pad(buf)
+	}
+	buf = append(buf, value...)
+	for _, child := range children {
+		buf = append(pad(buf), child...)
+	}
+	binary.LittleEndian.PutUint16(buf, uint16(len(buf)))
+	return buf
+}
+
+// encodeUTF16 returns the little-endian UTF-16 encoding of s.
+func encodeUTF16(s string) []byte {
+	var buf []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf = append(buf, byte(u), byte(u>>8))
+	}
+	return buf
+}
@@ -0,0 +1,627 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// === [ String tables ] =======================================================
+
+// StringTable returns the strings of the given string table resource (RT_STRING);
+// a map from string ID to string. Empty strings are omitted.
+//
+// Each string table resource contains a block of 16 strings, each prefixed by
+// its length in UTF-16 code units. The string IDs of the block are derived from
+// the resource ID of the string table.
+func StringTable(r *Resource) (map[uint32]string, error) {
+	if r.Type != ResourceString {
+		return nil, errors.Errorf("invalid resource type; expected %v, got %v", ResourceString, r.Type)
+	}
+	if r.ID == 0 {
+		return nil, errors.New("invalid string table resource ID; expected > 0")
+	}
+	strs := make(map[uint32]string)
+	data := r.Data
+	const nstrs = 16
+	for i := uint32(0); i < nstrs; i++ {
+		if len(data) < 2 {
+			return nil, errors.Errorf("invalid string table %d; truncated string %d", r.ID, i)
+		}
+		n := int(binary.LittleEndian.Uint16(data))
+		data = data[2:]
+		if len(data) < 2*n {
+			return nil, errors.Errorf("invalid string table %d; string %d of length %d exceeds %d bytes", r.ID, i, n, len(data))
+		}
+		if n > 0 {
+			id := (r.ID-1)*nstrs + i
+			strs[id] = decodeUTF16(data[:2*n])
+		}
+		data = data[2*n:]
+	}
+	return strs, nil
+}
+
+// === [ Dialogs ] =============================================================
+
+// A Dialog is a dialog box template (RT_DIALOG).
+type Dialog struct {
+	// Extended dialog box template (DLGTEMPLATEEX).
+	Extended bool
+	// Help context ID; extended dialog box template only.
+	HelpID uint32
+	// Window style.
+	Style uint32
+	// Extended window style.
+	ExStyle uint32
+	// Position and size of the dialog box, in dialog box units.
+	X, Y, Width, Height int16
+	// Menu resource; or empty if none.
+	Menu string
+	// Window class; or empty if predefined dialog box class.
+	Class string
+	// Dialog box title.
+	Title string
+	// Font point size; or 0 if no font is specified.
+	FontSize uint16
+	// Font weight; extended dialog box template only.
+	FontWeight uint16
+	// Italic font; extended dialog box template only.
+	FontItalic bool
+	// Font typeface name.
+	FontName string
+	// Controls of the dialog box.
+	Items []*DialogItem
+}
+
+// A DialogItem is a control of a dialog box template.
+type DialogItem struct {
+	// Control ID.
+	ID uint32
+	// Help context ID; extended dialog box template only.
+	HelpID uint32
+	// Window style.
+	Style uint32
+	// Extended window style.
+	ExStyle uint32
+	// Position and size of the control, in dialog box units.
+	X, Y, Width, Height int16
+	// Window class (e.g. "Button").
+	Class string
+	// Control text or resource.
+	Title string
+	// Creation data passed to the control.
+	Extra []byte
+}
+
+// dsSetFont (DS_SETFONT) is the dialog box style which specifies the presence
+// of font information; also part of DS_SHELLFONT.
+const dsSetFont = 0x40
+
+// ParseDialog parses the given dialog box template resource data, either a
+// standard (DLGTEMPLATE) or an extended (DLGTEMPLATEEX) dialog box template.
+//
+// ref: https://docs.microsoft.com/en-us/windows/win32/dlgbox/dlgtemplateex
+func ParseDialog(data []byte) (*Dialog, error) {
+	r := &dialogReader{data: data}
+	dlg := &Dialog{}
+	if len(data) >= 4 && binary.LittleEndian.Uint16(data[2:]) == 0xFFFF {
+		// DLGTEMPLATEEX
+		//
+		//    dlgVer    uint16 (1)
+		//    signature uint16 (0xFFFF)
+		//    helpID    uint32
+		//    exStyle   uint32
+		//    style     uint32
+		//    cDlgItems uint16
+		//    x, y, cx, cy int16
+		dlg.Extended = true
+		r.uint16()
+		r.uint16()
+		dlg.HelpID = r.uint32()
+		dlg.ExStyle = r.uint32()
+		dlg.Style = r.uint32()
+	} else {
+		// DLGTEMPLATE
+		//
+		//    style           uint32
+		//    dwExtendedStyle uint32
+		//    cdit            uint16
+		//    x, y, cx, cy    int16
+		dlg.Style = r.uint32()
+		dlg.ExStyle = r.uint32()
+	}
+	nitems := r.uint16()
+	dlg.X, dlg.Y, dlg.Width, dlg.Height = r.rect()
+	dlg.Menu = r.nameOrOrdinal(false)
+	dlg.Class = r.nameOrOrdinal(true)
+	dlg.Title = r.string()
+	if dlg.Style&dsSetFont != 0 {
+		dlg.FontSize = r.uint16()
+		if dlg.Extended {
+			dlg.FontWeight = r.uint16()
+			dlg.FontItalic = r.uint8() != 0
+			// charset
+			r.uint8()
+		}
+		dlg.FontName = r.string()
+	}
+	for i := 0; i < int(nitems); i++ {
+		// Controls are aligned on 32-bit boundaries.
+		r.align(4)
+		item := &DialogItem{}
+		if dlg.Extended {
+			// DLGITEMTEMPLATEEX
+			//
+			//    helpID       uint32
+			//    exStyle      uint32
+			//    style        uint32
+			//    x, y, cx, cy int16
+			//    id           uint32
+			item.HelpID = r.uint32()
+			item.ExStyle = r.uint32()
+			item.Style = r.uint32()
+			item.X, item.Y, item.Width, item.Height = r.rect()
+			item.ID = r.uint32()
+		} else {
+			// DLGITEMTEMPLATE
+			//
+			//    style           uint32
+			//    dwExtendedStyle uint32
+			//    x, y, cx, cy    int16
+			//    id              uint16
+			item.Style = r.uint32()
+			item.ExStyle = r.uint32()
+			item.X, item.Y, item.Width, item.Height = r.rect()
+			item.ID = uint32(r.uint16())
+		}
+		item.Class = r.nameOrOrdinal(true)
+		item.Title = r.nameOrOrdinal(false)
+		n := r.uint16()
+		item.Extra = r.bytes(int(n))
+		dlg.Items = append(dlg.Items, item)
+	}
+	if r.err != nil {
+		return nil, errors.WithStack(r.err)
+	}
+	return dlg, nil
+}
+
+// dialogReader reads the fields of dialog box templates. Reads past the end of
+// the data are recorded as an error and return zero values.
+type dialogReader struct {
+	// Dialog box template data.
+	data []byte
+	// Current offset into the data.
+	pos int
+	// First error encountered.
+	err error
+}
+
+// bytes reads n bytes.
+func (r *dialogReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data)-r.pos {
+		r.err = errors.Errorf("unexpected end of dialog box template; expected %d bytes at offset %d, got %d", n, r.pos, len(r.data)-r.pos)
+		return nil
+	}
+	buf := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return buf
+}
+
+// uint8 reads an 8-bit value.
+func (r *dialogReader) uint8() uint8 {
+	buf := r.bytes(1)
+	if buf == nil {
+		return 0
+	}
+	return buf[0]
+}
+
+// uint16 reads a 16-bit little-endian value.
+func (r *dialogReader) uint16() uint16 {
+	buf := r.bytes(2)
+	if buf == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(buf)
+}
+
+// uint32 reads a 32-bit little-endian value.
+func (r *dialogReader) uint32() uint32 {
+	buf := r.bytes(4)
+	if buf == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(buf)
+}
+
+// rect reads the position and size of a dialog box or control.
+func (r *dialogReader) rect() (x, y, width, height int16) {
+	x = int16(r.uint16())
+	y = int16(r.uint16())
+	width = int16(r.uint16())
+	height = int16(r.uint16())
+	return x, y, width, height
+}
+
+// string reads a NULL-terminated UTF-16 encoded string.
+func (r *dialogReader) string() string {
+	buf := &bytes.Buffer{}
+	for r.err == nil {
+		c := r.bytes(2)
+		if c == nil || (c[0] == 0 && c[1] == 0) {
+			break
+		}
+		buf.Write(c)
+	}
+	return decodeUTF16(buf.Bytes())
+}
+
+// nameOrOrdinal reads a variable-length array of 16-bit elements (sz_Or_Ord),
+// specifying either a NULL-terminated UTF-16 encoded string, an ordinal value
+// or nothing. Ordinals of window classes are mapped to the names of predefined
+// system classes.
+func (r *dialogReader) nameOrOrdinal(class bool) string {
+	if r.pos+2 > len(r.data) {
+		r.err = errors.Errorf("unexpected end of dialog box template at offset %d", r.pos)
+		return ""
+	}
+	switch binary.LittleEndian.Uint16(r.data[r.pos:]) {
+	case 0x0000:
+		r.pos += 2
+		return ""
+	case 0xFFFF:
+		r.pos += 2
+		ordinal := r.uint16()
+		if class {
+			if name, ok := predefinedClasses[ordinal]; ok {
+				return name
+			}
+		}
+		return fmt.Sprintf("#%d", ordinal)
+	}
+	return r.string()
+}
+
+// align aligns the current offset to the given alignment.
+func (r *dialogReader) align(n int) {
+	if rem := r.pos % n; rem != 0 {
+		r.pos += n - rem
+	}
+}
+
+// predefinedClasses maps from ordinal to the name of predefined system classes
+// of dialog box controls.
+var predefinedClasses = map[uint16]string{
+	0x0080: "Button",
+	0x0081: "Edit",
+	0x0082: "Static",
+	0x0083: "ListBox",
+	0x0084: "ScrollBar",
+	0x0085: "ComboBox",
+}
+
+// === [ Version information ] =================================================
+
+// VersionInfo is the version information of a binary executable (RT_VERSION).
+type VersionInfo struct {
+	// Binary version number of the file; most significant part first (e.g.
+	// 1.2.3.4).
+	FileVersion [4]uint16
+	// Binary version number of the product; most significant part first.
+	ProductVersion [4]uint16
+	// Attributes of the file (e.g. VS_FF_DEBUG).
+	FileFlags uint32
+	// Operating system for which the file was designed.
+	FileOS uint32
+	// General type of file (e.g. VFT_APP).
+	FileType uint32
+	// Function of the file, as specified by the file type.
+	FileSubtype uint32
+	// String tables; map from language and code page (e.g. "040904B0") to
+	// version strings (e.g. "ProductName").
+	Strings map[string]map[string]string
+}
+
+// ParseVersionInfo parses the given version information resource data
+// (VS_VERSIONINFO).
+//
+// ref: https://docs.microsoft.com/en-us/windows/win32/menurc/vs-versioninfo
+func ParseVersionInfo(data []byte) (*VersionInfo, error) {
+	root, err := parseVersionBlock(data, 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if root.key != "VS_VERSION_INFO" {
+		return nil, errors.Errorf("invalid version information key; expected %q, got %q", "VS_VERSION_INFO", root.key)
+	}
+	info := &VersionInfo{
+		Strings: make(map[string]map[string]string),
+	}
+	// VS_FIXEDFILEINFO
+	//
+	//    dwSignature        uint32 (0xFEEF04BD)
+	//    dwStrucVersion     uint32
+	//    dwFileVersionMS    uint32
+	//    dwFileVersionLS    uint32
+	//    dwProductVersionMS uint32
+	//    dwProductVersionLS uint32
+	//    dwFileFlagsMask    uint32
+	//    dwFileFlags        uint32
+	//    dwFileOS           uint32
+	//    dwFileType         uint32
+	//    dwFileSubtype      uint32
+	//    dwFileDateMS       uint32
+	//    dwFileDateLS       uint32
+	const fixedFileInfoSize = 52
+	if v := root.value; len(v) >= fixedFileInfoSize {
+		const signature = 0xFEEF04BD
+		if sig := binary.LittleEndian.Uint32(v); sig != signature {
+			return nil, errors.Errorf("invalid fixed file information signature; expected 0x%08X, got 0x%08X", uint32(signature), sig)
+		}
+		u16 := func(offset int) uint16 {
+			return binary.LittleEndian.Uint16(v[offset:])
+		}
+		info.FileVersion = [4]uint16{u16(10), u16(8), u16(14), u16(12)}
+		info.ProductVersion = [4]uint16{u16(18), u16(16), u16(22), u16(20)}
+		info.FileFlags = binary.LittleEndian.Uint32(v[28:]) & binary.LittleEndian.Uint32(v[24:])
+		info.FileOS = binary.LittleEndian.Uint32(v[32:])
+		info.FileType = binary.LittleEndian.Uint32(v[36:])
+		info.FileSubtype = binary.LittleEndian.Uint32(v[40:])
+	}
+	for _, child := range root.children {
+		if child.key != "StringFileInfo" {
+			// Skip VarFileInfo.
+			continue
+		}
+		for _, table := range child.children {
+			strs := make(map[string]string)
+			for _, s := range table.children {
+				strs[s.key] = strings.TrimRight(decodeUTF16(s.value), "\x00")
+			}
+			info.Strings[table.key] = strs
+		}
+	}
+	return info, nil
+}
+
+// versionBlock is a block of the version information hierarchy.
+type versionBlock struct {
+	// Block key (e.g. "StringFileInfo").
+	key string
+	// Block value.
+	value []byte
+	// Child blocks.
+	children []*versionBlock
+}
+
+// parseVersionBlock parses the version information block at the start of the
+// given data, at the given depth of the version information hierarchy.
+//
+//	wLength      uint16
+//	wValueLength uint16
+//	wType        uint16 (1 if text data, 0 if binary data)
+//	szKey        NULL-terminated UTF-16 string
+//	Padding      aligned to 32-bit boundary
+//	Value        wValueLength bytes (or UTF-16 code units if text)
+//	Padding      aligned to 32-bit boundary
+//	Children     blocks
+func parseVersionBlock(data []byte, depth int) (*versionBlock, error) {
+	// Maximum depth of the version information hierarchy (VS_VERSIONINFO,
+	// StringFileInfo, StringTable, String).
+	const maxDepth = 4
+	if depth >= maxDepth {
+		return nil, errors.Errorf("invalid version information; exceeds maximum depth %d", maxDepth)
+	}
+	const hdrSize = 6
+	if len(data) < hdrSize {
+		return nil, errors.Errorf("invalid version information block; expected >= %d bytes, got %d", hdrSize, len(data))
+	}
+	length := int(binary.LittleEndian.Uint16(data[0:]))
+	valueLength := int(binary.LittleEndian.Uint16(data[2:]))
+	text := binary.LittleEndian.Uint16(data[4:]) == 1
+	if length < hdrSize || length > len(data) {
+		return nil, errors.Errorf("invalid version information block length; expected >= %d and <= %d, got %d", hdrSize, len(data), length)
+	}
+	data = data[:length]
+	pos := hdrSize
+	// Parse key.
+	start := pos
+	for pos+2 <= len(data) && (data[pos] != 0 || data[pos+1] != 0) {
+		pos += 2
+	}
+	if pos+2 > len(data) {
+		return nil, errors.Errorf("invalid version information block key at offset %d; missing NULL-terminator", start)
+	}
+	block := &versionBlock{
+		key: decodeUTF16(data[start:pos]),
+	}
+	pos = align4(pos + 2)
+	// Parse value.
+	if text {
+		valueLength *= 2
+	}
+	if pos > len(data) {
+		if valueLength != 0 {
+			return nil, errors.Errorf("invalid version information block %q; value offset %d exceeds block length %d", block.key, pos, len(data))
+		}
+		// Block without value and children, whose length excludes the padding
+		// of the key.
+		return block, nil
+	}
+	if pos+valueLength > len(data) {
+		// Some resource compilers store the value length of text values in
+		// bytes rather than UTF-16 code units.
+		valueLength = len(data) - pos
+		if valueLength < 0 {
+			valueLength = 0
+		}
+	}
+	block.value = data[pos : pos+valueLength]
+	pos = align4(pos + valueLength)
+	// Parse children.
+	for pos+hdrSize <= len(data) {
+		child, err := parseVersionBlock(data[pos:], depth+1)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		block.children = append(block.children, child)
+		n := int(binary.LittleEndian.Uint16(data[pos:]))
+		pos = align4(pos + n)
+	}
+	return block, nil
+}
+
+// align4 aligns the given offset to a 32-bit boundary.
+func align4(offset int) int {
+	return (offset + 3) &^ 3
+}
+
+// === [ Icons and bitmaps ] ===================================================
+
+// IconFile returns the contents of an icon file (*.ico) of the given icon group
+// resource (RT_GROUP_ICON). The icon images of the group are located by ID
+// among the given resources, preferring the language of the icon group.
+//
+// ref: https://devblogs.microsoft.com/oldnewthing/20120720-00/?p=7083
+func IconFile(group *Resource, rs []*Resource) ([]byte, error) {
+	if group.Type != ResourceGroupIcon {
+		return nil, errors.Errorf("invalid resource type; expected %v, got %v", ResourceGroupIcon, group.Type)
+	}
+	// GRPICONDIR
+	//
+	//    idReserved uint16
+	//    idType     uint16 (1 for icons)
+	//    idCount    uint16
+	//    idEntries  [idCount]GRPICONDIRENTRY
+	//
+	// GRPICONDIRENTRY
+	//
+	//    bWidth       uint8
+	//    bHeight      uint8
+	//    bColorCount  uint8
+	//    bReserved    uint8
+	//    wPlanes      uint16
+	//    wBitCount    uint16
+	//    dwBytesInRes uint32
+	//    nID          uint16
+	const (
+		dirSize        = 6
+		groupEntrySize = 14
+		fileEntrySize  = 16
+	)
+	data := group.Data
+	if len(data) < dirSize {
+		return nil, errors.Errorf("invalid icon group %v; expected >= %d bytes, got %d", group.ID, dirSize, len(data))
+	}
+	n := int(binary.LittleEndian.Uint16(data[4:]))
+	if len(data) < dirSize+n*groupEntrySize {
+		return nil, errors.Errorf("invalid icon group %v; expected >= %d bytes, got %d", group.ID, dirSize+n*groupEntrySize, len(data))
+	}
+	// The icon file contains an ICONDIR header, where the nID field of each
+	// entry is replaced by a 32-bit file offset of the icon image.
+	hdr := &bytes.Buffer{}
+	hdr.Write(data[:dirSize])
+	images := &bytes.Buffer{}
+	offset := dirSize + n*fileEntrySize
+	for i := 0; i < n; i++ {
+		entry := data[dirSize+i*groupEntrySize : dirSize+(i+1)*groupEntrySize]
+		id := uint32(binary.LittleEndian.Uint16(entry[12:]))
+		icon := findResource(rs, ResourceIcon, id, group.Lang)
+		if icon == nil {
+			return nil, errors.Errorf("unable to locate icon %d of icon group %v", id, group.ID)
+		}
+		hdr.Write(entry[:8])
+		binary.Write(hdr, binary.LittleEndian, uint32(len(icon.Data)))
+		binary.Write(hdr, binary.LittleEndian, uint32(offset+images.Len()))
+		images.Write(icon.Data)
+	}
+	hdr.Write(images.Bytes())
+	return hdr.Bytes(), nil
+}
+
+// BitmapFile returns the contents of a bitmap file (*.bmp) of the given bitmap
+// resource (RT_BITMAP), by prepending a bitmap file header to the device
+// independent bitmap of the resource.
+func BitmapFile(r *Resource) ([]byte, error) {
+	if r.Type != ResourceBitmap {
+		return nil, errors.Errorf("invalid resource type; expected %v, got %v", ResourceBitmap, r.Type)
+	}
+	// BITMAPINFOHEADER
+	//
+	//    biSize          uint32
+	//    biWidth         int32
+	//    biHeight        int32
+	//    biPlanes        uint16
+	//    biBitCount      uint16
+	//    biCompression   uint32
+	//    biSizeImage     uint32
+	//    biXPelsPerMeter int32
+	//    biYPelsPerMeter int32
+	//    biClrUsed       uint32
+	//    biClrImportant  uint32
+	data := r.Data
+	if len(data) < 16 {
+		return nil, errors.Errorf("invalid bitmap %v; expected >= 16 bytes, got %d", r.ID, len(data))
+	}
+	hdrSize := binary.LittleEndian.Uint32(data[0:])
+	bitCount := binary.LittleEndian.Uint16(data[14:])
+	// Size of the color table.
+	var ncolors uint32
+	if hdrSize >= 36 && len(data) >= 36 {
+		ncolors = binary.LittleEndian.Uint32(data[32:])
+	}
+	if ncolors == 0 && bitCount <= 8 {
+		ncolors = 1 << bitCount
+	}
+	var compression uint32
+	if hdrSize >= 20 && len(data) >= 20 {
+		compression = binary.LittleEndian.Uint32(data[16:])
+	}
+	// BI_BITFIELDS
+	const biBitfields = 3
+	if compression == biBitfields && hdrSize == 40 {
+		// Three color masks follow the header.
+		ncolors += 3
+	}
+	// BITMAPFILEHEADER
+	//
+	//    bfType      uint16 ("BM")
+	//    bfSize      uint32
+	//    bfReserved1 uint16
+	//    bfReserved2 uint16
+	//    bfOffBits   uint32
+	const fileHdrSize = 14
+	buf := &bytes.Buffer{}
+	buf.WriteString("BM")
+	binary.Write(buf, binary.LittleEndian, uint32(fileHdrSize+len(data)))
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	binary.Write(buf, binary.LittleEndian, uint32(fileHdrSize+hdrSize+4*ncolors))
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// findResource returns the resource of the given type and ID, preferring the
+// given language; or nil if not present.
+func findResource(rs []*Resource, typ ResourceType, id, lang uint32) *Resource {
+	var found *Resource
+	for _, r := range rs {
+		if r.Type != typ || len(r.Name) > 0 || r.ID != id {
+			continue
+		}
+		if r.Lang == lang {
+			return r
+		}
+		if found == nil {
+			found = r
+		}
+	}
+	return found
+}
@@ -0,0 +1,476 @@
+package pef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// A Loader is a PEF Loader section, which contains information about imports,
+// exports, entry points and relocations of a PEF container.
+//
+// ref: https://web.archive.org/web/20020111211702/http://developer.apple.com:80/techpubs/mac/runtimehtml/RTArch-93.html
+type Loader struct {
+	// Loader header.
+	*LoaderHeader
+	// Imported libraries.
+	ImportedLibraries []*ImportedLibrary
+	// Imported symbols.
+	ImportedSymbols []*ImportedSymbol
+	// Relocation headers; one for each section containing relocations.
+	RelocHeaders []*RelocHeader
+	// Exported symbols.
+	ExportedSymbols []*ExportedSymbol
+	// Relocation instructions, referred to by offset from relocation headers.
+	relocInstrs []byte
+}
+
+// A LoaderHeader is a PEF Loader header.
+type LoaderHeader struct {
+	// Section index of the main symbol; or -1 if not present. For PowerPC, the
+	// main symbol refers to a transition vector.
+	MainSection int32
+	// Offset in bytes of the main symbol from the start of the section.
+	MainOffset uint32
+	// Section index of the initialization function; or -1 if not present.
+	InitSection int32
+	// Offset in bytes of the initialization function transition vector.
+	InitOffset uint32
+	// Section index of the termination function; or -1 if not present.
+	TermSection int32
+	// Offset in bytes of the termination function transition vector.
+	TermOffset uint32
+	// Number of imported libraries.
+	ImportedLibraryCount uint32
+	// Total number of imported symbols.
+	TotalImportedSymbolCount uint32
+	// Number of sections containing load-time relocations.
+	RelocSectionCount uint32
+	// Offset in bytes from the start of the Loader section to the start of the
+	// relocation instructions.
+	RelocInstrOffset uint32
+	// Offset in bytes from the start of the Loader section to the start of the
+	// loader string table.
+	LoaderStringsOffset uint32
+	// Offset in bytes from the start of the Loader section to the start of the
+	// export hash table.
+	ExportHashOffset uint32
+	// Number of export hash table entries as a power of 2.
+	ExportHashTablePower uint32
+	// Number of exported symbols.
+	ExportedSymbolCount uint32
+}
+
+// An ImportedLibrary describes an imported library (shared library fragment).
+type ImportedLibrary struct {
+	// Library name.
+	Name string
+	// Oldest compatible implementation version.
+	OldImpVersion uint32
+	// Current version.
+	CurrentVersion uint32
+	// Number of symbols imported from the library.
+	ImportedSymbolCount uint32
+	// Index of the first imported symbol of the library in the imported symbol
+	// table.
+	FirstImportedSymbol uint32
+	// Import options (e.g. initialize before, weak import).
+	Options uint8
+}
+
+// An ImportedSymbol describes an imported symbol.
+type ImportedSymbol struct {
+	// Symbol name.
+	Name string
+	// Symbol class.
+	Class SymbolClass
+	// Specifies whether the symbol is weakly imported; i.e. may be missing at
+	// load time.
+	Weak bool
+	// Imported library of the symbol; or nil if not present.
+	Library *ImportedLibrary
+}
+
+// A RelocHeader specifies the relocations of a section.
+type RelocHeader struct {
+	// Section index of the relocated section.
+	SectionIndex uint16
+	// Number of 16-bit relocation blocks of the section.
+	RelocCount uint32
+	// Offset in bytes from the start of the relocation instructions to the
+	// first relocation instruction of the section.
+	FirstRelocOffset uint32
+}
+
+// An ExportedSymbol describes an exported symbol.
+type ExportedSymbol struct {
+	// Symbol name.
+	Name string
+	// Symbol class.
+	Class SymbolClass
+	// Symbol value; offset from the start of the section for symbols of
+	// instantiated sections, or the imported symbol index of re-exported
+	// symbols.
+	Value uint32
+	// Section index of the symbol; or one of the special section indices
+	// (absolute or re-exported).
+	SectionIndex int16
+}
+
+// Special section indices of exported symbols.
+const (
+	// The symbol value is an absolute address.
+	sectionAbsolute = -2
+	// The symbol is a re-exported imported symbol, as identified by the
+	// imported symbol index of the symbol value.
+	sectionReexported = -3
+)
+
+// SymbolClass specifies the class of a PEF symbol.
+type SymbolClass uint8
+
+// Symbol classes.
+const (
+	// Code address.
+	ClassCode SymbolClass = 0
+	// Data address.
+	ClassData SymbolClass = 1
+	// Standard procedure pointer (transition vector).
+	ClassTVector SymbolClass = 2
+	// Direct data area (table of contents) symbol.
+	ClassTOC SymbolClass = 3
+	// Linker-inserted glue symbol.
+	ClassGlue SymbolClass = 4
+)
+
+// String returns the string representation of the symbol class.
+func (class SymbolClass) String() string {
+	m := map[SymbolClass]string{
+		ClassCode:    "code",
+		ClassData:    "data",
+		ClassTVector: "tvector",
+		ClassTOC:     "toc",
+		ClassGlue:    "glue",
+	}
+	if s, ok := m[class]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// parseLoaderSection parses the given Loader section.
+func parseLoaderSection(sect *Section) (*Loader, error) {
+	// Overview of the structure of a PEF Loader section.
+	//
+	//    Loader header
+	//    Imported library table
+	//    Imported symbol table
+	//    Relocation headers table
+	//    Relocations
+	//    Loader string table
+	//    Export hash table
+	//    Export key table
+	//    Exported symbol table
+	data, err := sect.Data()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	const loaderHeaderSize = 56
+	if len(data) < loaderHeaderSize {
+		return nil, errors.Errorf("invalid Loader section size; expected >= %d, got %d", loaderHeaderSize, len(data))
+	}
+	hdr := &LoaderHeader{}
+	if err := binary.Read(bytes.NewReader(data[:loaderHeaderSize]), binary.BigEndian, hdr); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	loader := &Loader{
+		LoaderHeader: hdr,
+	}
+	// check validates that the given range is contained within the Loader
+	// section.
+	check := func(name string, offset, size uint64) error {
+		if offset+size > uint64(len(data)) {
+			return errors.Errorf("invalid %s range [%d:%d] of Loader section (size %d)", name, offset, offset+size, len(data))
+		}
+		return nil
+	}
+	// str returns the NULL-terminated string at the given offset of the loader
+	// string table.
+	str := func(offset uint32) string {
+		start := uint64(hdr.LoaderStringsOffset) + uint64(offset)
+		if start >= uint64(len(data)) {
+			return ""
+		}
+		buf := data[start:]
+		if pos := bytes.IndexByte(buf, '\x00'); pos != -1 {
+			buf = buf[:pos]
+		}
+		return string(buf)
+	}
+
+	// Parse imported library table.
+	const importedLibrarySize = 24
+	offset := uint64(loaderHeaderSize)
+	if err := check("imported library table", offset, uint64(hdr.ImportedLibraryCount)*importedLibrarySize); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := uint32(0); i < hdr.ImportedLibraryCount; i++ {
+		buf := data[offset:]
+		lib := &ImportedLibrary{
+			Name:                str(binary.BigEndian.Uint32(buf[0:])),
+			OldImpVersion:       binary.BigEndian.Uint32(buf[4:]),
+			CurrentVersion:      binary.BigEndian.Uint32(buf[8:]),
+			ImportedSymbolCount: binary.BigEndian.Uint32(buf[12:]),
+			FirstImportedSymbol: binary.BigEndian.Uint32(buf[16:]),
+			Options:             buf[20],
+		}
+		loader.ImportedLibraries = append(loader.ImportedLibraries, lib)
+		offset += importedLibrarySize
+	}
+
+	// Parse imported symbol table.
+	const importedSymbolSize = 4
+	if err := check("imported symbol table", offset, uint64(hdr.TotalImportedSymbolCount)*importedSymbolSize); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := uint32(0); i < hdr.TotalImportedSymbolCount; i++ {
+		// The high-order 8 bits contain the symbol class and flags, and the
+		// low-order 24 bits contain the offset of the symbol name in the loader
+		// string table.
+		v := binary.BigEndian.Uint32(data[offset:])
+		const weakFlag = 0x80
+		sym := &ImportedSymbol{
+			Name:  str(v & 0x00FFFFFF),
+			Class: SymbolClass(v >> 24 & 0x0F),
+			Weak:  v>>24&weakFlag != 0,
+		}
+		loader.ImportedSymbols = append(loader.ImportedSymbols, sym)
+		offset += importedSymbolSize
+	}
+	for _, lib := range loader.ImportedLibraries {
+		for i := lib.FirstImportedSymbol; i < lib.FirstImportedSymbol+lib.ImportedSymbolCount; i++ {
+			if int(i) < len(loader.ImportedSymbols) {
+				loader.ImportedSymbols[i].Library = lib
+			}
+		}
+	}
+
+	// Parse relocation headers table.
+	const relocHeaderSize = 12
+	if err := check("relocation headers table", offset, uint64(hdr.RelocSectionCount)*relocHeaderSize); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := uint32(0); i < hdr.RelocSectionCount; i++ {
+		buf := data[offset:]
+		relocHdr := &RelocHeader{
+			SectionIndex:     binary.BigEndian.Uint16(buf[0:]),
+			RelocCount:       binary.BigEndian.Uint32(buf[4:]),
+			FirstRelocOffset: binary.BigEndian.Uint32(buf[8:]),
+		}
+		loader.RelocHeaders = append(loader.RelocHeaders, relocHdr)
+		offset += relocHeaderSize
+	}
+
+	// Relocation instructions are located between the relocation headers table
+	// and the loader string table.
+	if hdr.RelocInstrOffset > hdr.LoaderStringsOffset || uint64(hdr.LoaderStringsOffset) > uint64(len(data)) {
+		return nil, errors.Errorf("invalid relocation instructions range [%d:%d] of Loader section (size %d)", hdr.RelocInstrOffset, hdr.LoaderStringsOffset, len(data))
+	}
+	loader.relocInstrs = data[hdr.RelocInstrOffset:hdr.LoaderStringsOffset]
+
+	// Parse exported symbols.
+	//
+	// The export hash table is followed by the export key table and the
+	// exported symbol table. The export key table contains the length of each
+	// exported symbol name (high-order 16 bits) and its hash value; the names of
+	// exported symbols are not NULL-terminated.
+	const (
+		hashEntrySize      = 4
+		keyEntrySize       = 4
+		exportedSymbolSize = 10
+	)
+	nhash := uint64(1) << hdr.ExportHashTablePower
+	keyOffset := uint64(hdr.ExportHashOffset) + nhash*hashEntrySize
+	symOffset := keyOffset + uint64(hdr.ExportedSymbolCount)*keyEntrySize
+	if err := check("exported symbol table", symOffset, uint64(hdr.ExportedSymbolCount)*exportedSymbolSize); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := uint64(0); i < uint64(hdr.ExportedSymbolCount); i++ {
+		key := binary.BigEndian.Uint32(data[keyOffset+i*keyEntrySize:])
+		nameLen := uint64(key >> 16)
+		buf := data[symOffset+i*exportedSymbolSize:]
+		classAndName := binary.BigEndian.Uint32(buf[0:])
+		nameStart := uint64(hdr.LoaderStringsOffset) + uint64(classAndName&0x00FFFFFF)
+		if err := check("exported symbol name", nameStart, nameLen); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sym := &ExportedSymbol{
+			Name:         string(data[nameStart : nameStart+nameLen]),
+			Class:        SymbolClass(classAndName >> 24 & 0x0F),
+			Value:        binary.BigEndian.Uint32(buf[4:]),
+			SectionIndex: int16(binary.BigEndian.Uint16(buf[8:])),
+		}
+		loader.ExportedSymbols = append(loader.ExportedSymbols, sym)
+	}
+	return loader, nil
+}
+
+// parseLoader parses the imports, exports, entry points and relocations of the
+// Loader section of the given container, and records them in file. Imported
+// symbols are assigned synthetic addresses starting at next. The returned
+// address is the end address of the synthetic imported symbols.
+//
+// sects and sectAddrs map from section index to instantiated section and
+// section address respectively.
+func parseLoader(file *bin.File, container *Container, sects map[int]*bin.Section, sectAddrs map[int]bin.Address, next bin.Address) (bin.Address, error) {
+	loader := container.Loader
+
+	// Parse imports.
+	//
+	// Imported symbols are assigned synthetic addresses in a separate section,
+	// as the relocations of referencing sections add the addresses of imported
+	// symbols to the relocated words.
+	const ptrSize = 4
+	start := align(next, 4)
+	importAddrs := make([]bin.Address, len(loader.ImportedSymbols))
+	for i, sym := range loader.ImportedSymbols {
+		addr := start + bin.Address(i*ptrSize)
+		importAddrs[i] = addr
+		switch sym.Class {
+		case ClassCode, ClassTVector, ClassGlue:
+			file.Imports[addr] = sym.Name
+		}
+	}
+	end := start + bin.Address(len(loader.ImportedSymbols)*ptrSize)
+	if end > start {
+		size := int(end - start)
+		sect := &bin.Section{
+			Name:    ".extern",
+			Addr:    start,
+			Data:    make([]byte, size),
+			MemSize: size,
+			Perm:    bin.PermR,
+		}
+		file.Sections = append(file.Sections, sect)
+	}
+
+	// Parse relocations.
+	for _, relocHdr := range loader.RelocHeaders {
+		sect, ok := sects[int(relocHdr.SectionIndex)]
+		if !ok {
+			return 0, errors.Errorf("unable to locate relocated section %d", relocHdr.SectionIndex)
+		}
+		offsets, err := loader.relocate(relocHdr, sect.Data, sectAddrs, importAddrs)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		for _, offset := range offsets {
+			file.Relocs[sect.Addr+bin.Address(offset)] = bin.RelocAbs32
+		}
+	}
+
+	// Parse entry points.
+	//
+	// On PowerPC, the main, initialization and termination symbols refer to
+	// transition vectors, the first word of which contains the code address.
+	entry := func(sectIndex int32, offset uint32) (bin.Address, bool) {
+		if sectIndex < 0 {
+			return 0, false
+		}
+		sect, ok := sects[int(sectIndex)]
+		if !ok {
+			warn.Printf("unable to locate section %d of entry point", sectIndex)
+			return 0, false
+		}
+		addr := sect.Addr + bin.Address(offset)
+		if sect.Perm&bin.PermX != 0 {
+			return addr, true
+		}
+		return tvectorCode(file, addr)
+	}
+	if addr, ok := entry(loader.MainSection, loader.MainOffset); ok {
+		file.Entry = addr
+	}
+	if addr, ok := entry(loader.InitSection, loader.InitOffset); ok {
+		if file.Entry == 0 {
+			file.Entry = addr
+		}
+		if _, ok := file.Exports[addr]; !ok {
+			file.Exports[addr] = "__initialize"
+		}
+	}
+	if addr, ok := entry(loader.TermSection, loader.TermOffset); ok {
+		if _, ok := file.Exports[addr]; !ok {
+			file.Exports[addr] = "__terminate"
+		}
+	}
+
+	// Parse exports.
+	for _, sym := range loader.ExportedSymbols {
+		var addr bin.Address
+		switch sym.SectionIndex {
+		case sectionReexported:
+			if int(sym.Value) >= len(loader.ImportedSymbols) {
+				warn.Printf("invalid imported symbol index %d of re-exported symbol %q", sym.Value, sym.Name)
+				continue
+			}
+			imp := loader.ImportedSymbols[sym.Value]
+			forward := imp.Name
+			if imp.Library != nil {
+				forward = fmt.Sprintf("%s.%s", imp.Library.Name, imp.Name)
+			}
+			file.Forwards[sym.Name] = forward
+			continue
+		case sectionAbsolute:
+			addr = bin.Address(sym.Value)
+		default:
+			sectAddr, ok := sectAddrs[int(sym.SectionIndex)]
+			if !ok {
+				warn.Printf("unable to locate section %d of exported symbol %q", sym.SectionIndex, sym.Name)
+				continue
+			}
+			addr = sectAddr + bin.Address(sym.Value)
+		}
+		s := &bin.Symbol{
+			Name:    sym.Name,
+			Addr:    addr,
+			Kind:    bin.SymbolObject,
+			Binding: bin.BindingGlobal,
+		}
+		switch sym.Class {
+		case ClassCode, ClassGlue:
+			s.Kind = bin.SymbolFunc
+			file.Exports[addr] = sym.Name
+		case ClassTVector:
+			// The transition vector of the function is exported as a data
+			// object, and the code address as a function.
+			if code, ok := tvectorCode(file, addr); ok {
+				file.Exports[code] = sym.Name
+				f := &bin.Symbol{
+					Name:    sym.Name,
+					Addr:    code,
+					Kind:    bin.SymbolFunc,
+					Binding: bin.BindingGlobal,
+				}
+				file.Symbols = append(file.Symbols, f)
+			}
+		}
+		file.Symbols = append(file.Symbols, s)
+	}
+	return end, nil
+}
+
+// tvectorCode returns the code address of the transition vector at the given
+// address. The boolean return value indicates success.
+func tvectorCode(file *bin.File, addr bin.Address) (bin.Address, bool) {
+	for _, sect := range file.Sections {
+		if sect.Addr <= addr && addr+4 <= sect.Addr+bin.Address(len(sect.Data)) {
+			return bin.Address(binary.BigEndian.Uint32(sect.Data[addr-sect.Addr:])), true
+		}
+	}
+	warn.Printf("unable to locate transition vector at address %v", addr)
+	return 0, false
+}
@@ -0,0 +1,178 @@
+package pef
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Pattern-initialization opcodes.
+const (
+	// Zero-fill count bytes.
+	opZero = 0
+	// Copy count bytes of raw data.
+	opBlockCopy = 1
+	// Repeat a block of count bytes of raw data repeatCount+1 times.
+	opRepeatedBlock = 2
+	// Interleave a common block of raw data with custom blocks of raw data.
+	opInterleaveRepeatBlockWithBlockCopy = 3
+	// Interleave a common block of zeros with custom blocks of raw data.
+	opInterleaveRepeatBlockWithZero = 4
+)
+
+// unpackData expands the pattern description of a pattern-initialized data
+// section into its in-memory image of the given size.
+//
+// Each pattern-initialization instruction consists of an opcode byte, where the
+// high-order 3 bits specify the opcode and the low-order 5 bits an initial
+// count; followed by zero or more arguments and raw data. If the initial count
+// is 0, the count is stored as the first argument.
+//
+// ref: https://web.archive.org/web/20020111211702/http://developer.apple.com:80/techpubs/mac/runtimehtml/RTArch-94.html
+func unpackData(packed []byte, unpackedSize uint32) ([]byte, error) {
+	r := &patternReader{packed: packed}
+	out := &bytes.Buffer{}
+	out.Grow(int(unpackedSize))
+	// fits reports an error if n blocks of the given size followed by extra
+	// bytes would exceed the unpacked size.
+	fits := func(n, size, extra uint64) error {
+		remaining := uint64(unpackedSize) - uint64(out.Len())
+		if extra > remaining {
+			return errors.Errorf("invalid pattern-initialized data; unpacked size exceeds %d bytes", unpackedSize)
+		}
+		remaining -= extra
+		if size != 0 && n > remaining/size {
+			return errors.Errorf("invalid pattern-initialized data; unpacked size exceeds %d bytes", unpackedSize)
+		}
+		return nil
+	}
+	for r.pos < len(packed) {
+		b := packed[r.pos]
+		r.pos++
+		op := b >> 5
+		count := uint64(b & 0x1F)
+		if count == 0 {
+			c, err := r.arg()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			count = c
+		}
+		switch op {
+		case opZero:
+			if err := fits(1, count, 0); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			out.Write(make([]byte, count))
+		case opBlockCopy:
+			data, err := r.raw(count)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if err := fits(1, count, 0); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			out.Write(data)
+		case opRepeatedBlock:
+			repeatCount, err := r.arg()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			data, err := r.raw(count)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if err := fits(repeatCount+1, count, 0); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			for i := uint64(0); i < repeatCount+1; i++ {
+				out.Write(data)
+			}
+		case opInterleaveRepeatBlockWithBlockCopy, opInterleaveRepeatBlockWithZero:
+			// The common block is followed by repeatCount custom blocks, and the
+			// data is terminated by a trailing common block.
+			//
+			//    common custom_1 common custom_2 ... common custom_n common
+			customSize, err := r.arg()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			repeatCount, err := r.arg()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			// The unpacked size is repeatCount*(count+customSize) + count.
+			blockSize := count + customSize
+			if blockSize < count {
+				// saturate on overflow.
+				blockSize = math.MaxUint64
+			}
+			if err := fits(repeatCount, blockSize, count); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			common := make([]byte, count)
+			if op == opInterleaveRepeatBlockWithBlockCopy {
+				if common, err = r.raw(count); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+			for i := uint64(0); i < repeatCount; i++ {
+				custom, err := r.raw(customSize)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				out.Write(common)
+				out.Write(custom)
+			}
+			out.Write(common)
+		default:
+			return nil, errors.Errorf("support for pattern-initialization opcode %d at offset %d not yet implemented", op, r.pos-1)
+		}
+	}
+	if out.Len() != int(unpackedSize) {
+		return nil, errors.Errorf("invalid pattern-initialized data; expected %d unpacked bytes, got %d", unpackedSize, out.Len())
+	}
+	return out.Bytes(), nil
+}
+
+// patternReader reads the arguments and raw data of pattern-initialization
+// instructions.
+type patternReader struct {
+	// Pattern description.
+	packed []byte
+	// Current offset into the pattern description.
+	pos int
+}
+
+// arg reads a variable-length argument. Arguments are stored in big-endian
+// order using 7 bits per byte, where the high-order bit is set for every byte
+// except the last.
+func (r *patternReader) arg() (uint64, error) {
+	var v uint64
+	for i := 0; ; i++ {
+		if r.pos >= len(r.packed) {
+			return 0, errors.New("unexpected end of pattern-initialized data; truncated argument")
+		}
+		// An argument of 64 bits is encoded in at most 10 bytes.
+		if i >= 10 {
+			return 0, errors.Errorf("invalid argument at offset %d of pattern-initialized data; exceeds 64 bits", r.pos)
+		}
+		b := r.packed[r.pos]
+		r.pos++
+		v = v<<7 | uint64(b&0x7F)
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+}
+
+// raw reads n bytes of raw data.
+func (r *patternReader) raw(n uint64) ([]byte, error) {
+	if uint64(len(r.packed)-r.pos) < n {
+		return nil, errors.Errorf("unexpected end of pattern-initialized data; expected %d bytes of raw data at offset %d, got %d", n, r.pos, len(r.packed)-r.pos)
+	}
+	data := r.packed[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return data, nil
+}
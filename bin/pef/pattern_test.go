@@ -0,0 +1,106 @@
+package pef
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnpackData(t *testing.T) {
+	golden := []struct {
+		name string
+		// Pattern description.
+		packed []byte
+		// Unpacked size.
+		size uint32
+		// Expected in-memory image.
+		want []byte
+		// Expected error; or empty if valid.
+		err string
+	}{
+		{
+			name:   "zero",
+			packed: []byte{opZero<<5 | 3},
+			size:   3,
+			want:   []byte{0, 0, 0},
+		},
+		{
+			name:   "zero with count argument",
+			packed: []byte{opZero << 5, 0x81, 0x00},
+			size:   128,
+			want:   make([]byte, 128),
+		},
+		{
+			name:   "block copy",
+			packed: []byte{opBlockCopy<<5 | 3, 'a', 'b', 'c'},
+			size:   3,
+			want:   []byte("abc"),
+		},
+		{
+			name:   "repeated block",
+			packed: []byte{opRepeatedBlock<<5 | 2, 1, 'h', 'i'},
+			size:   4,
+			want:   []byte("hihi"),
+		},
+		{
+			// common=2, customSize=3, repeatCount=2
+			name:   "interleave repeat block with block copy",
+			packed: []byte{opInterleaveRepeatBlockWithBlockCopy<<5 | 2, 3, 2, 'a', 'b', '1', '2', '3', '4', '5', '6'},
+			size:   12,
+			want:   []byte("ab123ab456ab"),
+		},
+		{
+			// common=2, customSize=1, repeatCount=3
+			name:   "interleave repeat block with zero",
+			packed: []byte{opInterleaveRepeatBlockWithZero<<5 | 2, 1, 3, 'x', 'y', 'z'},
+			size:   11,
+			want:   []byte("\x00\x00x\x00\x00y\x00\x00z\x00\x00"),
+		},
+		{
+			name:   "interleave repeat block exceeds unpacked size",
+			packed: []byte{opInterleaveRepeatBlockWithBlockCopy<<5 | 2, 3, 2, 'a', 'b', '1', '2', '3', '4', '5', '6'},
+			size:   11,
+			err:    "unpacked size exceeds 11 bytes",
+		},
+		{
+			name:   "interleave repeat block with overflowing custom size",
+			packed: []byte{opInterleaveRepeatBlockWithZero<<5 | 2, 0x81, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F, 1},
+			size:   16,
+			err:    "unpacked size exceeds 16 bytes",
+		},
+		{
+			name:   "repeated block exceeds unpacked size",
+			packed: []byte{opRepeatedBlock<<5 | 2, 0xFF, 0x7F, 'h', 'i'},
+			size:   4,
+			err:    "unpacked size exceeds 4 bytes",
+		},
+		{
+			name:   "truncated raw data",
+			packed: []byte{opBlockCopy<<5 | 3, 'a', 'b'},
+			size:   3,
+			err:    "expected 3 bytes of raw data",
+		},
+		{
+			name:   "short unpacked data",
+			packed: []byte{opZero<<5 | 3},
+			size:   4,
+			err:    "expected 4 unpacked bytes, got 3",
+		},
+	}
+	for _, g := range golden {
+		got, err := unpackData(g.packed, g.size)
+		if len(g.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), g.err) {
+				t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to unpack data; %+v", g.name, err)
+			continue
+		}
+		if !bytes.Equal(got, g.want) {
+			t.Errorf("%s: unpacked data mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
@@ -4,17 +4,28 @@ package pef
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
 	"math"
 	"os"
 	"sort"
 	"time"
 
 	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
 	"github.com/pkg/errors"
 )
 
+var (
+	// dbg is a logger with the "pef:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("pef:")+" ", 0)
+	// warn is a logger with the "pef:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(os.Stderr, term.RedBold("pef:")+" ", 0)
+)
+
 // Register PEF format.
 func init() {
 	// Preferred Executable Format (PEF) format.
@@ -22,6 +33,7 @@ func init() {
 	//    4A 6F 79 21 70 65 66 66  |Joy!peff|
 	const magic = "Joy!peff"
 	bin.RegisterFormat("pef", magic, Parse)
+	bin.RegisterIdentify("pef", magic, Identify)
 }
 
 // ParseFile parses the given PEF binary executable, reading from path.
@@ -34,6 +46,45 @@ func ParseFile(path string) (*bin.File, error) {
 	return Parse(f)
 }
 
+// Identify identifies the machine architecture of the given PEF binary
+// executable, reading only the header of the first container from r.
+func Identify(r io.ReaderAt) (*bin.Info, error) {
+	// Tag1, Tag2 and Architecture fields of the container header.
+	var buf [12]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	archName := string(buf[8:])
+	arch, ok := parseArch(archName)
+	if !ok {
+		// Both PowerPC and Motorola 68K are 32-bit big-endian architectures.
+		info := &bin.Info{Format: "pef", BitSize: 32, ByteOrder: binary.BigEndian}
+		return info, errors.WithStack(unsupportedMachine(archName))
+	}
+	return bin.NewInfo("pef", arch), nil
+}
+
+// parseArch returns the machine architecture corresponding to the given
+// architecture field of the PEF container header. The boolean return value
+// indicates success.
+func parseArch(arch string) (bin.Arch, bool) {
+	switch arch {
+	case "pwpc":
+		return bin.ArchPowerPC_32, true
+	}
+	return 0, false
+}
+
+// unsupportedMachine returns an error for the given unsupported architecture
+// field of the PEF container header.
+func unsupportedMachine(arch string) error {
+	var machine uint32
+	for i := 0; i < len(arch) && i < 4; i++ {
+		machine = machine<<8 | uint32(arch[i])
+	}
+	return &bin.UnsupportedMachineError{Format: "pef", Machine: machine, MachineName: arch}
+}
+
 // Parse parses the given PEF binary executable, reading from r.
 //
 // Users are responsible for closing r.
@@ -45,24 +96,48 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 	}
 
 	// Parse machine architecture.
-	file := &bin.File{}
+	file := &bin.File{
+		Imports:     make(map[bin.Address]string),
+		Exports:     make(map[bin.Address]string),
+		Forwards:    make(map[string]string),
+		Relocs:      make(map[bin.Address]bin.RelocKind),
+		Relocatable: true,
+	}
 	for _, container := range f.Containers {
-		var arch bin.Arch
-		switch container.Architecture {
-		case "pwpc":
-			arch = bin.ArchPowerPC_32
-		default:
-			panic(fmt.Errorf("support for machine architecture %q not yet implemented", container.Architecture))
+		arch, ok := parseArch(container.Architecture)
+		if !ok {
+			return nil, errors.WithStack(unsupportedMachine(container.Architecture))
 		}
 		if file.Arch != 0 && arch != file.Arch {
-			panic(fmt.Errorf("support for multiple machine architectures not yet implemented; prev %q, new %q", file.Arch, arch))
+			return nil, errors.Errorf("support for multiple machine architectures not yet implemented; prev %q, new %q", file.Arch, arch)
 		}
 		file.Arch = arch
 	}
 
 	// Parse sections.
+	//
+	// Only instantiated sections are loaded into memory. The default addresses
+	// of sections are typically 0, in which case overlapping sections are
+	// assigned synthetic addresses, laid out consecutively.
+	var next bin.Address
 	for _, container := range f.Containers {
-		for _, s := range container.Sections {
+		synthetic := hasOverlap(container)
+		// Map from section index to section.
+		sects := make(map[int]*bin.Section)
+		// Map from section index to section address.
+		sectAddrs := make(map[int]bin.Address)
+		for i, s := range container.Sections {
+			if i >= int(container.InstSectionCount) {
+				// skip non-instantiated section (e.g. Loader section).
+				continue
+			}
+			addr := bin.Address(s.DefaultAddress)
+			if synthetic {
+				addr = align(next, s.Alignment)
+			}
+			if end := addr + bin.Address(s.TotalSize); next < end {
+				next = end
+			}
 			data, err := s.Data()
 			if err != nil {
 				return nil, errors.WithStack(err)
@@ -70,7 +145,7 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 			perm := parsePerm(s.SectionKind)
 			offset := container.Offset + uint64(s.ContainerOffset)
 			sect := &bin.Section{
-				Addr:     bin.Address(s.DefaultAddress),
+				Addr:     addr,
 				Offset:   offset,
 				Data:     data,
 				FileSize: int(s.PackedSize),
@@ -78,6 +153,16 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 				Perm:     perm,
 			}
 			file.Sections = append(file.Sections, sect)
+			sects[i] = sect
+			sectAddrs[i] = addr
+		}
+		// Parse imports, exports, entry points and relocations.
+		if container.Loader != nil {
+			end, err := parseLoader(file, container, sects, sectAddrs, next)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			next = end
 		}
 	}
 	// Sort sections.
@@ -92,10 +177,43 @@ func Parse(r io.ReaderAt) (*bin.File, error) {
 		return file.Sections[i].Addr < file.Sections[j].Addr
 	}
 	sort.Slice(file.Sections, less)
+	// Sort symbols.
+	symLess := func(i, j int) bool {
+		if file.Symbols[i].Addr == file.Symbols[j].Addr {
+			return file.Symbols[i].Name < file.Symbols[j].Name
+		}
+		return file.Symbols[i].Addr < file.Symbols[j].Addr
+	}
+	sort.Slice(file.Symbols, symLess)
 
 	return file, nil
 }
 
+// hasOverlap reports whether the instantiated sections of the given container
+// overlap in memory at their default addresses.
+func hasOverlap(container *Container) bool {
+	for i, s := range container.Sections {
+		for j, t := range container.Sections {
+			if i >= j || j >= int(container.InstSectionCount) {
+				continue
+			}
+			if s.DefaultAddress < t.DefaultAddress+t.TotalSize && t.DefaultAddress < s.DefaultAddress+s.TotalSize {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// align returns the address aligned to the given power of 2 alignment.
+func align(addr bin.Address, pow uint8) bin.Address {
+	n := bin.Address(1) << pow
+	if rem := addr % n; rem != 0 {
+		addr += n - rem
+	}
+	return addr
+}
+
 // NewFile creates a new File for accessing a PEF binary in an underlying
 // reader.
 //
@@ -141,6 +259,8 @@ type Container struct {
 	Offset uint64
 	// PEF sections.
 	Sections []*Section
+	// Loader section information; or nil if not present.
+	Loader *Loader
 }
 
 // parseContainer parses and returns a PEF container.
@@ -188,9 +308,11 @@ func parseContainer(r io.ReaderAt) (*Container, int64, error) {
 	// Parse Loader section.
 	for _, sect := range container.Sections {
 		if sect.SectionKind == kindLoader {
-			if err := parseLoaderSection(sect); err != nil {
+			loader, err := parseLoaderSection(sect)
+			if err != nil {
 				return nil, 0, errors.WithStack(err)
 			}
+			container.Loader = loader
 		}
 	}
 
@@ -295,8 +417,27 @@ type Section struct {
 	io.ReaderAt
 }
 
-// Data reads and returns the contents of the PEF section.
+// Data reads and returns the initialized contents of the PEF section, as
+// loaded into memory. The contents of pattern-initialized data sections are
+// expanded into their in-memory image.
 func (sect *Section) Data() ([]byte, error) {
+	buf, err := sect.RawData()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if sect.SectionKind == kindPatternInitializedData {
+		data, err := unpackData(buf, sect.UnpackedSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return data, nil
+	}
+	return buf, nil
+}
+
+// RawData reads and returns the raw contents of the PEF section, as stored in
+// the container.
+func (sect *Section) RawData() ([]byte, error) {
 	buf := make([]byte, sect.PackedSize)
 	if _, err := sect.ReadAt(buf, 0); err != nil {
 		return nil, errors.WithStack(err)
@@ -408,44 +549,3 @@ func parsePerm(kind uint8) bin.Perm {
 	}
 	return perm
 }
-
-// parseLoaderSection parses the given Loader section.
-func parseLoaderSection(sect *Section) error {
-	// Overview of the structure of a PEF Loader section.
-	//
-	//    Loader header
-	//    Imported library table
-	//    Imported symbol table
-	//    Relocation headers table
-	//    Relocations
-	//    Loader string table
-	//    Export hash table
-	//    Export key table
-	//    Exported symbol table
-	const loaderHeaderSize = 56
-	type LoaderHeader struct {
-		MainSection              int32
-		MainOffset               uint32
-		InitSection              int32
-		InitOffset               uint32
-		TermSection              int32
-		TermOffset               uint32
-		ImportedLibraryCount     uint32
-		TotalImportedSymbolCount uint32
-		RelocSectionCount        uint32
-		RelocInstrOffset         uint32
-		LoaderStringsOffset      uint32
-		ExportHashOffset         uint32
-		ExportHashTablePower     uint32
-		ExportedSymbolCount      uint32
-	}
-	buf := make([]byte, loaderHeaderSize)
-	if _, err := sect.ReadAt(buf, 0); err != nil {
-		return errors.WithStack(err)
-	}
-	hdr := &LoaderHeader{}
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, hdr); err != nil {
-		return errors.WithStack(err)
-	}
-	return nil
-}
@@ -0,0 +1,314 @@
+package pef
+
+import (
+	"encoding/binary"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// A relocator executes the relocation instructions of a section.
+//
+// ref: https://web.archive.org/web/20020111211702/http://developer.apple.com:80/techpubs/mac/runtimehtml/RTArch-93.html
+type relocator struct {
+	// Contents of the relocated section.
+	data []byte
+	// Relocation instructions of the section; sequence of 16-bit big-endian
+	// instruction words.
+	instrs []byte
+	// Map from section index to section address.
+	sectAddrs map[int]bin.Address
+	// Addresses of imported symbols, indexed by imported symbol index.
+	importAddrs []bin.Address
+	// Offset of the next relocated 32-bit word within the section.
+	relocAddress uint32
+	// Index of the next imported symbol.
+	importIndex uint32
+	// Address of the section used by code relocations (sectionC).
+	sectionC bin.Address
+	// Address of the section used by data relocations (sectionD).
+	sectionD bin.Address
+	// Offsets of relocated 32-bit words within the section.
+	relocs []uint32
+}
+
+// relocate executes the relocation instructions of the given section, adding
+// the addresses of sections and imported symbols to the relocated words of the
+// section contents. The offsets of relocated words are returned.
+func (loader *Loader) relocate(relocHdr *RelocHeader, data []byte, sectAddrs map[int]bin.Address, importAddrs []bin.Address) ([]uint32, error) {
+	const instrSize = 2
+	start := uint64(relocHdr.FirstRelocOffset)
+	end := start + uint64(relocHdr.RelocCount)*instrSize
+	if end > uint64(len(loader.relocInstrs)) {
+		return nil, errors.Errorf("invalid relocation instructions range [%d:%d] of section %d; exceeds %d bytes", start, end, relocHdr.SectionIndex, len(loader.relocInstrs))
+	}
+	r := &relocator{
+		data:        data,
+		instrs:      loader.relocInstrs[start:end],
+		sectAddrs:   sectAddrs,
+		importAddrs: importAddrs,
+		// sectionC and sectionD are initialized to the addresses of section 0
+		// and section 1 respectively.
+		sectionC: sectAddrs[0],
+		sectionD: sectAddrs[1],
+	}
+	// Offsets of executed relocation instructions; the instruction boundaries
+	// of blocks repeated by repeat instructions.
+	executed := make(map[int]bool)
+	for pc := 0; pc < len(r.instrs); {
+		op := r.word(pc)
+		switch {
+		case op>>12 == 0x9:
+			// RelocSmRepeat
+			//
+			//    1001 blockCount(4) repeatCount(8)
+			blockCount := int(op>>8&0x0F) + 1
+			repeatCount := int(op&0xFF) + 1
+			if err := r.repeat(executed, pc, blockCount, repeatCount); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			pc += instrSize
+		case op>>10 == 0x2C:
+			// RelocLgRepeat
+			//
+			//    101100 blockCount(4) repeatCount(22)
+			v := r.long(pc)
+			blockCount := int(v>>22&0x0F) + 1
+			repeatCount := int(v & 0x003FFFFF)
+			if err := r.repeat(executed, pc, blockCount, repeatCount); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			pc += 2 * instrSize
+		default:
+			n, err := r.exec(pc)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to execute relocation instruction at offset %d of section %d", pc, relocHdr.SectionIndex)
+			}
+			executed[pc] = true
+			pc += n
+		}
+	}
+	return r.relocs, nil
+}
+
+// repeat executes the relocation instructions of the block preceding the
+// repeat instruction at the given offset repeatCount times. The block size,
+// blockCount, is specified in 16-bit halfwords of the relocation instruction
+// stream; thus a block may hold fewer instructions than blockCount (e.g. 32-bit
+// instructions). The block must start at the boundary of an executed
+// instruction.
+func (r *relocator) repeat(executed map[int]bool, pc, blockCount, repeatCount int) error {
+	start := pc - 2*blockCount
+	if start < 0 || !executed[start] {
+		return errors.Errorf("invalid block count of repeat relocation instruction at offset %d; block start offset %d not at instruction boundary", pc, start)
+	}
+	for i := 0; i < repeatCount; i++ {
+		for pos := start; pos < pc; {
+			n, err := r.exec(pos)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			pos += n
+		}
+	}
+	return nil
+}
+
+// exec executes the relocation instruction at the given offset, and returns its
+// length in bytes.
+func (r *relocator) exec(pc int) (int, error) {
+	op := r.word(pc)
+	switch {
+	case op>>14 == 0x0:
+		// RelocBySectDWithSkip
+		//
+		//    00 skipCount(8) relocCount(6)
+		skipCount := uint32(op >> 6 & 0xFF)
+		relocCount := int(op & 0x3F)
+		r.relocAddress += 4 * skipCount
+		for i := 0; i < relocCount; i++ {
+			if err := r.add(r.sectionD); err != nil {
+				return 0, errors.WithStack(err)
+			}
+		}
+		return 2, nil
+	case op>>13 == 0x2:
+		// RelocRelocGroup
+		//
+		//    010 subopcode(4) runLength(9)
+		runLength := int(op&0x01FF) + 1
+		switch subop := op >> 9 & 0x0F; subop {
+		case 0x0:
+			// RelocBySectC
+			for i := 0; i < runLength; i++ {
+				if err := r.add(r.sectionC); err != nil {
+					return 0, errors.WithStack(err)
+				}
+			}
+		case 0x1:
+			// RelocBySectD
+			for i := 0; i < runLength; i++ {
+				if err := r.add(r.sectionD); err != nil {
+					return 0, errors.WithStack(err)
+				}
+			}
+		case 0x2:
+			// RelocTVector12; code address, TOC address and environment word.
+			for i := 0; i < runLength; i++ {
+				if err := r.add(r.sectionC); err != nil {
+					return 0, errors.WithStack(err)
+				}
+				if err := r.add(r.sectionD); err != nil {
+					return 0, errors.WithStack(err)
+				}
+				r.relocAddress += 4
+			}
+		case 0x3:
+			// RelocTVector8; code address and TOC address.
+			for i := 0; i < runLength; i++ {
+				if err := r.add(r.sectionC); err != nil {
+					return 0, errors.WithStack(err)
+				}
+				if err := r.add(r.sectionD); err != nil {
+					return 0, errors.WithStack(err)
+				}
+			}
+		case 0x4:
+			// RelocVTable8; data address and skipped word.
+			for i := 0; i < runLength; i++ {
+				if err := r.add(r.sectionD); err != nil {
+					return 0, errors.WithStack(err)
+				}
+				r.relocAddress += 4
+			}
+		case 0x5:
+			// RelocImportRun
+			for i := 0; i < runLength; i++ {
+				if err := r.addImport(r.importIndex); err != nil {
+					return 0, errors.WithStack(err)
+				}
+			}
+		default:
+			return 0, errors.Errorf("support for relocation group subopcode 0x%X not yet implemented", subop)
+		}
+		return 2, nil
+	case op>>13 == 0x3:
+		// RelocSmIndexGroup
+		//
+		//    011 subopcode(4) index(9)
+		index := uint32(op & 0x01FF)
+		if err := r.indexGroup(op>>9&0x0F, index); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return 2, nil
+	case op>>12 == 0x8:
+		// RelocIncrPosition
+		//
+		//    1000 offset(12)
+		r.relocAddress += uint32(op&0x0FFF) + 1
+		return 2, nil
+	case op>>10 == 0x28:
+		// RelocSetPosition
+		//
+		//    101000 offset(26)
+		r.relocAddress = r.long(pc) & 0x03FFFFFF
+		return 4, nil
+	case op>>10 == 0x29:
+		// RelocLgByImport
+		//
+		//    101001 index(26)
+		index := r.long(pc) & 0x03FFFFFF
+		if err := r.addImport(index); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return 4, nil
+	case op>>10 == 0x2D:
+		// RelocLgSetOrBySection
+		//
+		//    101101 subopcode(4) index(22)
+		v := r.long(pc)
+		index := v & 0x003FFFFF
+		var subop uint16
+		switch v >> 22 & 0x0F {
+		case 0x0:
+			// by section.
+			subop = 0x3
+		case 0x1:
+			// set sectionC.
+			subop = 0x1
+		case 0x2:
+			// set sectionD.
+			subop = 0x2
+		default:
+			return 0, errors.Errorf("support for large set or by section subopcode 0x%X not yet implemented", v>>22&0x0F)
+		}
+		if err := r.indexGroup(subop, index); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return 4, nil
+	}
+	return 0, errors.Errorf("support for relocation opcode 0x%04X not yet implemented", op)
+}
+
+// indexGroup executes the given subopcode of the small index group.
+func (r *relocator) indexGroup(subop uint16, index uint32) error {
+	switch subop {
+	case 0x0:
+		// RelocSmByImport
+		return r.addImport(index)
+	case 0x1:
+		// RelocSmSetSectC
+		r.sectionC = r.sectAddrs[int(index)]
+	case 0x2:
+		// RelocSmSetSectD
+		r.sectionD = r.sectAddrs[int(index)]
+	case 0x3:
+		// RelocSmBySection
+		return r.add(r.sectAddrs[int(index)])
+	default:
+		return errors.Errorf("support for index group subopcode 0x%X not yet implemented", subop)
+	}
+	return nil
+}
+
+// add adds the given address to the 32-bit word at the current relocation
+// address, and advances the relocation address.
+func (r *relocator) add(addr bin.Address) error {
+	offset := r.relocAddress
+	if uint64(offset)+4 > uint64(len(r.data)) {
+		return errors.Errorf("invalid relocation offset 0x%X; exceeds section size %d", offset, len(r.data))
+	}
+	v := binary.BigEndian.Uint32(r.data[offset:])
+	binary.BigEndian.PutUint32(r.data[offset:], v+uint32(addr))
+	r.relocs = append(r.relocs, offset)
+	r.relocAddress += 4
+	return nil
+}
+
+// addImport adds the address of the given imported symbol to the 32-bit word
+// at the current relocation address, and advances the relocation address and
+// the imported symbol index.
+func (r *relocator) addImport(index uint32) error {
+	if int(index) >= len(r.importAddrs) {
+		return errors.Errorf("invalid imported symbol index; expected < %d, got %d", len(r.importAddrs), index)
+	}
+	if err := r.add(r.importAddrs[index]); err != nil {
+		return errors.WithStack(err)
+	}
+	r.importIndex = index + 1
+	return nil
+}
+
+// word returns the 16-bit relocation instruction word at the given offset.
+func (r *relocator) word(pc int) uint16 {
+	return binary.BigEndian.Uint16(r.instrs[pc:])
+}
+
+// long returns the 32-bit relocation instruction at the given offset; or the
+// first word zero-extended if truncated.
+func (r *relocator) long(pc int) uint32 {
+	if pc+4 > len(r.instrs) {
+		return uint32(r.word(pc)) << 16
+	}
+	return binary.BigEndian.Uint32(r.instrs[pc:])
+}
@@ -0,0 +1,92 @@
+package pef
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestRelocateRepeat(t *testing.T) {
+	const (
+		sectC      = 0x1000
+		sectD      = 0x2000
+		importAddr = 0x3000
+	)
+	golden := []struct {
+		name string
+		// Relocation instructions; sequence of big-endian 16-bit words.
+		instrs []uint16
+		// Expected offsets of relocated words.
+		want []uint32
+		// Expected relocated words.
+		words []uint32
+		// Expected error; or empty if valid.
+		err string
+	}{
+		{
+			// RelocLgByImport 0
+			// RelocSmRepeat   blockCount=2, repeatCount=3
+			name:   "small repeat of 32-bit instruction",
+			instrs: []uint16{0xA400, 0x0000, 0x9102},
+			want:   []uint32{0, 4, 8, 12},
+			words:  []uint32{importAddr, importAddr, importAddr, importAddr},
+		},
+		{
+			// RelocBySectD    runLength=1
+			// RelocLgByImport 0
+			// RelocLgRepeat   blockCount=3, repeatCount=1
+			name:   "large repeat of 16- and 32-bit instructions",
+			instrs: []uint16{0x4200, 0xA400, 0x0000, 0xB080, 0x0001},
+			want:   []uint32{0, 4, 8, 12},
+			words:  []uint32{sectD, importAddr, sectD, importAddr},
+		},
+		{
+			// RelocLgByImport 0
+			// RelocSmRepeat   blockCount=1, repeatCount=1
+			name:   "block start within 32-bit instruction",
+			instrs: []uint16{0xA400, 0x0000, 0x9000},
+			err:    "not at instruction boundary",
+		},
+		{
+			// RelocBySectD    runLength=1
+			// RelocSmRepeat   blockCount=2, repeatCount=1
+			name:   "block start before first instruction",
+			instrs: []uint16{0x4200, 0x9100},
+			err:    "not at instruction boundary",
+		},
+	}
+	for _, g := range golden {
+		instrs := make([]byte, 2*len(g.instrs))
+		for i, v := range g.instrs {
+			binary.BigEndian.PutUint16(instrs[2*i:], v)
+		}
+		loader := &Loader{relocInstrs: instrs}
+		relocHdr := &RelocHeader{RelocCount: uint32(len(g.instrs))}
+		data := make([]byte, 16)
+		sectAddrs := map[int]bin.Address{0: sectC, 1: sectD}
+		relocs, err := loader.relocate(relocHdr, data, sectAddrs, []bin.Address{importAddr})
+		if len(g.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), g.err) {
+				t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to relocate section; %+v", g.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(relocs, g.want) {
+			t.Errorf("%s: relocation offsets mismatch; expected %v, got %v", g.name, g.want, relocs)
+		}
+		var words []uint32
+		for i := 0; i < len(data); i += 4 {
+			words = append(words, binary.BigEndian.Uint32(data[i:]))
+		}
+		if !reflect.DeepEqual(words, g.words) {
+			t.Errorf("%s: relocated words mismatch; expected %#x, got %#x", g.name, g.words, words)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package raw
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/bin/ihex"
+	"github.com/decomp/exp/bin/srec"
+)
+
+// Format returns the name of the file format of the given raw binary
+// executable, Intel HEX file, S-record file or memory map of raw binary files
+// (*.json), as identified by the file extension of path.
+//
+// The name is one of "ihex", "srec", "map" or "raw".
+func Format(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihex":
+		return "ihex"
+	case ".srec", ".s19", ".s28", ".s37", ".mot":
+		return "srec"
+	case ".json":
+		return "map"
+	default:
+		return "raw"
+	}
+}
+
+// ParseAny parses the given raw binary executable, Intel HEX file, S-record
+// file or memory map of raw binary files (*.json), as identified by the file
+// extension of path.
+func ParseAny(path string, arch bin.Arch) (*bin.File, error) {
+	switch Format(path) {
+	case "ihex":
+		return ihex.ParseFile(path, arch)
+	case "srec":
+		return srec.ParseFile(path, arch)
+	case "map":
+		return ParseMapFile(path, arch)
+	default:
+		return ParseFile(path, arch)
+	}
+}
@@ -0,0 +1,157 @@
+package raw
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+)
+
+// A Map is a memory map, describing how the regions of one or more raw binary
+// files are mapped into memory.
+//
+// Example memory map in JSON format.
+//
+//	{
+//		"entry": "0x8000",
+//		"regions": [
+//			{"name": "rom", "file": "rom.bin", "addr": "0x8000", "perm": "r-x"},
+//			{"name": "data", "file": "rom.bin", "offset": "0x7000", "length": "0x1000", "addr": "0x2000", "size": "0x2000", "perm": "rw-"}
+//		]
+//	}
+type Map struct {
+	// Entry point.
+	Entry bin.Address `json:"entry"`
+	// Memory regions.
+	Regions []*Region `json:"regions"`
+}
+
+// A Region is a memory region of a memory map.
+type Region struct {
+	// Region name; or empty if unnamed.
+	Name string `json:"name,omitempty"`
+	// Path to the raw binary file containing the contents of the region;
+	// relative to the directory of the memory map. An empty path specifies a
+	// zero-initialized region.
+	File string `json:"file,omitempty"`
+	// File offset of the region contents.
+	Offset bin.Uint64 `json:"offset,omitempty"`
+	// Length in bytes of the region contents; or 0 to read until the end of the
+	// file.
+	Length bin.Uint64 `json:"length,omitempty"`
+	// Load address of the region.
+	Addr bin.Address `json:"addr"`
+	// Size in bytes of the region in memory; or 0 if the same as the length of
+	// the region contents. Memory following the region contents is
+	// zero-initialized.
+	Size bin.Uint64 `json:"size,omitempty"`
+	// Access permissions of the region.
+	Perm bin.Perm `json:"perm"`
+}
+
+// ParseMapFile parses the given memory map in JSON format, reading from path,
+// and loads the raw binary files of its regions.
+func ParseMapFile(path string, arch bin.Arch) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	m := &Map{}
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse memory map %q", path)
+	}
+	return ParseMap(m, filepath.Dir(path), arch)
+}
+
+// ParseMap loads the raw binary files of the regions of the given memory map.
+// The paths of raw binary files are relative to dir.
+func ParseMap(m *Map, dir string, arch bin.Arch) (*bin.File, error) {
+	file := &bin.File{
+		Arch:  arch,
+		Entry: m.Entry,
+	}
+	for i, region := range m.Regions {
+		sect, err := loadRegion(region, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to load region %d (%q)", i, region.Name)
+		}
+		file.Sections = append(file.Sections, sect)
+	}
+	// Sort sections.
+	less := func(i, j int) bool {
+		if file.Sections[i].Addr == file.Sections[j].Addr {
+			if len(file.Sections[i].Data) > len(file.Sections[j].Data) {
+				// prioritize longer sections with identical addresses.
+				return true
+			}
+			return file.Sections[i].Name < file.Sections[j].Name
+		}
+		return file.Sections[i].Addr < file.Sections[j].Addr
+	}
+	sort.Slice(file.Sections, less)
+	for i := 1; i < len(file.Sections); i++ {
+		prev, sect := file.Sections[i-1], file.Sections[i]
+		if end := prev.Addr + bin.Address(prev.MemSize); sect.Addr < end {
+			return nil, errors.Errorf("overlapping regions %q and %q at address %v", prev.Name, sect.Name, sect.Addr)
+		}
+	}
+	if len(file.Sections) > 0 {
+		file.Base = file.Sections[0].Addr
+	}
+	file.BuildIndex()
+	return file, nil
+}
+
+// loadRegion loads the contents of the given memory region, reading the raw
+// binary file relative to dir.
+func loadRegion(region *Region, dir string) (*bin.Section, error) {
+	var data []byte
+	if len(region.File) > 0 {
+		path := region.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer f.Close()
+		if _, err := f.Seek(int64(region.Offset), io.SeekStart); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var r io.Reader = f
+		if region.Length != 0 {
+			r = io.LimitReader(f, int64(region.Length))
+		}
+		data, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if region.Length != 0 && uint64(len(data)) < uint64(region.Length) {
+			return nil, errors.Errorf("truncated region contents in %q; expected %d bytes at offset %v, got %d", region.File, uint64(region.Length), region.Offset, len(data))
+		}
+	}
+	memSize := len(data)
+	if region.Size != 0 {
+		if uint64(region.Size) < uint64(len(data)) {
+			return nil, errors.Errorf("invalid region size; expected >= %d, got %d", len(data), uint64(region.Size))
+		}
+		memSize = int(region.Size)
+	}
+	sect := &bin.Section{
+		Name:     region.Name,
+		Addr:     region.Addr,
+		Offset:   uint64(region.Offset),
+		Data:     data,
+		FileSize: len(data),
+		MemSize:  memSize,
+		Perm:     region.Perm,
+	}
+	return sect, nil
+}
@@ -13,8 +13,8 @@ import (
 // ParseFile parses the given raw binary executable, reading from path.
 //
 // The entry point and base address are both 0 by default. To specify a custom
-// entry point, set file.Entry, and to specify a custom base address, set
-// file.Sections[0].Addr.
+// entry point, set file.Entry, and to specify a custom base address, use
+// file.Rebase.
 func ParseFile(path string, arch bin.Arch) (*bin.File, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -27,12 +27,15 @@ func ParseFile(path string, arch bin.Arch) (*bin.File, error) {
 // Parse parses the given raw binary executable, reading from r.
 //
 // The entry point and base address are both 0 by default. To specify a custom
-// entry point, set file.Entry, and to specify a custom base address, set
-// file.Sections[0].Addr.
+// entry point, set file.Entry, and to specify a custom base address, use
+// file.Rebase.
 func Parse(r io.Reader, arch bin.Arch) (*bin.File, error) {
 	// Parse segments.
+	// Raw binary executables have no preferred base address, and may thus be
+	// loaded at any address.
 	file := &bin.File{
-		Arch: arch,
+		Arch:        arch,
+		Relocatable: true,
 	}
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -46,5 +49,6 @@ func Parse(r io.Reader, arch bin.Arch) (*bin.File, error) {
 		Perm:     bin.PermR | bin.PermW | bin.PermX,
 	}
 	file.Sections = append(file.Sections, seg)
+	file.BuildIndex()
 	return file, nil
 }
@@ -0,0 +1,47 @@
+package bin
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// MergeRecords finalizes the sections of a file parsed from a record-based
+// file format (e.g. Intel HEX or S-record), where each section holds the data
+// of one or more data records.
+//
+// The sections are sorted by address and contiguous sections of identical
+// access permissions are merged, regardless of the order of the data records.
+// The base address is set to the start address of the first section, and the
+// section index is built.
+func MergeRecords(file *File) error {
+	less := func(i, j int) bool {
+		return file.Sections[i].Addr < file.Sections[j].Addr
+	}
+	sort.SliceStable(file.Sections, less)
+	var sects []*Section
+	for _, sect := range file.Sections {
+		if len(sects) > 0 {
+			prev := sects[len(sects)-1]
+			end := prev.Addr + Address(len(prev.Data))
+			if sect.Addr < end {
+				return errors.Errorf("overlapping data records at address %v", sect.Addr)
+			}
+			if sect.Addr == end && sect.Perm == prev.Perm {
+				prev.Data = append(prev.Data, sect.Data...)
+				prev.FileSize = len(prev.Data)
+				prev.MemSize = len(prev.Data)
+				continue
+			}
+		}
+		sect.FileSize = len(sect.Data)
+		sect.MemSize = len(sect.Data)
+		sects = append(sects, sect)
+	}
+	file.Sections = sects
+	if len(file.Sections) > 0 {
+		file.Base = file.Sections[0].Addr
+	}
+	file.BuildIndex()
+	return nil
+}
@@ -0,0 +1,39 @@
+package bin
+
+import "github.com/pkg/errors"
+
+//go:generate stringer -linecomment -type RelocKind
+
+// RelocKind specifies the kind of a relocation; i.e. how the value stored at
+// the relocated address is adjusted when the binary executable is loaded at a
+// different base address than the preferred one.
+type RelocKind uint8
+
+// Relocation kinds.
+const (
+	// RelocAbs32 specifies a 32-bit absolute address.
+	RelocAbs32 RelocKind = 1 + iota // abs32
+	// RelocAbs64 specifies a 64-bit absolute address.
+	RelocAbs64 // abs64
+	// RelocHigh16 specifies the high 16 bits of a 32-bit absolute address.
+	RelocHigh16 // high16
+	// RelocLow16 specifies the low 16 bits of a 32-bit absolute address.
+	RelocLow16 // low16
+	// RelocSeg16 specifies a 16-bit real mode segment address (i.e. paragraph
+	// number) of a 16-bit x86 segment:offset address.
+	RelocSeg16 // seg16
+)
+
+// Size returns the size in bytes of the value stored at a relocated address of
+// the given relocation kind.
+func (kind RelocKind) Size() (int, error) {
+	switch kind {
+	case RelocAbs32:
+		return 4, nil
+	case RelocAbs64:
+		return 8, nil
+	case RelocHigh16, RelocLow16, RelocSeg16:
+		return 2, nil
+	}
+	return 0, errors.Errorf("support for relocation kind %v not yet implemented", kind)
+}
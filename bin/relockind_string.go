@@ -0,0 +1,28 @@
+// Code generated by "stringer -linecomment -type RelocKind"; DO NOT EDIT.
+
+package bin
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[RelocAbs32-1]
+	_ = x[RelocAbs64-2]
+	_ = x[RelocHigh16-3]
+	_ = x[RelocLow16-4]
+	_ = x[RelocSeg16-5]
+}
+
+const _RelocKind_name = "abs32abs64high16low16seg16"
+
+var _RelocKind_index = [...]uint8{0, 5, 10, 16, 21, 26}
+
+func (i RelocKind) String() string {
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_RelocKind_index)-1 {
+		return "RelocKind(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _RelocKind_name[_RelocKind_index[idx]:_RelocKind_index[idx+1]]
+}
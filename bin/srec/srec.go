@@ -0,0 +1,139 @@
+// Package srec provides access to Motorola S-record files.
+//
+// S-record files contain no information about the machine architecture nor the
+// access permissions of memory, so the architecture is specified by the user
+// and all memory is mapped as readable, writable and executable.
+package srec
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger with the "srec:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("srec:")+" ", 0)
+)
+
+// ParseFile parses the given S-record file, reading from path.
+//
+// The entry point is specified by the termination record if present; or 0
+// otherwise.
+func ParseFile(path string, arch bin.Arch) (*bin.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	return Parse(f, arch)
+}
+
+// Parse parses the given S-record file, reading from r.
+//
+// The entry point is specified by the termination record if present; or 0
+// otherwise.
+//
+// Each record has the following layout, where all fields except the record
+// type are encoded as pairs of hexadecimal digits.
+//
+//	type     "S0" through "S9"
+//	count    uint8 (number of bytes of address, data and checksum)
+//	address  uint16, uint24 or uint32 (big-endian, as specified by type)
+//	data     [count-addrSize-1]byte
+//	checksum uint8 (one's complement of the sum of the preceding fields)
+func Parse(r io.Reader, arch bin.Arch) (*bin.File, error) {
+	file := &bin.File{
+		Arch: arch,
+	}
+	// Current section; extended while data records are contiguous.
+	var sect *bin.Section
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) < 2 || line[0] != 'S' {
+			return nil, errors.Errorf("invalid record on line %d; expected 'S' prefix, got %q", lineNum, line)
+		}
+		typ := line[1]
+		rec, err := hex.DecodeString(line[2:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode record on line %d", lineNum)
+		}
+		if len(rec) < 1 || len(rec) != 1+int(rec[0]) {
+			return nil, errors.Errorf("invalid length of record on line %d", lineNum)
+		}
+		var sum uint8
+		for _, b := range rec {
+			sum += b
+		}
+		if sum != 0xFF {
+			return nil, errors.Errorf("invalid checksum of record on line %d", lineNum)
+		}
+		// Size in bytes of the address field.
+		var addrSize int
+		switch typ {
+		case '0', '1', '5', '9':
+			addrSize = 2
+		case '2', '6', '8':
+			addrSize = 3
+		case '3', '7':
+			addrSize = 4
+		default:
+			return nil, errors.Errorf("support for record type S%c on line %d not yet implemented", typ, lineNum)
+		}
+		if int(rec[0]) < addrSize+1 {
+			return nil, errors.Errorf("invalid length of S%c record on line %d; expected >= %d, got %d", typ, lineNum, addrSize+1, rec[0])
+		}
+		var addr bin.Address
+		for _, b := range rec[1 : 1+addrSize] {
+			addr = addr<<8 | bin.Address(b)
+		}
+		data := rec[1+addrSize : len(rec)-1]
+		switch typ {
+		case '0':
+			// Header record.
+			dbg.Printf("header: %q", data)
+		case '1', '2', '3':
+			// Data record.
+			if sect != nil && sect.Addr+bin.Address(len(sect.Data)) == addr {
+				sect.Data = append(sect.Data, data...)
+				continue
+			}
+			sect = &bin.Section{
+				Addr: addr,
+				Data: append([]byte(nil), data...),
+				Perm: bin.PermR | bin.PermW | bin.PermX,
+			}
+			file.Sections = append(file.Sections, sect)
+		case '5', '6':
+			// Record count; ignored.
+		case '7', '8', '9':
+			// Termination record, specifying the entry point.
+			file.Entry = addr
+			if err := bin.MergeRecords(file); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return file, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The termination record is optional in practice.
+	if err := bin.MergeRecords(file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return file, nil
+}
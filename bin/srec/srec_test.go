@@ -0,0 +1,136 @@
+package srec
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestParse(t *testing.T) {
+	golden := []struct {
+		name string
+		// Records of the S-record file.
+		recs []string
+		// Expected entry point.
+		entry bin.Address
+		// Expected sections.
+		sects []*bin.Section
+		// Expected error; or empty if valid.
+		err string
+	}{
+		{
+			name: "contiguous S1 data records",
+			recs: []string{
+				record('0', 2, 0, "48445220"),
+				record('1', 2, 0x0100, "0123"),
+				record('1', 2, 0x0102, "4567"),
+				record('9', 2, 0x0100, ""),
+			},
+			entry: 0x0100,
+			sects: []*bin.Section{
+				newSection(0x0100, "01234567"),
+			},
+		},
+		{
+			name: "out-of-order S2 data records",
+			recs: []string{
+				record('2', 3, 0x010102, "4567"),
+				record('2', 3, 0x010200, "AA"),
+				record('2', 3, 0x010100, "0123"),
+				record('8', 3, 0x010100, ""),
+			},
+			entry: 0x010100,
+			sects: []*bin.Section{
+				newSection(0x010100, "01234567"),
+				newSection(0x010200, "AA"),
+			},
+		},
+		{
+			name: "S3 data records without termination record",
+			recs: []string{
+				record('3', 4, 0x08000010, "CAFE"),
+				record('5', 2, 1, ""),
+			},
+			sects: []*bin.Section{
+				newSection(0x08000010, "CAFE"),
+			},
+		},
+		{
+			name: "invalid checksum",
+			recs: []string{
+				record('1', 2, 0x0100, "0123")[:12] + "00",
+			},
+			err: "invalid checksum of record on line 1",
+		},
+		{
+			name: "overlapping data records",
+			recs: []string{
+				record('1', 2, 0x0102, "4567"),
+				record('1', 2, 0x0100, "012345"),
+			},
+			err: "overlapping data records at address 0x102",
+		},
+	}
+	for _, g := range golden {
+		r := strings.NewReader(strings.Join(g.recs, "\n"))
+		file, err := Parse(r, bin.ArchX86_32)
+		if len(g.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), g.err) {
+				t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to parse S-record file; %+v", g.name, err)
+			continue
+		}
+		if file.Entry != g.entry {
+			t.Errorf("%s: entry point mismatch; expected %v, got %v", g.name, g.entry, file.Entry)
+		}
+		if want := g.sects[0].Addr; file.Base != want {
+			t.Errorf("%s: base address mismatch; expected %v, got %v", g.name, want, file.Base)
+		}
+		if !reflect.DeepEqual(file.Sections, g.sects) {
+			t.Errorf("%s: sections mismatch; expected %v, got %v", g.name, g.sects, file.Sections)
+		}
+	}
+}
+
+// record returns the S-record of the given type, address size, address and
+// hexadecimal data, with a valid checksum.
+func record(typ byte, addrSize int, addr uint32, data string) string {
+	buf, err := hex.DecodeString(data)
+	if err != nil {
+		panic(err)
+	}
+	rec := []byte{uint8(addrSize + len(buf) + 1)}
+	for i := addrSize - 1; i >= 0; i-- {
+		rec = append(rec, uint8(addr>>(8*uint(i))))
+	}
+	rec = append(rec, buf...)
+	var sum uint8
+	for _, b := range rec {
+		sum += b
+	}
+	rec = append(rec, ^sum)
+	return "S" + string(typ) + strings.ToUpper(hex.EncodeToString(rec))
+}
+
+// newSection returns a readable, writable and executable section of the given
+// address and hexadecimal data.
+func newSection(addr bin.Address, data string) *bin.Section {
+	buf, err := hex.DecodeString(data)
+	if err != nil {
+		panic(err)
+	}
+	return &bin.Section{
+		Addr:     addr,
+		Data:     buf,
+		FileSize: len(buf),
+		MemSize:  len(buf),
+		Perm:     bin.PermR | bin.PermW | bin.PermX,
+	}
+}
@@ -0,0 +1,73 @@
+package bin
+
+// A Symbol is a named address of a binary executable, as recorded by the symbol
+// table.
+type Symbol struct {
+	// Symbol name.
+	Name string
+	// Address of the symbol; or offset into the thread-local storage template
+	// for thread-local symbols.
+	Addr Address
+	// Size in bytes; or 0 if the symbol has no size or an unknown size.
+	Size uint64
+	// Symbol kind (e.g. function or data object).
+	Kind SymbolKind
+	// Symbol binding (e.g. local or global).
+	Binding SymbolBinding
+	// Symbol visibility (e.g. default or hidden).
+	Visibility SymbolVisibility
+}
+
+//go:generate stringer -linecomment -type SymbolKind
+
+// SymbolKind specifies the kind of a symbol.
+type SymbolKind uint8
+
+// Symbol kinds.
+const (
+	// The symbol kind is not specified.
+	SymbolNone SymbolKind = iota // none
+	// The symbol is associated with a function or other executable code.
+	SymbolFunc // function
+	// The symbol is associated with a data object, such as a variable or an
+	// array.
+	SymbolObject // object
+	// The symbol is associated with a thread-local data object. The address of
+	// the symbol is an offset into the thread-local storage template, rather
+	// than a virtual address.
+	SymbolTLS // thread-local object
+)
+
+//go:generate stringer -linecomment -type SymbolBinding
+
+// SymbolBinding specifies the binding of a symbol; i.e. its linkage visibility.
+type SymbolBinding uint8
+
+// Symbol bindings.
+const (
+	// Local symbol; not visible outside of the binary executable.
+	BindingLocal SymbolBinding = iota // local
+	// Global symbol.
+	BindingGlobal // global
+	// Weak symbol; global symbol with lower precedence.
+	BindingWeak // weak
+)
+
+//go:generate stringer -linecomment -type SymbolVisibility
+
+// SymbolVisibility specifies the visibility of a global symbol from other
+// components.
+type SymbolVisibility uint8
+
+// Symbol visibilities.
+const (
+	// Default symbol visibility as specified by the symbol binding.
+	VisibilityDefault SymbolVisibility = iota // default
+	// Internal symbol visibility.
+	VisibilityInternal // internal
+	// Hidden symbol visibility; not visible from other components.
+	VisibilityHidden // hidden
+	// Protected symbol visibility; visible from other components but not
+	// preemptable.
+	VisibilityProtected // protected
+)
@@ -0,0 +1,26 @@
+// Code generated by "stringer -linecomment -type SymbolBinding"; DO NOT EDIT.
+
+package bin
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[BindingLocal-0]
+	_ = x[BindingGlobal-1]
+	_ = x[BindingWeak-2]
+}
+
+const _SymbolBinding_name = "localglobalweak"
+
+var _SymbolBinding_index = [...]uint8{0, 5, 11, 15}
+
+func (i SymbolBinding) String() string {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_SymbolBinding_index)-1 {
+		return "SymbolBinding(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _SymbolBinding_name[_SymbolBinding_index[idx]:_SymbolBinding_index[idx+1]]
+}
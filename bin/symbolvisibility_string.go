@@ -0,0 +1,27 @@
+// Code generated by "stringer -linecomment -type SymbolVisibility"; DO NOT EDIT.
+
+package bin
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[VisibilityDefault-0]
+	_ = x[VisibilityInternal-1]
+	_ = x[VisibilityHidden-2]
+	_ = x[VisibilityProtected-3]
+}
+
+const _SymbolVisibility_name = "defaultinternalhiddenprotected"
+
+var _SymbolVisibility_index = [...]uint8{0, 7, 15, 21, 30}
+
+func (i SymbolVisibility) String() string {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_SymbolVisibility_index)-1 {
+		return "SymbolVisibility(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _SymbolVisibility_name[_SymbolVisibility_index[idx]:_SymbolVisibility_index[idx+1]]
+}
@@ -0,0 +1,16 @@
+package bin
+
+// A RuntimeFunc is an entry of the function table used for exception handling
+// and stack unwinding (e.g. the RUNTIME_FUNCTION table of the .pdata section of
+// x86-64 PE files), specifying the extent of a function or function chunk.
+type RuntimeFunc struct {
+	// Start address of the function (inclusive).
+	Start Address
+	// End address of the function (exclusive).
+	End Address
+	// Address of the unwind information of the function.
+	UnwindInfo Address
+	// Start address of the primary function of a function chunk, as specified
+	// by chained unwind information; or 0 if not a function chunk.
+	Parent Address
+}
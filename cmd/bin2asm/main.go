@@ -7,13 +7,17 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/decomp/exp/bin"
-	_ "github.com/decomp/exp/bin/elf" // register ELF decoder
-	_ "github.com/decomp/exp/bin/pe"  // register PE decoder
-	_ "github.com/decomp/exp/bin/pef" // register PEF decoder
+	_ "github.com/decomp/exp/bin/coff"  // register COFF decoder
+	_ "github.com/decomp/exp/bin/elf"   // register ELF decoder
+	_ "github.com/decomp/exp/bin/macho" // register Mach-O decoder
+	_ "github.com/decomp/exp/bin/pe"    // register PE decoder
+	_ "github.com/decomp/exp/bin/pef"   // register PEF decoder
 	"github.com/decomp/exp/bin/raw"
 	"github.com/decomp/exp/disasm/x86"
+	"github.com/mewkiz/pkg/jsonutil"
 	"github.com/mewkiz/pkg/term"
 	"github.com/mewrev/pe"
 	"github.com/pkg/errors"
@@ -56,6 +60,16 @@ func main() {
 		// TODO: Remove -last flag and lastAddr.
 		// lastAddr specifies the last function address to disassemble.
 		lastAddr bin.Address
+		// base specifies the base address at which to load the binary
+		// executable; or 0 to use the preferred base address.
+		base bin.Address
+		// discoverDir specifies the output directory of functions and basic
+		// blocks discovered by recursive descent disassembly; or empty to
+		// disable discovery.
+		discoverDir string
+		// sweep specifies the minimum confidence of functions and data proposed
+		// by linear sweep disassembly; or 0 to disable linear sweep.
+		sweep float64
 		// quiet specifies whether to suppress non-error messages.
 		quiet bool
 		// rawArch specifies the machine architecture of a raw binary executable.
@@ -70,8 +84,11 @@ func main() {
 	flag.Var(&firstAddr, "first", "first function address to disassemble")
 	flag.Var(&funcAddr, "func", "function address to disassemble")
 	flag.Var(&lastAddr, "last", "last function address to disassemble")
+	flag.Var(&base, "base", "base address at which to load the binary executable")
+	flag.StringVar(&discoverDir, "discover", "", "output directory of functions and basic blocks discovered by recursive descent disassembly (stores funcs.json, blocks.json, tables.json, chunks.json and data.json)")
+	flag.Float64Var(&sweep, "sweep", 0, "minimum confidence (0.0-1.0] of functions and data proposed by linear sweep disassembly of gaps between discovered code; requires -discover (stores sweep.json)")
 	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
-	flag.Var(&rawArch, "raw", "machine architecture of raw binary executable (x86_32, x86_64, MIPS_32, PowerPC_32, ...)")
+	flag.Var(&rawArch, "raw", "machine architecture of raw binary executable, Intel HEX file, S-record file or memory map (*.json) (x86_32, x86_64, MIPS_32, PowerPC_32, ...)")
 	flag.Var(&rawEntry, "rawentry", "entry point of raw binary executable")
 	flag.Var(&rawBase, "rawbase", "base address of raw binary executable")
 	flag.Parse()
@@ -79,6 +96,9 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if sweep > 0 && len(discoverDir) == 0 {
+		log.Fatal("invalid -sweep flag; linear sweep requires an output directory specified by -discover")
+	}
 	binPath := flag.Arg(0)
 	// Mute debug and warning messages if `-q` is set.
 	if quiet {
@@ -87,10 +107,40 @@ func main() {
 	}
 
 	// Prepare disassembler for the binary executable.
-	dis, err := newDisasm(binPath, rawArch, rawEntry, rawBase)
+	dis, err := newDisasm(binPath, base, rawArch, rawEntry, rawBase)
 	if err != nil {
+		if e, ok := errors.Cause(err).(*bin.UnsupportedMachineError); ok {
+			// Report unsupported machine architectures without stack trace.
+			log.Fatalf("unable to parse %q; %v", binPath, e)
+		}
 		log.Fatalf("%+v", err)
 	}
+
+	// Discover functions and basic blocks.
+	if len(discoverDir) > 0 {
+		disc, err := dis.Discover()
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if sweep > 0 {
+			// Store functions and data proposed by linear sweep, along with the
+			// discovered functions and basic blocks.
+			props, err := dis.Sweep(disc, sweep)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if err := dis.StoreJSON(discoverDir); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			sweepPath := filepath.Join(discoverDir, "sweep.json")
+			dbg.Printf("creating %q", sweepPath)
+			if err := jsonutil.WriteFile(sweepPath, props); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		} else if err := dis.StoreJSON(discoverDir); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
 	// Disassemble basic block.
 	if blockAddr != 0 {
 		block, err := dis.DecodeBlock(blockAddr)
@@ -109,6 +159,8 @@ func main() {
 
 	// Disassemble functions.
 	var fs []*x86.Func
+	// failed records the addresses of functions which failed to disassemble.
+	var failed []bin.Address
 	for _, funcAddr := range funcAddrs {
 		if firstAddr != 0 && funcAddr < firstAddr {
 			// skip functions before first address.
@@ -120,10 +172,15 @@ func main() {
 		}
 		f, err := dis.DecodeFunc(funcAddr)
 		if err != nil {
-			log.Fatalf("%+v", err)
+			warn.Printf("unable to disassemble function at %v; %v", funcAddr, err)
+			failed = append(failed, funcAddr)
+			continue
 		}
 		fs = append(fs, f)
 	}
+	if len(failed) > 0 {
+		warn.Printf("unable to disassemble %d of %d functions: %v", len(failed), len(fs)+len(failed), failed)
+	}
 
 	// Create output directory.
 	if err := os.MkdirAll(outDir, 0755); err != nil {
@@ -174,15 +231,21 @@ func main() {
 const outDir = "_dump_"
 
 // newDisasm returns a new disassembler for the given binary executable.
-func newDisasm(binPath string, rawArch bin.Arch, rawEntry, rawBase bin.Address) (*x86.Disasm, error) {
+func newDisasm(binPath string, base bin.Address, rawArch bin.Arch, rawEntry, rawBase bin.Address) (*x86.Disasm, error) {
 	// Parse raw binary executable.
 	if rawArch != 0 {
-		file, err := raw.ParseFile(binPath, rawArch)
+		file, err := raw.ParseAny(binPath, rawArch)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
-		file.Entry = rawEntry
-		file.Sections[0].Addr = rawBase
+		if rawBase != 0 {
+			if err := file.Rebase(rawBase); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		if rawEntry != 0 {
+			file.Entry = rawEntry
+		}
 		return x86.NewDisasm(file)
 	}
 	// Parse binary executable.
@@ -190,5 +253,11 @@ func newDisasm(binPath string, rawArch bin.Arch, rawEntry, rawBase bin.Address)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	// Load binary executable at custom base address.
+	if base != 0 {
+		if err := file.Rebase(base); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
 	return x86.NewDisasm(file)
 }
@@ -32,7 +32,10 @@ func dumpCFG(dis *x86.Disasm, f *x86.Func) (graph.Directed, error) {
 		g.AddNode(n)
 	}
 	for _, block := range f.Blocks {
-		targets := dis.Targets(block.Term, f.Addr)
+		targets, err := dis.Targets(block.Term, f.Addr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 		fmt.Println("block.Addr:", block.Addr)
 		fmt.Println("block.Term:", block.Term)
 		fmt.Println("targets:", targets)
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/decomp/exp/bin"
+)
+
+// File is a machine-readable description of a binary executable.
+type File struct {
+	// Name of the binary executable format (e.g. "pe" or "elf").
+	Format string `json:"format"`
+	// Machine architecture.
+	Arch bin.Arch `json:"arch"`
+	// Base address of the executable.
+	Base bin.Address `json:"base"`
+	// Entry point of the executable.
+	Entry bin.Address `json:"entry"`
+	// Sections of the executable, sorted by address in ascending order.
+	Sections []*Section `json:"sections"`
+	// Function imports, sorted by address in ascending order.
+	Imports []*Func `json:"imports"`
+	// Function exports, sorted by address in ascending order.
+	Exports []*Func `json:"exports"`
+	// Forwarded function exports; map from export name to forwarder.
+	Forwards map[string]string `json:"forwards,omitempty"`
+	// Symbols of the symbol table, sorted by address in ascending order.
+	Symbols []*Symbol `json:"symbols,omitempty"`
+	// Thread local storage (TLS) callbacks.
+	TLSCallbacks []bin.Address `json:"tls_callbacks,omitempty"`
+	// Detected strings, sorted by address in ascending order.
+	Strings []*String `json:"strings"`
+	// Detected pointers into mapped sections, sorted by address in ascending
+	// order.
+	Pointers []*Pointer `json:"pointers"`
+}
+
+// Section is a section (or segment) of a binary executable.
+type Section struct {
+	// Section name; or empty if unnamed section or memory segment.
+	Name string `json:"name,omitempty"`
+	// Start address of the section.
+	Addr bin.Address `json:"addr"`
+	// End address of the section in memory, including uninitialized data.
+	End bin.Address `json:"end"`
+	// File offset of the section.
+	Offset bin.Uint64 `json:"offset"`
+	// Size in bytes of the section contents in the executable file.
+	FileSize int `json:"file_size"`
+	// Size in bytes of the section contents when loaded into memory.
+	MemSize int `json:"mem_size"`
+	// Access permissions of the section in memory.
+	Perm bin.Perm `json:"perm"`
+}
+
+// Func is an imported or exported function.
+type Func struct {
+	// Address of the function (e.g. import address table slot of imports).
+	Addr bin.Address `json:"addr"`
+	// Function name.
+	Name string `json:"name"`
+}
+
+// Symbol is a named address of the symbol table.
+type Symbol struct {
+	// Symbol name.
+	Name string `json:"name"`
+	// Address of the symbol.
+	Addr bin.Address `json:"addr"`
+	// Size in bytes; or 0 if the symbol has no size or an unknown size.
+	Size uint64 `json:"size,omitempty"`
+	// Symbol kind (e.g. "function" or "object").
+	Kind string `json:"kind"`
+	// Symbol binding (e.g. "local" or "global").
+	Binding string `json:"binding"`
+}
+
+// Pointer is a pointer-sized value that points into a mapped section.
+type Pointer struct {
+	// Address of the pointer.
+	Addr bin.Address `json:"addr"`
+	// Target address of the pointer.
+	Target bin.Address `json:"target"`
+	// Name of the section containing the target address.
+	Section string `json:"section,omitempty"`
+	// Specifies whether the pointer is relocated; i.e. known to be an address.
+	Reloc bool `json:"reloc,omitempty"`
+}
+
+// dump returns a machine-readable description of the given binary executable
+// of the specified format. Detected strings contain at least minLen
+// characters.
+func dump(file *bin.File, format string, minLen int) *File {
+	info := &File{
+		Format:       format,
+		Arch:         file.Arch,
+		Base:         file.Base,
+		Entry:        file.Entry,
+		Imports:      sortedFuncs(file.Imports),
+		Exports:      sortedFuncs(file.Exports),
+		Forwards:     file.Forwards,
+		TLSCallbacks: file.TLSCallbacks,
+	}
+	for _, sect := range file.Sections {
+		s := &Section{
+			Name:     sect.Name,
+			Addr:     sect.Addr,
+			End:      sect.End(),
+			Offset:   bin.Uint64(sect.Offset),
+			FileSize: sect.FileSize,
+			MemSize:  sect.MemSize,
+			Perm:     sect.Perm,
+		}
+		info.Sections = append(info.Sections, s)
+	}
+	for _, sym := range file.Symbols {
+		s := &Symbol{
+			Name:    sym.Name,
+			Addr:    sym.Addr,
+			Size:    sym.Size,
+			Kind:    sym.Kind.String(),
+			Binding: sym.Binding.String(),
+		}
+		info.Symbols = append(info.Symbols, s)
+	}
+	info.Strings = findStrings(file, minLen)
+	info.Pointers = findPointers(file)
+	return info
+}
+
+// findPointers returns the pointer-sized values of the given binary executable
+// that point into mapped sections. Values are read at pointer-aligned addresses
+// of each section, and at the relocated addresses of the executable.
+func findPointers(file *bin.File) []*Pointer {
+	bits := file.Arch.BitSize()
+	if bits < 32 {
+		// Values of 16-bit architectures are too likely to point into mapped
+		// sections by coincidence, and far pointers are not supported.
+		warn.Printf("pointer detection of %d-bit architecture %v not yet supported", bits, file.Arch)
+		return []*Pointer{}
+	}
+	ptrSize := bin.Address(bits / 8)
+	order := file.ByteOrder()
+	// read returns the value of the given size at the specified address of the
+	// section.
+	read := func(sect *bin.Section, addr bin.Address, size bin.Address) (uint64, bool) {
+		offset := addr - sect.Addr
+		if offset+size > bin.Address(len(sect.Data)) {
+			return 0, false
+		}
+		buf := sect.Data[offset:]
+		switch size {
+		case 4:
+			return uint64(order.Uint32(buf)), true
+		case 8:
+			return order.Uint64(buf), true
+		}
+		return 0, false
+	}
+	ptrs := []*Pointer{}
+	seen := make(map[bin.Address]bool)
+	add := func(addr bin.Address, v uint64) {
+		if v == 0 || seen[addr] {
+			return
+		}
+		target := bin.Address(v)
+		tsect, ok := file.FindSection(target)
+		if !ok || !mapped(tsect) {
+			return
+		}
+		seen[addr] = true
+		_, reloc := file.Relocs[addr]
+		ptr := &Pointer{
+			Addr:    addr,
+			Target:  target,
+			Section: tsect.Name,
+			Reloc:   reloc,
+		}
+		ptrs = append(ptrs, ptr)
+	}
+	// Relocated addresses.
+	for addr, kind := range file.Relocs {
+		n, err := kind.Size()
+		if err != nil {
+			warn.Printf("skipping relocation at %v; %v", addr, err)
+			continue
+		}
+		size := bin.Address(n)
+		if size != 4 && size != 8 {
+			// Skip partial relocations (e.g. high and low 16 bits of address).
+			continue
+		}
+		sect, ok := file.FindSection(addr)
+		if !ok || !mapped(sect) {
+			continue
+		}
+		if v, ok := read(sect, addr, size); ok {
+			add(addr, v)
+		}
+	}
+	// Pointer-aligned addresses.
+	for _, sect := range file.Sections {
+		if !mapped(sect) {
+			continue
+		}
+		start := (sect.Addr + ptrSize - 1) &^ (ptrSize - 1)
+		end := sect.Addr + bin.Address(len(sect.Data))
+		for addr := start; addr+ptrSize <= end; addr += ptrSize {
+			if v, ok := read(sect, addr, ptrSize); ok {
+				add(addr, v)
+			}
+		}
+	}
+	sort.Slice(ptrs, func(i, j int) bool {
+		return ptrs[i].Addr < ptrs[j].Addr
+	})
+	return ptrs
+}
+
+// mapped reports whether the given section is mapped into memory; i.e. whether
+// it has access permissions. Sections not mapped into memory (e.g. ELF debug
+// sections) are located at address 0.
+func mapped(sect *bin.Section) bool {
+	return sect.Perm != 0
+}
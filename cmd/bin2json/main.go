@@ -0,0 +1,181 @@
+// The bin2json tool dumps information about binary executables
+// (*.exe -> *.json).
+//
+// The information includes the machine architecture, entry point, sections,
+// imports, exports and symbols of the binary executable, as well as detected
+// strings and pointers into mapped sections.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	_ "github.com/decomp/exp/bin/coff"  // register COFF decoder
+	_ "github.com/decomp/exp/bin/elf"   // register ELF decoder
+	_ "github.com/decomp/exp/bin/macho" // register Mach-O decoder
+	_ "github.com/decomp/exp/bin/pe"    // register PE decoder
+	_ "github.com/decomp/exp/bin/pef"   // register PEF decoder
+	"github.com/decomp/exp/bin/raw"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+// Loggers.
+var (
+	// dbg represents a logger with the "bin2json:" prefix, which logs debug
+	// messages to standard error.
+	dbg = log.New(os.Stderr, term.YellowBold("bin2json:")+" ", 0)
+	// warn represents a logger with the "bin2json:" prefix, which logs warning
+	// messages to standard error.
+	warn = log.New(os.Stderr, term.RedBold("bin2json:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Dump information about binary executables (*.exe -> *.json).
+
+Usage:
+
+	bin2json [OPTION]... FILE
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// base specifies the base address at which to load the binary
+		// executable; or 0 to use the preferred base address.
+		base bin.Address
+		// minLen specifies the minimum length in characters of detected strings.
+		minLen int
+		// output specifies the output path; or empty to write to standard output.
+		output string
+		// quiet specifies whether to suppress non-error messages.
+		quiet bool
+		// rawArch specifies the machine architecture of a raw binary executable.
+		rawArch bin.Arch
+		// rawEntry specifies the entry point of a raw binary executable.
+		rawEntry bin.Address
+		// rawBase specifies the base address of a raw binary executable.
+		rawBase bin.Address
+	)
+	flag.Usage = usage
+	flag.Var(&base, "base", "base address at which to load the binary executable")
+	flag.IntVar(&minLen, "n", 4, "minimum length in characters of detected strings")
+	flag.StringVar(&output, "o", "", "output path")
+	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	flag.Var(&rawArch, "raw", "machine architecture of raw binary executable, Intel HEX file, S-record file or memory map (*.json) (x86_32, x86_64, MIPS_32, PowerPC_32, ...)")
+	flag.Var(&rawEntry, "rawentry", "entry point of raw binary executable")
+	flag.Var(&rawBase, "rawbase", "base address of raw binary executable")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	binPath := flag.Arg(0)
+	// Mute debug and warning messages if `-q` is set.
+	if quiet {
+		dbg.SetOutput(ioutil.Discard)
+		warn.SetOutput(ioutil.Discard)
+	}
+
+	// Parse binary executable.
+	file, format, err := parseFile(binPath, base, rawArch, rawEntry, rawBase)
+	if err != nil {
+		if e, ok := errors.Cause(err).(*bin.UnsupportedMachineError); ok {
+			// Report unsupported machine architectures without stack trace.
+			log.Fatalf("unable to parse %q; %v", binPath, e)
+		}
+		log.Fatalf("%+v", err)
+	}
+
+	// Dump information about binary executable.
+	info := dump(file, format, minLen)
+	if err := storeJSON(output, info); err != nil {
+		log.Fatalf("%+v", err)
+	}
+}
+
+// parseFile parses the given binary executable, and returns the parsed file and
+// the name of its format.
+func parseFile(binPath string, base bin.Address, rawArch bin.Arch, rawEntry, rawBase bin.Address) (*bin.File, string, error) {
+	// Parse raw binary executable.
+	if rawArch != 0 {
+		file, err := raw.ParseAny(binPath, rawArch)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		if rawBase != 0 {
+			if err := file.Rebase(rawBase); err != nil {
+				return nil, "", errors.WithStack(err)
+			}
+		}
+		if rawEntry != 0 {
+			file.Entry = rawEntry
+		}
+		return file, raw.Format(binPath), nil
+	}
+	// Identify binary executable format.
+	binInfo, err := bin.IdentifyFile(binPath)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	// Parse binary executable.
+	file, err := bin.ParseFile(binPath)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	// Load binary executable at custom base address.
+	if base != 0 {
+		if err := file.Rebase(base); err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+	}
+	return file, binInfo.Format, nil
+}
+
+// storeJSON stores a JSON encoded representation of v to the given output
+// path, or to standard output if path is empty.
+func storeJSON(path string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	if len(path) == 0 {
+		if _, err := os.Stdout.Write(buf); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	dbg.Printf("creating %q", path)
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// sortedFuncs returns the functions of the given address map, sorted by
+// address in ascending order.
+func sortedFuncs(m map[bin.Address]string) []*Func {
+	funcs := make([]*Func, 0, len(m))
+	for addr, name := range m {
+		funcs = append(funcs, &Func{Addr: addr, Name: name})
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].Addr < funcs[j].Addr
+	})
+	return funcs
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestDump(t *testing.T) {
+	golden := []struct {
+		// Path to input binary executable.
+		in string
+		// Machine architecture of raw binary executable; or 0 if not raw.
+		rawArch bin.Arch
+		// Path to golden JSON output.
+		out string
+	}{
+		// Statically linked ELF executable with ASCII and UTF-16 strings, and a
+		// table of pointers to strings.
+		{in: "testdata/hello.out", out: "testdata/hello.json"},
+		// Intel HEX file with a pointer to a string.
+		{in: "testdata/hello.hex", rawArch: bin.ArchX86_32, out: "testdata/hello_hex.json"},
+	}
+	for _, g := range golden {
+		file, format, err := parseFile(g.in, 0, g.rawArch, 0, 0)
+		if err != nil {
+			t.Errorf("%q: unable to parse binary executable; %+v", g.in, err)
+			continue
+		}
+		info := dump(file, format, 4)
+		buf, err := json.MarshalIndent(info, "", "\t")
+		if err != nil {
+			t.Errorf("%q: unable to marshal JSON; %v", g.in, err)
+			continue
+		}
+		buf = append(buf, '\n')
+		want, err := ioutil.ReadFile(g.out)
+		if err != nil {
+			t.Errorf("%q: unable to read golden file; %v", g.in, err)
+			continue
+		}
+		if got := string(buf); got != string(want) {
+			t.Errorf("%q: JSON output mismatch; expected:\n%s\ngot:\n%s", g.in, want, got)
+		}
+	}
+}
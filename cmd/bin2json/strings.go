@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/decomp/exp/bin"
+)
+
+// String is a detected string of a binary executable.
+type String struct {
+	// Address of the string.
+	Addr bin.Address `json:"addr"`
+	// Character encoding of the string ("ascii" or "utf16").
+	Encoding string `json:"encoding"`
+	// String contents.
+	Value string `json:"value"`
+}
+
+// findStrings returns the ASCII and UTF-16 strings of at least minLen
+// characters located in the initialized data of the mapped sections of the
+// given binary executable.
+//
+// Only the printable ASCII subset of UTF-16 is detected, using code units of
+// the byte order of the binary executable aligned to 2 bytes.
+func findStrings(file *bin.File, minLen int) []*String {
+	if minLen < 1 {
+		minLen = 1
+	}
+	strs := []*String{}
+	seen := make(map[bin.Address]bool)
+	add := func(addr bin.Address, enc string, chars []byte) {
+		if len(chars) < minLen || seen[addr] {
+			return
+		}
+		seen[addr] = true
+		s := &String{
+			Addr:     addr,
+			Encoding: enc,
+			Value:    string(chars),
+		}
+		strs = append(strs, s)
+	}
+	order := file.ByteOrder()
+	for _, sect := range file.Sections {
+		if !mapped(sect) {
+			continue
+		}
+		data := sect.Data
+		// ASCII strings.
+		start := 0
+		for i := 0; i <= len(data); i++ {
+			if i < len(data) && isPrint(data[i]) {
+				continue
+			}
+			add(sect.Addr+bin.Address(start), "ascii", data[start:i])
+			start = i + 1
+		}
+		// UTF-16 strings.
+		base := int(sect.Addr & 1)
+		var chars []byte
+		start = base
+		for i := base; i+1 < len(data); i += 2 {
+			if c := order.Uint16(data[i:]); c < 0x80 && isPrint(byte(c)) {
+				chars = append(chars, byte(c))
+				continue
+			}
+			add(sect.Addr+bin.Address(start), "utf16", chars)
+			chars = chars[:0]
+			start = i + 2
+		}
+		add(sect.Addr+bin.Address(start), "utf16", chars)
+	}
+	sort.Slice(strs, func(i, j int) bool {
+		return strs[i].Addr < strs[j].Addr
+	})
+	return strs
+}
+
+// isPrint reports whether the given byte is a printable ASCII character or
+// whitespace.
+func isPrint(b byte) bool {
+	switch b {
+	case '\t', '\n', '\r':
+		return true
+	}
+	return 0x20 <= b && b <= 0x7E
+}
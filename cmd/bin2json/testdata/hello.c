@@ -0,0 +1,9 @@
+// Strings and pointers detected by bin2json.
+
+const char *greetings[] = {"hello", "world"};
+
+const unsigned short wide[] = {'w', 'i', 'd', 'e', 0};
+
+int entry(void) {
+	return greetings[0][0] + wide[0];
+}
@@ -8,17 +8,21 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 
 	"github.com/decomp/exp/bin"
-	_ "github.com/decomp/exp/bin/elf" // register ELF decoder
-	_ "github.com/decomp/exp/bin/pe"  // register PE decoder
-	_ "github.com/decomp/exp/bin/pef" // register PEF decoder
+	_ "github.com/decomp/exp/bin/coff"  // register COFF decoder
+	_ "github.com/decomp/exp/bin/elf"   // register ELF decoder
+	_ "github.com/decomp/exp/bin/macho" // register Mach-O decoder
+	_ "github.com/decomp/exp/bin/pe"    // register PE decoder
+	_ "github.com/decomp/exp/bin/pef"   // register PEF decoder
 	"github.com/decomp/exp/bin/raw"
 	"github.com/decomp/exp/lift/x86"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
+	"github.com/mewkiz/pkg/jsonutil"
 	"github.com/mewkiz/pkg/term"
 	"github.com/pkg/errors"
 )
@@ -64,6 +68,16 @@ func main() {
 		output string
 		// cfgonly specifies whether to output minimal LLVM IR needed for CFG generation.
 		cfgonly bool
+		// base specifies the base address at which to load the binary
+		// executable; or 0 to use the preferred base address.
+		base bin.Address
+		// discoverDir specifies the output directory of functions and basic
+		// blocks discovered by recursive descent disassembly; or empty to
+		// disable discovery.
+		discoverDir string
+		// sweep specifies the minimum confidence of functions and data proposed
+		// by linear sweep disassembly; or 0 to disable linear sweep.
+		sweep float64
 		// quiet specifies whether to suppress non-error messages.
 		quiet bool
 		// rawArch specifies the machine architecture of a raw binary executable.
@@ -79,9 +93,12 @@ func main() {
 	flag.Var(&funcAddr, "func", "function address to lift")
 	flag.Var(&lastAddr, "last", "last function address to lift")
 	flag.StringVar(&output, "o", "", "output path")
+	flag.Var(&base, "base", "base address at which to load the binary executable")
+	flag.StringVar(&discoverDir, "discover", "", "output directory of functions and basic blocks discovered by recursive descent disassembly (stores funcs.json, blocks.json, tables.json, chunks.json and data.json)")
+	flag.Float64Var(&sweep, "sweep", 0, "minimum confidence (0.0-1.0] of functions and data proposed by linear sweep disassembly of gaps between discovered code; requires -discover (stores sweep.json)")
 	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
 	flag.BoolVar(&cfgonly, "cfg-only", false, "output minimal LLVM IR needed for CFG generation")
-	flag.Var(&rawArch, "raw", "machine architecture of raw binary executable (x86_32, x86_64, PowerPC_32, ...)")
+	flag.Var(&rawArch, "raw", "machine architecture of raw binary executable, Intel HEX file, S-record file or memory map (*.json) (x86_32, x86_64, PowerPC_32, ...)")
 	flag.Var(&rawEntry, "rawentry", "entry point of raw binary executable")
 	flag.Var(&rawBase, "rawbase", "base address of raw binary executable")
 	flag.Parse()
@@ -89,6 +106,9 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if sweep > 0 && len(discoverDir) == 0 {
+		log.Fatal("invalid -sweep flag; linear sweep requires an output directory specified by -discover")
+	}
 	binPath := flag.Arg(0)
 	// Mute debug and warning messages if `-q` is set.
 	if quiet {
@@ -97,11 +117,41 @@ func main() {
 	}
 
 	// Prepare x86 to LLVM IR lifter for the binary executable.
-	l, err := newLifter(binPath, rawArch, rawEntry, rawBase)
+	l, err := newLifter(binPath, base, rawArch, rawEntry, rawBase)
 	if err != nil {
+		if e, ok := errors.Cause(err).(*bin.UnsupportedMachineError); ok {
+			// Report unsupported machine architectures without stack trace.
+			log.Fatalf("unable to parse %q; %v", binPath, e)
+		}
 		log.Fatalf("%+v", err)
 	}
 
+	// Discover functions and basic blocks.
+	if len(discoverDir) > 0 {
+		disc, err := l.Discover()
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if sweep > 0 {
+			// Store functions and data proposed by linear sweep, along with the
+			// discovered functions and basic blocks.
+			props, err := l.Sweep(disc, sweep)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if err := l.StoreJSON(discoverDir); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			sweepPath := filepath.Join(discoverDir, "sweep.json")
+			dbg.Printf("creating %q", sweepPath)
+			if err := jsonutil.WriteFile(sweepPath, props); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		} else if err := l.StoreJSON(discoverDir); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+
 	// Lift basic block.
 	if blockAddr != 0 {
 		block, err := l.DecodeBlock(blockAddr)
@@ -131,16 +181,25 @@ func main() {
 	}
 
 	// Create function lifters.
+	// failed records the addresses of functions which failed to disassemble.
+	var failed []bin.Address
 	for _, funcAddr := range funcAddrs {
 		asmFunc, err := l.DecodeFunc(funcAddr)
 		if err != nil {
-			log.Fatalf("%+v", err)
+			warn.Printf("unable to disassemble function at %v; %v", funcAddr, err)
+			failed = append(failed, funcAddr)
+			continue
 		}
 		f := l.NewFunc(asmFunc)
 		l.Funcs[funcAddr] = f
 	}
+	if len(failed) > 0 {
+		warn.Printf("unable to disassemble %d of %d functions: %v", len(failed), len(funcAddrs), failed)
+	}
 
 	// Lift functions.
+	// liftFailed records the addresses of functions which failed to lift.
+	var liftFailed []bin.Address
 	for i, funcAddr := range funcAddrs {
 		if i != 0 {
 			dbg.Println()
@@ -149,9 +208,19 @@ func main() {
 		if !ok {
 			continue
 		}
-		f.Lift()
+		if err := f.Lift(); err != nil {
+			warn.Printf("unable to lift function at %v; %v", funcAddr, err)
+			liftFailed = append(liftFailed, funcAddr)
+			// Output function declaration in place of the partially lifted
+			// function definition.
+			f.Blocks = nil
+			continue
+		}
 		dbg.Println(f)
 	}
+	if len(liftFailed) > 0 {
+		warn.Printf("unable to lift %d of %d functions: %v", len(liftFailed), len(funcAddrs), liftFailed)
+	}
 
 	// Store LLVM IR output.
 	w := os.Stdout
@@ -203,15 +272,21 @@ func main() {
 
 // newLifter returns a new x86 to LLVM IR lifter for the given binary
 // executable.
-func newLifter(binPath string, rawArch bin.Arch, rawEntry, rawBase bin.Address) (*x86.Lifter, error) {
+func newLifter(binPath string, base bin.Address, rawArch bin.Arch, rawEntry, rawBase bin.Address) (*x86.Lifter, error) {
 	// Parse raw binary executable.
 	if rawArch != 0 {
-		file, err := raw.ParseFile(binPath, rawArch)
+		file, err := raw.ParseAny(binPath, rawArch)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
-		file.Entry = rawEntry
-		file.Sections[0].Addr = rawBase
+		if rawBase != 0 {
+			if err := file.Rebase(rawBase); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		if rawEntry != 0 {
+			file.Entry = rawEntry
+		}
 		return x86.NewLifter(file)
 	}
 	// Parse binary executable.
@@ -219,6 +294,12 @@ func newLifter(binPath string, rawArch bin.Arch, rawEntry, rawBase bin.Address)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	// Load binary executable at custom base address.
+	if base != 0 {
+		if err := file.Rebase(base); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
 	return x86.NewLifter(file)
 }
 
@@ -0,0 +1,230 @@
+// The dump_rsrc tool extracts the resources of a PE binary.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/decomp/exp/bin/pe"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+// dbg represents a logger with the "dump_rsrc:" prefix, which logs debug
+// messages to standard error.
+var dbg = log.New(os.Stderr, term.YellowBold("dump_rsrc:")+" ", 0)
+
+func usage() {
+	const use = `
+Extract the resources of PE binaries (*.exe -> rsrc/*).
+
+Usage:
+
+	dump_rsrc [OPTION]... FILE...
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// outDir specifies the output directory.
+		outDir string
+		// quiet specifies whether to suppress non-error messages.
+		quiet bool
+	)
+	flag.Usage = usage
+	flag.StringVar(&outDir, "o", "rsrc", "output directory")
+	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	// Mute debug messages if `-q` is set.
+	if quiet {
+		dbg.SetOutput(ioutil.Discard)
+	}
+	for _, path := range flag.Args() {
+		dir := outDir
+		if flag.NArg() > 1 {
+			dir = filepath.Join(outDir, filepath.Base(path))
+		}
+		if err := dumpResources(path, dir); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+}
+
+// dumpResources extracts the resources of the given PE binary to the output
+// directory.
+//
+// Resources are stored in subdirectories named after the resource type, and
+// converted into their native file formats where possible.
+//
+//	group_icon/*.ico    icon files
+//	bitmap/*.bmp        bitmap files
+//	string/*.txt        string tables
+//	dialog/*.txt        dialog box templates
+//	version/*.txt       version information
+//	manifest/*.manifest application manifests
+//	*/*.bin             raw resource data
+func dumpResources(path, outDir string) error {
+	rsrc, err := pe.ParseResourcesFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if rsrc == nil {
+		dbg.Printf("no resources in %q", path)
+		return nil
+	}
+	rs := rsrc.Resources()
+	for _, r := range rs {
+		data, ext, err := convert(r, rs)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		typeName := r.TypeName
+		if len(typeName) == 0 {
+			typeName = r.Type.String()
+		}
+		name := r.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("%d", r.ID)
+		}
+		filename := fmt.Sprintf("%s_%d%s", name, r.Lang, ext)
+		dir := filepath.Join(outDir, filepath.Base(typeName))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.WithStack(err)
+		}
+		outPath := filepath.Join(dir, filepath.Base(filename))
+		dbg.Printf("creating %q", outPath)
+		if err := ioutil.WriteFile(outPath, data, 0644); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// convert converts the given resource into its native file format, returning
+// the file contents and extension.
+func convert(r *pe.Resource, rs []*pe.Resource) ([]byte, string, error) {
+	switch r.Type {
+	case pe.ResourceGroupIcon:
+		data, err := pe.IconFile(r, rs)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		return data, ".ico", nil
+	case pe.ResourceBitmap:
+		data, err := pe.BitmapFile(r)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		return data, ".bmp", nil
+	case pe.ResourceString:
+		strs, err := pe.StringTable(r)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		var ids []int
+		for id := range strs {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		buf := &bytes.Buffer{}
+		for _, id := range ids {
+			fmt.Fprintf(buf, "%d\t%q\n", id, strs[uint32(id)])
+		}
+		return buf.Bytes(), ".txt", nil
+	case pe.ResourceDialog:
+		dlg, err := pe.ParseDialog(r.Data)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		return dumpDialog(dlg), ".txt", nil
+	case pe.ResourceVersion:
+		info, err := pe.ParseVersionInfo(r.Data)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		return dumpVersionInfo(info), ".txt", nil
+	case pe.ResourceManifest:
+		return r.Data, ".manifest", nil
+	}
+	return r.Data, ".bin", nil
+}
+
+// dumpDialog returns a textual representation of the given dialog box
+// template, in the syntax of resource scripts.
+func dumpDialog(dlg *pe.Dialog) []byte {
+	buf := &bytes.Buffer{}
+	keyword := "DIALOG"
+	if dlg.Extended {
+		keyword = "DIALOGEX"
+	}
+	fmt.Fprintf(buf, "%s %d, %d, %d, %d\n", keyword, dlg.X, dlg.Y, dlg.Width, dlg.Height)
+	fmt.Fprintf(buf, "STYLE 0x%08X\n", dlg.Style)
+	if dlg.ExStyle != 0 {
+		fmt.Fprintf(buf, "EXSTYLE 0x%08X\n", dlg.ExStyle)
+	}
+	if len(dlg.Title) > 0 {
+		fmt.Fprintf(buf, "CAPTION %q\n", dlg.Title)
+	}
+	if len(dlg.Menu) > 0 {
+		fmt.Fprintf(buf, "MENU %s\n", dlg.Menu)
+	}
+	if len(dlg.Class) > 0 {
+		fmt.Fprintf(buf, "CLASS %q\n", dlg.Class)
+	}
+	if len(dlg.FontName) > 0 {
+		fmt.Fprintf(buf, "FONT %d, %q\n", dlg.FontSize, dlg.FontName)
+	}
+	buf.WriteString("{\n")
+	for _, item := range dlg.Items {
+		fmt.Fprintf(buf, "\tCONTROL %q, %d, %q, 0x%08X, %d, %d, %d, %d\n", item.Title, int32(item.ID), item.Class, item.Style, item.X, item.Y, item.Width, item.Height)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// dumpVersionInfo returns a textual representation of the given version
+// information.
+func dumpVersionInfo(info *pe.VersionInfo) []byte {
+	buf := &bytes.Buffer{}
+	v := info.FileVersion
+	fmt.Fprintf(buf, "FILEVERSION %d,%d,%d,%d\n", v[0], v[1], v[2], v[3])
+	v = info.ProductVersion
+	fmt.Fprintf(buf, "PRODUCTVERSION %d,%d,%d,%d\n", v[0], v[1], v[2], v[3])
+	fmt.Fprintf(buf, "FILEFLAGS 0x%X\n", info.FileFlags)
+	fmt.Fprintf(buf, "FILEOS 0x%X\n", info.FileOS)
+	fmt.Fprintf(buf, "FILETYPE 0x%X\n", info.FileType)
+	fmt.Fprintf(buf, "FILESUBTYPE 0x%X\n", info.FileSubtype)
+	var langs []string
+	for lang := range info.Strings {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		fmt.Fprintf(buf, "\nBLOCK %q\n", lang)
+		strs := info.Strings[lang]
+		var keys []string
+		for key := range strs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(buf, "\tVALUE %q, %q\n", key, strs[key])
+		}
+	}
+	return buf.Bytes()
+}
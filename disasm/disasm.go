@@ -2,8 +2,10 @@
 package disasm
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 
 	"github.com/decomp/exp/bin"
@@ -87,6 +89,32 @@ func New(file *bin.File) (*Disasm, error) {
 		dis.BlockAddrs = bin.InsertAddr(dis.BlockAddrs, addr)
 	}
 
+	// Add TLS callback functions to function and basic block addresses.
+	for _, addr := range dis.File.TLSCallbacks {
+		dis.FuncAddrs = bin.InsertAddr(dis.FuncAddrs, addr)
+		dis.BlockAddrs = bin.InsertAddr(dis.BlockAddrs, addr)
+	}
+
+	// Add function symbols to function and basic block addresses.
+	for _, sym := range dis.File.Symbols {
+		if sym.Kind != bin.SymbolFunc {
+			continue
+		}
+		dis.FuncAddrs = bin.InsertAddr(dis.FuncAddrs, sym.Addr)
+		dis.BlockAddrs = bin.InsertAddr(dis.BlockAddrs, sym.Addr)
+	}
+
+	// Add functions of the function table to function and basic block
+	// addresses. Function chunks, as specified by chained unwind information,
+	// are added as basic blocks of their primary function.
+	for _, f := range dis.File.RuntimeFuncs {
+		dis.BlockAddrs = bin.InsertAddr(dis.BlockAddrs, f.Start)
+		if f.Parent != 0 {
+			continue
+		}
+		dis.FuncAddrs = bin.InsertAddr(dis.FuncAddrs, f.Start)
+	}
+
 	// Parse jump table targets.
 	if err := parseJSON("tables.json", &dis.Tables); err != nil {
 		return nil, errors.WithStack(err)
@@ -97,6 +125,19 @@ func New(file *bin.File) (*Disasm, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	// Add function chunks of the function table.
+	for _, f := range dis.File.RuntimeFuncs {
+		if f.Parent == 0 || dis.IsFunc(f.Start) {
+			continue
+		}
+		funcAddrs, ok := dis.Chunks[f.Start]
+		if !ok {
+			funcAddrs = make(map[bin.Address]bool)
+			dis.Chunks[f.Start] = funcAddrs
+		}
+		funcAddrs[f.Parent] = true
+	}
+
 	// Compute fragments of the binary; distinct byte sequences of either code or
 	// data.
 	//
@@ -105,6 +146,12 @@ func New(file *bin.File) (*Disasm, error) {
 	if err := parseJSON("data.json", &dataAddrs); err != nil {
 		return nil, errors.WithStack(err)
 	}
+	// Append known data regions of the binary executable (e.g. PE resource
+	// data) to data addresses.
+	sort.Sort(bin.Addresses(dataAddrs))
+	for _, dataAddr := range dis.File.DataAddrs {
+		dataAddrs = bin.InsertAddr(dataAddrs, dataAddr)
+	}
 	// Append basic block addresses to fragments.
 	for _, blockAddr := range dis.BlockAddrs {
 		frag := &Fragment{
@@ -121,6 +168,37 @@ func New(file *bin.File) (*Disasm, error) {
 		}
 		dis.Frags = append(dis.Frags, frag)
 	}
+	// Append end addresses of sized function symbols to fragments, to bound the
+	// decoding of basic blocks to the extent of their functions. The bytes
+	// following a function (e.g. alignment padding) are treated as data, unless
+	// also the start of a basic block.
+	for _, sym := range dis.File.Symbols {
+		if sym.Kind != bin.SymbolFunc || sym.Size == 0 {
+			continue
+		}
+		end := sym.Addr + bin.Address(sym.Size)
+		if isBlock(dis.BlockAddrs, end) {
+			continue
+		}
+		frag := &Fragment{
+			Addr: end,
+			Kind: KindData,
+		}
+		dis.Frags = append(dis.Frags, frag)
+	}
+	// Append end addresses of functions and function chunks of the function
+	// table to fragments, to bound the decoding of basic blocks to their
+	// extent.
+	for _, f := range dis.File.RuntimeFuncs {
+		if isBlock(dis.BlockAddrs, f.End) {
+			continue
+		}
+		frag := &Fragment{
+			Addr: f.End,
+			Kind: KindData,
+		}
+		dis.Frags = append(dis.Frags, frag)
+	}
 	// Sort fragments based on address.
 	less := func(i, j int) bool {
 		return dis.Frags[i].Addr < dis.Frags[j].Addr
@@ -145,6 +223,60 @@ func (dis *Disasm) IsFunc(addr bin.Address) bool {
 	return false
 }
 
+// StoreJSON stores the function addresses, basic block addresses, jump tables,
+// function chunks and data addresses of the disassembler to the given output
+// directory, using the same JSON files and formats as parsed by New.
+//
+//    funcs.json
+//    blocks.json
+//    tables.json
+//    chunks.json
+//    data.json
+//
+// The output directory is created if not present, and must differ from the
+// working directory, so as not to overwrite the associated JSON files parsed by
+// New.
+func (dis *Disasm) StoreJSON(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if absDir == wd {
+		return errors.Errorf("invalid output directory %q; storing JSON files to the working directory would overwrite the associated JSON files", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	var dataAddrs []bin.Address
+	for _, frag := range dis.Frags {
+		if frag.Kind == KindData {
+			dataAddrs = append(dataAddrs, frag.Addr)
+		}
+	}
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "funcs.json", v: dis.FuncAddrs},
+		{name: "blocks.json", v: dis.BlockAddrs},
+		{name: "tables.json", v: dis.Tables},
+		{name: "chunks.json", v: dis.Chunks},
+		{name: "data.json", v: dataAddrs},
+	}
+	for _, file := range files {
+		jsonPath := filepath.Join(dir, file.name)
+		dbg.Printf("creating %q", jsonPath)
+		if err := jsonutil.WriteFile(jsonPath, file.v); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
 // A Fragment represents a sequence of bytes (either code or data).
 type Fragment struct {
 	// Start address of fragment.
@@ -164,8 +296,34 @@ const (
 	KindData
 )
 
+// String returns the string representation of the fragment kind.
+func (kind FragmentKind) String() string {
+	switch kind {
+	case KindCode:
+		return "code"
+	case KindData:
+		return "data"
+	}
+	return fmt.Sprintf("FragmentKind(%d)", uint(kind))
+}
+
+// MarshalText returns the textual representation of the fragment kind.
+func (kind FragmentKind) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
 // ### [ Helper functions ] ####################################################
 
+// isBlock reports whether the given address is present in the sorted list of
+// basic block addresses.
+func isBlock(blockAddrs []bin.Address, addr bin.Address) bool {
+	less := func(i int) bool {
+		return addr <= blockAddrs[i]
+	}
+	index := sort.Search(len(blockAddrs), less)
+	return index < len(blockAddrs) && blockAddrs[index] == addr
+}
+
 // parseJSON parses the given JSON file and stores the result into v.
 func parseJSON(jsonPath string, v interface{}) error {
 	if !osutil.Exists(jsonPath) {
@@ -0,0 +1,59 @@
+package disasm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	"github.com/mewkiz/pkg/jsonutil"
+	"github.com/mewkiz/pkg/osutil"
+)
+
+func TestStoreJSON(t *testing.T) {
+	dis := &Disasm{
+		FuncAddrs:  []bin.Address{0x1000},
+		BlockAddrs: []bin.Address{0x1000, 0x1010},
+		Tables:     make(map[bin.Address][]bin.Address),
+		Chunks:     make(map[bin.Address]map[bin.Address]bool),
+		Frags: []*Fragment{
+			{Addr: 0x1000, Kind: KindCode},
+			{Addr: 0x1008, Kind: KindData},
+			{Addr: 0x1010, Kind: KindCode},
+		},
+	}
+	// Associated JSON files of the working directory are not overwritten.
+	if err := dis.StoreJSON("."); err == nil {
+		t.Errorf("expected error when storing JSON files to the working directory")
+	}
+	if osutil.Exists("funcs.json") {
+		t.Errorf("unexpected JSON file %q in working directory", "funcs.json")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "disasm")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	// The output directory is created if not present.
+	dir := filepath.Join(tmpDir, "out")
+	if err := dis.StoreJSON(dir); err != nil {
+		t.Fatalf("unable to store JSON files; %+v", err)
+	}
+	var funcAddrs, dataAddrs []bin.Address
+	if err := jsonutil.ParseFile(filepath.Join(dir, "funcs.json"), &funcAddrs); err != nil {
+		t.Fatalf("unable to parse JSON file; %v", err)
+	}
+	if !reflect.DeepEqual(funcAddrs, dis.FuncAddrs) {
+		t.Errorf("function addresses mismatch; expected %v, got %v", dis.FuncAddrs, funcAddrs)
+	}
+	if err := jsonutil.ParseFile(filepath.Join(dir, "data.json"), &dataAddrs); err != nil {
+		t.Fatalf("unable to parse JSON file; %v", err)
+	}
+	wantData := []bin.Address{0x1008}
+	if !reflect.DeepEqual(dataAddrs, wantData) {
+		t.Errorf("data addresses mismatch; expected %v, got %v", wantData, dataAddrs)
+	}
+}
@@ -1,7 +1,6 @@
 package mips
 
 import (
-	"encoding/binary"
 	"sort"
 
 	"github.com/decomp/exp/bin"
@@ -113,8 +112,14 @@ func (dis *Disasm) DecodeBlock(entry bin.Address) (*BasicBlock, error) {
 
 // DecodeInst decodes and returns the instruction at the given address.
 func (dis *Disasm) DecodeInst(addr bin.Address) (*Inst, error) {
-	code := dis.File.Code(addr)
-	word := binary.LittleEndian.Uint32(code)
+	code, err := dis.File.CodeAt(addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(code) < 4 {
+		return nil, errors.Errorf("unable to decode instruction at %v; expected >= 4 bytes, got %d", addr, len(code))
+	}
+	word := dis.File.ByteOrder().Uint32(code)
 	i := mips32.DecodeInstruction(word)
 	inst := &Inst{
 		Addr:        addr,
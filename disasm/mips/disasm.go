@@ -2,7 +2,6 @@
 package mips
 
 import (
-	"fmt"
 	"log"
 	"os"
 
@@ -63,10 +62,14 @@ func NewDisasm(file *bin.File) (*Disasm, error) {
 
 	// Parse processor mode.
 	switch dis.File.Arch {
-	case bin.ArchMIPS_32:
+	case bin.ArchMIPS_32, bin.ArchMIPS_32BE:
 		dis.Mode = 32
+	case bin.ArchMIPS_64LE, bin.ArchMIPS_64BE:
+		// TODO: Add support for MIPS64 instructions; the mips32 decoder only
+		// handles the MIPS32 instruction set.
+		return nil, errors.Errorf("support for machine architecture %v not yet implemented; unable to decode MIPS64 instructions", dis.File.Arch)
 	default:
-		panic(fmt.Errorf("support for machine architecture %v not yet implemented", dis.File.Arch))
+		return nil, errors.Errorf("support for machine architecture %v not yet implemented", dis.File.Arch)
 	}
 
 	// Parse CPU contexts.
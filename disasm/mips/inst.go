@@ -68,22 +68,7 @@ func (dis *Disasm) Targets(term *Inst, funcEntry bin.Address) []bin.Address {
 			// TODO: Add support for symbol code pointers.
 			panic(fmt.Errorf("support for terminators with symbol code pointers not yet implemented; %v", term))
 		}
-		if cp.Absolute {
-			target := term.Addr&0xF0000000 | bin.Address(cp.Constant)
-			targets = append(targets, target)
-		} else {
-			// Relative target.
-			var target bin.Address
-			switch dis.Mode {
-			case 32:
-				target = bin.Address(uint32(term.Addr) + cp.Constant + mipsInstLen)
-			case 64:
-				target = term.Addr + bin.Address(cp.Constant) + mipsInstLen
-			default:
-				panic(fmt.Errorf("support for CPU mode %d not yet implemented", dis.Mode))
-			}
-			targets = append(targets, target)
-		}
+		targets = append(targets, target(term.Addr, cp.Absolute, cp.Constant))
 		targets = append(targets, next)
 		return targets
 	// Unconditional jump instructions.
@@ -99,14 +84,7 @@ func (dis *Disasm) Targets(term *Inst, funcEntry bin.Address) []bin.Address {
 			//	return nil
 			//}
 		}
-		if cp.Absolute {
-			target := term.Addr&0xF0000000 | bin.Address(cp.Constant)
-			targets = append(targets, target)
-		} else {
-			// Relative target.
-			target := term.Addr + bin.Address(cp.Constant) + mipsInstLen
-			targets = append(targets, target)
-		}
+		targets = append(targets, target(term.Addr, cp.Absolute, cp.Constant))
 		return targets
 	// Unconditional indirect jump instructions.
 	case "JALR", "JR":
@@ -124,6 +102,24 @@ func (dis *Disasm) Targets(term *Inst, funcEntry bin.Address) []bin.Address {
 	panic(fmt.Errorf("support for terminator instruction %v not yet implemented", term.Name))
 }
 
+// target returns the target address of the branch or jump instruction at the
+// given address, as specified by its code pointer.
+//
+// Absolute targets are located in the 256 MB aligned region of the delay slot
+// instruction. Relative targets are offsets (in two's complement) from the
+// delay slot instruction.
+//
+// Targets are computed in the 32-bit address space, as MIPS64 is not yet
+// supported (see NewDisasm).
+func target(addr bin.Address, absolute bool, c uint32) bin.Address {
+	// Address of the delay slot instruction.
+	delaySlot := uint32(addr + mipsInstLen)
+	if absolute {
+		return bin.Address(delaySlot&0xF0000000 | c)
+	}
+	return bin.Address(delaySlot + c)
+}
+
 // isTailCall reports whether the given JMP instruction is a tail call
 // instruction.
 func (dis *Disasm) isTailCall(funcEntry bin.Address, target bin.Address) bool {
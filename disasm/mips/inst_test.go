@@ -0,0 +1,36 @@
+package mips
+
+import (
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestTarget(t *testing.T) {
+	golden := []struct {
+		// Address of branch or jump instruction.
+		addr bin.Address
+		// Absolute or relative code pointer.
+		absolute bool
+		c        uint32
+		// Expected target address.
+		want bin.Address
+	}{
+		// j 0x00400020
+		{addr: 0x00400000, absolute: true, c: 0x00400020, want: 0x00400020},
+		// Absolute targets are located in the 256 MB region of the delay slot.
+		{addr: 0x1FFFFFFC, absolute: true, c: 0x00000010, want: 0x20000010},
+		// beq forward; offset from the delay slot.
+		{addr: 0x00400000, absolute: false, c: 0x00000010, want: 0x00400014},
+		// beq backward; negative offset in two's complement.
+		{addr: 0x00400010, absolute: false, c: 0xFFFFFFF0, want: 0x00400004},
+		// Relative targets wrap around in the 32-bit address space.
+		{addr: 0xFFFFFFF8, absolute: false, c: 0x00000008, want: 0x00000004},
+	}
+	for _, g := range golden {
+		got := target(g.addr, g.absolute, g.c)
+		if got != g.want {
+			t.Errorf("%v: target mismatch; expected %v, got %v", g.addr, g.want, got)
+		}
+	}
+}
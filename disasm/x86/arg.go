@@ -1,10 +1,8 @@
 package x86
 
 import (
-	"fmt"
-
 	"github.com/decomp/exp/bin"
-	"github.com/kr/pretty"
+	"github.com/pkg/errors"
 	"golang.org/x/arch/x86/x86asm"
 )
 
@@ -18,17 +16,25 @@ func (inst *Inst) Arg(i int) *Arg {
 }
 
 // Reg returns the register at the i:th argument of the instruction.
-func (inst *Inst) Reg(i int) *Reg {
-	reg := NewReg(inst.Args[i], inst)
+func (inst *Inst) Reg(i int) (*Reg, error) {
+	r, ok := inst.Args[i].(x86asm.Reg)
+	if !ok {
+		return nil, errors.Errorf("invalid register argument type of instruction at %v; expected x86asm.Reg, got %T", inst.Addr, inst.Args[i])
+	}
+	reg := NewReg(r, inst)
 	reg.OpIndex = i
-	return reg
+	return reg, nil
 }
 
 // Mem returns the memory reference at the i:th argument of the instruction.
-func (inst *Inst) Mem(i int) *Mem {
-	mem := NewMem(inst.Args[i], inst)
+func (inst *Inst) Mem(i int) (*Mem, error) {
+	m, ok := inst.Args[i].(x86asm.Mem)
+	if !ok {
+		return nil, errors.Errorf("invalid memory reference argument type of instruction at %v; expected x86asm.Mem, got %T", inst.Addr, inst.Args[i])
+	}
+	mem := NewMem(m, inst)
 	mem.OpIndex = i
-	return mem
+	return mem, nil
 }
 
 // --- [ argument ] ------------------------------------------------------------
@@ -65,11 +71,7 @@ type Reg struct {
 }
 
 // NewReg returns a new x86 register argument with the given parent instruction.
-func NewReg(arg x86asm.Arg, parent *Inst) *Reg {
-	reg, ok := arg.(x86asm.Reg)
-	if !ok {
-		panic(fmt.Errorf("invalid register argument type; expected x86asm.Reg, got %T", arg))
-	}
+func NewReg(reg x86asm.Reg, parent *Inst) *Reg {
 	return &Reg{
 		Reg:    reg,
 		Parent: parent,
@@ -90,11 +92,7 @@ type Mem struct {
 
 // NewMem returns a new memory reference argument with the given parent
 // instruction.
-func NewMem(arg x86asm.Arg, parent *Inst) *Mem {
-	mem, ok := arg.(x86asm.Mem)
-	if !ok {
-		panic(fmt.Errorf("invalid memory reference argument type; expected x86asm.Mem, got %T", arg))
-	}
+func NewMem(mem x86asm.Mem, parent *Inst) *Mem {
 	return &Mem{
 		Mem:    mem,
 		Parent: parent,
@@ -120,9 +118,17 @@ func (mem *Mem) Index() *Reg {
 
 // Addrs returns the addresses specified by the given argument. Addr specifies
 // the address of the terminator, and next the address of the next instruction.
-func (dis *Disasm) Addrs(arg x86asm.Arg, addr, next bin.Address) []bin.Address {
+// Jump tables are recovered once, and reused from dis.Tables and dis.JumpTables
+// by subsequent invocations.
+func (dis *Disasm) Addrs(arg x86asm.Arg, addr, next bin.Address) ([]bin.Address, error) {
 	switch arg := arg.(type) {
-	//case x86asm.Reg:
+	case x86asm.Reg:
+		// Jump table target.
+		t, err := dis.JumpTable(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "support for indirect jump through register %v at %v not yet implemented", arg, addr)
+		}
+		return t.Targets, nil
 	case x86asm.Mem:
 		// Segment:[Base+Scale*Index+Disp].
 
@@ -135,42 +141,70 @@ func (dis *Disasm) Addrs(arg x86asm.Arg, addr, next bin.Address) []bin.Address {
 		// Static target.
 		disp := bin.Address(arg.Disp)
 		if arg.Segment == 0 && arg.Base == 0 && arg.Index == 0 {
-			return []bin.Address{disp}
-		}
-
-		// Adjust disposition based on index register value.
-		if arg.Index != 0 {
-			if context, ok := dis.Contexts[addr]; ok {
-				if c, ok := context.Regs[Register(arg.Index)]; ok {
-					if indexMin, ok := c["min"]; ok {
-						disp += bin.Address(arg.Scale) * indexMin.Addr()
-					}
-				}
-			}
+			return []bin.Address{disp}, nil
 		}
 
 		// Jump table target.
-		if targets, ok := dis.Tables[disp]; ok {
-			if arg.Segment == 0 && arg.Base == 0 && arg.Scale == 4 && arg.Index != 0 {
-				return targets
+		if targets, ok := dis.tableTargets(arg, addr); ok {
+			return targets, nil
+		}
+		var tableErr error
+		if arg.Index != 0 {
+			t, err := dis.JumpTable(addr)
+			if err == nil {
+				return t.Targets, nil
 			}
+			tableErr = err
 		}
 
 		// TODO: Figure out how to handle indirect jump to function pointer.
 
 		// Target is likely a function pointer; skip for now.
-		if arg.Base != 0 && disp < dis.codeStart() {
+		codeStart, err := dis.codeStart()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if arg.Base != 0 && disp < codeStart {
 			warn.Printf("ignoring indirect targets from %v of memory reference %v", addr, arg)
-			return nil
+			return nil, nil
 		}
 
-		pretty.Println("mem:", arg)
-		panic("x86.Disasm.Addrs: not yet implemented")
+		if tableErr != nil {
+			return nil, errors.Wrapf(tableErr, "support for indirect jump through memory reference %v at %v not yet implemented", arg, addr)
+		}
+		return nil, errors.Errorf("support for indirect jump through memory reference %v at %v not yet implemented", arg, addr)
 	//case x86asm.Imm:
 	case x86asm.Rel:
 		target := next + bin.Address(arg)
-		return []bin.Address{target}
+		return []bin.Address{target}, nil
 	default:
-		panic(fmt.Errorf("support for argument type %T not yet implemented", arg))
+		return nil, errors.Errorf("support for argument type %T at %v not yet implemented", arg, addr)
+	}
+}
+
+// tableTargets returns the targets of the jump table referenced by the given
+// memory reference of the instruction at addr. The boolean return value
+// indicates success.
+func (dis *Disasm) tableTargets(arg x86asm.Mem, addr bin.Address) ([]bin.Address, bool) {
+	if arg.Segment != 0 || arg.Base != 0 || arg.Scale != 4 || arg.Index == 0 {
+		return nil, false
+	}
+	// Adjust disposition based on index register value.
+	disp := bin.Address(arg.Disp)
+	if context, ok := dis.Contexts[addr]; ok {
+		if c, ok := context.Regs[Register(arg.Index)]; ok {
+			if indexMin, ok := c["min"]; ok {
+				min, err := indexMin.Addr()
+				if err != nil {
+					warn.Printf("invalid minimum value of index register %v at %v; %v", arg.Index, addr, err)
+					return nil, false
+				}
+				disp += bin.Address(arg.Scale) * min
+			}
+		}
 	}
+	dis.tablesMu.Lock()
+	defer dis.tablesMu.Unlock()
+	targets, ok := dis.Tables[disp]
+	return targets, ok
 }
@@ -1,7 +1,6 @@
 package x86
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -73,42 +72,83 @@ func (v Value) MarshalText() ([]byte, error) {
 }
 
 // Addr returns the virtual address represented by v.
-func (v Value) Addr() bin.Address {
+func (v Value) Addr() (bin.Address, error) {
 	var addr bin.Address
 	if err := addr.Set(v.s); err != nil {
-		panic(fmt.Errorf("unable to parse value %q as virtual address; %v", v.s, err))
+		return 0, errors.Errorf("unable to parse value %q as virtual address; %v", v.s, err)
 	}
-	return addr
+	return addr, nil
 }
 
 // Int64 returns the 64-bit signed integer represented by v.
-func (v Value) Int64() int64 {
+func (v Value) Int64() (int64, error) {
 	x, err := strconv.ParseInt(v.s, 10, 64)
 	if err != nil {
-		panic(fmt.Errorf("unable to parse value %q as int64; %v", v.s, err))
+		return 0, errors.Errorf("unable to parse value %q as int64; %v", v.s, err)
 	}
-	return x
+	return x, nil
 }
 
 // Uint64 returns the 64-bit unsigned integer represented by v.
-func (v Value) Uint64() uint64 {
+func (v Value) Uint64() (uint64, error) {
 	s := v.s
 	base := 10
 	if strings.HasPrefix(s, "0x") {
 		s = s[len("0x"):]
+		base = 16
 	}
 	x, err := strconv.ParseUint(s, base, 64)
 	if err != nil {
-		panic(fmt.Errorf("unable to parse value %q as uint64; %v", v.s, err))
+		return 0, errors.Errorf("unable to parse value %q as uint64; %v", v.s, err)
 	}
-	return x
+	return x, nil
 }
 
 // Bool returns the boolean represented by v.
-func (v Value) Bool() bool {
+func (v Value) Bool() (bool, error) {
 	b, err := strconv.ParseBool(v.s)
 	if err != nil {
-		panic(fmt.Errorf("unable to parse value %q as bool; %v", v.s, err))
+		return false, errors.Errorf("unable to parse value %q as bool; %v", v.s, err)
 	}
-	return b
+	return b, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// validate validates the CPU contexts, reporting an error if the value of a
+// key defined by ValueContext is of the wrong type.
+func (cs Contexts) validate() error {
+	for addr, context := range cs {
+		for reg, c := range context.Regs {
+			if err := c.validate(); err != nil {
+				return errors.Errorf("invalid context of register %v at address %v; %v", reg, addr, err)
+			}
+		}
+		for i, c := range context.Args {
+			if err := c.validate(); err != nil {
+				return errors.Errorf("invalid context of argument %d at address %v; %v", i, addr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate validates the value context, reporting an error if the value of a
+// key defined by ValueContext is of the wrong type.
+func (c ValueContext) validate() error {
+	for key, v := range c {
+		var err error
+		switch key {
+		case "addr":
+			_, err = v.Addr()
+		case "extractvalue":
+			_, err = v.Bool()
+		case "min", "max", "Mem.offset", "param":
+			_, err = v.Int64()
+		}
+		if err != nil {
+			return errors.Errorf("invalid value of key %q; %v", key, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package x86
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+func TestContextsValidate(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{
+			name: "valid",
+			in:   `{"0x401000": {"regs": {"EAX": {"addr": "0x402000", "min": "-1", "extractvalue": "true"}}, "args": {"1": {"Mem.offset": "4", "type": "i32*"}}}}`,
+		},
+		{
+			name: "invalid address",
+			in:   `{"0x401000": {"regs": {"EAX": {"addr": "foo"}}}}`,
+			err:  `invalid context of register EAX at address 0x401000; invalid value of key "addr"`,
+		},
+		{
+			name: "invalid bool",
+			in:   `{"0x401000": {"regs": {"EAX": {"extractvalue": "yes please"}}}}`,
+			err:  `invalid value of key "extractvalue"`,
+		},
+		{
+			name: "invalid int64",
+			in:   `{"0x401000": {"args": {"0": {"param": "0x"}}}}`,
+			err:  `invalid context of argument 0 at address 0x401000; invalid value of key "param"`,
+		},
+		{
+			name: "unknown register",
+			in:   `{"0x401000": {"regs": {"FOO": {"min": "0"}}}}`,
+			err:  `support for register "FOO" not yet implemented`,
+		},
+	}
+	for _, g := range golden {
+		var contexts Contexts
+		err := json.Unmarshal([]byte(g.in), &contexts)
+		if err == nil {
+			err = contexts.validate()
+		}
+		switch {
+		case len(g.err) == 0 && err != nil:
+			t.Errorf("%s: unexpected error; %v", g.name, err)
+		case len(g.err) > 0 && err == nil:
+			t.Errorf("%s: expected error %q, got nil", g.name, g.err)
+		case len(g.err) > 0 && !strings.Contains(err.Error(), g.err):
+			t.Errorf("%s: error mismatch; expected %q, got %q", g.name, g.err, err)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	v := Value{s: "0x10"}
+	if x, err := v.Uint64(); err != nil || x != 0x10 {
+		t.Errorf("uint64 mismatch; expected %v, got %v (%v)", 0x10, x, err)
+	}
+	if x, err := v.Addr(); err != nil || x != 0x10 {
+		t.Errorf("address mismatch; expected %v, got %v (%v)", bin.Address(0x10), x, err)
+	}
+	if _, err := v.Int64(); err == nil {
+		t.Errorf("expected error when parsing %q as int64", v)
+	}
+	if _, err := v.Bool(); err == nil {
+		t.Errorf("expected error when parsing %q as bool", v)
+	}
+}
+
+func TestInstArg(t *testing.T) {
+	// lea eax, [ebx+4]
+	inst := &Inst{Addr: 0x1000}
+	inst.Args[0] = x86asm.EAX
+	inst.Args[1] = x86asm.Mem{Base: x86asm.EBX, Disp: 4}
+	if reg, err := inst.Reg(0); err != nil || reg.Reg != x86asm.EAX {
+		t.Errorf("register mismatch; expected %v, got %v (%v)", x86asm.EAX, reg, err)
+	}
+	if mem, err := inst.Mem(1); err != nil || mem.OpIndex != 1 || mem.Base().Reg != x86asm.EBX {
+		t.Errorf("memory reference mismatch; expected %v, got %v (%v)", inst.Args[1], mem, err)
+	}
+	if _, err := inst.Reg(1); err == nil {
+		t.Errorf("expected error for register of memory reference argument")
+	}
+	if _, err := inst.Mem(0); err == nil {
+		t.Errorf("expected error for memory reference of register argument")
+	}
+}
+
+func TestNewDisasmArch(t *testing.T) {
+	file := &bin.File{Arch: bin.ArchARM_32}
+	_, err := NewDisasm(file)
+	if err == nil || !strings.Contains(err.Error(), "support for machine architecture") {
+		t.Errorf("error mismatch; expected unsupported machine architecture, got %v", err)
+	}
+}
@@ -35,7 +35,9 @@ type Inst struct {
 	x86asm.Inst
 }
 
-// DecodeFunc decodes and returns the function at the given address.
+// DecodeFunc decodes and returns the function at the given address. An error
+// is returned if any basic block of the function fails to decode or has
+// unresolved targets; other functions are unaffected.
 func (dis *Disasm) DecodeFunc(entry bin.Address) (*Func, error) {
 	dbg.Printf("decoding function at %v", entry)
 	f := &Func{
@@ -52,11 +54,14 @@ func (dis *Disasm) DecodeFunc(entry bin.Address) (*Func, error) {
 		}
 		block, err := dis.DecodeBlock(blockAddr)
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return nil, errors.Wrapf(err, "unable to decode basic block at %v in function at %v", blockAddr, entry)
 		}
 		f.Blocks[blockAddr] = block
 		// Add block targets to queue.
-		targets := dis.Targets(block.Term, entry)
+		targets, err := dis.Targets(block.Term, entry)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 		for _, target := range targets {
 			dbg.Printf("adding basic block address %v to queue", target)
 			queue.push(target)
@@ -69,7 +74,10 @@ func (dis *Disasm) DecodeFunc(entry bin.Address) (*Func, error) {
 func (dis *Disasm) DecodeBlock(entry bin.Address) (*BasicBlock, error) {
 	dbg.Printf("decoding basic block at %v", entry)
 	// Compute end address of the basic block.
-	maxLen := dis.maxBlockLen(entry)
+	maxLen, err := dis.maxBlockLen(entry)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	addr := entry
 	end := entry + bin.Address(maxLen)
 	// Decode instructions.
@@ -104,11 +112,14 @@ func (dis *Disasm) DecodeBlock(entry bin.Address) (*BasicBlock, error) {
 
 // DecodeInst decodes and returns the instruction at the given address.
 func (dis *Disasm) DecodeInst(addr bin.Address) (*Inst, error) {
-	code := dis.File.Code(addr)
-	i, err := x86asm.Decode(code, dis.Mode)
+	code, err := dis.File.CodeAt(addr)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	i, err := x86asm.Decode(code, dis.Mode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decode instruction at %v", addr)
+	}
 	inst := &Inst{
 		Addr: addr,
 		Inst: i,
@@ -117,19 +128,23 @@ func (dis *Disasm) DecodeInst(addr bin.Address) (*Inst, error) {
 }
 
 // maxBlockLen returns the maximum length of the given basic block.
-func (dis *Disasm) maxBlockLen(blockAddr bin.Address) int64 {
+func (dis *Disasm) maxBlockLen(blockAddr bin.Address) (int64, error) {
 	less := func(i int) bool {
 		return blockAddr < dis.Frags[i].Addr
 	}
 	index := sort.Search(len(dis.Frags), less)
 	if 0 <= index && index < len(dis.Frags) {
-		return int64(dis.Frags[index].Addr - blockAddr)
+		return int64(dis.Frags[index].Addr - blockAddr), nil
+	}
+	end, err := dis.codeEnd()
+	if err != nil {
+		return 0, errors.WithStack(err)
 	}
-	return int64(dis.codeEnd() - blockAddr)
+	return int64(end - blockAddr), nil
 }
 
 // codeStart returns the start address of the first code section.
-func (dis *Disasm) codeStart() bin.Address {
+func (dis *Disasm) codeStart() (bin.Address, error) {
 	var min bin.Address
 	for _, sect := range dis.File.Sections {
 		if sect.Perm&bin.PermX != 0 {
@@ -140,13 +155,13 @@ func (dis *Disasm) codeStart() bin.Address {
 		}
 	}
 	if min == 0 {
-		panic("unable to locate start address of first code section")
+		return 0, errors.New("unable to locate start address of first code section")
 	}
-	return min
+	return min, nil
 }
 
 // codeEnd returns the end address of the last code section.
-func (dis *Disasm) codeEnd() bin.Address {
+func (dis *Disasm) codeEnd() (bin.Address, error) {
 	var max bin.Address
 	for _, sect := range dis.File.Sections {
 		if sect.Perm&bin.PermX != 0 {
@@ -157,7 +172,7 @@ func (dis *Disasm) codeEnd() bin.Address {
 		}
 	}
 	if max == 0 {
-		panic("unable to locate end address of last code section")
+		return 0, errors.New("unable to locate end address of last code section")
 	}
-	return max
+	return max, nil
 }
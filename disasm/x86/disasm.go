@@ -2,9 +2,10 @@
 package x86
 
 import (
-	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/decomp/exp/bin"
 	"github.com/decomp/exp/disasm"
@@ -37,6 +38,15 @@ type Disasm struct {
 	Mode int
 	// CPU contexts.
 	Contexts Contexts
+	// Map from indirect jump address to recovered jump table.
+	JumpTables map[bin.Address]*JumpTable
+
+	// Mutex guarding jump tables recovered after initialization; i.e. Tables,
+	// JumpTables and preds.
+	tablesMu sync.Mutex
+	// Map from basic block address to the addresses of its predecessor basic
+	// blocks; computed on first use by JumpTable.
+	preds map[bin.Address][]bin.Address
 }
 
 // NewDisasm creates a new Disasm for accessing the assembly instructions of the
@@ -53,6 +63,10 @@ type Disasm struct {
 // Associated files of the x86 disassembler.
 //
 //    contexts.json
+//    jump_tables.json
+//
+// Use Discover to locate functions and basic blocks of binary executables
+// without associated JSON files.
 func NewDisasm(file *bin.File) (*Disasm, error) {
 	// Prepare x86 disassembler.
 	d, err := disasm.New(file)
@@ -60,28 +74,56 @@ func NewDisasm(file *bin.File) (*Disasm, error) {
 		return nil, errors.WithStack(err)
 	}
 	dis := &Disasm{
-		Disasm:   d,
-		Contexts: make(Contexts),
+		Disasm:     d,
+		Contexts:   make(Contexts),
+		JumpTables: make(map[bin.Address]*JumpTable),
 	}
 
 	// Parse processor mode.
 	switch dis.File.Arch {
+	case bin.ArchX86_16:
+		dis.Mode = 16
 	case bin.ArchX86_32:
 		dis.Mode = 32
 	case bin.ArchX86_64:
 		dis.Mode = 64
 	default:
-		panic(fmt.Errorf("support for machine architecture %v not yet implemented", dis.File.Arch))
+		return nil, errors.Errorf("support for machine architecture %v not yet implemented", dis.File.Arch)
 	}
 
 	// Parse CPU contexts.
 	if err := parseJSON("contexts.json", &dis.Contexts); err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if err := dis.Contexts.validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Parse jump tables.
+	if err := parseJSON("jump_tables.json", &dis.JumpTables); err != nil {
+		return nil, errors.WithStack(err)
+	}
 
 	return dis, nil
 }
 
+// StoreJSON stores the function addresses, basic block addresses, jump tables,
+// function chunks and data addresses of the disassembler to the given output
+// directory, using the same JSON files and formats as parsed by NewDisasm. The
+// recovered jump tables of indirect jumps, including their default targets,
+// are stored to jump_tables.json.
+func (dis *Disasm) StoreJSON(dir string) error {
+	if err := dis.Disasm.StoreJSON(dir); err != nil {
+		return errors.WithStack(err)
+	}
+	jsonPath := filepath.Join(dir, "jump_tables.json")
+	dbg.Printf("creating %q", jsonPath)
+	if err := jsonutil.WriteFile(jsonPath, dis.JumpTables); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
 // ### [ Helper functions ] ####################################################
 
 // parseJSON parses the given JSON file and stores the result into v.
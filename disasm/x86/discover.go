@@ -0,0 +1,474 @@
+package x86
+
+import (
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/disasm"
+	"github.com/pkg/errors"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// noReturnFuncs specifies the set of imported functions that do not return to
+// their caller.
+var noReturnFuncs = map[string]bool{
+	"ExitProcess":                        true,
+	"ExitThread":                         true,
+	"FatalAppExitA":                      true,
+	"FatalAppExitW":                      true,
+	"RaiseException":                     true,
+	"TerminateProcess":                   true,
+	"_CxxThrowException":                 true,
+	"_Exit":                              true,
+	"__assert_fail":                      true,
+	"__cxa_rethrow":                      true,
+	"__cxa_throw":                        true,
+	"__stack_chk_fail":                   true,
+	"_amsg_exit":                         true,
+	"_exit":                              true,
+	"_invalid_parameter_noinfo_noreturn": true,
+	"abort":                              true,
+	"exit":                               true,
+	"longjmp":                            true,
+	"pthread_exit":                       true,
+	"siglongjmp":                         true,
+}
+
+// Discover locates the functions and basic blocks of the binary executable by
+// recursive descent disassembly, starting from the known function addresses
+// (e.g. entry point, exports, function symbols and funcs.json).
+//
+// Targets of direct calls are added as functions. Targets of direct jumps and
+// jump tables, and the fallthrough addresses of conditional jumps, are added as
+// basic blocks. Jump tables of indirect jumps are recovered by backward slicing
+// (see JumpTable) and added to dis.JumpTables and dis.Tables. The addresses
+// following unconditional jumps and returns are added as data fragments, unless
+// also the start of a basic block. Jump targets outside of the extent of the
+// jumping function are added as function chunks.
+//
+// The discovered function addresses, basic block addresses, function chunks
+// and fragments are added to dis; use StoreJSON to store them in the JSON
+// format parsed by NewDisasm. The returned discovery may be passed to Sweep to
+// continue discovery from functions proposed by linear sweep disassembly.
+func (dis *Disasm) Discover() (*Discovery, error) {
+	d := &Discovery{
+		dis:    dis,
+		funcs:  make(map[bin.Address]bool),
+		queued: make(map[bin.Address]bool),
+		blocks: make(map[bin.Address]*run),
+		ends:   make(map[bin.Address]bool),
+		preds:  make(map[bin.Address][]bin.Address),
+	}
+	if err := d.discover(dis.FuncAddrs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d, nil
+}
+
+// A Discovery tracks information required for recursive descent disassembly.
+type Discovery struct {
+	// Disassembler.
+	dis *Disasm
+	// Set of function addresses.
+	funcs map[bin.Address]bool
+	// Set of function addresses and callees queued for decoding.
+	queued map[bin.Address]bool
+	// Map from basic block address to decoded instruction run; or nil if not
+	// decodable.
+	blocks map[bin.Address]*run
+	// Set of addresses following unconditional jumps and returns.
+	ends map[bin.Address]bool
+	// Map from basic block address to the addresses of its predecessor basic
+	// blocks, which either fall through or conditionally jump into the basic
+	// block; used to locate bounds checks of jump tables.
+	preds map[bin.Address][]bin.Address
+}
+
+// discover locates the functions and basic blocks reachable from the given
+// function addresses, and records them in the disassembler. Basic blocks
+// decoded by previous invocations are not decoded again.
+func (d *Discovery) discover(funcAddrs []bin.Address) error {
+	dis := d.dis
+	for _, funcAddr := range funcAddrs {
+		d.funcs[funcAddr] = true
+		d.queued[funcAddr] = true
+	}
+	// Decode functions.
+	funcQueue := append([]bin.Address(nil), funcAddrs...)
+	for len(funcQueue) > 0 {
+		entry := funcQueue[len(funcQueue)-1]
+		funcQueue = funcQueue[:len(funcQueue)-1]
+		blockQueue := []bin.Address{entry}
+		for len(blockQueue) > 0 {
+			blockAddr := blockQueue[len(blockQueue)-1]
+			blockQueue = blockQueue[:len(blockQueue)-1]
+			if _, ok := d.blocks[blockAddr]; ok {
+				// skip basic block if already decoded.
+				continue
+			}
+			r := d.decodeRun(blockAddr)
+			d.blocks[blockAddr] = r
+			if r == nil {
+				continue
+			}
+			for _, callee := range r.calls {
+				if !d.queued[callee] {
+					d.queued[callee] = true
+					funcQueue = append(funcQueue, callee)
+				}
+			}
+			blockQueue = append(blockQueue, r.targets...)
+			for _, target := range r.jumps {
+				if !d.queued[target] {
+					blockQueue = append(blockQueue, target)
+				}
+			}
+		}
+		// Only add callees as functions if their entry basic block is
+		// decodable.
+		if !d.funcs[entry] {
+			if d.blocks[entry] == nil {
+				warn.Printf("skipping function at %v discovered by call; unable to decode entry basic block", entry)
+				continue
+			}
+			dbg.Printf("function at %v discovered by call", entry)
+			d.funcs[entry] = true
+		}
+	}
+	// Record discovered functions and basic blocks.
+	for funcAddr := range d.funcs {
+		dis.FuncAddrs = bin.InsertAddr(dis.FuncAddrs, funcAddr)
+	}
+	for blockAddr, r := range d.blocks {
+		if r == nil {
+			// skip basic block if not decodable.
+			continue
+		}
+		dis.BlockAddrs = bin.InsertAddr(dis.BlockAddrs, blockAddr)
+	}
+	// Record function chunks.
+	if err := d.locateChunks(); err != nil {
+		return errors.WithStack(err)
+	}
+	// Record fragments.
+	dis.updateFrags(d.ends)
+	// Invalidate predecessors of basic blocks computed prior to discovery.
+	dis.resetPreds()
+	return nil
+}
+
+// A run is a sequence of instructions decoded from a basic block address up to
+// and including the first terminating instruction.
+type run struct {
+	// Targets of direct calls.
+	calls []bin.Address
+	// Basic block successors; targets of conditional jumps and loops, and
+	// fallthrough addresses.
+	targets []bin.Address
+	// Targets of unconditional jumps; basic block successors or tail calls.
+	jumps []bin.Address
+	// Address following a call to a function which does not return; or 0 if
+	// not present. Not decoded when discovering basic blocks, but followed when
+	// locating function chunks, as calls are not terminating instructions of
+	// DecodeFunc.
+	next bin.Address
+}
+
+// decodeRun decodes the instructions starting at the given basic block address
+// up to and including the first terminating instruction, or up to the first
+// call to a function which does not return. A nil run is returned if the first
+// instruction is not decodable.
+func (d *Discovery) decodeRun(blockAddr bin.Address) *run {
+	dis := d.dis
+	r := &run{}
+	addr := blockAddr
+	for {
+		inst, err := dis.DecodeInst(addr)
+		if err != nil {
+			warn.Printf("unable to decode instruction at %v of basic block at %v; %v", addr, blockAddr, err)
+			if addr == blockAddr {
+				return nil
+			}
+			return r
+		}
+		next := addr + bin.Address(inst.Len)
+		switch inst.Op {
+		case x86asm.CALL:
+			if target, ok := dis.relTarget(inst); ok && dis.isCode(target) {
+				r.calls = append(r.calls, target)
+			}
+			if dis.isNoReturn(inst) {
+				dbg.Printf("call to non-returning function at %v", addr)
+				r.next = next
+				return r
+			}
+		// Loop terminators.
+		case x86asm.LOOP, x86asm.LOOPE, x86asm.LOOPNE:
+			r.targets = append(r.targets, dis.jumpTargets(inst)...)
+			r.targets = append(r.targets, next)
+			d.addPred(next, blockAddr)
+			return r
+		// Conditional jump terminators.
+		case x86asm.JA, x86asm.JAE, x86asm.JB, x86asm.JBE, x86asm.JCXZ, x86asm.JE, x86asm.JECXZ, x86asm.JG, x86asm.JGE, x86asm.JL, x86asm.JLE, x86asm.JNE, x86asm.JNO, x86asm.JNP, x86asm.JNS, x86asm.JO, x86asm.JP, x86asm.JRCXZ, x86asm.JS:
+			targets := dis.jumpTargets(inst)
+			for _, target := range targets {
+				d.addPred(target, blockAddr)
+			}
+			r.targets = append(r.targets, targets...)
+			r.targets = append(r.targets, next)
+			d.addPred(next, blockAddr)
+			return r
+		// Unconditional jump terminators.
+		case x86asm.JMP:
+			targets := dis.jumpTargets(inst)
+			if targets == nil {
+				targets = d.jumpTable(blockAddr, inst)
+			}
+			for _, target := range targets {
+				if _, ok := dis.File.Imports[target]; ok {
+					continue
+				}
+				r.jumps = append(r.jumps, target)
+			}
+			d.ends[next] = true
+			return r
+		// Return terminators.
+		case x86asm.RET:
+			d.ends[next] = true
+			return r
+		}
+		addr = next
+	}
+}
+
+// addPred records the basic block at blockAddr as a predecessor of the basic
+// block at the given address, which it either falls through or conditionally
+// jumps into.
+func (d *Discovery) addPred(succ, blockAddr bin.Address) {
+	d.preds[succ] = append(d.preds[succ], blockAddr)
+}
+
+// jumpTable recovers the jump table of the given indirect jump instruction of
+// the basic block at blockAddr, and returns its targets. Recovered jump tables
+// are added to dis.JumpTables and dis.Tables.
+func (d *Discovery) jumpTable(blockAddr bin.Address, inst *Inst) []bin.Address {
+	switch arg := inst.Args[0].(type) {
+	case x86asm.Mem:
+		if arg.Index == 0 {
+			// indirect jump through function pointer.
+			return nil
+		}
+	case x86asm.Reg:
+		// indirect jump through register.
+	default:
+		return nil
+	}
+	dis := d.dis
+	t, err := dis.lookupTable(d.preds[blockAddr], blockAddr, inst.Addr)
+	if err != nil {
+		warn.Printf("%v", err)
+		return nil
+	}
+	dbg.Printf("jump table at %v of indirect jump at %v (%d targets, default target %v)", t.Addr, inst.Addr, len(t.Targets), t.Default)
+	return t.Targets
+}
+
+// locateChunks records jump targets outside of the extent of the jumping
+// function as function chunks, under the assumption that functions are
+// continuous and span up to the succeeding function. The basic blocks of each
+// function are traversed as by DecodeFunc.
+func (d *Discovery) locateChunks() error {
+	dis := d.dis
+	for _, entry := range dis.FuncAddrs {
+		funcEnd, err := dis.funcEnd(entry)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		visited := make(map[bin.Address]bool)
+		queue := []bin.Address{entry}
+		for len(queue) > 0 {
+			blockAddr := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if visited[blockAddr] {
+				continue
+			}
+			visited[blockAddr] = true
+			r, ok := d.blocks[blockAddr]
+			if !ok {
+				// Decode instructions following calls to non-returning
+				// functions.
+				r = d.decodeRun(blockAddr)
+				d.blocks[blockAddr] = r
+			}
+			if r == nil {
+				continue
+			}
+			queue = append(queue, r.targets...)
+			if r.next != 0 {
+				queue = append(queue, r.next)
+			}
+			for _, target := range r.jumps {
+				switch {
+				case entry <= target && target < funcEnd:
+					// Target inside function body.
+				case dis.IsFunc(target):
+					// Tail call.
+					continue
+				default:
+					dbg.Printf("function chunk at %v of function at %v", target, entry)
+					parents, ok := dis.Chunks[target]
+					if !ok {
+						parents = make(map[bin.Address]bool)
+						dis.Chunks[target] = parents
+					}
+					parents[entry] = true
+				}
+				queue = append(queue, target)
+			}
+		}
+	}
+	return nil
+}
+
+// updateFrags updates the fragments of the disassembler based on its basic
+// block addresses and the given data addresses. Fragments at basic block
+// addresses are code fragments; data addresses which are not the start of a
+// basic block are added as data fragments.
+func (dis *Disasm) updateFrags(dataAddrs map[bin.Address]bool) {
+	kinds := make(map[bin.Address]disasm.FragmentKind)
+	for _, frag := range dis.Frags {
+		kinds[frag.Addr] = frag.Kind
+	}
+	for dataAddr := range dataAddrs {
+		if !dis.isCode(dataAddr) {
+			continue
+		}
+		if _, ok := kinds[dataAddr]; !ok {
+			kinds[dataAddr] = disasm.KindData
+		}
+	}
+	for _, blockAddr := range dis.BlockAddrs {
+		kinds[blockAddr] = disasm.KindCode
+	}
+	dis.Frags = dis.Frags[:0]
+	for addr, kind := range kinds {
+		frag := &disasm.Fragment{
+			Addr: addr,
+			Kind: kind,
+		}
+		dis.Frags = append(dis.Frags, frag)
+	}
+	less := func(i, j int) bool {
+		return dis.Frags[i].Addr < dis.Frags[j].Addr
+	}
+	sort.Slice(dis.Frags, less)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// jumpTargets returns the statically known targets of the given jump
+// instruction; i.e. the target of direct jumps, the import or jump table
+// referenced by memory operands, or nil if unknown.
+func (dis *Disasm) jumpTargets(inst *Inst) []bin.Address {
+	if target, ok := dis.relTarget(inst); ok {
+		if !dis.isCode(target) {
+			warn.Printf("jump to non-code address %v at %v", target, inst.Addr)
+			return nil
+		}
+		return []bin.Address{target}
+	}
+	mem, ok := inst.Args[0].(x86asm.Mem)
+	if !ok {
+		return nil
+	}
+	if addr, ok := dis.memAddr(mem, inst); ok {
+		if _, ok := dis.File.Imports[addr]; ok {
+			// Jump to imported function.
+			return []bin.Address{addr}
+		}
+	}
+	// Jump table target.
+	if targets, ok := dis.tableTargets(mem, inst.Addr); ok {
+		return targets
+	}
+	return nil
+}
+
+// relTarget returns the target address of the given relative branch
+// instruction. The boolean return value indicates success.
+func (dis *Disasm) relTarget(inst *Inst) (bin.Address, bool) {
+	rel, ok := inst.Args[0].(x86asm.Rel)
+	if !ok {
+		return 0, false
+	}
+	next := inst.Addr + bin.Address(inst.Len)
+	return next + bin.Address(rel), true
+}
+
+// memAddr returns the static address of the given memory reference of the
+// instruction; i.e. absolute addresses and RIP-relative addresses. The boolean
+// return value indicates success.
+func (dis *Disasm) memAddr(mem x86asm.Mem, inst *Inst) (bin.Address, bool) {
+	if mem.Index != 0 {
+		return 0, false
+	}
+	switch mem.Base {
+	case 0:
+		if mem.Segment != 0 {
+			return 0, false
+		}
+		return bin.Address(mem.Disp), true
+	case x86asm.RIP:
+		next := inst.Addr + bin.Address(inst.Len)
+		return next + bin.Address(mem.Disp), true
+	}
+	return 0, false
+}
+
+// isNoReturn reports whether the given call instruction calls an imported
+// function which does not return, either directly or through an import thunk
+// (e.g. jmp [__imp_ExitProcess]).
+func (dis *Disasm) isNoReturn(inst *Inst) bool {
+	var slot bin.Address
+	switch arg := inst.Args[0].(type) {
+	case x86asm.Mem:
+		addr, ok := dis.memAddr(arg, inst)
+		if !ok {
+			return false
+		}
+		slot = addr
+	case x86asm.Rel:
+		target, _ := dis.relTarget(inst)
+		if name, ok := dis.File.Imports[target]; ok {
+			// Call to import stub (e.g. ELF PLT stub).
+			return noReturnFuncs[name]
+		}
+		thunk, err := dis.DecodeInst(target)
+		if err != nil || thunk.Op != x86asm.JMP {
+			return false
+		}
+		mem, ok := thunk.Args[0].(x86asm.Mem)
+		if !ok {
+			return false
+		}
+		addr, ok := dis.memAddr(mem, thunk)
+		if !ok {
+			return false
+		}
+		slot = addr
+	default:
+		return false
+	}
+	name, ok := dis.File.Imports[slot]
+	return ok && noReturnFuncs[name]
+}
+
+// isCode reports whether the given address is located within the initialized
+// data of an executable section.
+func (dis *Disasm) isCode(addr bin.Address) bool {
+	sect, ok := dis.File.FindSection(addr)
+	if !ok || sect.Perm&bin.PermX == 0 {
+		return false
+	}
+	return addr < sect.Addr+bin.Address(len(sect.Data))
+}
@@ -0,0 +1,74 @@
+package x86
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+)
+
+func TestDiscover(t *testing.T) {
+	//    0x1000  call 0x100C
+	//    0x1005  call 0x1016   ; invalid instruction
+	//    0x100A  ret
+	//    0x100B  int3
+	//    0x100C  xor  eax, eax
+	//    0x100E  jmp  0x1011
+	//    0x1010  int3
+	//    0x1011  ret
+	//    0x1012  int3 (x4)
+	//    0x1016  db   0xFF, 0xFF
+	code := []byte{
+		0xE8, 0x07, 0x00, 0x00, 0x00,
+		0xE8, 0x0C, 0x00, 0x00, 0x00,
+		0xC3,
+		0xCC,
+		0x31, 0xC0,
+		0xEB, 0x01,
+		0xCC,
+		0xC3,
+		0xCC, 0xCC, 0xCC, 0xCC,
+		0xFF, 0xFF,
+	}
+	dis := newTestDisasm(t, 0x1000, code)
+	if _, err := dis.Discover(); err != nil {
+		t.Fatalf("unable to discover functions; %+v", err)
+	}
+	// The callee at 0x1016 is not decodable, and thus not added as a function.
+	wantFuncs := []bin.Address{0x1000, 0x100C}
+	if !reflect.DeepEqual(dis.FuncAddrs, wantFuncs) {
+		t.Errorf("function addresses mismatch; expected %v, got %v", wantFuncs, dis.FuncAddrs)
+	}
+	wantBlocks := []bin.Address{0x1000, 0x100C, 0x1011}
+	if !reflect.DeepEqual(dis.BlockAddrs, wantBlocks) {
+		t.Errorf("basic block addresses mismatch; expected %v, got %v", wantBlocks, dis.BlockAddrs)
+	}
+}
+
+// newTestDisasm returns a disassembler of the given x86_32 code, located at the
+// specified address in a readable and executable section. Additional sections
+// (e.g. of jump tables) may be specified by sects.
+func newTestDisasm(t *testing.T, addr bin.Address, code []byte, sects ...*bin.Section) *Disasm {
+	t.Helper()
+	file := &bin.File{
+		Arch:  bin.ArchX86_32,
+		Entry: addr,
+		Sections: []*bin.Section{
+			{
+				Name:     ".text",
+				Addr:     addr,
+				Data:     code,
+				FileSize: len(code),
+				MemSize:  len(code),
+				Perm:     bin.PermR | bin.PermX,
+			},
+		},
+	}
+	file.Sections = append(file.Sections, sects...)
+	file.BuildIndex()
+	dis, err := NewDisasm(file)
+	if err != nil {
+		t.Fatalf("unable to create disassembler; %+v", err)
+	}
+	return dis
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
 	"golang.org/x/arch/x86/x86asm"
 )
 
@@ -47,73 +48,93 @@ func (term *Inst) IsDummyTerm() bool {
 // Targets returns the targets of the given terminator instruction. Entry
 // denotes the entry address of the function containing the terminator
 // instruction.
-func (dis *Disasm) Targets(term *Inst, funcEntry bin.Address) []bin.Address {
+func (dis *Disasm) Targets(term *Inst, funcEntry bin.Address) ([]bin.Address, error) {
 	if term.IsDummyTerm() {
 		// Dummy terminator; fall through into the succeeding basic block, the
 		// address of which is denoted by term.Addr.
-		return []bin.Address{term.Addr}
+		return []bin.Address{term.Addr}, nil
 	}
 	next := term.Addr + bin.Address(term.Len)
 	switch term.Op {
 	// Loop terminators.
 	case x86asm.LOOP, x86asm.LOOPE, x86asm.LOOPNE:
-		targets := dis.Addrs(term.Args[0], term.Addr, next)
-		return append(targets, next)
+		targets, err := dis.Addrs(term.Args[0], term.Addr, next)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to locate targets of terminator at %v in function at %v", term.Addr, funcEntry)
+		}
+		return append(targets, next), nil
 	// Conditional jump terminators.
 	case x86asm.JA, x86asm.JAE, x86asm.JB, x86asm.JBE, x86asm.JCXZ, x86asm.JE, x86asm.JECXZ, x86asm.JG, x86asm.JGE, x86asm.JL, x86asm.JLE, x86asm.JNE, x86asm.JNO, x86asm.JNP, x86asm.JNS, x86asm.JO, x86asm.JP, x86asm.JRCXZ, x86asm.JS:
-		targets := dis.Addrs(term.Args[0], term.Addr, next)
-		return append(targets, next)
+		targets, err := dis.Addrs(term.Args[0], term.Addr, next)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to locate targets of terminator at %v in function at %v", term.Addr, funcEntry)
+		}
+		return append(targets, next), nil
 	// Unconditional jump terminators.
 	case x86asm.JMP:
-		preTargets := dis.Addrs(term.Args[0], term.Addr, next)
+		preTargets, err := dis.Addrs(term.Args[0], term.Addr, next)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to locate targets of terminator at %v in function at %v", term.Addr, funcEntry)
+		}
 		var targets []bin.Address
 		for _, target := range preTargets {
-			if dis.isTailCall(funcEntry, target) {
+			tailCall, err := dis.isTailCall(funcEntry, target)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid jump at %v", term.Addr)
+			}
+			if tailCall {
 				dbg.Printf("tail call at %v", term.Addr)
 			} else {
 				// Append target if not part of a tail call.
 				targets = append(targets, target)
 			}
 		}
-		return targets
+		return targets, nil
 	// Return terminators.
 	case x86asm.RET:
 		// no targets.
-		return nil
+		return nil, nil
 	}
-	panic(fmt.Errorf("support for terminator instruction %v not yet implemented", term.Op))
+	return nil, errors.Errorf("support for terminator instruction %v at %v in function at %v not yet implemented", term.Op, term.Addr, funcEntry)
 }
 
 // isTailCall reports whether the given JMP instruction is a tail call
 // instruction.
-func (dis *Disasm) isTailCall(funcEntry bin.Address, target bin.Address) bool {
-	funcEnd := dis.funcEnd(funcEntry)
+func (dis *Disasm) isTailCall(funcEntry bin.Address, target bin.Address) (bool, error) {
+	funcEnd, err := dis.funcEnd(funcEntry)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
 	if funcEntry <= target && target < funcEnd {
 		// Target inside function body.
-		return false
+		return false, nil
 	}
 	if parents, ok := dis.Chunks[target]; ok {
 		if parents[funcEntry] {
 			// Target part of function chunk.
-			return false
+			return false, nil
 		}
 	}
 	if !dis.IsFunc(target) {
-		panic(fmt.Errorf("tail call to non-function address %v from function at %v.\n\ttip: %v may be a function chunk of the parent function at %v\n\tadd to lst:  FUNCTION CHUNK AT .text:%08X\n\tadd to json: %q: %q,", target, funcEntry, target, funcEntry, uint64(target), target, funcEntry))
+		return false, errors.Errorf("tail call to non-function address %v from function at %v.\n\ttip: %v may be a function chunk of the parent function at %v\n\tadd to lst:  FUNCTION CHUNK AT .text:%08X\n\tadd to json: %q: %q,", target, funcEntry, target, funcEntry, uint64(target), target, funcEntry)
 	}
 	// Target is a tail call.
-	return true
+	return true, nil
 }
 
 // funcEnd returns the end address of the function, under the assumption that
 // the function is continuous.
-func (dis *Disasm) funcEnd(funcEntry bin.Address) bin.Address {
+func (dis *Disasm) funcEnd(funcEntry bin.Address) (bin.Address, error) {
 	less := func(i int) bool {
 		return funcEntry < dis.FuncAddrs[i]
 	}
 	index := sort.Search(len(dis.FuncAddrs), less)
 	if 0 <= index && index < len(dis.FuncAddrs) {
-		return dis.FuncAddrs[index]
+		return dis.FuncAddrs[index], nil
+	}
+	end, err := dis.codeEnd()
+	if err != nil {
+		return 0, errors.WithStack(err)
 	}
-	return dis.codeEnd()
+	return end, nil
 }
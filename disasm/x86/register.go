@@ -1,8 +1,7 @@
 package x86
 
 import (
-	"fmt"
-
+	"github.com/pkg/errors"
 	"golang.org/x/arch/x86/x86asm"
 )
 
@@ -26,7 +25,11 @@ func (reg Register) String() string {
 
 // Set sets reg to the register represented by s.
 func (reg *Register) Set(s string) error {
-	*reg = Register(parseReg(s))
+	r, err := parseReg(s)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*reg = Register(r)
 	return nil
 }
 
@@ -41,7 +44,7 @@ func (reg Register) MarshalText() ([]byte, error) {
 }
 
 // parseReg returns the x86 register corresponding to the given string.
-func parseReg(s string) x86asm.Reg {
+func parseReg(s string) (x86asm.Reg, error) {
 	m := map[string]x86asm.Reg{
 		// 8-bit
 		"AL":   x86asm.AL,
@@ -216,9 +219,9 @@ func parseReg(s string) x86asm.Reg {
 		"RDX:RAX": X86asm_RDX_RAX,
 	}
 	if reg, ok := m[s]; ok {
-		return reg
+		return reg, nil
 	}
-	panic(fmt.Errorf("support for register %q not yet implemented", s))
+	return 0, errors.Errorf("support for register %q not yet implemented", s)
 }
 
 // Registers.
@@ -0,0 +1,456 @@
+package x86
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/disasm"
+	"github.com/pkg/errors"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// A Proposal is a fragment proposed by linear sweep disassembly; either the
+// start of a function or a run of data.
+type Proposal struct {
+	// Start address of the proposed fragment.
+	Addr bin.Address `json:"addr"`
+	// Proposed fragment kind (code or data). Code fragments are proposed
+	// function starts.
+	Kind disasm.FragmentKind `json:"kind"`
+	// Confidence of the proposal, ranging from 0 (none) to 1 (certain).
+	Confidence float64 `json:"confidence"`
+	// Evidence supporting the proposal (e.g. "prologue" or "padding").
+	Reasons []string `json:"reasons"`
+}
+
+// Sweep locates functions and data in the gaps of executable sections not
+// covered by the instructions of known basic blocks, by linear sweep
+// disassembly. Sweep is intended to be used after Discover, to locate
+// functions not reachable from known functions (e.g. callbacks and virtual
+// methods), and takes the discovery returned by Discover.
+//
+// Alignment padding (e.g. INT3 and NOP runs) in the gaps is skipped. Decodable
+// instruction runs are proposed as function starts, with confidence based on
+// function prologue patterns, preceding alignment padding and references from
+// pointers of the binary executable. Undecodable runs are proposed as data.
+//
+// Proposals with a confidence of at least minConfidence are added to dis, after
+// which discovery is continued from the proposed functions, to locate the
+// functions and basic blocks reachable from them. All proposals are returned,
+// sorted by address.
+func (dis *Disasm) Sweep(d *Discovery, minConfidence float64) ([]*Proposal, error) {
+	refs := dis.pointerRefs()
+	gaps, err := dis.gaps()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var props []*Proposal
+	for _, gap := range gaps {
+		props = append(props, dis.sweepGap(gap, refs)...)
+	}
+	// Add proposals.
+	var funcAddrs []bin.Address
+	dataAddrs := make(map[bin.Address]bool)
+	for _, prop := range props {
+		if prop.Confidence < minConfidence {
+			continue
+		}
+		switch prop.Kind {
+		case disasm.KindCode:
+			dbg.Printf("function at %v proposed by linear sweep (confidence %.2f)", prop.Addr, prop.Confidence)
+			funcAddrs = append(funcAddrs, prop.Addr)
+		case disasm.KindData:
+			dataAddrs[prop.Addr] = true
+		}
+	}
+	dis.updateFrags(dataAddrs)
+	// Discover functions and basic blocks reachable from proposed functions.
+	if err := d.discover(funcAddrs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return props, nil
+}
+
+// Confidence of function start proposals.
+const (
+	// Decodable instruction run terminated by a jump or return.
+	confidenceDecodable = 0.1
+	// Start address follows alignment padding.
+	confidencePadding = 0.15
+	// Start address is aligned to 16 bytes.
+	confidenceAligned = 0.05
+	// Start address is referenced by a pointer of the binary executable.
+	confidencePointer = 0.3
+)
+
+// Confidence of data proposals.
+const (
+	// First instruction of run is not decodable.
+	confidenceUndecodable = 0.9
+	// Instruction of run is not decodable.
+	confidencePartial = 0.5
+)
+
+// A prologue is a byte pattern of a function prologue.
+type prologue struct {
+	// Processor mode of the prologue (16, 32 or 64); or 0 if valid in every
+	// mode.
+	mode int
+	// Byte pattern of the prologue.
+	pattern []byte
+	// Confidence of the prologue identifying a function start.
+	confidence float64
+	// Length in bytes of the prologue not supported by the x86 decoder (e.g.
+	// ENDBR64), and thus skipped when decoding.
+	skip int
+}
+
+// prologues specifies the function prologue patterns, ordered by decreasing
+// confidence.
+var prologues = []prologue{
+	// mov edi, edi; push ebp; mov ebp, esp (hot-patchable)
+	{mode: 32, pattern: []byte{0x8B, 0xFF, 0x55, 0x8B, 0xEC}, confidence: 0.6},
+	// push rbp; mov rbp, rsp
+	{mode: 64, pattern: []byte{0x55, 0x48, 0x89, 0xE5}, confidence: 0.6},
+	{mode: 64, pattern: []byte{0x55, 0x48, 0x8B, 0xEC}, confidence: 0.6},
+	// endbr64
+	{mode: 64, pattern: []byte{0xF3, 0x0F, 0x1E, 0xFA}, confidence: 0.6, skip: 4},
+	// endbr32
+	{mode: 32, pattern: []byte{0xF3, 0x0F, 0x1E, 0xFB}, confidence: 0.6, skip: 4},
+	// push ebp; mov ebp, esp
+	{pattern: []byte{0x55, 0x8B, 0xEC}, confidence: 0.5},
+	{pattern: []byte{0x55, 0x89, 0xE5}, confidence: 0.5},
+	// mov r11, rsp
+	{mode: 64, pattern: []byte{0x4C, 0x8B, 0xDC}, confidence: 0.5},
+	// mov [rsp+disp8], rbx/rcx/rdx (spill to home space)
+	{mode: 64, pattern: []byte{0x48, 0x89, 0x5C, 0x24}, confidence: 0.4},
+	{mode: 64, pattern: []byte{0x48, 0x89, 0x4C, 0x24}, confidence: 0.4},
+	{mode: 64, pattern: []byte{0x48, 0x89, 0x54, 0x24}, confidence: 0.4},
+	// sub rsp, imm
+	{mode: 64, pattern: []byte{0x48, 0x83, 0xEC}, confidence: 0.4},
+	{mode: 64, pattern: []byte{0x48, 0x81, 0xEC}, confidence: 0.4},
+	// push rbx/rbp/rsi/rdi (REX)
+	{mode: 64, pattern: []byte{0x40, 0x53}, confidence: 0.4},
+	{mode: 64, pattern: []byte{0x40, 0x55}, confidence: 0.4},
+	{mode: 64, pattern: []byte{0x40, 0x56}, confidence: 0.4},
+	{mode: 64, pattern: []byte{0x40, 0x57}, confidence: 0.4},
+	// push r12-r15
+	{mode: 64, pattern: []byte{0x41, 0x54}, confidence: 0.3},
+	{mode: 64, pattern: []byte{0x41, 0x55}, confidence: 0.3},
+	{mode: 64, pattern: []byte{0x41, 0x56}, confidence: 0.3},
+	{mode: 64, pattern: []byte{0x41, 0x57}, confidence: 0.3},
+	// enter imm16, 0
+	{mode: 16, pattern: []byte{0xC8}, confidence: 0.3},
+	// sub esp, imm
+	{mode: 32, pattern: []byte{0x83, 0xEC}, confidence: 0.3},
+	{mode: 32, pattern: []byte{0x81, 0xEC}, confidence: 0.3},
+	// push imm8; push imm32 (structured exception handling prologue)
+	{mode: 32, pattern: []byte{0x6A}, confidence: 0.2},
+	// push ebx/esi/edi
+	{mode: 32, pattern: []byte{0x53, 0x56, 0x57}, confidence: 0.2},
+	{mode: 32, pattern: []byte{0x56, 0x57}, confidence: 0.2},
+}
+
+// matchPrologue returns the function prologue at the start of the given code.
+// The boolean return value indicates success.
+func (dis *Disasm) matchPrologue(code []byte) (prologue, bool) {
+	for _, p := range prologues {
+		if p.mode != 0 && p.mode != dis.Mode {
+			continue
+		}
+		if bytes.HasPrefix(code, p.pattern) {
+			return p, true
+		}
+	}
+	return prologue{}, false
+}
+
+// An interval is a half-open address range [start, end).
+type interval struct {
+	// Start address of the interval.
+	start bin.Address
+	// End address of the interval.
+	end bin.Address
+}
+
+// gaps returns the address ranges of the executable sections not covered by
+// the instructions of known basic blocks, sorted by address.
+func (dis *Disasm) gaps() ([]interval, error) {
+	// Merge executable sections (and segments).
+	var code []interval
+	for _, sect := range dis.File.Sections {
+		if sect.Perm&bin.PermX == 0 || len(sect.Data) == 0 {
+			continue
+		}
+		code = append(code, interval{start: sect.Addr, end: sect.Addr + bin.Address(len(sect.Data))})
+	}
+	code = mergeIntervals(code)
+	// Merge instructions of known basic blocks.
+	var covered []interval
+	for _, blockAddr := range dis.BlockAddrs {
+		end, err := dis.blockEnd(blockAddr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if end > blockAddr {
+			covered = append(covered, interval{start: blockAddr, end: end})
+		}
+	}
+	covered = mergeIntervals(covered)
+	// Subtract covered intervals from executable intervals.
+	var gaps []interval
+	j := 0
+	for _, c := range code {
+		start := c.start
+		for ; j < len(covered) && covered[j].end <= start; j++ {
+		}
+		for k := j; k < len(covered) && covered[k].start < c.end; k++ {
+			if covered[k].start > start {
+				gaps = append(gaps, interval{start: start, end: covered[k].start})
+			}
+			if covered[k].end > start {
+				start = covered[k].end
+			}
+		}
+		if start < c.end {
+			gaps = append(gaps, interval{start: start, end: c.end})
+		}
+	}
+	return gaps, nil
+}
+
+// blockEnd returns the end address of the instructions of the basic block at
+// the given address; i.e. the address following its terminating instruction,
+// the first undecodable instruction or the end of the basic block as bounded
+// by the succeeding fragment.
+func (dis *Disasm) blockEnd(blockAddr bin.Address) (bin.Address, error) {
+	maxLen, err := dis.maxBlockLen(blockAddr)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	end := blockAddr + bin.Address(maxLen)
+	addr := blockAddr
+	for addr < end {
+		inst, err := dis.DecodeInst(addr)
+		if err != nil || inst.Op == 0 {
+			// Lone prefixes (e.g. of the ENDBR64 instruction, which is not
+			// supported by the x86 decoder) are decoded as instructions without
+			// opcode.
+			break
+		}
+		addr += bin.Address(inst.Len)
+		if inst.isTerm() {
+			break
+		}
+	}
+	return addr, nil
+}
+
+// sweepGap returns the function starts and data runs proposed by linear sweep
+// disassembly of the given gap. Refs specifies the set of addresses referenced
+// by pointers.
+func (dis *Disasm) sweepGap(gap interval, refs map[bin.Address]bool) []*Proposal {
+	var props []*Proposal
+	padded := false
+	for addr := gap.start; addr < gap.end; {
+		// Skip alignment padding.
+		if n := dis.paddingLen(addr, gap.end); n > 0 {
+			addr += bin.Address(n)
+			padded = true
+			continue
+		}
+		code, err := dis.File.CodeAt(addr)
+		if err != nil {
+			break
+		}
+		p, hasPrologue := dis.matchPrologue(code)
+		end, ok := dis.sweepRun(addr+bin.Address(p.skip), gap.end)
+		if !ok {
+			// Propose undecodable run as data.
+			confidence := confidencePartial
+			if end == addr+bin.Address(p.skip) {
+				confidence = confidenceUndecodable
+			}
+			dataEnd := dis.dataEnd(addr, gap.end)
+			prop := &Proposal{
+				Addr:       addr,
+				Kind:       disasm.KindData,
+				Confidence: confidence,
+				Reasons:    []string{"undecodable"},
+			}
+			props = append(props, prop)
+			addr = dataEnd
+			padded = false
+			continue
+		}
+		// Propose decodable run as function start.
+		prop := &Proposal{
+			Addr:       addr,
+			Kind:       disasm.KindCode,
+			Confidence: confidenceDecodable,
+			Reasons:    []string{"decodable"},
+		}
+		if hasPrologue {
+			prop.Confidence += p.confidence
+			prop.Reasons = append(prop.Reasons, "prologue")
+		}
+		if padded {
+			prop.Confidence += confidencePadding
+			prop.Reasons = append(prop.Reasons, "padding")
+		}
+		if addr%16 == 0 {
+			prop.Confidence += confidenceAligned
+			prop.Reasons = append(prop.Reasons, "aligned")
+		}
+		if refs[addr] {
+			prop.Confidence += confidencePointer
+			prop.Reasons = append(prop.Reasons, "pointer")
+		}
+		if prop.Confidence > 1 {
+			prop.Confidence = 1
+		}
+		props = append(props, prop)
+		addr = end
+		padded = false
+	}
+	return props
+}
+
+// sweepRun decodes the instructions starting at the given address, up to and
+// including the first unconditional jump or return, or up to the end address.
+// The boolean return value indicates whether every instruction was decodable;
+// if not, the address of the first undecodable instruction is returned.
+func (dis *Disasm) sweepRun(addr, end bin.Address) (bin.Address, bool) {
+	for addr < end {
+		inst, err := dis.DecodeInst(addr)
+		if err != nil || inst.Op == 0 || addr+bin.Address(inst.Len) > end {
+			return addr, false
+		}
+		addr += bin.Address(inst.Len)
+		switch inst.Op {
+		case x86asm.JMP, x86asm.RET:
+			return addr, true
+		}
+	}
+	// Run falls through into known code.
+	return addr, true
+}
+
+// dataEnd returns the end address of the data run starting at the given
+// address; i.e. the address of the succeeding alignment padding or 16-byte
+// aligned function prologue, or the end address of the gap.
+func (dis *Disasm) dataEnd(addr, end bin.Address) bin.Address {
+	for addr++; addr < end; addr++ {
+		if dis.paddingLen(addr, end) > 1 {
+			return addr
+		}
+		if addr%16 == 0 {
+			if code, err := dis.File.CodeAt(addr); err == nil {
+				if _, ok := dis.matchPrologue(code); ok {
+					return addr
+				}
+			}
+		}
+	}
+	return end
+}
+
+// paddingLen returns the length in bytes of the alignment padding (e.g. INT3
+// and NOP instructions) starting at the given address, bounded by the end
+// address.
+func (dis *Disasm) paddingLen(addr, end bin.Address) int {
+	n := 0
+	for addr < end {
+		inst, err := dis.DecodeInst(addr)
+		if err != nil || !dis.isPadding(inst) || addr+bin.Address(inst.Len) > end {
+			break
+		}
+		addr += bin.Address(inst.Len)
+		n += inst.Len
+	}
+	return n
+}
+
+// isPadding reports whether the given instruction is used for alignment
+// padding.
+func (dis *Disasm) isPadding(inst *Inst) bool {
+	switch inst.Op {
+	case x86asm.NOP:
+		return true
+	case x86asm.INT:
+		// INT3
+		return inst.Args[0] == x86asm.Imm(3)
+	case x86asm.LEA, x86asm.MOV:
+		// lea esi, [esi+0] and mov esi, esi of 32-bit code.
+		if dis.Mode != 32 {
+			return false
+		}
+		dst, ok := inst.Args[0].(x86asm.Reg)
+		if !ok {
+			return false
+		}
+		switch src := inst.Args[1].(type) {
+		case x86asm.Reg:
+			return inst.Op == x86asm.MOV && src == dst
+		case x86asm.Mem:
+			return inst.Op == x86asm.LEA && src.Base == dst && src.Index == 0 && src.Disp == 0 && src.Segment == 0
+		}
+	}
+	return false
+}
+
+// pointerRefs returns the set of addresses within executable sections
+// referenced by pointer-sized values at pointer-aligned addresses of
+// non-executable sections, or at relocated addresses.
+func (dis *Disasm) pointerRefs() map[bin.Address]bool {
+	refs := make(map[bin.Address]bool)
+	if dis.Mode == 16 {
+		// Far pointers not yet supported.
+		return refs
+	}
+	ptrSize := bin.Address(dis.Mode / 8)
+	add := func(addr bin.Address) {
+		v, _, err := dis.File.Uintptr(addr)
+		if err != nil {
+			return
+		}
+		if target := bin.Address(v); dis.isCode(target) {
+			refs[target] = true
+		}
+	}
+	for addr, kind := range dis.File.Relocs {
+		if size, err := kind.Size(); err == nil && bin.Address(size) == ptrSize {
+			add(addr)
+		}
+	}
+	for _, sect := range dis.File.Sections {
+		if sect.Perm == 0 || sect.Perm&bin.PermX != 0 {
+			continue
+		}
+		start := (sect.Addr + ptrSize - 1) &^ (ptrSize - 1)
+		end := sect.Addr + bin.Address(len(sect.Data))
+		for addr := start; addr+ptrSize <= end; addr += ptrSize {
+			add(addr)
+		}
+	}
+	return refs
+}
+
+// mergeIntervals returns the union of the given intervals, sorted by address.
+func mergeIntervals(intervals []interval) []interval {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start < intervals[j].start
+	})
+	var merged []interval
+	for _, v := range intervals {
+		if n := len(merged); n > 0 && v.start <= merged[n-1].end {
+			if v.end > merged[n-1].end {
+				merged[n-1].end = v.end
+			}
+			continue
+		}
+		merged = append(merged, v)
+	}
+	return merged
+}
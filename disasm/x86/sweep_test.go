@@ -0,0 +1,91 @@
+package x86
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/disasm"
+)
+
+func TestSweep(t *testing.T) {
+	//    0x1000  ret
+	//    0x1001  int3 (x15)
+	//    0x1010  push ebp      ; not reachable from entry point
+	//    0x1011  mov  ebp, esp
+	//    0x1013  call 0x1020
+	//    0x1018  pop  ebp
+	//    0x1019  ret
+	//    0x101A  int3 (x6)
+	//    0x1020  xor  eax, eax
+	//    0x1022  ret
+	//    0x1023  db   0xFF, 0xFF
+	code := []byte{0xC3}
+	code = append(code, bytes.Repeat([]byte{0xCC}, 15)...)
+	code = append(code,
+		0x55,
+		0x89, 0xE5,
+		0xE8, 0x08, 0x00, 0x00, 0x00,
+		0x5D,
+		0xC3,
+	)
+	code = append(code, bytes.Repeat([]byte{0xCC}, 6)...)
+	code = append(code,
+		0x31, 0xC0,
+		0xC3,
+		0xFF, 0xFF,
+	)
+	dis := newTestDisasm(t, 0x1000, code)
+	d, err := dis.Discover()
+	if err != nil {
+		t.Fatalf("unable to discover functions; %+v", err)
+	}
+	props, err := dis.Sweep(d, 0.5)
+	if err != nil {
+		t.Fatalf("unable to sweep gaps; %+v", err)
+	}
+	want := []*Proposal{
+		{
+			Addr:       0x1010,
+			Kind:       disasm.KindCode,
+			Confidence: confidenceDecodable + 0.5 + confidencePadding + confidenceAligned,
+			Reasons:    []string{"decodable", "prologue", "padding", "aligned"},
+		},
+		{
+			Addr:       0x1020,
+			Kind:       disasm.KindCode,
+			Confidence: confidenceDecodable + confidencePadding + confidenceAligned,
+			Reasons:    []string{"decodable", "padding", "aligned"},
+		},
+		{
+			Addr:       0x1023,
+			Kind:       disasm.KindData,
+			Confidence: confidenceUndecodable,
+			Reasons:    []string{"undecodable"},
+		},
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("proposals mismatch; expected %v, got %v", want, props)
+	}
+	// The function at 0x1020 is below the minimum confidence, but discovered by
+	// the call of the proposed function at 0x1010.
+	wantFuncs := []bin.Address{0x1000, 0x1010, 0x1020}
+	if !reflect.DeepEqual(dis.FuncAddrs, wantFuncs) {
+		t.Errorf("function addresses mismatch; expected %v, got %v", wantFuncs, dis.FuncAddrs)
+	}
+	wantBlocks := []bin.Address{0x1000, 0x1010, 0x1020}
+	if !reflect.DeepEqual(dis.BlockAddrs, wantBlocks) {
+		t.Errorf("basic block addresses mismatch; expected %v, got %v", wantBlocks, dis.BlockAddrs)
+	}
+	var dataAddrs []bin.Address
+	for _, frag := range dis.Frags {
+		if frag.Kind == disasm.KindData {
+			dataAddrs = append(dataAddrs, frag.Addr)
+		}
+	}
+	wantData := []bin.Address{0x1001, 0x101A, 0x1023}
+	if !reflect.DeepEqual(dataAddrs, wantData) {
+		t.Errorf("data addresses mismatch; expected %v, got %v", wantData, dataAddrs)
+	}
+}
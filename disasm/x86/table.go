@@ -0,0 +1,681 @@
+package x86
+
+import (
+	"sort"
+
+	"github.com/decomp/exp/bin"
+	"github.com/pkg/errors"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// maxTableLen specifies the maximum number of entries of recovered jump tables.
+const maxTableLen = 4096
+
+// A JumpTable is a jump table of an indirect jump instruction (e.g. switch
+// statement).
+type JumpTable struct {
+	// Address of the indirect jump instruction.
+	Jump bin.Address `json:"jump"`
+	// Address of the jump table.
+	Addr bin.Address `json:"addr"`
+	// Targets of the jump table, indexed by jump table entry.
+	Targets []bin.Address `json:"targets"`
+	// Address of the byte index table of two-level jump tables, which maps from
+	// case index to jump table entry; or 0 if not present.
+	IndexAddr bin.Address `json:"index_addr,omitempty"`
+	// Jump table entries of the byte index table, indexed by case index.
+	Index []uint8 `json:"index,omitempty"`
+	// Default target of the bounds check, taken if the case index is out of
+	// bounds; or 0 if the case index is masked rather than bounds checked (e.g.
+	// and eax, 3).
+	Default bin.Address `json:"default,omitempty"`
+}
+
+// JumpTable recovers the jump table of the indirect jump instruction at the
+// given address, using the known basic block addresses to locate the
+// instructions preceding the jump.
+//
+// Jump tables are recovered by backward slicing from the indirect jump to the
+// bounds check of the case index (e.g. cmp eax, 7; ja default) or mask of the
+// case index (e.g. and eax, 3), and support
+// absolute jump tables (e.g. jmp [eax*4+table]), relative jump tables of x86-64
+// (e.g. movsxd rax, [rdx+rax*4]; add rax, rdx; jmp rax) and two-level jump
+// tables with byte index tables (e.g. movzx eax, byte [eax+index]). The bounds
+// check may be located in a predecessor basic block, which either falls
+// through or conditionally jumps into the basic block of the indirect jump.
+// Jump tables present in dis.Tables take precedence over the jump table entries
+// read from the binary executable.
+//
+// Recovered jump tables are recorded in dis.JumpTables and dis.Tables, and
+// reused by subsequent invocations.
+func (dis *Disasm) JumpTable(addr bin.Address) (*JumpTable, error) {
+	// Locate basic block containing the jump.
+	less := func(i int) bool {
+		return addr < dis.BlockAddrs[i]
+	}
+	index := sort.Search(len(dis.BlockAddrs), less) - 1
+	if index < 0 {
+		return nil, errors.Errorf("unable to locate basic block of indirect jump at %v", addr)
+	}
+	blockAddr := dis.BlockAddrs[index]
+	dis.tablesMu.Lock()
+	defer dis.tablesMu.Unlock()
+	if t, ok := dis.JumpTables[addr]; ok {
+		return t, nil
+	}
+	preds := dis.blockPreds()[blockAddr]
+	return dis.recordTable(preds, blockAddr, addr)
+}
+
+// lookupTable returns the jump table of the indirect jump instruction at the
+// given address of the basic block at blockAddr, recovering it if not yet
+// known. Preds specifies the addresses of the predecessor basic blocks of
+// blockAddr.
+func (dis *Disasm) lookupTable(preds []bin.Address, blockAddr, jumpAddr bin.Address) (*JumpTable, error) {
+	dis.tablesMu.Lock()
+	defer dis.tablesMu.Unlock()
+	if t, ok := dis.JumpTables[jumpAddr]; ok {
+		return t, nil
+	}
+	return dis.recordTable(preds, blockAddr, jumpAddr)
+}
+
+// recordTable recovers the jump table of the indirect jump instruction at the
+// given address, and records it in dis.JumpTables and dis.Tables.
+//
+// pre-condition: dis.tablesMu is locked.
+func (dis *Disasm) recordTable(preds []bin.Address, blockAddr, jumpAddr bin.Address) (*JumpTable, error) {
+	t, err := dis.recoverTable(preds, blockAddr, jumpAddr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dis.JumpTables[jumpAddr] = t
+	if _, ok := dis.Tables[t.Addr]; !ok {
+		dis.Tables[t.Addr] = t.Targets
+	}
+	return t, nil
+}
+
+// blockPreds returns the map from basic block address to the addresses of its
+// predecessor basic blocks, which either fall through or jump into the basic
+// block. The map is computed from the known basic block addresses on first use.
+//
+// pre-condition: dis.tablesMu is locked.
+func (dis *Disasm) blockPreds() map[bin.Address][]bin.Address {
+	if dis.preds != nil {
+		return dis.preds
+	}
+	dis.preds = make(map[bin.Address][]bin.Address)
+	for i, blockAddr := range dis.BlockAddrs {
+		var end bin.Address
+		if i+1 < len(dis.BlockAddrs) {
+			end = dis.BlockAddrs[i+1]
+		}
+		for addr := blockAddr; ; {
+			if addr == end {
+				// Fall through into succeeding basic block.
+				dis.preds[end] = append(dis.preds[end], blockAddr)
+				break
+			}
+			inst, err := dis.DecodeInst(addr)
+			if err != nil {
+				break
+			}
+			next := addr + bin.Address(inst.Len)
+			if end != 0 && next > end {
+				break
+			}
+			if inst.isTerm() {
+				if target, ok := dis.relTarget(inst); ok {
+					dis.preds[target] = append(dis.preds[target], blockAddr)
+				}
+				switch inst.Op {
+				case x86asm.JMP, x86asm.RET:
+				default:
+					// Fall through into succeeding basic block of conditional
+					// jumps and loops.
+					dis.preds[next] = append(dis.preds[next], blockAddr)
+				}
+				break
+			}
+			addr = next
+		}
+	}
+	return dis.preds
+}
+
+// resetPreds invalidates the predecessor basic blocks computed by blockPreds;
+// e.g. after adding basic blocks.
+func (dis *Disasm) resetPreds() {
+	dis.tablesMu.Lock()
+	dis.preds = nil
+	dis.tablesMu.Unlock()
+}
+
+// recoverTable recovers the jump table of the indirect jump instruction at the
+// given address, by backward slicing the instructions of the basic block at
+// blockAddr and of a predecessor basic block (if any) which either falls
+// through or jumps into blockAddr. Each predecessor is tried in turn, until the
+// jump table is recovered; the error of the first predecessor is reported
+// otherwise.
+func (dis *Disasm) recoverTable(preds []bin.Address, blockAddr, jumpAddr bin.Address) (*JumpTable, error) {
+	var firstErr error
+	candidates := append(append([]bin.Address(nil), preds...), 0)
+	for _, predAddr := range candidates {
+		insts, err := dis.sliceInsts(predAddr, blockAddr, jumpAddr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s := &slicer{dis: dis, block: blockAddr, insts: insts}
+		t, err := s.recoverTable()
+		if err == nil {
+			t.Jump = jumpAddr
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, errors.Wrapf(firstErr, "unable to recover jump table of indirect jump at %v", jumpAddr)
+}
+
+// sliceInsts returns the instructions from the basic block address up to and
+// including the instruction at jumpAddr, preceded by the instructions of the
+// basic block at predAddr if it falls through or jumps into the basic block at
+// blockAddr.
+func (dis *Disasm) sliceInsts(predAddr, blockAddr, jumpAddr bin.Address) ([]*Inst, error) {
+	var insts []*Inst
+	if predAddr != 0 {
+		if pred, ok := dis.predInsts(predAddr, blockAddr); ok {
+			insts = append(insts, pred...)
+		}
+	}
+	for addr := blockAddr; addr <= jumpAddr; {
+		inst, err := dis.DecodeInst(addr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		insts = append(insts, inst)
+		addr += bin.Address(inst.Len)
+	}
+	if last := insts[len(insts)-1]; last.Addr != jumpAddr {
+		return nil, errors.Errorf("unable to locate indirect jump at %v in basic block at %v", jumpAddr, blockAddr)
+	}
+	return insts, nil
+}
+
+// predInsts returns the instructions of the basic block at predAddr, if it
+// falls through or jumps into the basic block at blockAddr; i.e. the
+// instructions up to and including the jump to blockAddr, or up to blockAddr if
+// falling through. The boolean return value indicates success.
+func (dis *Disasm) predInsts(predAddr, blockAddr bin.Address) ([]*Inst, bool) {
+	var insts []*Inst
+	for addr := predAddr; addr != blockAddr; {
+		inst, err := dis.DecodeInst(addr)
+		if err != nil {
+			return nil, false
+		}
+		insts = append(insts, inst)
+		next := addr + bin.Address(inst.Len)
+		if inst.isTerm() {
+			if target, ok := dis.relTarget(inst); ok && target == blockAddr {
+				return insts, true
+			}
+			switch inst.Op {
+			case x86asm.JMP, x86asm.RET:
+				return nil, false
+			}
+			// Fall through of conditional jump.
+			return insts, next == blockAddr
+		}
+		if predAddr < blockAddr && next > blockAddr {
+			return nil, false
+		}
+		addr = next
+	}
+	return insts, true
+}
+
+// A slicer tracks information required for backward slicing of the
+// instructions preceding an indirect jump.
+type slicer struct {
+	// Disassembler.
+	dis *Disasm
+	// Address of the basic block of the indirect jump.
+	block bin.Address
+	// Instructions preceding and including the indirect jump.
+	insts []*Inst
+}
+
+// recoverTable recovers the jump table of the indirect jump terminating the
+// instructions of the slicer.
+func (s *slicer) recoverTable() (*JumpTable, error) {
+	dis := s.dis
+	// Locate the instruction reading the jump table entry.
+	load, loadIndex, rel, err := s.locateLoad()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mem := s.insts[loadIndex].Args[load].(x86asm.Mem)
+	entrySize := s.insts[loadIndex].MemBytes
+	tableAddr, idx, scale, from, err := s.tableRef(mem, loadIndex)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if scale != entrySize {
+		return nil, errors.Errorf("jump table entry size (%d) and index scale (%d) mismatch", entrySize, scale)
+	}
+	// Locate bounds check of case index.
+	t := &JumpTable{
+		Addr: tableAddr,
+	}
+	n, err := s.locateBound(idx, from, t)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Read byte index table of two-level jump tables.
+	if t.IndexAddr != 0 {
+		for i := 0; i < n; i++ {
+			v, err := dis.File.Uint8(t.IndexAddr + bin.Address(i))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			t.Index = append(t.Index, v)
+		}
+		n = 0
+		for _, v := range t.Index {
+			if int(v) >= n {
+				n = int(v) + 1
+			}
+		}
+	}
+	// Jump tables of the disassembler take precedence.
+	if targets, ok := dis.Tables[tableAddr]; ok {
+		t.Targets = targets
+		return t, nil
+	}
+	// Read jump table entries.
+	for i := 0; i < n; i++ {
+		entryAddr := tableAddr + bin.Address(i*entrySize)
+		var target bin.Address
+		switch entrySize {
+		case 4:
+			v, err := dis.File.Uint32(entryAddr)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			switch {
+			case !rel.present:
+				target = bin.Address(v)
+			case rel.signed:
+				target = rel.base + bin.Address(int32(v))
+			default:
+				target = rel.base + bin.Address(v)
+			}
+		case 8:
+			v, err := dis.File.Uint64(entryAddr)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			target = rel.base + bin.Address(v)
+		default:
+			return nil, errors.Errorf("support for jump table entry size %d not yet implemented", entrySize)
+		}
+		if !dis.isCode(target) {
+			return nil, errors.Errorf("invalid target %v of jump table entry at %v; not located in executable section", target, entryAddr)
+		}
+		t.Targets = append(t.Targets, target)
+	}
+	return t, nil
+}
+
+// relative specifies how jump table entries relate to target addresses.
+type relative struct {
+	// Specifies whether jump table entries are relative to base.
+	present bool
+	// Base address of relative jump table entries (e.g. address of jump table or
+	// image base).
+	base bin.Address
+	// Specifies whether relative jump table entries are sign-extended.
+	signed bool
+}
+
+// locateLoad locates the instruction reading the jump table entry used as
+// target by the indirect jump. The operand index of the memory reference and
+// the instruction index of the load are returned, as well as how jump table
+// entries relate to target addresses.
+func (s *slicer) locateLoad() (load, loadIndex int, rel relative, err error) {
+	jmpIndex := len(s.insts) - 1
+	jmp := s.insts[jmpIndex]
+	switch arg := jmp.Args[0].(type) {
+	case x86asm.Mem:
+		// jmp [table+idx*4]
+		return 0, jmpIndex, rel, nil
+	case x86asm.Reg:
+		// mov eax, [table+idx*4]; jmp eax
+		//
+		// movsxd rax, [rdx+idx*4]; add rax, rdx; jmp rax
+		reg := arg
+		for i := jmpIndex - 1; i >= 0; i-- {
+			inst := s.insts[i]
+			if !writes(inst, reg) {
+				continue
+			}
+			switch inst.Op {
+			case x86asm.ADD:
+				src, ok := inst.Args[1].(x86asm.Reg)
+				if !ok || rel.present {
+					return 0, 0, rel, errors.Errorf("unsupported instruction %v at %v in backward slice of target register %v", inst, inst.Addr, arg)
+				}
+				base, ok := s.regValue(src, i)
+				if !ok {
+					return 0, 0, rel, errors.Errorf("unable to locate value of register %v at %v", src, inst.Addr)
+				}
+				rel.present = true
+				rel.base = base
+			case x86asm.CDQE:
+				rel.signed = true
+			case x86asm.MOV, x86asm.MOVSXD:
+				if inst.Op == x86asm.MOVSXD {
+					rel.signed = true
+				}
+				switch src := inst.Args[1].(type) {
+				case x86asm.Mem:
+					if src.Index == 0 {
+						return 0, 0, rel, errors.Errorf("target register %v loaded from function pointer %v at %v", arg, src, inst.Addr)
+					}
+					return 1, i, rel, nil
+				case x86asm.Reg:
+					reg = src
+				default:
+					return 0, 0, rel, errors.Errorf("unsupported instruction %v at %v in backward slice of target register %v", inst, inst.Addr, arg)
+				}
+			default:
+				return 0, 0, rel, errors.Errorf("unsupported instruction %v at %v in backward slice of target register %v", inst, inst.Addr, arg)
+			}
+		}
+		return 0, 0, rel, errors.Errorf("unable to locate jump table read of target register %v", arg)
+	default:
+		return 0, 0, rel, errors.Errorf("support for indirect jump argument type %T not yet implemented", arg)
+	}
+}
+
+// tableRef returns the table address and case index register of the given
+// memory reference of the instruction at index i, as well as the scale of the
+// case index. The index of the instruction from which to continue backward
+// slicing of the case index is returned as well; e.g. the instruction
+// pre-scaling the case index.
+func (s *slicer) tableRef(mem x86asm.Mem, i int) (tableAddr bin.Address, idx x86asm.Reg, scale, from int, err error) {
+	if mem.Segment != 0 {
+		return 0, 0, 0, 0, errors.Errorf("support for jump table memory reference with segment register %v not yet implemented", mem.Segment)
+	}
+	disp := bin.Address(mem.Disp)
+	idx, scale = mem.Index, int(mem.Scale)
+	switch {
+	case mem.Base == 0:
+		// [table+idx*4]
+		tableAddr = disp
+	default:
+		// [rdx+idx*4], where rdx holds the table address.
+		if base, ok := s.regValue(mem.Base, i); ok {
+			tableAddr = base + disp
+			break
+		}
+		// [idx+table]
+		if mem.Index == 0 {
+			tableAddr = disp
+			idx, scale = mem.Base, 1
+			break
+		}
+		// [rdx+rax], where rax holds the table address.
+		base, ok := s.regValue(mem.Index, i)
+		if !ok || scale != 1 {
+			return 0, 0, 0, 0, errors.Errorf("unable to locate table address of memory reference %v at %v", mem, s.insts[i].Addr)
+		}
+		tableAddr = base + disp
+		idx = mem.Base
+	}
+	if idx == 0 {
+		return 0, 0, 0, 0, errors.Errorf("unable to locate case index register of memory reference %v at %v", mem, s.insts[i].Addr)
+	}
+	// Locate pre-scaled case index; e.g. lea rdx, [rax*4].
+	if scale == 1 {
+		for j := i - 1; j >= 0; j-- {
+			inst := s.insts[j]
+			if !writes(inst, idx) {
+				continue
+			}
+			if inst.Op == x86asm.LEA {
+				src := inst.Args[1].(x86asm.Mem)
+				if src.Base == 0 && src.Disp == 0 && src.Index != 0 {
+					return tableAddr, src.Index, int(src.Scale), j, nil
+				}
+			}
+			break
+		}
+	}
+	return tableAddr, idx, scale, i, nil
+}
+
+// locateBound locates the bounds check or mask of the given case index
+// register, by backward slicing from the instruction at index i. The number of
+// case indices is returned, and the default target and byte index table (if
+// any) are recorded in t.
+func (s *slicer) locateBound(idx x86asm.Reg, i int, t *JumpTable) (int, error) {
+	var tracked x86asm.Arg = idx
+	for j := i - 1; j >= 0; j-- {
+		inst := s.insts[j]
+		if inst.Op == x86asm.CMP && sameArg(inst.Args[0], tracked) {
+			switch bound := inst.Args[1].(type) {
+			case x86asm.Imm:
+				// cmp eax, 7
+				return s.boundJump(j, int64(bound), t)
+			case x86asm.Reg:
+				// push 7; pop edx; cmp eax, edx
+				if v, ok := s.regValue(bound, j); ok {
+					return s.boundJump(j, int64(v), t)
+				}
+			}
+			return 0, errors.Errorf("unsupported bounds check %v at %v", inst, inst.Addr)
+		}
+		if inst.Op == x86asm.AND && sameArg(inst.Args[0], tracked) {
+			mask, ok := inst.Args[1].(x86asm.Imm)
+			if !ok || mask < 0 || mask >= maxTableLen {
+				return 0, errors.Errorf("unsupported case index mask %v at %v", inst, inst.Addr)
+			}
+			return int(mask) + 1, nil
+		}
+		if !writes(inst, tracked) {
+			continue
+		}
+		switch inst.Op {
+		case x86asm.MOV, x86asm.MOVZX, x86asm.MOVSXD, x86asm.MOVSX:
+			switch src := inst.Args[1].(type) {
+			case x86asm.Reg:
+				// mov edi, edi
+				tracked = src
+				continue
+			case x86asm.Mem:
+				// movzx eax, byte [eax+index]
+				if inst.Op == x86asm.MOVZX && inst.MemBytes == 1 && t.IndexAddr == 0 {
+					indexAddr, idx2, scale, from, err := s.tableRef(src, j)
+					if _, ok := s.dis.File.FindSection(indexAddr); err == nil && ok && scale == 1 {
+						t.IndexAddr = indexAddr
+						tracked = idx2
+						j = from
+						continue
+					}
+				}
+				if src.Index == 0 {
+					// mov eax, [rbp-4]
+					tracked = src
+					continue
+				}
+			}
+		case x86asm.CDQE:
+			continue
+		}
+		return 0, errors.Errorf("unsupported instruction %v at %v in backward slice of case index %v", inst, inst.Addr, tracked)
+	}
+	return 0, errors.Errorf("unable to locate bounds check of case index %v", tracked)
+}
+
+// boundJump returns the number of case indices of the bounds check at index i,
+// comparing the case index against the given bound. The default target of the
+// bounds check is recorded in t.
+//
+// Only unsigned bounds checks are supported, as signed comparisons (e.g. jg
+// default) do not bound negative case indices.
+func (s *slicer) boundJump(i int, bound int64, t *JumpTable) (int, error) {
+	cmp := s.insts[i]
+	for _, inst := range s.insts[i+1:] {
+		var (
+			n int64
+			// Specifies whether the conditional jump is taken for case indices
+			// within bounds.
+			inBounds bool
+		)
+		switch inst.Op {
+		case x86asm.JA:
+			// case index > bound
+			n = bound + 1
+		case x86asm.JAE:
+			// case index >= bound
+			n = bound
+		case x86asm.JBE:
+			// case index <= bound
+			n = bound + 1
+			inBounds = true
+		case x86asm.JB:
+			// case index < bound
+			n = bound
+			inBounds = true
+		case x86asm.JG, x86asm.JGE, x86asm.JL, x86asm.JLE:
+			return 0, errors.Errorf("unsupported signed bounds check %v at %v of case index compared at %v", inst, inst.Addr, cmp.Addr)
+		case x86asm.JCXZ, x86asm.JE, x86asm.JECXZ, x86asm.JNE, x86asm.JNO, x86asm.JNP, x86asm.JNS, x86asm.JO, x86asm.JP, x86asm.JRCXZ, x86asm.JS:
+			return 0, errors.Errorf("unsupported conditional jump %v at %v of bounds check at %v", inst, inst.Addr, cmp.Addr)
+		default:
+			continue
+		}
+		if n <= 0 || n > maxTableLen {
+			return 0, errors.Errorf("invalid number of case indices (%d) of bounds check at %v", n, cmp.Addr)
+		}
+		target, ok := s.dis.relTarget(inst)
+		if !ok {
+			return 0, errors.Errorf("unable to locate default target of bounds check at %v", cmp.Addr)
+		}
+		next := inst.Addr + bin.Address(inst.Len)
+		if inBounds {
+			// cmp eax, 7; jbe block; default: ...
+			if target != s.block {
+				return 0, errors.Errorf("conditional jump %v at %v of bounds check at %v does not jump to basic block at %v", inst, inst.Addr, cmp.Addr, s.block)
+			}
+			t.Default = next
+		} else {
+			// cmp eax, 7; ja default
+			if target == s.block {
+				return 0, errors.Errorf("conditional jump %v at %v of bounds check at %v jumps to basic block at %v for case indices out of bounds", inst, inst.Addr, cmp.Addr, s.block)
+			}
+			t.Default = target
+		}
+		return int(n), nil
+	}
+	return 0, errors.Errorf("unable to locate conditional jump of bounds check at %v", cmp.Addr)
+}
+
+// regValue returns the static value of the given register before the
+// instruction at index i; e.g. lea rdx, [rip+table] or push 7; pop edx. The
+// boolean return value indicates success.
+func (s *slicer) regValue(reg x86asm.Reg, i int) (bin.Address, bool) {
+	for j := i - 1; j >= 0; j-- {
+		inst := s.insts[j]
+		if !writes(inst, reg) {
+			continue
+		}
+		switch inst.Op {
+		case x86asm.LEA:
+			return s.dis.memAddr(inst.Args[1].(x86asm.Mem), inst)
+		case x86asm.MOV:
+			if v, ok := inst.Args[1].(x86asm.Imm); ok {
+				return bin.Address(v), true
+			}
+		case x86asm.POP:
+			// Locate matching push.
+			for k := j - 1; k >= 0; k-- {
+				prev := s.insts[k]
+				if prev.Op == x86asm.PUSH {
+					if v, ok := prev.Args[0].(x86asm.Imm); ok {
+						return bin.Address(v), true
+					}
+					break
+				}
+				if prev.Op == x86asm.POP || writes(prev, x86asm.RSP) {
+					break
+				}
+			}
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// ### [ Helper functions ] ####################################################
+
+// writes reports whether the given instruction writes to the given register
+// (or any register overlapping with it; e.g. EAX and RAX) or memory reference.
+func writes(inst *Inst, arg x86asm.Arg) bool {
+	switch inst.Op {
+	case x86asm.CMP, x86asm.TEST, x86asm.PUSH, x86asm.NOP:
+		return false
+	case x86asm.CALL:
+		// Conservatively assume that calls clobber all registers and memory.
+		return true
+	case x86asm.CDQE, x86asm.CWDE, x86asm.CBW:
+		return sameArg(x86asm.RAX, arg)
+	case x86asm.CDQ, x86asm.CQO, x86asm.CWD:
+		return sameArg(x86asm.RDX, arg)
+	case x86asm.MUL, x86asm.DIV, x86asm.IDIV:
+		return sameArg(x86asm.RAX, arg) || sameArg(x86asm.RDX, arg)
+	case x86asm.XCHG:
+		return sameArg(inst.Args[0], arg) || sameArg(inst.Args[1], arg)
+	}
+	if inst.isTerm() {
+		return false
+	}
+	return sameArg(inst.Args[0], arg)
+}
+
+// sameArg reports whether the given arguments refer to the same register
+// (including overlapping registers; e.g. EAX and RAX) or memory reference.
+func sameArg(a, b x86asm.Arg) bool {
+	switch a := a.(type) {
+	case x86asm.Reg:
+		if b, ok := b.(x86asm.Reg); ok {
+			return regFamily(a) == regFamily(b)
+		}
+	case x86asm.Mem:
+		if b, ok := b.(x86asm.Mem); ok {
+			return a == b
+		}
+	}
+	return false
+}
+
+// regFamily returns the 64-bit general purpose register overlapping with the
+// given register (e.g. RAX for AL, AH, AX and EAX), or the register itself if
+// not a general purpose register.
+func regFamily(reg x86asm.Reg) x86asm.Reg {
+	switch {
+	case x86asm.AL <= reg && reg <= x86asm.BL:
+		return x86asm.RAX + (reg - x86asm.AL)
+	case x86asm.AH <= reg && reg <= x86asm.BH:
+		return x86asm.RAX + (reg - x86asm.AH)
+	case x86asm.SPB <= reg && reg <= x86asm.R15B:
+		return x86asm.RSP + (reg - x86asm.SPB)
+	case x86asm.AX <= reg && reg <= x86asm.R15W:
+		return x86asm.RAX + (reg - x86asm.AX)
+	case x86asm.EAX <= reg && reg <= x86asm.R15L:
+		return x86asm.RAX + (reg - x86asm.EAX)
+	}
+	return reg
+}
@@ -0,0 +1,224 @@
+package x86
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/bin/elf"
+	"github.com/mewkiz/pkg/jsonutil"
+)
+
+func TestJumpTable(t *testing.T) {
+	golden := []struct {
+		name string
+		// x86_32 code located at 0x1000.
+		code []byte
+		// Basic block addresses.
+		blocks []bin.Address
+		// Address of indirect jump.
+		jump bin.Address
+		// Expected jump table.
+		want *JumpTable
+		// Expected error; or empty if valid.
+		err string
+	}{
+		{
+			//    0x1000  cmp eax, 3
+			//    0x1003  ja  0x1010
+			//    0x1005  jmp [eax*4+0x2000]
+			//    0x100C  int3 (x4)
+			//    0x1010  ret
+			name: "bounds check in preceding basic block",
+			code: []byte{
+				0x83, 0xF8, 0x03,
+				0x77, 0x0B,
+				0xFF, 0x24, 0x85, 0x00, 0x20, 0x00, 0x00,
+				0xCC, 0xCC, 0xCC, 0xCC,
+				0xC3,
+			},
+			blocks: []bin.Address{0x1000, 0x1005, 0x1010},
+			jump:   0x1005,
+			want: &JumpTable{
+				Jump:    0x1005,
+				Addr:    0x2000,
+				Targets: []bin.Address{0x1010, 0x1010, 0x1010, 0x1010},
+				Default: 0x1010,
+			},
+		},
+		{
+			//    0x1000  cmp eax, 3
+			//    0x1003  jbe 0x1010
+			//    0x1005  xor eax, eax
+			//    0x1007  ret
+			//    0x1008  int3 (x8)
+			//    0x1010  jmp [eax*4+0x2000]
+			name: "bounds check in predecessor jumping to basic block",
+			code: []byte{
+				0x83, 0xF8, 0x03,
+				0x76, 0x0B,
+				0x31, 0xC0,
+				0xC3,
+				0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC,
+				0xFF, 0x24, 0x85, 0x00, 0x20, 0x00, 0x00,
+			},
+			blocks: []bin.Address{0x1000, 0x1005, 0x1010},
+			jump:   0x1010,
+			want: &JumpTable{
+				Jump:    0x1010,
+				Addr:    0x2000,
+				Targets: []bin.Address{0x1005, 0x1007, 0x1005, 0x1007},
+				Default: 0x1005,
+			},
+		},
+		{
+			//    0x1000  cmp eax, 3
+			//    0x1003  jg  0x1010
+			//    0x1005  jmp [eax*4+0x2000]
+			//    0x100C  int3 (x4)
+			//    0x1010  ret
+			name: "signed bounds check",
+			code: []byte{
+				0x83, 0xF8, 0x03,
+				0x7F, 0x0B,
+				0xFF, 0x24, 0x85, 0x00, 0x20, 0x00, 0x00,
+				0xCC, 0xCC, 0xCC, 0xCC,
+				0xC3,
+			},
+			blocks: []bin.Address{0x1000, 0x1005, 0x1010},
+			jump:   0x1005,
+			err:    "unsupported signed bounds check",
+		},
+	}
+	for _, g := range golden {
+		// Jump table at 0x2000 of 4 entries.
+		table := make([]byte, 16)
+		for i, target := range []uint32{0x1005, 0x1007, 0x1005, 0x1007} {
+			if g.want != nil {
+				target = uint32(g.want.Targets[i])
+			}
+			binary.LittleEndian.PutUint32(table[4*i:], target)
+		}
+		rodata := &bin.Section{
+			Name:     ".rodata",
+			Addr:     0x2000,
+			Data:     table,
+			FileSize: len(table),
+			MemSize:  len(table),
+			Perm:     bin.PermR,
+		}
+		dis := newTestDisasm(t, 0x1000, g.code, rodata)
+		dis.BlockAddrs = g.blocks
+		got, err := dis.JumpTable(g.jump)
+		if len(g.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), g.err) {
+				t.Errorf("%s: error mismatch; expected error containing %q, got %v", g.name, g.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to recover jump table; %+v", g.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: jump table mismatch; expected %+v, got %+v", g.name, g.want, got)
+		}
+		if !reflect.DeepEqual(dis.Tables[g.want.Addr], g.want.Targets) {
+			t.Errorf("%s: targets of jump table at %v mismatch; expected %v, got %v", g.name, g.want.Addr, g.want.Targets, dis.Tables[g.want.Addr])
+		}
+	}
+}
+
+func TestJumpTableSwitch(t *testing.T) {
+	golden := []struct {
+		// Path to input ELF file.
+		in string
+		// Expected jump table.
+		want *JumpTable
+	}{
+		// Absolute jump table; jmp [eax*4+table].
+		{
+			in: "testdata/switch_x86_32.out",
+			want: &JumpTable{
+				Jump:    0x80480BD,
+				Addr:    0x8048140,
+				Targets: []bin.Address{0x80480E8, 0x80480F8, 0x8048108, 0x8048118, 0x80480C8, 0x80480D8},
+				Default: 0x80480A0,
+			},
+		},
+		// Jump table relative to the table address; movsxd rax, [rdx+rdi*4];
+		// add rax, rdx; jmp rax.
+		{
+			in: "testdata/switch_x86_64.out",
+			want: &JumpTable{
+				Jump:    0x259,
+				Addr:    0x2CC,
+				Targets: []bin.Address{0x280, 0x290, 0x2A0, 0x2B0, 0x260, 0x270},
+				Default: 0x230,
+			},
+		},
+	}
+	for _, g := range golden {
+		file, err := elf.ParseFile(g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse ELF file; %+v", g.in, err)
+			continue
+		}
+		dis, err := NewDisasm(file)
+		if err != nil {
+			t.Errorf("%q: unable to create disassembler; %+v", g.in, err)
+			continue
+		}
+		if _, err := dis.Discover(); err != nil {
+			t.Errorf("%q: unable to discover functions; %+v", g.in, err)
+			continue
+		}
+		want := map[bin.Address]*JumpTable{g.want.Jump: g.want}
+		if !reflect.DeepEqual(dis.JumpTables, want) {
+			t.Errorf("%q: jump tables mismatch; expected %+v, got %+v", g.in, want, dis.JumpTables)
+			continue
+		}
+		// Recovered jump tables are stored, including their default targets.
+		dir, err := ioutil.TempDir("", "x86")
+		if err != nil {
+			t.Fatalf("unable to create temporary directory; %v", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := dis.StoreJSON(dir); err != nil {
+			t.Errorf("%q: unable to store JSON files; %+v", g.in, err)
+			continue
+		}
+		var stored map[bin.Address]*JumpTable
+		if err := jsonutil.ParseFile(filepath.Join(dir, "jump_tables.json"), &stored); err != nil {
+			t.Errorf("%q: unable to parse JSON file; %v", g.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(stored, want) {
+			t.Errorf("%q: stored jump tables mismatch; expected %+v, got %+v", g.in, want, stored)
+		}
+		// Recovered jump tables are reused.
+		if got, err := dis.JumpTable(g.want.Jump); err != nil || got != dis.JumpTables[g.want.Jump] {
+			t.Errorf("%q: jump table at %v not reused; got %+v (%v)", g.in, g.want.Jump, got, err)
+		}
+		// Recover jump table from the discovered basic blocks.
+		blocks := dis.BlockAddrs
+		if dis, err = NewDisasm(file); err != nil {
+			t.Errorf("%q: unable to create disassembler; %+v", g.in, err)
+			continue
+		}
+		dis.BlockAddrs = blocks
+		got, err := dis.JumpTable(g.want.Jump)
+		if err != nil {
+			t.Errorf("%q: unable to recover jump table; %+v", g.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%q: jump table mismatch; expected %+v, got %+v", g.in, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Switch statements compiled to jump tables.
+
+int sink;
+
+__attribute__((noinline)) int dense(int x) {
+	switch (x) {
+	case 0:
+		return sink + 11;
+	case 1:
+		return sink * 13;
+	case 2:
+		return sink - 17;
+	case 3:
+		return sink ^ 19;
+	case 4:
+		return sink | 23;
+	case 5:
+		return sink & 29;
+	}
+	return -1;
+}
+
+int entry(void) {
+	return dense(sink);
+}
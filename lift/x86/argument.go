@@ -14,6 +14,7 @@ import (
 	"github.com/llir/llvm/ir/metadata"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
 	"golang.org/x/arch/x86/x86asm"
 )
 
@@ -150,7 +151,7 @@ func (f *Func) reg(reg x86asm.Reg) value.Value {
 // useMem loads and returns the value of the given memory reference, emitting
 // code to f.
 func (f *Func) useMem(mem *x86.Mem) value.Named {
-	src := f.mem(mem)
+	src := f.memRef(mem)
 	v := f.cur.NewLoad(src)
 	if mem.Parent != nil && mem.Parent.MemBytes != 0 {
 		if t, ok := src.Type().(*types.PointerType); ok {
@@ -180,7 +181,7 @@ func (f *Func) useMem(mem *x86.Mem) value.Named {
 // useMemElem loads and returns a value of the specified element type from the
 // given memory reference, emitting code to f.
 func (f *Func) useMemElem(mem *x86.Mem, elem types.Type) value.Value {
-	src := f.mem(mem)
+	src := f.memRef(mem)
 	typ := types.NewPointer(elem)
 	if !typ.Equal(src.Type()) {
 		src = f.cur.NewBitCast(src, typ)
@@ -190,7 +191,7 @@ func (f *Func) useMemElem(mem *x86.Mem, elem types.Type) value.Value {
 
 // defMem stores the value to the given memory reference, emitting code to f.
 func (f *Func) defMem(mem *x86.Mem, v value.Value) {
-	dst := f.mem(mem)
+	dst := f.memRef(mem)
 	// Bitcast pointer to appropriate size.
 	dst = f.castToPtr(dst, mem.Parent)
 	f.cur.NewStore(v, dst)
@@ -199,7 +200,7 @@ func (f *Func) defMem(mem *x86.Mem, v value.Value) {
 // defMemElem stores the value of the specified element type to the given memory
 // reference, emitting code to f.
 func (f *Func) defMemElem(mem *x86.Mem, v value.Value, elem types.Type) {
-	dst := f.mem(mem)
+	dst := f.memRef(mem)
 	typ := types.NewPointer(elem)
 	if !typ.Equal(dst.Type()) {
 		dst = f.cur.NewBitCast(dst, typ)
@@ -207,9 +208,23 @@ func (f *Func) defMemElem(mem *x86.Mem, v value.Value, elem types.Type) {
 	f.cur.NewStore(v, dst)
 }
 
+// memRef returns a pointer to the LLVM IR value associated with the given
+// memory argument, emitting code to f. Errors are recorded in f and reported by
+// Lift, in which case a null pointer is returned to let code emission proceed.
+func (f *Func) memRef(mem *x86.Mem) value.Value {
+	v, err := f.mem(mem)
+	if err != nil {
+		if f.err == nil {
+			f.err = err
+		}
+		return constant.NewNull(types.NewPointer(types.I32))
+	}
+	return v
+}
+
 // mem returns a pointer to the LLVM IR value associated with the given memory
 // argument, emitting code to f.
-func (f *Func) mem(mem *x86.Mem) value.Value {
+func (f *Func) mem(mem *x86.Mem) (value.Value, error) {
 	// Segment:[Base+Scale*Index+Disp].
 	var (
 		segment value.Value
@@ -250,13 +265,16 @@ func (f *Func) mem(mem *x86.Mem) value.Value {
 		case x86asm.ESP, x86asm.EBP:
 			name := fmt.Sprintf("%s_%d", strings.ToLower(x86.Register(mem.Mem.Base).String()), f.espDisp+mem.Disp)
 			if v, ok := f.locals[name]; ok {
-				return v
+				return v, nil
 			}
 			v := ir.NewAlloca(types.I32)
 			v.SetName(name)
+			if localName, ok := f.localNames[name]; ok {
+				v.SetName(localName)
+			}
 			f.locals[name] = v
 			dbg.Printf("local %v of %q: %v\n", name, f.Ident(), v)
-			return v
+			return v, nil
 		}
 	}
 
@@ -265,11 +283,14 @@ func (f *Func) mem(mem *x86.Mem) value.Value {
 		if context, ok := f.l.Contexts[mem.Parent.Addr]; ok {
 			if c, ok := context.Args[mem.OpIndex]; ok {
 				if o, ok := c["Mem.offset"]; ok {
-					offset := o.Int64()
+					offset, err := o.Int64()
+					if err != nil {
+						return nil, errors.Wrapf(err, "invalid memory reference offset of %v instruction at %v", mem.Parent.Op, mem.Parent.Addr)
+					}
 					addr := rel + bin.Address(mem.Disp-offset)
 					v, ok := f.addr(addr)
 					if !ok {
-						panic(fmt.Errorf("unable to locate value at address %v; referenced from %v instruction at %v", addr, mem.Parent.Op, mem.Parent.Addr))
+						return nil, errors.Errorf("unable to locate value at address %v; referenced from %v instruction at %v", addr, mem.Parent.Op, mem.Parent.Addr)
 					}
 					// TODO: Figure out how to handle negative offsets.
 					if offset < 0 {
@@ -315,10 +336,10 @@ func (f *Func) mem(mem *x86.Mem) value.Value {
 			// TODO: don't write to f.l from here as it should be read-only to
 			// allow for concurrent execution.
 			f.l.Globals[addr] = g
-			return g
+			return g, nil
 			panic(fmt.Errorf("unable to locate value at address %v; referenced from %v instruction at %v", addr, mem.Parent.Op, mem.Parent.Addr))
 		}
-		return disp
+		return disp, nil
 	}
 
 	// TODO: Handle Segment.
@@ -362,7 +383,7 @@ func (f *Func) mem(mem *x86.Mem) value.Value {
 	// TODO: Cast into proper type, once type analysis information is available.
 
 	// Force bitcast into pointer type.
-	return f.castToPtr(src, mem.Parent)
+	return f.castToPtr(src, mem.Parent), nil
 }
 
 // castToPtr casts the given value into a pointer, where the element type is
@@ -634,32 +655,36 @@ func (f *Func) global(addr bin.Address) (value.Named, bool) {
 
 // getAddr returns the static address represented by the given argument, and a
 // boolean indicating success.
-func (f *Func) getAddr(arg *x86.Arg) (bin.Address, bool) {
+func (f *Func) getAddr(arg *x86.Arg) (bin.Address, bool, error) {
 	switch a := arg.Arg.(type) {
 	case x86asm.Reg:
 		if context, ok := f.l.Contexts[arg.Parent.Addr]; ok {
 			if c, ok := context.Regs[x86.Register(a)]; ok {
 				if addr, ok := c["addr"]; ok {
-					return addr.Addr(), true
+					v, err := addr.Addr()
+					if err != nil {
+						return 0, false, errors.Wrapf(err, "invalid address of %v register context at %v", a, arg.Parent.Addr)
+					}
+					return v, true, nil
 				}
-				panic(fmt.Errorf("support for register context `%v` not yet implemented", c))
+				return 0, false, errors.Errorf("support for register context `%v` not yet implemented", c)
 			}
 		}
 	case x86asm.Rel:
 		next := arg.Parent.Addr + bin.Address(arg.Parent.Len)
 		addr := next + bin.Address(a)
-		return addr, true
+		return addr, true, nil
 	case x86asm.Mem:
 		if a.Segment == 0 && a.Base == 0 && a.Scale == 0 && a.Index == 0 {
-			return bin.Address(a.Disp), true
+			return bin.Address(a.Disp), true, nil
 		}
 	}
-	return 0, false
+	return 0, false, nil
 }
 
 // getFunc resolves the function, function type, and calling convention of the
 // given argument. The boolean return value indicates success.
-func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.CallingConv, bool) {
+func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.CallingConv, bool, error) {
 	// Check if register symbol context present.
 	switch a := arg.Arg.(type) {
 	case x86asm.Reg:
@@ -669,55 +694,62 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 					fname := symbol.String()
 					fn, ok := f.l.FuncByName[fname]
 					if !ok {
-						panic(fmt.Errorf("unable to locate external function %q", fname))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("unable to locate external function %q", fname)
 					}
-					return fn, fn.Sig, fn.CallingConv, true
+					return fn, fn.Sig, fn.CallingConv, true, nil
 				}
 				// TODO: Remove poor man's type propagation once the type analysis and
 				// data flow analysis phases have been properly implemented.
 				if param, ok := c["param"]; ok {
-					p := param.Int64()
+					p, err := param.Int64()
+					if err != nil {
+						return nil, nil, enum.CallingConvNone, false, errors.Wrapf(err, "invalid function parameter index of instruction at address %v", arg.Parent.Addr)
+					}
 					if p >= int64(len(f.Params)) {
-						panic(fmt.Errorf("invalid function parameter index; expected < %d, got %d", len(f.Params), p))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function parameter index; expected < %d, got %d", len(f.Params), p)
 					}
 					v := f.Params[p]
 					typ := v.Type()
 					ptr, ok := typ.(*types.PointerType)
 					if !ok {
-						panic(fmt.Errorf("invalid function pointer type of function parameter %q referenced from instruction at address %v; expected *types.PointerType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, typ))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function pointer type of function parameter %q referenced from instruction at address %v; expected *types.PointerType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, typ)
 					}
 					sig, ok := ptr.ElemType.(*types.FuncType)
 					if !ok {
-						panic(fmt.Errorf("invalid function type of function parameter %q referenced from instruction at address %v; expected *types.FuncType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, ptr.ElemType))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function type of function parameter %q referenced from instruction at address %v; expected *types.FuncType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, ptr.ElemType)
 					}
 					// TODO: Figure out how to recover calling convention.
 					// Perhaps through context.json at call sites?
-					return v, sig, enum.CallingConvNone, true
+					return v, sig, enum.CallingConvNone, true, nil
 				}
 			}
 		}
 	}
 
-	if addr, ok := f.getAddr(arg); ok {
+	addr, ok, err := f.getAddr(arg)
+	if err != nil {
+		return nil, nil, enum.CallingConvNone, false, errors.WithStack(err)
+	}
+	if ok {
 		if fn, ok := f.l.Funcs[addr]; ok {
 			v := fn.Func
-			return v, v.Sig, v.CallingConv, true
+			return v, v.Sig, v.CallingConv, true, nil
 		}
 		if g, ok := f.l.Globals[addr]; ok {
 			ptr, ok := g.Typ.ElemType.(*types.PointerType)
 			if !ok {
-				panic(fmt.Errorf("invalid function pointer type of global variable at address %v referenced from instruction at address %v; expected *types.PointerType, got %T; ", addr, arg.Parent.Addr, g.Typ.ElemType))
+				return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function pointer type of global variable at address %v referenced from instruction at address %v; expected *types.PointerType, got %T; ", addr, arg.Parent.Addr, g.Typ.ElemType)
 			}
 			sig, ok := ptr.ElemType.(*types.FuncType)
 			if !ok {
-				panic(fmt.Errorf("invalid function type of global variable at address %v referenced from instruction at address %v; expected *types.FuncType, got %T; ", addr, arg.Parent.Addr, ptr.ElemType))
+				return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function type of global variable at address %v referenced from instruction at address %v; expected *types.FuncType, got %T; ", addr, arg.Parent.Addr, ptr.ElemType)
 			}
 			v := f.cur.NewLoad(g)
 			// TODO: Figure out how to recover calling convention.
 			// Perhaps through context.json at call sites?
-			return v, sig, enum.CallingConvNone, true
+			return v, sig, enum.CallingConvNone, true, nil
 		}
-		panic(fmt.Errorf("unable to locate function at address %v referenced from instruction at address %v", addr, arg.Parent.Addr))
+		return nil, nil, enum.CallingConvNone, false, errors.Errorf("unable to locate function at address %v referenced from instruction at address %v", addr, arg.Parent.Addr)
 	}
 
 	// Handle function pointers in structures.
@@ -727,7 +759,7 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 			context, ok := f.l.Contexts[arg.Parent.Addr]
 			if !ok {
 				pretty.Println(arg.Arg)
-				panic(fmt.Errorf("unable to locate context for %v register used at %v", a.Base, arg.Parent.Addr))
+				return nil, nil, enum.CallingConvNone, false, errors.Errorf("unable to locate context for %v register used at %v", a.Base, arg.Parent.Addr)
 			}
 			if c, ok := context.Regs[x86.Register(a.Base)]; ok {
 				if typStr, ok := c["type"]; ok {
@@ -743,16 +775,26 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 						if sig := typ.ElemType.(*types.FuncType); ok {
 							// TODO: Figure out how to recover calling convention.
 							// Perhaps through context.json at call sites?
-							return v, sig, enum.CallingConvNone, true
+							return v, sig, enum.CallingConvNone, true, nil
 						}
 					}
-					panic(fmt.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type()))
+					return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type())
 				}
 				if addr, ok := c["addr"]; ok {
-					v := f.useAddr(addr.Addr())
+					x, err := addr.Addr()
+					if err != nil {
+						return nil, nil, enum.CallingConvNone, false, errors.Wrapf(err, "invalid address of %v register context at %v", a.Base, arg.Parent.Addr)
+					}
+					v := f.useAddr(x)
 					// HACK: Remove once proper type and data flow analysis has been
 					// implemented.
-					if extractvalue, ok := c["extractvalue"]; ok && extractvalue.Bool() {
+					extract := false
+					if extractvalue, ok := c["extractvalue"]; ok {
+						if extract, err = extractvalue.Bool(); err != nil {
+							return nil, nil, enum.CallingConvNone, false, errors.Wrapf(err, "invalid extractvalue of %v register context at %v", a.Base, arg.Parent.Addr)
+						}
+					}
+					if extract {
 						dbg.Println("extractvalue:", v)
 						dbg.Println("extractvalue.Type():", v.Type())
 						// TODO: Handle index based on Index regster if present.
@@ -765,22 +807,26 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 						if sig := typ.ElemType.(*types.FuncType); ok {
 							// TODO: Figure out how to recover calling convention.
 							// Perhaps through context.json at call sites?
-							return v, sig, enum.CallingConvNone, true
+							return v, sig, enum.CallingConvNone, true, nil
 						}
 					}
-					panic(fmt.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type()))
+					return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type())
 				}
 				if min, ok := c["min"]; ok {
-					addr := bin.Address(a.Disp + min.Int64())
+					x, err := min.Int64()
+					if err != nil {
+						return nil, nil, enum.CallingConvNone, false, errors.Wrapf(err, "invalid minimum value of %v register context at %v", a.Base, arg.Parent.Addr)
+					}
+					addr := bin.Address(a.Disp + x)
 					v := f.useAddr(addr)
 					if typ, ok := v.Type().(*types.PointerType); ok {
 						if sig := typ.ElemType.(*types.FuncType); ok {
 							// TODO: Figure out how to recover calling convention.
 							// Perhaps through context.json at call sites?
-							return v, sig, enum.CallingConvNone, true
+							return v, sig, enum.CallingConvNone, true, nil
 						}
 					}
-					panic(fmt.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type()))
+					return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type())
 				}
 			}
 
@@ -788,23 +834,26 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 				// TODO: Remove poor man's type propagation once the type analysis and
 				// data flow analysis phases have been properly implemented.
 				if param, ok := c["param"]; ok {
-					p := param.Int64()
+					p, err := param.Int64()
+					if err != nil {
+						return nil, nil, enum.CallingConvNone, false, errors.Wrapf(err, "invalid function parameter index of instruction at address %v", arg.Parent.Addr)
+					}
 					if p >= int64(len(f.Params)) {
-						panic(fmt.Errorf("invalid function parameter index; expected < %d, got %d", len(f.Params), p))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function parameter index; expected < %d, got %d", len(f.Params), p)
 					}
 					v := f.Params[p]
 					typ := v.Type()
 					ptr, ok := typ.(*types.PointerType)
 					if !ok {
-						panic(fmt.Errorf("invalid function pointer type of function parameter %q referenced from instruction at address %v; expected *types.PointerType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, typ))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function pointer type of function parameter %q referenced from instruction at address %v; expected *types.PointerType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, typ)
 					}
 					sig, ok := ptr.ElemType.(*types.FuncType)
 					if !ok {
-						panic(fmt.Errorf("invalid function type of function parameter %q referenced from instruction at address %v; expected *types.FuncType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, ptr.ElemType))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid function type of function parameter %q referenced from instruction at address %v; expected *types.FuncType, got %T; ", f.Params[p].Ident(), arg.Parent.Addr, ptr.ElemType)
 					}
 					// TODO: Figure out how to recover calling convention.
 					// Perhaps through context.json at call sites?
-					return v, sig, enum.CallingConvNone, true
+					return v, sig, enum.CallingConvNone, true, nil
 				}
 			}
 
@@ -813,23 +862,27 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 			context, ok := f.l.Contexts[arg.Parent.Addr]
 			if !ok {
 				pretty.Println(arg.Arg)
-				panic(fmt.Errorf("unable to locate context for %v register used at %v", a.Index, arg.Parent.Addr))
+				return nil, nil, enum.CallingConvNone, false, errors.Errorf("unable to locate context for %v register used at %v", a.Index, arg.Parent.Addr)
 			}
 			if c, ok := context.Regs[x86.Register(a.Index)]; ok {
 				if min, ok := c["min"]; ok {
-					addr := bin.Address(a.Disp + int64(a.Scale)*min.Int64())
+					x, err := min.Int64()
+					if err != nil {
+						return nil, nil, enum.CallingConvNone, false, errors.Wrapf(err, "invalid minimum value of %v register context at %v", a.Index, arg.Parent.Addr)
+					}
+					addr := bin.Address(a.Disp + int64(a.Scale)*x)
 					v := f.useAddr(addr)
 					if typ, ok := v.Type().(*types.PointerType); ok {
 						if sig := typ.ElemType.(*types.FuncType); ok {
 							// TODO: Figure out how to recover calling convention.
 							// Perhaps through context.json at call sites?
-							return v, sig, enum.CallingConvNone, true
+							return v, sig, enum.CallingConvNone, true, nil
 						}
 					}
 					// HACK: Use gep as a fallback for 0 element offsets.
 					fallback, ok := f.addr(addr)
 					if !ok {
-						panic(fmt.Sprintf("unable to locate variable associated with address %v", addr))
+						return nil, nil, enum.CallingConvNone, false, errors.Errorf("unable to locate variable associated with address %v", addr)
 					}
 					fallback = f.getElementPtr(fallback, 0)
 					v = f.cur.NewLoad(fallback)
@@ -837,10 +890,10 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 						if sig := typ.ElemType.(*types.FuncType); ok {
 							// TODO: Figure out how to recover calling convention.
 							// Perhaps through context.json at call sites?
-							return v, sig, enum.CallingConvNone, true
+							return v, sig, enum.CallingConvNone, true, nil
 						}
 					}
-					panic(fmt.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type()))
+					return nil, nil, enum.CallingConvNone, false, errors.Errorf("invalid callee type; expected pointer to function type, got %v", v.Type())
 				}
 			}
 		}
@@ -856,7 +909,7 @@ func (f *Func) getFunc(arg *x86.Arg) (value.Named, *types.FuncType, enum.Calling
 		addr := bin.Address(a.Disp)
 		dbg.Println("   addr:", addr)
 	}
-	panic("not yet implemented")
+	return nil, nil, enum.CallingConvNone, false, nil
 }
 
 // redefEDX_EAX redefines the 64-bit pseudo-register EDX:EAX based on the value
@@ -0,0 +1,51 @@
+package x86
+
+import (
+	"testing"
+
+	"github.com/decomp/exp/disasm/x86"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+func TestMalformedContext(t *testing.T) {
+	// Register and memory reference contexts of the instruction at 0x1000, the
+	// values of which are not valid addresses or integers.
+	var v x86.Value
+	if err := v.Set("foo"); err != nil {
+		t.Fatalf("unable to set context value; %+v", err)
+	}
+	inst := &x86.Inst{Addr: 0x1000}
+	contexts := x86.Contexts{
+		0x1000: {
+			Regs: map[x86.Register]x86.ValueContext{
+				x86.Register(x86asm.EAX): {"addr": v},
+			},
+			Args: map[int]x86.ValueContext{
+				0: {"Mem.offset": v},
+			},
+		},
+	}
+	f := &Func{l: &Lifter{Disasm: &x86.Disasm{Contexts: contexts}}}
+
+	// Errors of register contexts are returned.
+	arg := x86.NewArg(x86asm.EAX, inst)
+	if _, _, err := f.getAddr(arg); err == nil {
+		t.Errorf("getAddr: expected error for invalid address of register context, got nil")
+	}
+	if _, _, _, _, err := f.getFunc(arg); err == nil {
+		t.Errorf("getFunc: expected error for invalid address of register context, got nil")
+	}
+
+	// Errors of memory reference contexts are recorded in f, and reported by
+	// Lift.
+	mem := x86.NewMem(x86asm.Mem{Disp: 0x10}, inst)
+	if _, err := f.mem(mem); err == nil {
+		t.Errorf("mem: expected error for invalid memory reference offset, got nil")
+	}
+	if v := f.memRef(mem); v == nil {
+		t.Errorf("memRef: expected placeholder value, got nil")
+	}
+	if f.err == nil {
+		t.Errorf("memRef: expected recorded error for invalid memory reference offset, got nil")
+	}
+}
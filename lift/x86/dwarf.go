@@ -0,0 +1,839 @@
+package x86
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/decomp/exp/bin"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// ImportDWARF imports the DWARF debug information of the given binary
+// executable, and returns the corresponding LLVM IR module; in the same form as
+// info.ll. The module contains type definitions of named types, global
+// variables with types and initializers, and function signatures with parameter
+// names and types. Global variables and functions are annotated with "addr"
+// metadata, and functions with "locals" metadata naming their stack slots.
+func ImportDWARF(file *bin.File) (*ir.Module, error) {
+	if file.DWARF == nil {
+		return nil, errors.New("unable to locate DWARF debug information")
+	}
+	imp := &dwarfImporter{
+		file:   file,
+		d:      file.DWARF,
+		module: &ir.Module{},
+		types:  make(map[dwarf.Offset]types.Type),
+		defs:   make(map[string]types.Type),
+		names:  make(map[string]bool),
+	}
+	if err := imp.importEntries(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return imp.module, nil
+}
+
+// dwarfImporter tracks information required to import DWARF debug information.
+type dwarfImporter struct {
+	// Binary executable.
+	file *bin.File
+	// DWARF debug information.
+	d *dwarf.Data
+	// LLVM IR module containing the imported information.
+	module *ir.Module
+	// Map from DWARF type offset to LLVM IR type.
+	types map[dwarf.Offset]types.Type
+	// Map from type definition name to LLVM IR type.
+	defs map[string]types.Type
+	// Set of global variable and function names in use.
+	names map[string]bool
+}
+
+// importEntries imports the global variables, functions and named types of each
+// compilation unit.
+func (imp *dwarfImporter) importEntries() error {
+	r := imp.d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit, dwarf.TagNamespace:
+			// Import children of compilation units and namespaces.
+			continue
+		case dwarf.TagSubprogram:
+			if err := imp.importFunc(r, e); err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		case dwarf.TagVariable:
+			if isDeclaration(e) {
+				// Skip declarations (e.g. extern int x;); the variable is
+				// imported from its definition.
+				break
+			}
+			if err := imp.importGlobal(e); err != nil {
+				return errors.WithStack(err)
+			}
+		case dwarf.TagTypedef, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagClassType, dwarf.TagEnumerationType:
+			if _, ok := e.Val(dwarf.AttrName).(string); ok {
+				if _, err := imp.typeOf(e.Offset); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+	}
+	return nil
+}
+
+// importFunc imports the function signature and local variables of the given
+// subprogram entry, and skips its children.
+func (imp *dwarfImporter) importFunc(r *dwarf.Reader, e *dwarf.Entry) error {
+	// Parse parameters and local variables.
+	var (
+		params   []*ir.Param
+		variadic bool
+		locals   []*dwarf.Entry
+	)
+	if e.Children {
+		for depth := 1; depth > 0; {
+			child, err := r.Next()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if child == nil {
+				break
+			}
+			if child.Tag == 0 {
+				depth--
+				continue
+			}
+			switch {
+			case depth == 1 && child.Tag == dwarf.TagFormalParameter:
+				typ, err := imp.entryType(child)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				name, _ := imp.entryName(child)
+				params = append(params, ir.NewParam(name, typ))
+			case depth == 1 && child.Tag == dwarf.TagUnspecifiedParameters:
+				variadic = true
+			case child.Tag == dwarf.TagVariable:
+				// Local variables of the function and its lexical blocks.
+				locals = append(locals, child)
+			}
+			if child.Children {
+				depth++
+			}
+		}
+	}
+	// Skip declarations, abstract instances of inline functions, functions
+	// without associated virtual addresses and functions discarded by the
+	// linker (e.g. --gc-sections), the address of which is resolved to 0.
+	if isDeclaration(e) {
+		return nil
+	}
+	entry, ok := e.Val(dwarf.AttrLowpc).(uint64)
+	if !ok || entry == 0 {
+		return nil
+	}
+	addr := bin.Address(entry) + imp.file.DWARFDelta
+	name, ok := imp.entryName(e)
+	if !ok {
+		name = fmt.Sprintf("f_%06X", uint64(addr))
+	}
+	retType, err := imp.entryType(e)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var paramTypes []types.Type
+	for _, param := range params {
+		paramTypes = append(paramTypes, param.Typ)
+	}
+	sig := types.NewFunc(retType, paramTypes...)
+	sig.Variadic = variadic
+	f := &ir.Func{
+		Typ:    types.NewPointer(sig),
+		Sig:    sig,
+		Params: params,
+	}
+	f.SetName(imp.uniqueName(name, addr))
+	f.Metadata = append(f.Metadata, addrMetadata(addr))
+	if md, ok := imp.localsMetadata(e, locals); ok {
+		f.Metadata = append(f.Metadata, md)
+	}
+	imp.module.Funcs = append(imp.module.Funcs, f)
+	return nil
+}
+
+// DWARF location expression operations.
+const (
+	// Address; followed by a target address.
+	opAddr = 0x03
+	// Register-relative address; opBreg0 + DWARF register number, followed by
+	// a signed LEB128 offset.
+	opBreg0 = 0x70
+	// Frame base-relative address; followed by a signed LEB128 offset.
+	opFbreg = 0x91
+	// Canonical frame address (CFA).
+	opCallFrameCFA = 0x9C
+)
+
+// localsMetadata returns a "locals" metadata attachment of the local variables
+// of the given subprogram entry, mapping stack slots to variable names. The
+// boolean return value indicates whether any local variable is located at a
+// stack slot.
+//
+// Stack slots are named by the frame pointer and displacement used by the
+// lifter for stack local memory accesses (e.g. "ebp_-4"), assuming a standard
+// function prologue (push ebp; mov ebp, esp).
+//
+//	!locals !{!{!"ebp_-4", !"sum"}}
+func (imp *dwarfImporter) localsMetadata(e *dwarf.Entry, locals []*dwarf.Entry) (*metadata.Attachment, bool) {
+	frameBase, ok := imp.frameBase(e)
+	if !ok {
+		return nil, false
+	}
+	fp := "ebp"
+	if imp.file.Arch.BitSize() == 64 {
+		fp = "rbp"
+	}
+	md := &metadata.Tuple{}
+	slots := make(map[string]bool)
+	for _, local := range locals {
+		loc, ok := local.Val(dwarf.AttrLocation).([]byte)
+		if !ok || len(loc) < 2 || loc[0] != opFbreg {
+			// Skip local variables located in registers, location lists or
+			// static storage.
+			continue
+		}
+		offset, n := sleb128(loc[1:])
+		if n != len(loc)-1 {
+			continue
+		}
+		name, ok := imp.entryName(local)
+		if !ok {
+			continue
+		}
+		slot := fmt.Sprintf("%s_%d", fp, frameBase+offset)
+		if slots[slot] {
+			// Keep the first variable of stack slots shared by lexical blocks.
+			continue
+		}
+		slots[slot] = true
+		md.Fields = append(md.Fields, &metadata.Tuple{
+			Fields: []metadata.Field{
+				&metadata.String{Value: slot},
+				&metadata.String{Value: name},
+			},
+		})
+	}
+	if len(md.Fields) == 0 {
+		return nil, false
+	}
+	return &metadata.Attachment{Name: "locals", Node: md}, true
+}
+
+// frameBase returns the displacement of the frame base of the given subprogram
+// entry from the frame pointer (EBP or RBP). The boolean return value indicates
+// success.
+func (imp *dwarfImporter) frameBase(e *dwarf.Entry) (int64, bool) {
+	loc, ok := e.Val(dwarf.AttrFrameBase).([]byte)
+	if !ok || len(loc) == 0 {
+		return 0, false
+	}
+	ptrSize := int64(imp.file.Arch.BitSize() / 8)
+	// DWARF register number of the frame pointer.
+	fpReg := byte(5) // EBP
+	if ptrSize == 8 {
+		fpReg = 6 // RBP
+	}
+	switch {
+	case len(loc) == 1 && loc[0] == opCallFrameCFA:
+		// The CFA is located above the return address and the saved frame
+		// pointer.
+		return 2 * ptrSize, true
+	case loc[0] == opBreg0+fpReg:
+		offset, n := sleb128(loc[1:])
+		if n != len(loc)-1 {
+			return 0, false
+		}
+		return offset, true
+	}
+	return 0, false
+}
+
+// sleb128 decodes the signed LEB128 encoded value at the start of buf, and
+// returns the value and the number of bytes read; or 0 bytes read if invalid.
+func sleb128(buf []byte) (int64, int) {
+	var (
+		v     int64
+		shift uint
+	)
+	for i, b := range buf {
+		if shift >= 64 {
+			return 0, 0
+		}
+		v |= int64(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				// Sign extend.
+				v |= -1 << shift
+			}
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// isDeclaration reports whether the given entry is a declaration (i.e. has the
+// DW_AT_declaration attribute).
+func isDeclaration(e *dwarf.Entry) bool {
+	decl, _ := e.Val(dwarf.AttrDeclaration).(bool)
+	return decl
+}
+
+// importGlobal imports the global variable of the given variable entry. Local
+// variables and variables without static storage are skipped.
+func (imp *dwarfImporter) importGlobal(e *dwarf.Entry) error {
+	loc, ok := e.Val(dwarf.AttrLocation).([]byte)
+	if !ok {
+		return nil
+	}
+	// Global variables are located by a single DW_OP_addr operation.
+	ptrSize := imp.file.Arch.BitSize() / 8
+	if len(loc) != 1+ptrSize || loc[0] != opAddr {
+		return nil
+	}
+	var addr bin.Address
+	switch ptrSize {
+	case 4:
+		addr = bin.Address(imp.file.ByteOrder().Uint32(loc[1:]))
+	case 8:
+		addr = bin.Address(imp.file.ByteOrder().Uint64(loc[1:]))
+	default:
+		return errors.Errorf("support for pointer size %d not yet implemented", ptrSize)
+	}
+	addr += imp.file.DWARFDelta
+	name, ok := imp.entryName(e)
+	if !ok {
+		name = fmt.Sprintf("g_%06X", uint64(addr))
+	}
+	contentType, err := imp.entryType(e)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if types.Equal(contentType, types.Void) {
+		contentType = types.I8
+	}
+	g := &ir.Global{
+		Typ:         types.NewPointer(contentType),
+		ContentType: contentType,
+		Init:        imp.initializer(contentType, addr),
+	}
+	g.SetName(imp.uniqueName(name, addr))
+	g.Metadata = append(g.Metadata, addrMetadata(addr))
+	imp.module.Globals = append(imp.module.Globals, g)
+	return nil
+}
+
+// initializer returns the initializer of the global variable of the given
+// content type at the specified address, as read from the binary executable.
+// Uninitialized data (e.g. .bss) and content types without a known in-memory
+// representation (e.g. x86_fp80) are zero-initialized.
+func (imp *dwarfImporter) initializer(t types.Type, addr bin.Address) constant.Constant {
+	zero := constant.NewZeroInitializer(t)
+	size, ok := imp.sizeOf(t)
+	if !ok || size == 0 {
+		return zero
+	}
+	buf := make([]byte, size)
+	if err := imp.file.ReadAt(buf, addr); err != nil {
+		warn.Printf("unable to read initializer of global variable at address %v; %v", addr, err)
+		return zero
+	}
+	if bytes.Count(buf, []byte{0}) == len(buf) {
+		return zero
+	}
+	c, ok := imp.constantOf(t, buf)
+	if !ok {
+		return zero
+	}
+	return c
+}
+
+// sizeOf returns the size in bytes of the in-memory representation of the
+// given type. The boolean return value indicates success.
+func (imp *dwarfImporter) sizeOf(t types.Type) (uint64, bool) {
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize%8 != 0 {
+			return 0, false
+		}
+		return t.BitSize / 8, true
+	case *types.FloatType:
+		switch {
+		case types.Equal(t, types.Float):
+			return 4, true
+		case types.Equal(t, types.Double):
+			return 8, true
+		}
+		return 0, false
+	case *types.PointerType:
+		return uint64(imp.file.Arch.BitSize() / 8), true
+	case *types.ArrayType:
+		elemSize, ok := imp.sizeOf(t.ElemType)
+		return t.Len * elemSize, ok
+	case *types.StructType:
+		// Structures imported from DWARF are packed; the size is the sum of the
+		// field sizes.
+		if t.Opaque || !t.Packed {
+			return 0, false
+		}
+		var size uint64
+		for _, field := range t.Fields {
+			fieldSize, ok := imp.sizeOf(field)
+			if !ok {
+				return 0, false
+			}
+			size += fieldSize
+		}
+		return size, true
+	default:
+		return 0, false
+	}
+}
+
+// constantOf returns the constant of the given type represented by the given
+// in-memory data. The boolean return value indicates success.
+//
+// pre-condition: len(buf) is the size of t, as returned by sizeOf.
+func (imp *dwarfImporter) constantOf(t types.Type, buf []byte) (constant.Constant, bool) {
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize > 64 {
+			return nil, false
+		}
+		v := imp.uintOf(buf)
+		// Sign extend.
+		shift := 64 - uint(t.BitSize)
+		return constant.NewInt(t, int64(v<<shift)>>shift), true
+	case *types.FloatType:
+		v := imp.uintOf(buf)
+		if len(buf) == 4 {
+			return constant.NewFloat(t, float64(math.Float32frombits(uint32(v)))), true
+		}
+		return constant.NewFloat(t, math.Float64frombits(v)), true
+	case *types.PointerType:
+		v := imp.uintOf(buf)
+		if v == 0 {
+			return constant.NewNull(t), true
+		}
+		intType := types.I32
+		if len(buf) == 8 {
+			intType = types.I64
+		}
+		return constant.NewIntToPtr(constant.NewInt(intType, int64(v)), t), true
+	case *types.ArrayType:
+		if types.Equal(t.ElemType, types.I8) {
+			c := constant.NewCharArray(buf)
+			c.Typ = t
+			return c, true
+		}
+		arr := &constant.Array{Typ: t}
+		elemSize := uint64(len(buf)) / t.Len
+		for i := uint64(0); i < t.Len; i++ {
+			elem, ok := imp.constantOf(t.ElemType, buf[i*elemSize:(i+1)*elemSize])
+			if !ok {
+				return nil, false
+			}
+			arr.Elems = append(arr.Elems, elem)
+		}
+		return arr, true
+	case *types.StructType:
+		st := &constant.Struct{Typ: t}
+		for _, fieldType := range t.Fields {
+			size, _ := imp.sizeOf(fieldType)
+			field, ok := imp.constantOf(fieldType, buf[:size])
+			if !ok {
+				return nil, false
+			}
+			st.Fields = append(st.Fields, field)
+			buf = buf[size:]
+		}
+		return st, true
+	default:
+		return nil, false
+	}
+}
+
+// uintOf returns the unsigned integer represented by the given in-memory data,
+// in the byte order of the binary executable.
+//
+// pre-condition: len(buf) <= 8
+func (imp *dwarfImporter) uintOf(buf []byte) uint64 {
+	var v uint64
+	if imp.file.ByteOrder() == binary.LittleEndian {
+		for i := len(buf) - 1; i >= 0; i-- {
+			v = v<<8 | uint64(buf[i])
+		}
+		return v
+	}
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// entryName returns the name of the given entry, as specified directly or
+// through the declaration (DW_AT_specification) or abstract instance
+// (DW_AT_abstract_origin) of the entry. The boolean return value indicates
+// success.
+func (imp *dwarfImporter) entryName(e *dwarf.Entry) (string, bool) {
+	// Guard against cyclic references.
+	for i := 0; i < 8; i++ {
+		if name, ok := e.Val(dwarf.AttrName).(string); ok {
+			return name, true
+		}
+		off, ok := e.Val(dwarf.AttrSpecification).(dwarf.Offset)
+		if !ok {
+			off, ok = e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		}
+		if !ok {
+			return "", false
+		}
+		r := imp.d.Reader()
+		r.Seek(off)
+		next, err := r.Next()
+		if err != nil || next == nil {
+			return "", false
+		}
+		e = next
+	}
+	return "", false
+}
+
+// entryType returns the LLVM IR type of the DW_AT_type attribute of the given
+// entry; or void if not present.
+func (imp *dwarfImporter) entryType(e *dwarf.Entry) (types.Type, error) {
+	off, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+	if !ok {
+		return types.Void, nil
+	}
+	return imp.typeOf(off)
+}
+
+// typeOf returns the LLVM IR type of the DWARF type at the given offset.
+func (imp *dwarfImporter) typeOf(off dwarf.Offset) (types.Type, error) {
+	if t, ok := imp.types[off]; ok {
+		return t, nil
+	}
+	dt, err := imp.d.Type(off)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return imp.convert(dt), nil
+}
+
+// convert returns the LLVM IR type corresponding to the given DWARF type.
+// Named types are recorded as type definitions.
+func (imp *dwarfImporter) convert(dt dwarf.Type) types.Type {
+	off := dt.Common().Offset
+	if t, ok := imp.types[off]; ok {
+		return t
+	}
+	t := imp.newType(dt)
+	imp.types[off] = t
+	return t
+}
+
+// newType returns a new LLVM IR type corresponding to the given DWARF type.
+func (imp *dwarfImporter) newType(dt dwarf.Type) types.Type {
+	switch dt := dt.(type) {
+	case *dwarf.VoidType:
+		return types.Void
+	case *dwarf.QualType:
+		return imp.convert(dt.Type)
+	case *dwarf.TypedefType:
+		return imp.newTypedef(dt)
+	case *dwarf.StructType:
+		return imp.newStruct(dt, structName(dt))
+	case *dwarf.EnumType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.IntType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.UintType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.CharType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.UcharType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.BoolType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.AddrType:
+		return imp.newInt(dt.ByteSize)
+	case *dwarf.FloatType:
+		return newFloat(dt.ByteSize)
+	case *dwarf.ComplexType:
+		elem := newFloat(dt.ByteSize / 2)
+		return types.NewStruct(elem, elem)
+	case *dwarf.PtrType:
+		elem := imp.convert(dt.Type)
+		if types.Equal(elem, types.Void) {
+			// void pointers are represented as i8 pointers.
+			elem = types.I8
+		}
+		return types.NewPointer(elem)
+	case *dwarf.ArrayType:
+		elem := imp.convert(dt.Type)
+		n := dt.Count
+		if n < 0 {
+			// Flexible array member.
+			n = 0
+		}
+		return types.NewArray(uint64(n), elem)
+	case *dwarf.FuncType:
+		retType := imp.convert(dt.ReturnType)
+		var params []types.Type
+		variadic := false
+		for _, param := range dt.ParamType {
+			if _, ok := param.(*dwarf.DotDotDotType); ok {
+				variadic = true
+				continue
+			}
+			params = append(params, imp.convert(param))
+		}
+		sig := types.NewFunc(retType, params...)
+		sig.Variadic = variadic
+		return sig
+	default:
+		// Unsupported types (e.g. C++ rvalue references and pointers to
+		// members) are represented as byte arrays of the same size.
+		size := dt.Size()
+		if size < 0 {
+			size = 0
+		}
+		return types.NewArray(uint64(size), types.I8)
+	}
+}
+
+// newInt returns a new integer type of the given size in bytes.
+func (imp *dwarfImporter) newInt(size int64) types.Type {
+	switch size {
+	case 1:
+		return types.I8
+	case 2:
+		return types.I16
+	case 4:
+		return types.I32
+	case 8:
+		return types.I64
+	case 16:
+		return types.I128
+	}
+	if size <= 0 {
+		// Unknown size; e.g. enums without DW_AT_byte_size.
+		return types.I32
+	}
+	return types.NewInt(uint64(size) * 8)
+}
+
+// newFloat returns a new floating-point type of the given size in bytes.
+func newFloat(size int64) types.Type {
+	switch size {
+	case 2:
+		return types.Half
+	case 4:
+		return types.Float
+	case 8:
+		return types.Double
+	default:
+		// long double; 80-bit extended precision padded to 12 or 16 bytes on
+		// x86.
+		return types.X86_FP80
+	}
+}
+
+// newTypedef returns a new type definition of the given DWARF typedef.
+//
+// Typedefs of structures are resolved to the structure type, naming anonymous
+// structures by the typedef name. Typedefs of other types are recorded as type
+// definitions of a copy of the underlying type.
+func (imp *dwarfImporter) newTypedef(dt *dwarf.TypedefType) types.Type {
+	underlying := dt.Type
+	for {
+		q, ok := underlying.(*dwarf.QualType)
+		if !ok {
+			break
+		}
+		underlying = q.Type
+	}
+	if st, ok := underlying.(*dwarf.StructType); ok {
+		if len(st.StructName) == 0 {
+			// Name anonymous structure by typedef name.
+			if t, ok := imp.types[st.Offset]; ok {
+				return t
+			}
+			t := imp.newStruct(st, dt.Name)
+			imp.types[st.Offset] = t
+			return t
+		}
+		return imp.convert(st)
+	}
+	t := imp.convert(dt.Type)
+	// The typedef may have been converted while converting the underlying type
+	// (e.g. typedef struct s *sp; struct s { sp next; }).
+	if t, ok := imp.types[dt.Offset]; ok {
+		return t
+	}
+	if prev, ok := imp.defs[dt.Name]; ok {
+		// Type definition already present (e.g. from other compilation unit).
+		return prev
+	}
+	def := copyType(t)
+	if def == nil {
+		return t
+	}
+	def.SetName(dt.Name)
+	imp.defs[dt.Name] = def
+	imp.module.TypeDefs = append(imp.module.TypeDefs, def)
+	return def
+}
+
+// newStruct returns a new structure type of the given DWARF structure, union or
+// class type.
+//
+// Structures are represented as packed LLVM IR structures, with explicit
+// padding between fields to preserve the field offsets of the DWARF type. Bit
+// fields and unions are represented as byte arrays.
+func (imp *dwarfImporter) newStruct(dt *dwarf.StructType, name string) types.Type {
+	var t *types.StructType
+	if prev, ok := imp.defs[name]; ok && len(name) > 0 {
+		// Type definition already present (e.g. from other compilation unit).
+		// Opaque structures (i.e. forward declarations) are completed by the
+		// structure definition.
+		st, ok := prev.(*types.StructType)
+		if !ok || !st.Opaque || dt.Incomplete {
+			return prev
+		}
+		t = st
+		t.Opaque = false
+	} else {
+		t = &types.StructType{Packed: true}
+		if len(name) > 0 {
+			t.SetName(name)
+			imp.defs[name] = t
+			imp.module.TypeDefs = append(imp.module.TypeDefs, t)
+		}
+	}
+	// Record placeholder before converting fields, to handle self-referential
+	// structures.
+	imp.types[dt.Offset] = t
+	if dt.Incomplete {
+		t.Opaque = true
+		return t
+	}
+	if dt.Kind == "union" {
+		if dt.ByteSize > 0 {
+			t.Fields = append(t.Fields, types.NewArray(uint64(dt.ByteSize), types.I8))
+		}
+		return t
+	}
+	var offset int64
+	for _, field := range dt.Field {
+		if field.ByteOffset < offset {
+			// Overlapping field; e.g. bit field sharing storage unit.
+			continue
+		}
+		if field.BitSize != 0 {
+			// Bit fields are represented as padding.
+			continue
+		}
+		if field.ByteOffset > offset {
+			t.Fields = append(t.Fields, types.NewArray(uint64(field.ByteOffset-offset), types.I8))
+			offset = field.ByteOffset
+		}
+		size := field.Type.Size()
+		if size < 0 {
+			// Unknown size; e.g. flexible array member.
+			size = 0
+		}
+		t.Fields = append(t.Fields, imp.convert(field.Type))
+		offset += size
+	}
+	if dt.ByteSize > offset {
+		t.Fields = append(t.Fields, types.NewArray(uint64(dt.ByteSize-offset), types.I8))
+	}
+	return t
+}
+
+// structName returns the LLVM IR type name of the given DWARF structure, union
+// or class type (e.g. "struct.foo"); or the empty string if anonymous.
+func structName(dt *dwarf.StructType) string {
+	if len(dt.StructName) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s", dt.Kind, dt.StructName)
+}
+
+// copyType returns a shallow copy of the given type; or nil if the type cannot
+// be copied to form a type definition.
+func copyType(t types.Type) types.Type {
+	switch t := t.(type) {
+	case *types.IntType:
+		u := *t
+		return &u
+	case *types.FloatType:
+		u := *t
+		return &u
+	case *types.PointerType:
+		u := *t
+		return &u
+	case *types.ArrayType:
+		u := *t
+		return &u
+	case *types.FuncType:
+		u := *t
+		return &u
+	case *types.VectorType:
+		u := *t
+		return &u
+	default:
+		return nil
+	}
+}
+
+// uniqueName returns a unique global identifier name based on the given name,
+// disambiguating duplicate names (e.g. static functions of different
+// compilation units) by address.
+func (imp *dwarfImporter) uniqueName(name string, addr bin.Address) string {
+	if imp.names[name] {
+		name = fmt.Sprintf("%s_%06X", name, uint64(addr))
+	}
+	imp.names[name] = true
+	return name
+}
+
+// addrMetadata returns an "addr" metadata attachment of the given address.
+func addrMetadata(addr bin.Address) *metadata.Attachment {
+	return &metadata.Attachment{
+		Name: "addr",
+		Node: &metadata.Tuple{
+			Fields: []metadata.Field{&metadata.String{Value: addr.String()}},
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package x86
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/decomp/exp/bin"
+	"github.com/decomp/exp/bin/elf"
+)
+
+func TestImportDWARF(t *testing.T) {
+	// ELF executable with DWARF debug information, as built from dwarf.c and
+	// dwarf_ext.c by the Makefile of the testdata directory.
+	file, err := elf.ParseFile("testdata/x86_32/dwarf/dwarf.out")
+	if err != nil {
+		t.Fatalf("unable to parse ELF file; %+v", err)
+	}
+	module, err := ImportDWARF(file)
+	if err != nil {
+		t.Fatalf("unable to import DWARF debug information; %+v", err)
+	}
+
+	// Global variables; the declaration of extern_var is skipped in favour of
+	// its definition.
+	goldenGlobals := []struct {
+		// Global variable name.
+		name string
+		// Global variable address.
+		addr bin.Address
+		// Initializer, as read from the data of the executable.
+		init string
+	}{
+		{name: "counter", addr: 0x080490EC, init: "42"},
+		{name: "greeting", addr: 0x080490F0, init: `c"hi\00"`},
+		{name: "origin", addr: 0x080490F4, init: "<{ i32 1, i32 2 }>"},
+		{name: "cursor", addr: 0x080490FC, init: "inttoptr (i32 134516980 to %struct.point*)"},
+		{name: "zeroed", addr: 0x08049104, init: "zeroinitializer"},
+		{name: "extern_var", addr: 0x08049100, init: "7"},
+	}
+	if len(module.Globals) != len(goldenGlobals) {
+		t.Errorf("number of global variables mismatch; expected %d, got %d", len(goldenGlobals), len(module.Globals))
+	}
+	for i, g := range goldenGlobals {
+		if i >= len(module.Globals) {
+			break
+		}
+		got := module.Globals[i]
+		if got.Name() != g.name {
+			t.Errorf("global variable %d name mismatch; expected %q, got %q", i, g.name, got.Name())
+			continue
+		}
+		if addr, ok := metadataAddr(got.Metadata); !ok || addr != g.addr {
+			t.Errorf("%q: address mismatch; expected %v, got %v", g.name, g.addr, addr)
+		}
+		if init := got.Init.Ident(); init != g.init {
+			t.Errorf("%q: initializer mismatch; expected %q, got %q", g.name, g.init, init)
+		}
+	}
+
+	// Functions; the unused function discarded by the linker is skipped.
+	goldenFuncs := []struct {
+		// Function name.
+		name string
+		// Function address.
+		addr bin.Address
+		// Function signature.
+		sig string
+		// Local variables; map from stack slot to variable name.
+		locals map[string]string
+	}{
+		{name: "entry", addr: 0x080480AA, sig: "i32 ()", locals: map[string]string{"ebp_-4": "local"}},
+		{name: "add", addr: 0x08048094, sig: "i32 (i32, i32)", locals: map[string]string{"ebp_-4": "sum"}},
+	}
+	if len(module.Funcs) != len(goldenFuncs) {
+		t.Errorf("number of functions mismatch; expected %d, got %d", len(goldenFuncs), len(module.Funcs))
+	}
+	for i, g := range goldenFuncs {
+		if i >= len(module.Funcs) {
+			break
+		}
+		got := module.Funcs[i]
+		if got.Name() != g.name {
+			t.Errorf("function %d name mismatch; expected %q, got %q", i, g.name, got.Name())
+			continue
+		}
+		if addr, ok := metadataAddr(got.Metadata); !ok || addr != g.addr {
+			t.Errorf("%q: address mismatch; expected %v, got %v", g.name, g.addr, addr)
+		}
+		if sig := got.Sig.String(); sig != g.sig {
+			t.Errorf("%q: signature mismatch; expected %q, got %q", g.name, g.sig, sig)
+		}
+		if locals := parseLocalNames(got.Metadata); !reflect.DeepEqual(locals, g.locals) {
+			t.Errorf("%q: local variables mismatch; expected %v, got %v", g.name, g.locals, locals)
+		}
+	}
+}
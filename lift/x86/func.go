@@ -11,6 +11,7 @@ import (
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/metadata"
 	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
 	"golang.org/x/arch/x86/x86asm"
 )
 
@@ -32,6 +33,9 @@ type Func struct {
 	fstatusFlags map[FStatusFlag]*ir.InstAlloca
 	// Local varialbes used within the function.
 	locals map[string]*ir.InstAlloca
+	// Names of local variables, as specified by "locals" metadata; map from
+	// stack slot (e.g. "ebp_-4") to variable name.
+	localNames map[string]string
 	// usesEDX_EAX specifies whether any instruction of the function uses
 	// EDX:EAX.
 	usesEDX_EAX bool
@@ -47,6 +51,10 @@ type Func struct {
 	// FPU register stack top; integer value in range [0, 7].
 	st *ir.InstAlloca
 
+	// First error encountered while emitting code for memory references;
+	// reported by Lift.
+	err error
+
 	// Read-only global lifter state.
 	l *Lifter
 }
@@ -83,6 +91,7 @@ func (l *Lifter) NewFunc(asmFunc *x86.Func) *Func {
 	f.statusFlags = make(map[StatusFlag]*ir.InstAlloca)
 	f.fstatusFlags = make(map[FStatusFlag]*ir.InstAlloca)
 	f.locals = make(map[string]*ir.InstAlloca)
+	f.localNames = parseLocalNames(f.Metadata)
 	f.l = l
 	// Prepare output LLVM IR basic blocks.
 	for addr := range asmFunc.Blocks {
@@ -129,7 +138,7 @@ func (l *Lifter) NewFunc(asmFunc *x86.Func) *Func {
 }
 
 // Lift lifts the function from input assembly to LLVM IR.
-func (f *Func) Lift() {
+func (f *Func) Lift() error {
 	dbg.Printf("lifting function %q at %v", f.Ident(), f.AsmFunc.Addr)
 	// Allocate a local variable for the FPU stack top used within the function.
 	if f.usesFPU {
@@ -143,11 +152,13 @@ func (f *Func) Lift() {
 	}
 	sort.Sort(blockAddrs)
 	if len(blockAddrs) == 0 {
-		panic(fmt.Errorf("invalid function definition at %v; missing function body", f.AsmFunc.Addr))
+		return errors.Errorf("invalid function definition at %v; missing function body", f.AsmFunc.Addr)
 	}
 	for _, blockAddr := range blockAddrs {
 		bb := f.AsmFunc.Blocks[blockAddr]
-		f.liftBlock(bb)
+		if err := f.liftBlock(bb); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	// Add new entry basic block to define registers, status flags, and local
 	// variables (allocated on the stack) used within the function.
@@ -249,15 +260,27 @@ func (f *Func) Lift() {
 		entry.NewBr(target)
 		f.Blocks = append([]*ir.Block{entry}, f.Blocks...)
 	}
+	return errors.WithStack(f.err)
 }
 
 // liftBlock lifts the basic block from input assembly to LLVM IR.
-func (f *Func) liftBlock(bb *x86.BasicBlock) {
+func (f *Func) liftBlock(bb *x86.BasicBlock) error {
 	dbg.Printf("lifting basic block at %v", bb.Addr)
 	f.cur = f.blocks[bb.Addr]
 	f.Blocks = append(f.Blocks, f.cur)
 	for _, inst := range bb.Insts {
-		f.liftInst(inst)
+		if err := f.liftInst(inst); err != nil {
+			return errors.Wrapf(err, "unable to lift instruction at %v", inst.Addr)
+		}
+		if f.err != nil {
+			return errors.Wrapf(f.err, "unable to lift instruction at %v", inst.Addr)
+		}
+	}
+	if err := f.liftTerm(bb.Term); err != nil {
+		return errors.Wrapf(err, "unable to lift terminator at %v", bb.Term.Addr)
+	}
+	if f.err != nil {
+		return errors.Wrapf(f.err, "unable to lift terminator at %v", bb.Term.Addr)
 	}
-	f.liftTerm(bb.Term)
+	return nil
 }
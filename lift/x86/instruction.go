@@ -1599,9 +1599,12 @@ func (f *Func) liftInstBTS(inst *x86.Inst) error {
 // to f.
 func (f *Func) liftInstCALL(inst *x86.Inst) error {
 	// Locate callee information.
-	callee, sig, callconv, ok := f.getFunc(inst.Arg(0))
+	callee, sig, callconv, ok, err := f.getFunc(inst.Arg(0))
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	if !ok {
-		panic(fmt.Errorf("unable to locate function for argument %v of instruction at address %v", inst.Arg(0), inst.Addr))
+		return errors.Errorf("unable to locate function for argument %v of instruction at address %v", inst.Arg(0), inst.Addr)
 	}
 
 	// Handle function arguments.
@@ -2860,7 +2863,14 @@ func (f *Func) liftInstLDS(inst *x86.Inst) error {
 // liftInstLEA lifts the given x86 LEA instruction to LLVM IR, emitting code to
 // f.
 func (f *Func) liftInstLEA(inst *x86.Inst) error {
-	y := f.mem(inst.Mem(1))
+	mem, err := inst.Mem(1)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	y, err := f.mem(mem)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	f.defArg(inst.Arg(0), y)
 	return nil
 }
@@ -5947,7 +5957,10 @@ func (f *Func) liftInstXGETBV(inst *x86.Inst) error {
 // to f.
 func (f *Func) liftInstXLATB(inst *x86.Inst) error {
 	// Set AL to memory byte DS:[(E)BX + unsigned AL].
-	mem := inst.Mem(0)
+	mem, err := inst.Mem(0)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	if mem.Mem.Index != 0 {
 		panic(fmt.Errorf("invalid index of XLAT memory reference; expected 0, got %v", mem.Mem.Index))
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/decomp/exp/disasm/x86"
 	"github.com/llir/llvm/asm"
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/metadata"
 	"github.com/llir/llvm/ir/types"
 	"github.com/mewkiz/pkg/osutil"
@@ -67,6 +68,10 @@ type Lifter struct {
 // Associated files of the x86 to LLVM IR lifter.
 //
 //    info.ll
+//
+// DWARF debug information of the binary executable, if present, is imported as
+// type definitions, global variables, function signatures and names of local
+// variables. Information specified by info.ll takes precedence.
 func NewLifter(file *bin.File) (*Lifter, error) {
 	// Prepare x86 to LLVM IR lifter.
 	dis, err := x86.NewDisasm(file)
@@ -87,6 +92,16 @@ func NewLifter(file *bin.File) (*Lifter, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	// Import DWARF debug information.
+	if file.DWARF != nil {
+		dwarfModule, err := ImportDWARF(file)
+		if err != nil {
+			warn.Printf("unable to import DWARF debug information; %v", err)
+		} else {
+			mergeModule(module, dwarfModule)
+		}
+	}
+
 	// Parse types.
 	l.TypeDefs = module.TypeDefs
 
@@ -103,6 +118,20 @@ func NewLifter(file *bin.File) (*Lifter, error) {
 		l.Globals[addr] = g
 	}
 
+	// Add global variables of data object symbols. Thread-local symbols are
+	// skipped, as their addresses are offsets into the thread-local storage
+	// template rather than virtual addresses.
+	for _, sym := range file.Symbols {
+		if sym.Kind != bin.SymbolObject {
+			continue
+		}
+		if _, ok := l.Globals[sym.Addr]; ok {
+			// Skip symbol if already specified through global variable.
+			continue
+		}
+		l.Globals[sym.Addr] = newSymbolGlobal(sym)
+	}
+
 	// Parse function signatures.
 	for _, f := range module.Funcs {
 		l.FuncByName[f.Name()] = f
@@ -168,6 +197,101 @@ func NewLifter(file *bin.File) (*Lifter, error) {
 
 // ### [ Helper functions ] ####################################################
 
+// newSymbolGlobal returns a new global variable for the given data object
+// symbol, with content type based on the size of the symbol.
+func newSymbolGlobal(sym *bin.Symbol) *ir.Global {
+	var contentType types.Type
+	switch sym.Size {
+	case 0:
+		// TODO: Remove once the lift library matures a bit.
+		warn.Printf("unknown size of global variable %q at address %v; guessing i32", sym.Name, sym.Addr)
+		contentType = types.I32
+	case 1:
+		contentType = types.I8
+	case 2:
+		contentType = types.I16
+	case 4:
+		contentType = types.I32
+	case 8:
+		contentType = types.I64
+	default:
+		contentType = types.NewArray(sym.Size, types.I8)
+	}
+	typ := types.NewPointer(contentType)
+	g := &ir.Global{
+		Typ:         typ,
+		ContentType: contentType,
+		Init:        constant.NewZeroInitializer(contentType),
+	}
+	g.SetName(sym.Name)
+	md := &metadata.Attachment{
+		Name: "addr",
+		Node: &metadata.Tuple{
+			Fields: []metadata.Field{&metadata.String{Value: sym.Addr.String()}},
+		},
+	}
+	g.Metadata = append(g.Metadata, md)
+	return g
+}
+
+// mergeModule merges the type definitions, global variables and functions of
+// src into dst. Information of dst takes precedence; type definitions of src
+// are skipped if already defined in dst, and global variables and functions of
+// src are skipped if their name or address is already present in dst.
+func mergeModule(dst, src *ir.Module) {
+	typeNames := make(map[string]bool)
+	for _, def := range dst.TypeDefs {
+		typeNames[def.Name()] = true
+	}
+	for _, def := range src.TypeDefs {
+		if !typeNames[def.Name()] {
+			dst.TypeDefs = append(dst.TypeDefs, def)
+		}
+	}
+	names := make(map[string]bool)
+	addrs := make(map[bin.Address]bool)
+	for _, g := range dst.Globals {
+		names[g.Name()] = true
+		if addr, ok := metadataAddr(g.Metadata); ok {
+			addrs[addr] = true
+		}
+	}
+	for _, f := range dst.Funcs {
+		names[f.Name()] = true
+		if addr, ok := metadataAddr(f.Metadata); ok {
+			addrs[addr] = true
+		}
+	}
+	present := func(name string, mds []*metadata.Attachment) bool {
+		addr, ok := metadataAddr(mds)
+		return names[name] || (ok && addrs[addr])
+	}
+	for _, g := range src.Globals {
+		if !present(g.Name(), g.Metadata) {
+			dst.Globals = append(dst.Globals, g)
+		}
+	}
+	for _, f := range src.Funcs {
+		if !present(f.Name(), f.Metadata) {
+			dst.Funcs = append(dst.Funcs, f)
+		}
+	}
+}
+
+// metadataAddr returns the address of the "addr" metadata attachment of the
+// given metadata attachments. The boolean return value indicates success.
+func metadataAddr(mds []*metadata.Attachment) (bin.Address, bool) {
+	node, ok := findMetadataAttachment(mds, "addr")
+	if !ok {
+		return 0, false
+	}
+	addr, err := parseMetadataAddr(node)
+	if err != nil {
+		return 0, false
+	}
+	return addr, true
+}
+
 // parseModule parses and returns the given LLVM IR module.
 func parseModule(llPath string) (*ir.Module, error) {
 	if !osutil.Exists(llPath) {
@@ -188,6 +312,39 @@ func findMetadataAttachment(mds []*metadata.Attachment, name string) (metadata.M
 	return nil, false
 }
 
+// parseLocalNames returns the names of local variables specified by the
+// "locals" metadata attachment of the given metadata attachments; a map from
+// stack slot to variable name. Malformed entries are skipped.
+//
+//	!locals !{!{!"ebp_-4", !"sum"}}
+func parseLocalNames(mds []*metadata.Attachment) map[string]string {
+	names := make(map[string]string)
+	node, ok := findMetadataAttachment(mds, "locals")
+	if !ok {
+		return names
+	}
+	tuple, ok := node.(*metadata.Tuple)
+	if !ok {
+		warn.Printf(`invalid "locals" metadata node type; expected *metadata.Tuple, got %T`, node)
+		return names
+	}
+	for _, field := range tuple.Fields {
+		local, ok := field.(*metadata.Tuple)
+		if !ok || len(local.Fields) != 2 {
+			warn.Printf(`invalid "locals" metadata entry %v; expected !{!"slot", !"name"}`, field)
+			continue
+		}
+		slot, ok1 := local.Fields[0].(*metadata.String)
+		name, ok2 := local.Fields[1].(*metadata.String)
+		if !ok1 || !ok2 {
+			warn.Printf(`invalid "locals" metadata entry %v; expected !{!"slot", !"name"}`, field)
+			continue
+		}
+		names[slot.Value] = name.Value
+	}
+	return names
+}
+
 // parseMetadataAddr returns the address corresponding to the given "addr"
 // metadata node.
 func parseMetadataAddr(node metadata.MDNode) (bin.Address, error) {
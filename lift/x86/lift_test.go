@@ -8,9 +8,10 @@ import (
 	"testing"
 
 	"github.com/decomp/exp/bin"
-	_ "github.com/decomp/exp/bin/elf" // register ELF decoder
-	_ "github.com/decomp/exp/bin/pe"  // register PE decoder
-	_ "github.com/decomp/exp/bin/pef" // register PEF decoder
+	_ "github.com/decomp/exp/bin/coff" // register COFF decoder
+	_ "github.com/decomp/exp/bin/elf"  // register ELF decoder
+	_ "github.com/decomp/exp/bin/pe"   // register PE decoder
+	_ "github.com/decomp/exp/bin/pef"  // register PEF decoder
 	"github.com/decomp/exp/bin/raw"
 	"github.com/llir/llvm/ir"
 	"github.com/mewkiz/pkg/diffutil"
@@ -43,8 +44,8 @@ func TestLift(t *testing.T) {
 		{dir: "testdata/x86_64/format", in: "format_elf.o", out: "format_o.ll"},
 		{dir: "testdata/x86_64/format", in: "format_elf.so", out: "format_so.ll"},
 		{dir: "testdata/x86_64/format", in: "format_elf.out", out: "format_out.ll"},
-		// TODO: Add support for COFF files.
-		//{in: "testdata/format.coff", out: "testdata/format_coff.ll"},
+		{dir: "testdata/x86_32/format", in: "format.coff", out: "format_coff.ll"},
+		{dir: "testdata/x86_64/format", in: "format.coff", out: "format_coff.ll"},
 
 		// Arithmetic instructions.
 		{dir: "testdata/x86_32/arithmetic", in: "arithmetic.so", out: "arithmetic.ll"},
@@ -146,7 +147,10 @@ func TestLift(t *testing.T) {
 			if !ok {
 				continue
 			}
-			f.Lift()
+			if err := f.Lift(); err != nil {
+				t.Errorf("%q: unable to lift function at %v; %+v", in, funcAddr, err)
+				continue
+			}
 			module.Funcs = append(module.Funcs, f.Func)
 		}
 		buf, err := ioutil.ReadFile(g.out)
@@ -284,7 +284,10 @@ func (f *Func) liftTermJE(term *x86.Inst) error {
 func (f *Func) liftTermJcc(arg *x86.Arg, cond value.Value) error {
 	// Target branch of conditional jump.
 	nextAddr := arg.Parent.Addr + bin.Address(arg.Parent.Len)
-	targetAddr, ok := f.getAddr(arg)
+	targetAddr, ok, err := f.getAddr(arg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	if !ok {
 		return errors.Errorf("unable to locate address for terminator argument %v", arg)
 	}
@@ -109,7 +109,11 @@ func (f *Func) liftTerm(term *x86.Inst) error {
 // f.
 func (f *Func) liftTermJMP(term *x86.Inst) error {
 	// Handle tail calls.
-	if f.isTailCall(term) {
+	tailCall, err := f.isTailCall(term)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if tailCall {
 		// Hack: interpret the JMP instruction as a CALL instruction. This works
 		// since emitInstCALL only interprets inst.Args[0], which is the same in
 		// both JMP and CALL instructions.
@@ -129,7 +133,11 @@ func (f *Func) liftTermJMP(term *x86.Inst) error {
 
 	// Handle static jump.
 	arg := term.Arg(0)
-	if targetAddr, ok := f.getAddr(arg); ok {
+	targetAddr, ok, err := f.getAddr(arg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if ok {
 		target, ok := f.blocks[targetAddr]
 		if !ok {
 			return errors.Errorf("unable to locate target basic block at %v", targetAddr)
@@ -139,7 +147,10 @@ func (f *Func) liftTermJMP(term *x86.Inst) error {
 	}
 	// Handle jump tables.
 	if _, ok := arg.Arg.(x86asm.Mem); ok {
-		mem := term.Mem(0)
+		mem, err := term.Mem(0)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 		if targetAddrs, ok := f.l.Tables[bin.Address(mem.Disp)]; ok {
 			// TODO: Implement proper support for jump table translation. The
 			// current implementation makes a range of assumptions, which do not
@@ -205,18 +216,22 @@ func (f *Func) liftTermRET(term *x86.Inst) error {
 // === [ Helper functions ] ====================================================
 
 // isTailCall reports whether the given instruction is a tail call instruction.
-func (f *Func) isTailCall(inst *x86.Inst) bool {
+func (f *Func) isTailCall(inst *x86.Inst) (bool, error) {
 	arg := inst.Arg(0)
-	if target, ok := f.getAddr(arg); ok {
+	target, ok, err := f.getAddr(arg)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if ok {
 		if f.contains(target) {
-			return false
+			return false, nil
 		}
 		if !f.l.IsFunc(target) {
 			dbg.Println("arg:", arg)
 			pretty.Println(arg)
 			panic(fmt.Errorf("tail call to non-function address %v", target))
 		}
-		return true
+		return true, nil
 	}
 	// Target read from jump table (e.g. switch statement).
 	if mem, ok := arg.Arg.(x86asm.Mem); ok {
@@ -229,10 +244,10 @@ func (f *Func) isTailCall(inst *x86.Inst) bool {
 						pretty.Println(arg)
 						panic(fmt.Errorf("tail call to non-function address %v", target))
 					}
-					return true
+					return true, nil
 				}
 			}
-			return false
+			return false, nil
 		}
 	}
 
@@ -246,10 +261,13 @@ func (f *Func) isTailCall(inst *x86.Inst) bool {
 	cur := f.cur
 	dummy := &ir.Block{}
 	f.cur = dummy
-	_, _, _, ok := f.getFunc(arg)
+	_, _, _, ok, err = f.getFunc(arg)
 	f.cur = cur
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
 	if ok {
-		return true
+		return true, nil
 	}
 
 	dbg.Println("arg:", arg)
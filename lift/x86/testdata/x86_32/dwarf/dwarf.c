@@ -0,0 +1,26 @@
+struct point {
+	int x;
+	int y;
+};
+
+int counter = 42;
+char greeting[] = "hi";
+struct point origin = {1, 2};
+struct point *cursor = &origin;
+int zeroed;
+
+extern int extern_var;
+
+int unused(int v) {
+	return v * 2;
+}
+
+static int add(int a, int b) {
+	int sum = a + b;
+	return sum;
+}
+
+int entry(void) {
+	int local = add(counter, cursor->y);
+	return local + zeroed + extern_var + greeting[0];
+}
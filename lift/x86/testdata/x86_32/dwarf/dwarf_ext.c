@@ -0,0 +1 @@
+int extern_var = 7;
@@ -0,0 +1,4 @@
+define void @_start() !addr !{!"0x0"} {
+block_000000:
+	ret void
+}